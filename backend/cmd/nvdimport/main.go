@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"cyber-risk-monitor/internal/config"
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/risk"
+	"cyber-risk-monitor/internal/vuln"
+)
+
+// nvdimport loads offline NVD JSON feeds into the local CVE tables and
+// re-matches and re-scores existing scan results against them.
+//
+//	go run ./cmd/nvdimport nvdcve-2.0-2024.json.gz nvdcve-2.0-modified.json.gz
+func main() {
+	rematch := flag.Bool("rematch", true, "re-match stored scan results after importing")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatalf("Usage: nvdimport [-rematch=false] FEED.json[.gz]...")
+	}
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Connect to database
+	database, err := db.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	// Make sure the CVE tables exist
+	if err := database.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	vulns := vuln.NewDatabase(database)
+
+	for _, path := range flag.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open feed: %v", err)
+		}
+
+		stats, err := vulns.Import(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Failed to import %s: %v", path, err)
+		}
+		log.Printf("Imported %s: %d CVEs, %d vulnerable products", path, stats.CVEs, stats.Matches)
+	}
+
+	if *rematch {
+		matched, err := vulns.RematchAll()
+		if err != nil {
+			log.Fatalf("Failed to re-match scan results: %v", err)
+		}
+		log.Printf("Re-matched stored scan results: %d vulnerabilities found", matched)
+
+		// Newly matched CVEs change the risk of the affected results
+		rules := risk.NewRuleLoader(cfg.RiskRulesPath, 0)
+		if _, err := rules.Reload(); err != nil {
+			log.Fatalf("Failed to load risk rules: %v", err)
+		}
+		engine := risk.DefaultEngine().AddFactor(risk.RuleFactor{Source: rules})
+
+		scored, err := risk.NewService(database, engine).RescoreAll()
+		if err != nil {
+			log.Fatalf("Failed to re-score assets: %v", err)
+		}
+		log.Printf("Re-scored %d assets", scored)
+	}
+}
@@ -1,22 +1,39 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/gorilla/websocket"
+	"github.com/vektah/gqlparser/v2/ast"
 
 	"cyber-risk-monitor/internal/auth"
 	"cyber-risk-monitor/internal/config"
 	"cyber-risk-monitor/internal/db"
 	"cyber-risk-monitor/internal/graph"
 	"cyber-risk-monitor/internal/graph/generated"
+	"cyber-risk-monitor/internal/loader"
+	"cyber-risk-monitor/internal/scanner"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish after a shutdown signal
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load configuration
 	cfg := config.Load()
@@ -28,6 +45,14 @@ func main() {
 	}
 	defer database.Close()
 
+	// Manage migrations without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(database, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Run database migrations
 	if err := database.RunMigrations(); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
@@ -36,10 +61,61 @@ func main() {
 	// Create GraphQL resolver
 	resolver := graph.NewResolver(database, cfg)
 
-	// Create GraphQL server
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{
+	// Stop the server and the background workers on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the scan queue workers, recovering scans orphaned by stopped servers
+	if err := resolver.ScanManager.Start(ctx, scanner.QueueConfig{
+		Workers:       cfg.ScanWorkers,
+		PerUserLimit:  cfg.ScanPerUserLimit,
+		PollInterval:  time.Duration(cfg.ScanPollIntervalMS) * time.Millisecond,
+		MaxAttempts:   cfg.ScanMaxAttempts,
+		LeaseDuration: time.Duration(cfg.ScanLeaseSeconds) * time.Second,
+	}); err != nil {
+		log.Fatalf("Failed to start scan queue: %v", err)
+	}
+
+	// Load the risk rules files and watch them for changes
+	resolver.Rules.Start(ctx)
+
+	// Re-open findings once the exceptions that accepted them end
+	resolver.FindingTracker.Start(ctx, time.Duration(cfg.ExceptionCheckIntervalSeconds)*time.Second)
+
+	// Start the scheduler that queues recurring scans
+	resolver.Scheduler.Start(ctx)
+
+	// Origins the frontend is served from
+	allowedOrigins := []string{"http://localhost:3000", "http://localhost:5173"}
+
+	// Create GraphQL server with the default transports, authenticating
+	// subscriptions from their websocket init payload and checking roles
+	// with the @hasRole directive
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{
 		Resolvers: resolver,
+		Directives: generated.DirectiveRoot{
+			HasRole: resolver.HasRole,
+		},
 	}))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              auth.WebsocketInit(cfg.JWTSecret),
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || slices.Contains(allowedOrigins, origin)
+			},
+		},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New[string](100),
+	})
 
 	// Setup router
 	router := chi.NewRouter()
@@ -48,7 +124,7 @@ func main() {
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:5173"},
+		AllowedOrigins:   allowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
@@ -59,6 +135,9 @@ func main() {
 	// Add JWT middleware for protected routes
 	router.Use(auth.JWTMiddleware(cfg.JWTSecret))
 
+	// Batch the nested reads of each request through its own DataLoaders
+	router.Use(loader.Middleware(resolver.Store))
+
 	// GraphQL routes
 	router.Handle("/", playground.Handler("GraphQL playground", "/query"))
 	router.Handle("/query", srv)
@@ -74,7 +153,24 @@ func main() {
 	log.Printf("🚀 Server ready at http://localhost%s", port)
 	log.Printf("📊 GraphQL Playground at http://localhost%s/", port)
 
-	if err := http.ListenAndServe(port, router); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	server := &http.Server{Addr: port, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down")
+
+	// Let in-flight requests finish, then wait for the scan workers to requeue
+	// the scans they were running before the database is closed
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the server: %v", err)
 	}
+	resolver.ScanManager.Wait()
+	log.Printf("Server stopped")
 }
@@ -10,13 +10,19 @@ import (
 type Claims struct {
 	UserID int    `json:"user_id"`
 	Email  string `json:"email"`
+	// OrgID is the organization the user is working in and Role their role
+	// in it when the token was issued
+	OrgID int    `json:"org_id"`
+	Role  string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, email, jwtSecret string) (string, error) {
+func GenerateToken(userID int, email string, orgID int, role, jwtSecret string) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
+		OrgID:  orgID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 )
@@ -10,6 +11,12 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
+// Errors returned for Authorization values that don't authenticate a user
+var (
+	ErrInvalidAuthorization = errors.New("invalid authorization header format")
+	ErrInvalidToken         = errors.New("invalid token")
+)
+
 func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,13 +26,11 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if tokenString == authHeader {
+			claims, err := ParseAuthorization(authHeader, jwtSecret)
+			if errors.Is(err, ErrInvalidAuthorization) {
 				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
 				return
 			}
-
-			claims, err := ValidateToken(tokenString, jwtSecret)
 			if err != nil {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
@@ -37,6 +42,20 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	}
 }
 
+// ParseAuthorization validates the bearer token of an Authorization header value
+func ParseAuthorization(authorization, jwtSecret string) (*Claims, error) {
+	tokenString := strings.TrimPrefix(authorization, "Bearer ")
+	if tokenString == authorization {
+		return nil, ErrInvalidAuthorization
+	}
+
+	claims, err := ValidateToken(tokenString, jwtSecret)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
 func GetUserFromContext(ctx context.Context) (*Claims, bool) {
 	user, ok := ctx.Value(UserContextKey).(*Claims)
 	return user, ok
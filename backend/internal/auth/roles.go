@@ -0,0 +1,27 @@
+package auth
+
+import "slices"
+
+// Roles a user can have in an organization. Admins manage its members and
+// can do everything the other roles can; analysts manage assets and run
+// scans; auditors have read-only access plus exports; viewers have read-only
+// access.
+const (
+	RoleAdmin   = "admin"
+	RoleAnalyst = "analyst"
+	RoleAuditor = "auditor"
+	RoleViewer  = "viewer"
+)
+
+// Roles lists every role
+var Roles = []string{RoleAdmin, RoleAnalyst, RoleAuditor, RoleViewer}
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// HasRole reports whether the user has one of roles. Admins have every role.
+func (c *Claims) HasRole(roles ...string) bool {
+	return c.Role == RoleAdmin || slices.Contains(roles, c.Role)
+}
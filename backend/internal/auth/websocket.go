@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// WebsocketInit authenticates GraphQL websocket connections from the
+// Authorization field of their init payload, since browsers can't send headers
+// when opening a websocket. Like JWTMiddleware, it leaves connections without
+// one unauthenticated and rejects invalid tokens.
+func WebsocketInit(jwtSecret string) transport.WebsocketInitFunc {
+	return func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		authorization := initPayload.Authorization()
+		if authorization == "" {
+			return ctx, nil, nil
+		}
+
+		claims, err := ParseAuthorization(authorization, jwtSecret)
+		if err != nil {
+			return ctx, nil, err
+		}
+
+		return context.WithValue(ctx, UserContextKey, claims), nil, nil
+	}
+}
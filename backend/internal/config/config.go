@@ -10,6 +10,29 @@ type Config struct {
 	JWTSecret   string
 	Port        string
 	Environment string
+
+	// Native TCP connect scanner settings
+	TCPScanPorts       string
+	TCPScanConcurrency int
+	TCPScanTimeoutMS   int
+	TCPBannerTimeoutMS int
+
+	// Scan queue settings
+	ScanWorkers        int
+	ScanPerUserLimit   int
+	ScanPollIntervalMS int
+	ScanMaxAttempts    int
+	ScanLeaseSeconds   int
+
+	// Scheduled scan settings
+	SchedulerIntervalSeconds int
+
+	// Risk rules settings
+	RiskRulesPath          string
+	RiskRulesReloadSeconds int
+
+	// How often findings accepted by ended risk exceptions are re-opened
+	ExceptionCheckIntervalSeconds int
 }
 
 func Load() *Config {
@@ -18,6 +41,24 @@ func Load() *Config {
 		JWTSecret:   getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("ENVIRONMENT", "development"),
+
+		TCPScanPorts:       getEnv("TCP_SCAN_PORTS", "1-1000"),
+		TCPScanConcurrency: getEnvAsInt("TCP_SCAN_CONCURRENCY", 100),
+		TCPScanTimeoutMS:   getEnvAsInt("TCP_SCAN_TIMEOUT_MS", 1000),
+		TCPBannerTimeoutMS: getEnvAsInt("TCP_BANNER_TIMEOUT_MS", 2000),
+
+		ScanWorkers:        getEnvAsInt("SCAN_WORKERS", 4),
+		ScanPerUserLimit:   getEnvAsInt("SCAN_PER_USER_LIMIT", 2),
+		ScanPollIntervalMS: getEnvAsInt("SCAN_POLL_INTERVAL_MS", 2000),
+		ScanMaxAttempts:    getEnvAsInt("SCAN_MAX_ATTEMPTS", 3),
+		ScanLeaseSeconds:   getEnvAsInt("SCAN_LEASE_SECONDS", 60),
+
+		SchedulerIntervalSeconds: getEnvAsInt("SCHEDULER_INTERVAL_SECONDS", 30),
+
+		RiskRulesPath:          getEnv("RISK_RULES_PATH", "rules"),
+		RiskRulesReloadSeconds: getEnvAsInt("RISK_RULES_RELOAD_SECONDS", 10),
+
+		ExceptionCheckIntervalSeconds: getEnvAsInt("EXCEPTION_CHECK_INTERVAL_SECONDS", 60),
 	}
 }
 
@@ -4,15 +4,31 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
 
+// Dialect identifies the database engine behind a connection
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
 type DB struct {
 	*sql.DB
+	Dialect Dialect
 }
 
+// NewConnection connects to the database in databaseURL: a SQLite file for
+// sqlite:// URLs (sqlite:///var/lib/crm.db), otherwise a PostgreSQL server
 func NewConnection(databaseURL string) (*DB, error) {
+	if path, ok := strings.CutPrefix(databaseURL, "sqlite://"); ok {
+		return newSQLiteConnection(path)
+	}
+
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -23,27 +39,29 @@ func NewConnection(databaseURL string) (*DB, error) {
 	}
 
 	log.Println("Successfully connected to PostgreSQL database")
-	return &DB{db}, nil
+	return &DB{DB: db, Dialect: Postgres}, nil
+}
+
+// MillisBetween returns a SQL expression for the whole milliseconds from the
+// start timestamp expression to the end one
+func (db *DB) MillisBetween(start, end string) string {
+	if db.Dialect == SQLite {
+		return fmt.Sprintf("CAST(ROUND((julianday(%s) - julianday(%s)) * 86400000) AS INTEGER)", end, start)
+	}
+	return fmt.Sprintf("(EXTRACT(EPOCH FROM (%s - %s)) * 1000)::BIGINT", end, start)
 }
 
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
 
+// RunMigrations applies any pending migrations
 func (db *DB) RunMigrations() error {
-	migrations := []string{
-		createUsersTable,
-		createAssetsTable,
-		createScansTable,
-		createScanResultsTable,
-	}
-
-	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return fmt.Errorf("failed to run migration: %w", err)
-		}
+	applied, err := db.MigrateUp()
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Println("Database migrations completed successfully")
+	log.Printf("Database migrations completed successfully (%d applied)", applied)
 	return nil
 }
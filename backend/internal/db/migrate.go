@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Migration is a versioned schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the migration's up SQL so edits to applied migrations are detected
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum differs from the migration's current SQL
+	Modified bool
+}
+
+// migrationLockID is the advisory lock held while migrating so concurrent
+// servers don't apply the same migration twice
+const migrationLockID = 7263501
+
+const createSchemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+);`
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// migrations returns the migrations for the database's dialect
+func (db *DB) migrations() []Migration {
+	if db.Dialect == SQLite {
+		return sqliteMigrations
+	}
+	return migrations
+}
+
+// withMigrationLock runs fn on a single connection holding the migration lock.
+// SQLite has no advisory locks, and a SQLite file is only used by one server.
+func (db *DB) withMigrationLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if db.Dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, createSchemaMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// loadApplied reads the applied migrations keyed by version
+func loadApplied(conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var m appliedMigration
+		if err := rows.Scan(&version, &m.name, &m.checksum, &m.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		applied[version] = m
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigration executes a migration's SQL and records it in one transaction
+func runMigration(conn *sql.Conn, statements string, record string, args ...any) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// MigrateUp applies every pending migration in version order, returning how many were applied.
+// It refuses to run if an applied migration's SQL has changed since it was applied.
+func (db *DB) MigrateUp() (int, error) {
+	count := 0
+	err := db.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range db.migrations() {
+			if a, ok := applied[m.Version]; ok {
+				if a.checksum != m.Checksum() {
+					return fmt.Errorf("migration %d (%s) has changed since it was applied", m.Version, m.Name)
+				}
+				continue
+			}
+
+			err := runMigration(conn, m.Up,
+				`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`,
+				m.Version, m.Name, m.Checksum(), time.Now())
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// MigrateDown reverts the most recently applied migrations, newest first,
+// returning how many were reverted
+func (db *DB) MigrateDown(steps int) (int, error) {
+	count := 0
+	err := db.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+
+		migrations := db.migrations()
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+
+			err := runMigration(conn, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			log.Printf("Reverted migration %d (%s)", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// MigrationStatus lists every known migration and whether it has been applied
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := db.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range db.migrations() {
+			status := MigrationStatus{Version: m.Version, Name: m.Name}
+			if a, ok := applied[m.Version]; ok {
+				appliedAt := a.appliedAt
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+				status.Modified = a.checksum != m.Checksum()
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
@@ -42,3 +42,421 @@ CREATE TABLE IF NOT EXISTS scan_results (
     version VARCHAR(255),
     banner TEXT
 );`
+
+const addAssetScanEngineColumn = `
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS scan_engine VARCHAR(50) DEFAULT 'nmap';`
+
+const addScanEngineColumn = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS engine VARCHAR(50) DEFAULT 'nmap';`
+
+const createScanProfilesTable = `
+CREATE TABLE IF NOT EXISTS scan_profiles (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    port_spec VARCHAR(255) NOT NULL,
+    timing_template INTEGER NOT NULL DEFAULT 3,
+    version_intensity INTEGER,
+    scan_type VARCHAR(20) NOT NULL DEFAULT 'syn',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);`
+
+const seedScanProfiles = `
+INSERT INTO scan_profiles (name, description, port_spec, timing_template, version_intensity, scan_type)
+SELECT v.name, v.description, v.port_spec, v.timing_template, v.version_intensity, v.scan_type
+FROM (VALUES
+    ('quick top-100', 'SYN scan of the 100 most common TCP ports', 'top:100', 4, NULL::INTEGER, 'syn'),
+    ('full TCP 1-65535', 'SYN scan of every TCP port with version detection', '1-65535', 4, 7, 'syn'),
+    ('service-only', 'Thorough version detection on common service ports', '21,22,23,25,53,80,110,143,443,445,3306,3389,5432,8080', 3, 9, 'syn'),
+    ('UDP top-50', 'UDP scan of the 50 most common UDP ports', 'top:50', 4, 0, 'udp')
+) AS v(name, description, port_spec, timing_template, version_intensity, scan_type)
+WHERE NOT EXISTS (
+    SELECT 1 FROM scan_profiles p WHERE p.user_id IS NULL AND p.name = v.name
+);`
+
+const addAssetScanProfileColumn = `
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS scan_profile_id INTEGER REFERENCES scan_profiles(id) ON DELETE SET NULL;`
+
+const addScanProfileColumn = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS profile_id INTEGER REFERENCES scan_profiles(id) ON DELETE SET NULL;`
+
+const addScanResultHostColumns = `
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS host VARCHAR(255);
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS hostname VARCHAR(255);`
+
+const addScanQueueColumns = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_scans_status ON scans(status);`
+
+const createAssetGroupsTable = `
+CREATE TABLE IF NOT EXISTS asset_groups (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES asset_groups(id) ON DELETE SET NULL;`
+
+const createScanSchedulesTable = `
+CREATE TABLE IF NOT EXISTS scan_schedules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    group_id INTEGER REFERENCES asset_groups(id) ON DELETE CASCADE,
+    cron VARCHAR(100) NOT NULL,
+    engine VARCHAR(50),
+    profile_id INTEGER REFERENCES scan_profiles(id) ON DELETE SET NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMP,
+    next_run_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    CHECK ((asset_id IS NULL) <> (group_id IS NULL))
+);
+CREATE INDEX IF NOT EXISTS idx_scan_schedules_next_run ON scan_schedules(next_run_at) WHERE enabled;`
+
+const createScanDiffsTables = `
+CREATE TABLE IF NOT EXISTS scan_diffs (
+    id SERIAL PRIMARY KEY,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    base_scan_id INTEGER REFERENCES scans(id) ON DELETE CASCADE,
+    target_scan_id INTEGER REFERENCES scans(id) ON DELETE CASCADE,
+    opened_count INTEGER NOT NULL DEFAULT 0,
+    closed_count INTEGER NOT NULL DEFAULT 0,
+    changed_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (base_scan_id, target_scan_id)
+);
+CREATE INDEX IF NOT EXISTS idx_scan_diffs_target_scan ON scan_diffs(target_scan_id);
+CREATE TABLE IF NOT EXISTS scan_diff_entries (
+    id SERIAL PRIMARY KEY,
+    diff_id INTEGER REFERENCES scan_diffs(id) ON DELETE CASCADE,
+    change VARCHAR(20) NOT NULL,
+    host VARCHAR(255),
+    hostname VARCHAR(255),
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    service VARCHAR(100),
+    version VARCHAR(255),
+    previous_service VARCHAR(100),
+    previous_version VARCHAR(255)
+);`
+
+const createVulnerabilityTables = `
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS cpes TEXT;
+CREATE TABLE IF NOT EXISTS cves (
+    id VARCHAR(32) PRIMARY KEY,
+    description TEXT,
+    cvss_score REAL,
+    cvss_version VARCHAR(8),
+    cvss_vector VARCHAR(255),
+    severity VARCHAR(16),
+    published_at TIMESTAMP,
+    last_modified_at TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS cve_cpe_matches (
+    id SERIAL PRIMARY KEY,
+    cve_id VARCHAR(32) REFERENCES cves(id) ON DELETE CASCADE,
+    part VARCHAR(1) NOT NULL,
+    vendor VARCHAR(255) NOT NULL,
+    product VARCHAR(255) NOT NULL,
+    version VARCHAR(255) NOT NULL DEFAULT '*',
+    version_update VARCHAR(255) NOT NULL DEFAULT '*',
+    version_start_including VARCHAR(100),
+    version_start_excluding VARCHAR(100),
+    version_end_including VARCHAR(100),
+    version_end_excluding VARCHAR(100)
+);
+CREATE INDEX IF NOT EXISTS idx_cve_cpe_matches_product ON cve_cpe_matches(vendor, product);
+CREATE INDEX IF NOT EXISTS idx_cve_cpe_matches_cve ON cve_cpe_matches(cve_id);
+CREATE TABLE IF NOT EXISTS scan_result_vulnerabilities (
+    scan_result_id INTEGER REFERENCES scan_results(id) ON DELETE CASCADE,
+    cve_id VARCHAR(32) REFERENCES cves(id) ON DELETE CASCADE,
+    cpe VARCHAR(255) NOT NULL,
+    PRIMARY KEY (scan_result_id, cve_id)
+);`
+
+const addRiskScoreColumns = `
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS risk_score REAL;
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS risk_severity VARCHAR(16);
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS criticality VARCHAR(16) NOT NULL DEFAULT 'medium';
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS internet_facing BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS risk_score REAL;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS risk_severity VARCHAR(16);
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS risk_scored_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_score REAL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_severity VARCHAR(16);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_scored_at TIMESTAMP;`
+
+const createFindingsTables = `
+CREATE TABLE IF NOT EXISTS findings (
+    id SERIAL PRIMARY KEY,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    host VARCHAR(255) NOT NULL DEFAULT '',
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    rule_id VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    remediation TEXT,
+    service VARCHAR(100),
+    version VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    assignee_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    scan_result_id INTEGER REFERENCES scan_results(id) ON DELETE SET NULL,
+    last_scan_id INTEGER REFERENCES scans(id) ON DELETE SET NULL,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (asset_id, host, port, protocol, rule_id)
+);
+CREATE INDEX IF NOT EXISTS idx_findings_status ON findings(status);
+CREATE TABLE IF NOT EXISTS finding_comments (
+    id SERIAL PRIMARY KEY,
+    finding_id INTEGER REFERENCES findings(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_finding_comments_finding ON finding_comments(finding_id);`
+
+const createRiskExceptionsTable = `
+CREATE TABLE IF NOT EXISTS risk_exceptions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    group_id INTEGER REFERENCES asset_groups(id) ON DELETE CASCADE,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10),
+    service VARCHAR(100),
+    justification TEXT NOT NULL,
+    approver_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    CHECK ((asset_id IS NULL) <> (group_id IS NULL))
+);
+CREATE INDEX IF NOT EXISTS idx_risk_exceptions_asset ON risk_exceptions(asset_id);
+CREATE INDEX IF NOT EXISTS idx_risk_exceptions_group ON risk_exceptions(group_id);
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS exception_id INTEGER REFERENCES risk_exceptions(id) ON DELETE SET NULL;`
+
+const addScanTimestampColumns = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS error TEXT;`
+
+const reconcileScanLifecycleColumns = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS queued_at TIMESTAMP;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+UPDATE scans SET queued_at = COALESCE(created_at, started_at, NOW());
+UPDATE scans SET started_at = NULL WHERE status = 'pending';
+UPDATE scans SET completed_at = COALESCE(completed_at, updated_at)
+WHERE status IN ('completed', 'failed', 'cancelled');
+UPDATE scans SET duration_ms = (EXTRACT(EPOCH FROM (completed_at - started_at)) * 1000)::BIGINT
+WHERE completed_at IS NOT NULL AND started_at IS NOT NULL AND completed_at >= started_at;
+UPDATE scans SET error_message = COALESCE(error_message, error);
+ALTER TABLE scans ALTER COLUMN queued_at SET NOT NULL;
+ALTER TABLE scans ALTER COLUMN queued_at SET DEFAULT NOW();
+ALTER TABLE scans ALTER COLUMN started_at DROP DEFAULT;
+ALTER TABLE scans ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE scans DROP COLUMN IF EXISTS created_at;
+ALTER TABLE scans DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE scans DROP COLUMN IF EXISTS error;
+DROP INDEX IF EXISTS idx_scans_status;
+CREATE INDEX IF NOT EXISTS idx_scans_status_queued ON scans(status, queued_at);`
+
+const addScanProgressColumns = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS progress DOUBLE PRECISION;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS estimated_completion TIMESTAMP;`
+
+const assignUserRoles = `
+UPDATE users SET role = 'analyst' WHERE role IS NULL OR role NOT IN ('admin', 'analyst', 'auditor', 'viewer');
+UPDATE users SET role = 'admin'
+WHERE id = (SELECT MIN(id) FROM users)
+  AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin');
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'analyst';
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;`
+
+// createOrganizations gives each existing user an organization with the same
+// ID holding their data, in which they keep their role
+const createOrganizations = `
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members(user_id);
+INSERT INTO organizations (id, name, created_at) SELECT id, email, created_at FROM users;
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), COALESCE((SELECT MAX(id) FROM organizations), 0) + 1, false);
+INSERT INTO organization_members (organization_id, user_id, role, created_at) SELECT id, id, role, created_at FROM users;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS current_organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+UPDATE users SET current_organization_id = id;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE assets SET organization_id = user_id;
+CREATE INDEX IF NOT EXISTS idx_assets_organization ON assets(organization_id);
+ALTER TABLE asset_groups ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE asset_groups SET organization_id = user_id;
+ALTER TABLE scan_profiles ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE scan_profiles SET organization_id = user_id;
+ALTER TABLE scan_schedules ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE scan_schedules SET organization_id = user_id;
+ALTER TABLE risk_exceptions ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE risk_exceptions SET organization_id = user_id;`
+
+// promoteSoleMembers makes the only member of each organization without an
+// admin its admin, since createOrganizations kept users' roles and only one
+// user per install was an admin
+const promoteSoleMembers = `
+UPDATE organization_members SET role = 'admin'
+WHERE organization_id IN (
+    SELECT organization_id FROM organization_members
+    GROUP BY organization_id
+    HAVING COUNT(*) = 1 AND SUM(CASE WHEN role = 'admin' THEN 1 ELSE 0 END) = 0
+);`
+
+const moveRiskScoresToOrganizations = `
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS risk_score REAL;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS risk_severity VARCHAR(16);
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS risk_scored_at TIMESTAMP;
+ALTER TABLE users DROP COLUMN IF EXISTS risk_scored_at;
+ALTER TABLE users DROP COLUMN IF EXISTS risk_severity;
+ALTER TABLE users DROP COLUMN IF EXISTS risk_score;`
+
+// Scans queued before requested_by existed are attributed to the asset's creator
+const addScanRequestedBy = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS requested_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+UPDATE scans SET requested_by = (SELECT a.user_id FROM assets a WHERE a.id = scans.asset_id)
+WHERE requested_by IS NULL;
+CREATE INDEX IF NOT EXISTS idx_scans_requested_by_status ON scans(requested_by, status);`
+
+// Running scans claimed before leases existed have none, so the first server
+// to start after the upgrade recovers them
+const addScanLeases = `
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS claimed_by VARCHAR(255);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_scans_status_lease ON scans(status, lease_expires_at);`
+
+// migrations lists every schema change in the order it is applied. Applied
+// migrations are recorded in schema_migrations with a checksum of their up SQL,
+// so never edit one that has shipped: append a new migration instead. The early
+// migrations keep their IF NOT EXISTS guards so databases created before
+// versioning adopt them without changes.
+var migrations = []Migration{
+	{1, "create_users_table", createUsersTable, `DROP TABLE IF EXISTS users;`},
+	{2, "create_assets_table", createAssetsTable, `DROP TABLE IF EXISTS assets;`},
+	{3, "create_scans_table", createScansTable, `DROP TABLE IF EXISTS scans;`},
+	{4, "create_scan_results_table", createScanResultsTable, `DROP TABLE IF EXISTS scan_results;`},
+	{5, "add_asset_scan_engine_column", addAssetScanEngineColumn, `ALTER TABLE assets DROP COLUMN IF EXISTS scan_engine;`},
+	{6, "add_scan_engine_column", addScanEngineColumn, `ALTER TABLE scans DROP COLUMN IF EXISTS engine;`},
+	{7, "create_scan_profiles_table", createScanProfilesTable, `DROP TABLE IF EXISTS scan_profiles;`},
+	{8, "seed_scan_profiles", seedScanProfiles, `
+DELETE FROM scan_profiles
+WHERE user_id IS NULL AND name IN ('quick top-100', 'full TCP 1-65535', 'service-only', 'UDP top-50');`},
+	{9, "add_asset_scan_profile_column", addAssetScanProfileColumn, `ALTER TABLE assets DROP COLUMN IF EXISTS scan_profile_id;`},
+	{10, "add_scan_profile_column", addScanProfileColumn, `ALTER TABLE scans DROP COLUMN IF EXISTS profile_id;`},
+	{11, "add_scan_result_host_columns", addScanResultHostColumns, `
+ALTER TABLE scan_results DROP COLUMN IF EXISTS hostname;
+ALTER TABLE scan_results DROP COLUMN IF EXISTS host;`},
+	{12, "add_scan_queue_columns", addScanQueueColumns, `
+DROP INDEX IF EXISTS idx_scans_status;
+ALTER TABLE scans DROP COLUMN IF EXISTS attempts;`},
+	{13, "create_asset_groups_table", createAssetGroupsTable, `
+ALTER TABLE assets DROP COLUMN IF EXISTS group_id;
+DROP TABLE IF EXISTS asset_groups;`},
+	{14, "create_scan_schedules_table", createScanSchedulesTable, `DROP TABLE IF EXISTS scan_schedules;`},
+	{15, "create_scan_diffs_tables", createScanDiffsTables, `
+DROP TABLE IF EXISTS scan_diff_entries;
+DROP TABLE IF EXISTS scan_diffs;`},
+	{16, "create_vulnerability_tables", createVulnerabilityTables, `
+DROP TABLE IF EXISTS scan_result_vulnerabilities;
+DROP TABLE IF EXISTS cve_cpe_matches;
+DROP TABLE IF EXISTS cves;
+ALTER TABLE scan_results DROP COLUMN IF EXISTS cpes;`},
+	{17, "add_risk_score_columns", addRiskScoreColumns, `
+ALTER TABLE users DROP COLUMN IF EXISTS risk_scored_at;
+ALTER TABLE users DROP COLUMN IF EXISTS risk_severity;
+ALTER TABLE users DROP COLUMN IF EXISTS risk_score;
+ALTER TABLE assets DROP COLUMN IF EXISTS risk_scored_at;
+ALTER TABLE assets DROP COLUMN IF EXISTS risk_severity;
+ALTER TABLE assets DROP COLUMN IF EXISTS risk_score;
+ALTER TABLE assets DROP COLUMN IF EXISTS internet_facing;
+ALTER TABLE assets DROP COLUMN IF EXISTS criticality;
+ALTER TABLE scan_results DROP COLUMN IF EXISTS risk_severity;
+ALTER TABLE scan_results DROP COLUMN IF EXISTS risk_score;`},
+	{18, "create_findings_tables", createFindingsTables, `
+DROP TABLE IF EXISTS finding_comments;
+DROP TABLE IF EXISTS findings;`},
+	{19, "create_risk_exceptions_table", createRiskExceptionsTable, `
+ALTER TABLE findings DROP COLUMN IF EXISTS exception_id;
+DROP TABLE IF EXISTS risk_exceptions;`},
+	{20, "add_scan_timestamp_columns", addScanTimestampColumns, `
+ALTER TABLE scans DROP COLUMN IF EXISTS error;
+ALTER TABLE scans DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE scans DROP COLUMN IF EXISTS created_at;`},
+	{21, "reconcile_scan_lifecycle_columns", reconcileScanLifecycleColumns, `
+DROP INDEX IF EXISTS idx_scans_status_queued;
+CREATE INDEX IF NOT EXISTS idx_scans_status ON scans(status);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS error TEXT;
+UPDATE scans SET created_at = queued_at, updated_at = COALESCE(completed_at, started_at, queued_at), error = error_message;
+UPDATE scans SET started_at = queued_at WHERE started_at IS NULL;
+ALTER TABLE scans ALTER COLUMN started_at SET DEFAULT NOW();
+ALTER TABLE scans ALTER COLUMN status SET DEFAULT 'running';
+ALTER TABLE scans DROP COLUMN IF EXISTS error_code;
+ALTER TABLE scans DROP COLUMN IF EXISTS duration_ms;
+ALTER TABLE scans DROP COLUMN IF EXISTS queued_at;`},
+	{22, "add_scan_progress_columns", addScanProgressColumns, `
+ALTER TABLE scans DROP COLUMN IF EXISTS estimated_completion;
+ALTER TABLE scans DROP COLUMN IF EXISTS progress;`},
+	{23, "assign_user_roles", assignUserRoles, `
+ALTER TABLE users ALTER COLUMN role DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'user';
+UPDATE users SET role = 'user';`},
+	{24, "create_organizations", createOrganizations, `
+ALTER TABLE risk_exceptions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE scan_schedules DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE scan_profiles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE asset_groups DROP COLUMN IF EXISTS organization_id;
+DROP INDEX IF EXISTS idx_assets_organization;
+ALTER TABLE assets DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(50) NOT NULL DEFAULT 'analyst';
+UPDATE users SET role = COALESCE(
+    (SELECT m.role FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = users.current_organization_id),
+    (SELECT m.role FROM organization_members m WHERE m.user_id = users.id ORDER BY m.created_at LIMIT 1),
+    'analyst'
+);
+ALTER TABLE users DROP COLUMN IF EXISTS current_organization_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;`},
+	{25, "promote_sole_organization_members", promoteSoleMembers, `
+-- Members keep the admin role, since their previous one is not recorded`},
+	{26, "move_risk_scores_to_organizations", moveRiskScoresToOrganizations, `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_score REAL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_severity VARCHAR(16);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS risk_scored_at TIMESTAMP;
+ALTER TABLE organizations DROP COLUMN IF EXISTS risk_scored_at;
+ALTER TABLE organizations DROP COLUMN IF EXISTS risk_severity;
+ALTER TABLE organizations DROP COLUMN IF EXISTS risk_score;`},
+	{27, "add_scan_requested_by", addScanRequestedBy, `
+DROP INDEX IF EXISTS idx_scans_requested_by_status;
+ALTER TABLE scans DROP COLUMN IF EXISTS requested_by;`},
+	{28, "add_scan_leases", addScanLeases, `
+DROP INDEX IF EXISTS idx_scans_status_lease;
+ALTER TABLE scans DROP COLUMN IF EXISTS lease_expires_at;
+ALTER TABLE scans DROP COLUMN IF EXISTS claimed_by;`},
+}
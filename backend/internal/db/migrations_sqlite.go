@@ -0,0 +1,331 @@
+package db
+
+// createSQLiteSchema creates the schema PostgreSQL databases reach after
+// migration 21 in one step, since SQLite databases have no earlier history
+const createSQLiteSchema = `
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    role VARCHAR(50) DEFAULT 'user',
+    risk_score REAL,
+    risk_severity VARCHAR(16),
+    risk_scored_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE scan_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    port_spec VARCHAR(255) NOT NULL,
+    timing_template INTEGER NOT NULL DEFAULT 3,
+    version_intensity INTEGER,
+    scan_type VARCHAR(20) NOT NULL DEFAULT 'syn',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO scan_profiles (name, description, port_spec, timing_template, version_intensity, scan_type) VALUES
+    ('quick top-100', 'SYN scan of the 100 most common TCP ports', 'top:100', 4, NULL, 'syn'),
+    ('full TCP 1-65535', 'SYN scan of every TCP port with version detection', '1-65535', 4, 7, 'syn'),
+    ('service-only', 'Thorough version detection on common service ports', '21,22,23,25,53,80,110,143,443,445,3306,3389,5432,8080', 3, 9, 'syn'),
+    ('UDP top-50', 'UDP scan of the 50 most common UDP ports', 'top:50', 4, 0, 'udp');
+CREATE TABLE asset_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE assets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    asset_type VARCHAR(50) DEFAULT 'server',
+    scan_engine VARCHAR(50) DEFAULT 'nmap',
+    scan_profile_id INTEGER REFERENCES scan_profiles(id) ON DELETE SET NULL,
+    group_id INTEGER REFERENCES asset_groups(id) ON DELETE SET NULL,
+    criticality VARCHAR(16) NOT NULL DEFAULT 'medium',
+    internet_facing BOOLEAN NOT NULL DEFAULT FALSE,
+    risk_score REAL,
+    risk_severity VARCHAR(16),
+    risk_scored_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_scanned_at TIMESTAMP
+);
+CREATE TABLE scans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    status VARCHAR(50) DEFAULT 'pending',
+    engine VARCHAR(50) DEFAULT 'nmap',
+    profile_id INTEGER REFERENCES scan_profiles(id) ON DELETE SET NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    queued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    duration_ms BIGINT,
+    error_code VARCHAR(50),
+    error_message TEXT
+);
+CREATE INDEX idx_scans_status_queued ON scans(status, queued_at);
+CREATE TABLE scan_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scan_id INTEGER REFERENCES scans(id) ON DELETE CASCADE,
+    host VARCHAR(255),
+    hostname VARCHAR(255),
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) DEFAULT 'tcp',
+    state VARCHAR(20) NOT NULL,
+    service VARCHAR(100),
+    version VARCHAR(255),
+    banner TEXT,
+    cpes TEXT,
+    risk_score REAL,
+    risk_severity VARCHAR(16)
+);
+CREATE TABLE scan_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    group_id INTEGER REFERENCES asset_groups(id) ON DELETE CASCADE,
+    cron VARCHAR(100) NOT NULL,
+    engine VARCHAR(50),
+    profile_id INTEGER REFERENCES scan_profiles(id) ON DELETE SET NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMP,
+    next_run_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((asset_id IS NULL) <> (group_id IS NULL))
+);
+CREATE INDEX idx_scan_schedules_next_run ON scan_schedules(next_run_at) WHERE enabled;
+CREATE TABLE scan_diffs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    base_scan_id INTEGER REFERENCES scans(id) ON DELETE CASCADE,
+    target_scan_id INTEGER REFERENCES scans(id) ON DELETE CASCADE,
+    opened_count INTEGER NOT NULL DEFAULT 0,
+    closed_count INTEGER NOT NULL DEFAULT 0,
+    changed_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (base_scan_id, target_scan_id)
+);
+CREATE INDEX idx_scan_diffs_target_scan ON scan_diffs(target_scan_id);
+CREATE TABLE scan_diff_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    diff_id INTEGER REFERENCES scan_diffs(id) ON DELETE CASCADE,
+    change VARCHAR(20) NOT NULL,
+    host VARCHAR(255),
+    hostname VARCHAR(255),
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    service VARCHAR(100),
+    version VARCHAR(255),
+    previous_service VARCHAR(100),
+    previous_version VARCHAR(255)
+);
+CREATE TABLE cves (
+    id VARCHAR(32) PRIMARY KEY,
+    description TEXT,
+    cvss_score REAL,
+    cvss_version VARCHAR(8),
+    cvss_vector VARCHAR(255),
+    severity VARCHAR(16),
+    published_at TIMESTAMP,
+    last_modified_at TIMESTAMP
+);
+CREATE TABLE cve_cpe_matches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    cve_id VARCHAR(32) REFERENCES cves(id) ON DELETE CASCADE,
+    part VARCHAR(1) NOT NULL,
+    vendor VARCHAR(255) NOT NULL,
+    product VARCHAR(255) NOT NULL,
+    version VARCHAR(255) NOT NULL DEFAULT '*',
+    version_update VARCHAR(255) NOT NULL DEFAULT '*',
+    version_start_including VARCHAR(100),
+    version_start_excluding VARCHAR(100),
+    version_end_including VARCHAR(100),
+    version_end_excluding VARCHAR(100)
+);
+CREATE INDEX idx_cve_cpe_matches_product ON cve_cpe_matches(vendor, product);
+CREATE INDEX idx_cve_cpe_matches_cve ON cve_cpe_matches(cve_id);
+CREATE TABLE scan_result_vulnerabilities (
+    scan_result_id INTEGER REFERENCES scan_results(id) ON DELETE CASCADE,
+    cve_id VARCHAR(32) REFERENCES cves(id) ON DELETE CASCADE,
+    cpe VARCHAR(255) NOT NULL,
+    PRIMARY KEY (scan_result_id, cve_id)
+);
+CREATE TABLE risk_exceptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    group_id INTEGER REFERENCES asset_groups(id) ON DELETE CASCADE,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10),
+    service VARCHAR(100),
+    justification TEXT NOT NULL,
+    approver_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((asset_id IS NULL) <> (group_id IS NULL))
+);
+CREATE INDEX idx_risk_exceptions_asset ON risk_exceptions(asset_id);
+CREATE INDEX idx_risk_exceptions_group ON risk_exceptions(group_id);
+CREATE TABLE findings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    host VARCHAR(255) NOT NULL DEFAULT '',
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    rule_id VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    remediation TEXT,
+    service VARCHAR(100),
+    version VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    assignee_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    scan_result_id INTEGER REFERENCES scan_results(id) ON DELETE SET NULL,
+    last_scan_id INTEGER REFERENCES scans(id) ON DELETE SET NULL,
+    exception_id INTEGER REFERENCES risk_exceptions(id) ON DELETE SET NULL,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (asset_id, host, port, protocol, rule_id)
+);
+CREATE INDEX idx_findings_status ON findings(status);
+CREATE TABLE finding_comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    finding_id INTEGER REFERENCES findings(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_finding_comments_finding ON finding_comments(finding_id);`
+
+const addSQLiteScanProgressColumns = `
+ALTER TABLE scans ADD COLUMN progress REAL;
+ALTER TABLE scans ADD COLUMN estimated_completion TIMESTAMP;`
+
+// SQLite can't change a column's default, so users keep role's 'user' default
+// and new users are always created with an explicit role
+const assignSQLiteUserRoles = `
+UPDATE users SET role = 'analyst' WHERE role IS NULL OR role NOT IN ('admin', 'analyst', 'auditor', 'viewer');
+UPDATE users SET role = 'admin'
+WHERE id = (SELECT MIN(id) FROM users)
+  AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin');`
+
+const createSQLiteOrganizations = `
+CREATE TABLE organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+CREATE INDEX idx_organization_members_user ON organization_members(user_id);
+INSERT INTO organizations (id, name, created_at) SELECT id, email, created_at FROM users;
+INSERT INTO organization_members (organization_id, user_id, role, created_at) SELECT id, id, role, created_at FROM users;
+ALTER TABLE users ADD COLUMN current_organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+UPDATE users SET current_organization_id = id;
+ALTER TABLE users DROP COLUMN role;
+ALTER TABLE assets ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE assets SET organization_id = user_id;
+CREATE INDEX idx_assets_organization ON assets(organization_id);
+ALTER TABLE asset_groups ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE asset_groups SET organization_id = user_id;
+ALTER TABLE scan_profiles ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE scan_profiles SET organization_id = user_id;
+ALTER TABLE scan_schedules ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE scan_schedules SET organization_id = user_id;
+ALTER TABLE risk_exceptions ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE risk_exceptions SET organization_id = user_id;`
+
+const moveSQLiteRiskScoresToOrganizations = `
+ALTER TABLE organizations ADD COLUMN risk_score REAL;
+ALTER TABLE organizations ADD COLUMN risk_severity VARCHAR(16);
+ALTER TABLE organizations ADD COLUMN risk_scored_at TIMESTAMP;
+ALTER TABLE users DROP COLUMN risk_scored_at;
+ALTER TABLE users DROP COLUMN risk_severity;
+ALTER TABLE users DROP COLUMN risk_score;`
+
+const addSQLiteScanRequestedBy = `
+ALTER TABLE scans ADD COLUMN requested_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+UPDATE scans SET requested_by = (SELECT a.user_id FROM assets a WHERE a.id = scans.asset_id)
+WHERE requested_by IS NULL;
+CREATE INDEX idx_scans_requested_by_status ON scans(requested_by, status);`
+
+const addSQLiteScanLeases = `
+ALTER TABLE scans ADD COLUMN claimed_by VARCHAR(255);
+ALTER TABLE scans ADD COLUMN lease_expires_at TIMESTAMP;
+CREATE INDEX idx_scans_status_lease ON scans(status, lease_expires_at);`
+
+// sqliteMigrations lists the schema changes applied to SQLite databases. They
+// start from a baseline equivalent to PostgreSQL migrations 1-21 and, from
+// then on, every PostgreSQL migration needs a SQLite counterpart with the same
+// version and name.
+var sqliteMigrations = []Migration{
+	{21, "create_sqlite_schema", createSQLiteSchema, `
+DROP TABLE IF EXISTS finding_comments;
+DROP TABLE IF EXISTS findings;
+DROP TABLE IF EXISTS risk_exceptions;
+DROP TABLE IF EXISTS scan_result_vulnerabilities;
+DROP TABLE IF EXISTS cve_cpe_matches;
+DROP TABLE IF EXISTS cves;
+DROP TABLE IF EXISTS scan_diff_entries;
+DROP TABLE IF EXISTS scan_diffs;
+DROP TABLE IF EXISTS scan_schedules;
+DROP TABLE IF EXISTS scan_results;
+DROP TABLE IF EXISTS scans;
+DROP TABLE IF EXISTS assets;
+DROP TABLE IF EXISTS asset_groups;
+DROP TABLE IF EXISTS scan_profiles;
+DROP TABLE IF EXISTS users;`},
+	{22, "add_scan_progress_columns", addSQLiteScanProgressColumns, `
+ALTER TABLE scans DROP COLUMN estimated_completion;
+ALTER TABLE scans DROP COLUMN progress;`},
+	{23, "assign_user_roles", assignSQLiteUserRoles, `
+UPDATE users SET role = 'user';`},
+	{24, "create_organizations", createSQLiteOrganizations, `
+ALTER TABLE risk_exceptions DROP COLUMN organization_id;
+ALTER TABLE scan_schedules DROP COLUMN organization_id;
+ALTER TABLE scan_profiles DROP COLUMN organization_id;
+ALTER TABLE asset_groups DROP COLUMN organization_id;
+DROP INDEX IF EXISTS idx_assets_organization;
+ALTER TABLE assets DROP COLUMN organization_id;
+ALTER TABLE users ADD COLUMN role VARCHAR(50) NOT NULL DEFAULT 'analyst';
+UPDATE users SET role = COALESCE(
+    (SELECT m.role FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = users.current_organization_id),
+    (SELECT m.role FROM organization_members m WHERE m.user_id = users.id ORDER BY m.created_at LIMIT 1),
+    'analyst'
+);
+ALTER TABLE users DROP COLUMN current_organization_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;`},
+	{25, "promote_sole_organization_members", promoteSoleMembers, `
+-- Members keep the admin role, since their previous one is not recorded`},
+	{26, "move_risk_scores_to_organizations", moveSQLiteRiskScoresToOrganizations, `
+ALTER TABLE users ADD COLUMN risk_score REAL;
+ALTER TABLE users ADD COLUMN risk_severity VARCHAR(16);
+ALTER TABLE users ADD COLUMN risk_scored_at TIMESTAMP;
+ALTER TABLE organizations DROP COLUMN risk_scored_at;
+ALTER TABLE organizations DROP COLUMN risk_severity;
+ALTER TABLE organizations DROP COLUMN risk_score;`},
+	{27, "add_scan_requested_by", addSQLiteScanRequestedBy, `
+DROP INDEX IF EXISTS idx_scans_requested_by_status;
+ALTER TABLE scans DROP COLUMN requested_by;`},
+	{28, "add_scan_leases", addSQLiteScanLeases, `
+DROP INDEX IF EXISTS idx_scans_status_lease;
+ALTER TABLE scans DROP COLUMN lease_expires_at;
+ALTER TABLE scans DROP COLUMN claimed_by;`},
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestUpgradeMakesUsersAdminsOfTheirOrganizations migrates a database whose
+// users were given roles by assign_user_roles, which made only the first one an
+// admin, and checks every user can manage the organization holding their data
+func TestUpgradeMakesUsersAdminsOfTheirOrganizations(t *testing.T) {
+	database, err := NewConnection("sqlite://" + filepath.Join(t.TempDir(), "upgrade.db"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer database.Close()
+
+	// Start from the schema before organizations existed, after assign_user_roles
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	steps := 0
+	for _, m := range database.migrations() {
+		if m.Version > 23 {
+			steps++
+		}
+	}
+	if _, err := database.MigrateDown(steps); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+
+	users := []struct {
+		email string
+		role  string
+	}{
+		{"first@example.com", "admin"},
+		{"second@example.com", "analyst"},
+	}
+	ids := make([]int, len(users))
+	for i, user := range users {
+		err := database.QueryRow(`INSERT INTO users (email, password_hash, role) VALUES ($1, 'hash', $2) RETURNING id`,
+			user.email, user.role).Scan(&ids[i])
+		if err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+		if _, err := database.Exec(`INSERT INTO assets (user_id, name, target, asset_type) VALUES ($1, 'web', '10.0.0.1', 'host')`, ids[i]); err != nil {
+			t.Fatalf("insert asset: %v", err)
+		}
+	}
+
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	for i, id := range ids {
+		var orgID int
+		if err := database.QueryRow(`SELECT organization_id FROM assets WHERE user_id = $1`, id).Scan(&orgID); err != nil {
+			t.Fatalf("get asset organization: %v", err)
+		}
+
+		var role string
+		err := database.QueryRow(`SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, id).Scan(&role)
+		if err != nil {
+			t.Fatalf("%s is not a member of the organization holding their assets: %v", users[i].email, err)
+		}
+		if role != "admin" {
+			t.Errorf("%s has role %q in their organization, want admin", users[i].email, role)
+		}
+	}
+}
+
+// TestPromoteSoleMembersKeepsSharedOrganizations checks only organizations
+// with one member and no admin are changed
+func TestPromoteSoleMembersKeepsSharedOrganizations(t *testing.T) {
+	database, err := NewConnection("sqlite://" + filepath.Join(t.TempDir(), "promote.db"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer database.Close()
+
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	statements := []string{
+		`INSERT INTO users (id, email, password_hash) VALUES (1, 'a@example.com', 'hash'), (2, 'b@example.com', 'hash'), (3, 'c@example.com', 'hash')`,
+		`INSERT INTO organizations (id, name) VALUES (1, 'sole viewer'), (2, 'shared'), (3, 'sole admin')`,
+		`INSERT INTO organization_members (organization_id, user_id, role) VALUES
+			(1, 1, 'viewer'),
+			(2, 1, 'admin'), (2, 2, 'viewer'),
+			(3, 3, 'admin')`,
+	}
+	for _, statement := range statements {
+		if _, err := database.Exec(statement); err != nil {
+			t.Fatalf("insert fixtures: %v", err)
+		}
+	}
+
+	if _, err := database.Exec(promoteSoleMembers); err != nil {
+		t.Fatalf("promote sole members: %v", err)
+	}
+
+	want := map[[2]int]string{
+		{1, 1}: "admin",
+		{2, 1}: "admin",
+		{2, 2}: "viewer",
+		{3, 3}: "admin",
+	}
+	for key, role := range want {
+		var got string
+		err := database.QueryRow(`SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`, key[0], key[1]).Scan(&got)
+		if err != nil {
+			t.Fatalf("get member: %v", err)
+		}
+		if got != role {
+			t.Errorf("member %d of organization %d has role %q, want %q", key[1], key[0], got, role)
+		}
+	}
+}
@@ -5,40 +5,210 @@ import (
 )
 
 type User struct {
-	ID           int       `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	Role         string    `json:"role" db:"role"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID                    int       `json:"id" db:"id"`
+	Email                 string    `json:"email" db:"email"`
+	PasswordHash          string    `json:"-" db:"password_hash"`
+	CurrentOrganizationID *int      `json:"current_organization_id" db:"current_organization_id"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type Organization struct {
+	ID           int        `json:"id" db:"id"`
+	Name         string     `json:"name" db:"name"`
+	RiskScore    *float64   `json:"risk_score" db:"risk_score"`
+	RiskSeverity *string    `json:"risk_severity" db:"risk_severity"`
+	RiskScoredAt *time.Time `json:"risk_scored_at" db:"risk_scored_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int       `json:"organization_id" db:"organization_id"`
+	UserID         int       `json:"user_id" db:"user_id"`
+	Role           string    `json:"role" db:"role"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 }
 
 type Asset struct {
-	ID            int        `json:"id" db:"id"`
-	UserID        int        `json:"user_id" db:"user_id"`
-	Name          string     `json:"name" db:"name"`
-	Target        string     `json:"target" db:"target"`
-	AssetType     string     `json:"asset_type" db:"asset_type"`
-	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
-	LastScannedAt *time.Time `json:"last_scanned_at" db:"last_scanned_at"`
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	OrganizationID int        `json:"organization_id" db:"organization_id"`
+	Name           string     `json:"name" db:"name"`
+	Target         string     `json:"target" db:"target"`
+	AssetType      string     `json:"asset_type" db:"asset_type"`
+	ScanEngine     string     `json:"scan_engine" db:"scan_engine"`
+	ScanProfileID  *int       `json:"scan_profile_id" db:"scan_profile_id"`
+	GroupID        *int       `json:"group_id" db:"group_id"`
+	Criticality    string     `json:"criticality" db:"criticality"`
+	InternetFacing bool       `json:"internet_facing" db:"internet_facing"`
+	RiskScore      *float64   `json:"risk_score" db:"risk_score"`
+	RiskSeverity   *string    `json:"risk_severity" db:"risk_severity"`
+	RiskScoredAt   *time.Time `json:"risk_scored_at" db:"risk_scored_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	LastScannedAt  *time.Time `json:"last_scanned_at" db:"last_scanned_at"`
 }
 
 type Scan struct {
+	ID                  int        `json:"id" db:"id"`
+	AssetID             int        `json:"asset_id" db:"asset_id"`
+	RequestedBy         *int       `json:"requested_by" db:"requested_by"`
+	Status              string     `json:"status" db:"status"`
+	Engine              string     `json:"engine" db:"engine"`
+	ProfileID           *int       `json:"profile_id" db:"profile_id"`
+	Attempts            int        `json:"attempts" db:"attempts"`
+	QueuedAt            time.Time  `json:"queued_at" db:"queued_at"`
+	StartedAt           *time.Time `json:"started_at" db:"started_at"`
+	CompletedAt         *time.Time `json:"completed_at" db:"completed_at"`
+	DurationMS          *int64     `json:"duration_ms" db:"duration_ms"`
+	ErrorCode           *string    `json:"error_code" db:"error_code"`
+	ErrorMessage        *string    `json:"error_message" db:"error_message"`
+	Progress            *float64   `json:"progress" db:"progress"`
+	EstimatedCompletion *time.Time `json:"estimated_completion" db:"estimated_completion"`
+	ClaimedBy           *string    `json:"claimed_by" db:"claimed_by"`
+	LeaseExpiresAt      *time.Time `json:"lease_expires_at" db:"lease_expires_at"`
+}
+
+type ScanResult struct {
+	ID           int      `json:"id" db:"id"`
+	ScanID       int      `json:"scan_id" db:"scan_id"`
+	Host         *string  `json:"host" db:"host"`
+	Hostname     *string  `json:"hostname" db:"hostname"`
+	Port         int      `json:"port" db:"port"`
+	Protocol     string   `json:"protocol" db:"protocol"`
+	State        string   `json:"state" db:"state"`
+	Service      *string  `json:"service" db:"service"`
+	Version      *string  `json:"version" db:"version"`
+	Banner       *string  `json:"banner" db:"banner"`
+	CPEs         *string  `json:"cpes" db:"cpes"`
+	RiskScore    *float64 `json:"risk_score" db:"risk_score"`
+	RiskSeverity *string  `json:"risk_severity" db:"risk_severity"`
+}
+
+type AssetGroup struct {
+	ID             int       `json:"id" db:"id"`
+	UserID         int       `json:"user_id" db:"user_id"`
+	OrganizationID int       `json:"organization_id" db:"organization_id"`
+	Name           string    `json:"name" db:"name"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type ScanSchedule struct {
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	OrganizationID int        `json:"organization_id" db:"organization_id"`
+	Name           string     `json:"name" db:"name"`
+	AssetID        *int       `json:"asset_id" db:"asset_id"`
+	GroupID        *int       `json:"group_id" db:"group_id"`
+	Cron           string     `json:"cron" db:"cron"`
+	Engine         *string    `json:"engine" db:"engine"`
+	ProfileID      *int       `json:"profile_id" db:"profile_id"`
+	Enabled        bool       `json:"enabled" db:"enabled"`
+	LastRunAt      *time.Time `json:"last_run_at" db:"last_run_at"`
+	NextRunAt      *time.Time `json:"next_run_at" db:"next_run_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type ScanDiff struct {
+	ID           int       `json:"id" db:"id"`
+	AssetID      int       `json:"asset_id" db:"asset_id"`
+	BaseScanID   int       `json:"base_scan_id" db:"base_scan_id"`
+	TargetScanID int       `json:"target_scan_id" db:"target_scan_id"`
+	OpenedCount  int       `json:"opened_count" db:"opened_count"`
+	ClosedCount  int       `json:"closed_count" db:"closed_count"`
+	ChangedCount int       `json:"changed_count" db:"changed_count"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+type ScanDiffEntry struct {
+	ID              int     `json:"id" db:"id"`
+	DiffID          int     `json:"diff_id" db:"diff_id"`
+	Change          string  `json:"change" db:"change"`
+	Host            *string `json:"host" db:"host"`
+	Hostname        *string `json:"hostname" db:"hostname"`
+	Port            int     `json:"port" db:"port"`
+	Protocol        string  `json:"protocol" db:"protocol"`
+	Service         *string `json:"service" db:"service"`
+	Version         *string `json:"version" db:"version"`
+	PreviousService *string `json:"previous_service" db:"previous_service"`
+	PreviousVersion *string `json:"previous_version" db:"previous_version"`
+}
+
+type CVE struct {
+	ID             string     `json:"id" db:"id"`
+	Description    *string    `json:"description" db:"description"`
+	CVSSScore      *float64   `json:"cvss_score" db:"cvss_score"`
+	CVSSVersion    *string    `json:"cvss_version" db:"cvss_version"`
+	CVSSVector     *string    `json:"cvss_vector" db:"cvss_vector"`
+	Severity       *string    `json:"severity" db:"severity"`
+	PublishedAt    *time.Time `json:"published_at" db:"published_at"`
+	LastModifiedAt *time.Time `json:"last_modified_at" db:"last_modified_at"`
+}
+
+type CVECPEMatch struct {
+	ID                    int     `json:"id" db:"id"`
+	CVEID                 string  `json:"cve_id" db:"cve_id"`
+	Part                  string  `json:"part" db:"part"`
+	Vendor                string  `json:"vendor" db:"vendor"`
+	Product               string  `json:"product" db:"product"`
+	Version               string  `json:"version" db:"version"`
+	VersionUpdate         string  `json:"version_update" db:"version_update"`
+	VersionStartIncluding *string `json:"version_start_including" db:"version_start_including"`
+	VersionStartExcluding *string `json:"version_start_excluding" db:"version_start_excluding"`
+	VersionEndIncluding   *string `json:"version_end_including" db:"version_end_including"`
+	VersionEndExcluding   *string `json:"version_end_excluding" db:"version_end_excluding"`
+}
+
+type ScanResultVulnerability struct {
+	ScanResultID int    `json:"scan_result_id" db:"scan_result_id"`
+	CVEID        string `json:"cve_id" db:"cve_id"`
+	CPE          string `json:"cpe" db:"cpe"`
+}
+
+type Finding struct {
 	ID           int        `json:"id" db:"id"`
 	AssetID      int        `json:"asset_id" db:"asset_id"`
+	Host         string     `json:"host" db:"host"`
+	Port         int        `json:"port" db:"port"`
+	Protocol     string     `json:"protocol" db:"protocol"`
+	RuleID       string     `json:"rule_id" db:"rule_id"`
+	Title        string     `json:"title" db:"title"`
+	Severity     string     `json:"severity" db:"severity"`
+	Remediation  *string    `json:"remediation" db:"remediation"`
+	Service      *string    `json:"service" db:"service"`
+	Version      *string    `json:"version" db:"version"`
 	Status       string     `json:"status" db:"status"`
-	StartedAt    time.Time  `json:"started_at" db:"started_at"`
-	CompletedAt  *time.Time `json:"completed_at" db:"completed_at"`
-	ErrorMessage *string    `json:"error_message" db:"error_message"`
+	AssigneeID   *int       `json:"assignee_id" db:"assignee_id"`
+	ScanResultID *int       `json:"scan_result_id" db:"scan_result_id"`
+	LastScanID   *int       `json:"last_scan_id" db:"last_scan_id"`
+	ExceptionID  *int       `json:"exception_id" db:"exception_id"`
+	FirstSeenAt  time.Time  `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	ResolvedAt   *time.Time `json:"resolved_at" db:"resolved_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
 }
 
-type ScanResult struct {
-	ID       int     `json:"id" db:"id"`
-	ScanID   int     `json:"scan_id" db:"scan_id"`
-	Port     int     `json:"port" db:"port"`
-	Protocol string  `json:"protocol" db:"protocol"`
-	State    string  `json:"state" db:"state"`
-	Service  *string `json:"service" db:"service"`
-	Version  *string `json:"version" db:"version"`
-	Banner   *string `json:"banner" db:"banner"`
+type FindingComment struct {
+	ID        int       `json:"id" db:"id"`
+	FindingID int       `json:"finding_id" db:"finding_id"`
+	UserID    *int      `json:"user_id" db:"user_id"`
+	Body      string    `json:"body" db:"body"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type RiskException struct {
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	OrganizationID int        `json:"organization_id" db:"organization_id"`
+	AssetID        *int       `json:"asset_id" db:"asset_id"`
+	GroupID        *int       `json:"group_id" db:"group_id"`
+	Port           int        `json:"port" db:"port"`
+	Protocol       *string    `json:"protocol" db:"protocol"`
+	Service        *string    `json:"service" db:"service"`
+	Justification  string     `json:"justification" db:"justification"`
+	ApproverID     *int       `json:"approver_id" db:"approver_id"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 }
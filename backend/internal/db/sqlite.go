@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverName is the driver that runs the PostgreSQL queries used across
+// the codebase against SQLite, rewriting the syntax the two disagree on
+const sqliteDriverName = "sqlite3-crm"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{})
+}
+
+// sqliteParams enforces foreign keys, waits on locks instead of failing, and
+// takes the write lock when a transaction begins so concurrent
+// read-then-update transactions (claiming scans, firing schedules) serialise
+const sqliteParams = "_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+
+// newSQLiteConnection opens the SQLite database file at path, creating it if needed
+func newSQLiteConnection(path string) (*DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("failed to open database: missing SQLite file path")
+	}
+
+	db, err := sql.Open(sqliteDriverName, path+"?"+sqliteParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Printf("Successfully opened SQLite database %s", path)
+	return &DB{DB: db, Dialect: SQLite}, nil
+}
+
+// sqliteNow is the current UTC time in the format the driver stores time.Time
+// arguments in, so stored and generated timestamps compare as text
+const sqliteNow = `(strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))`
+
+var (
+	// $1 placeholders become ?1, which SQLite binds by number like PostgreSQL
+	placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+	// ::TYPE casts are dropped since SQLite columns are dynamically typed
+	castPattern = regexp.MustCompile(`::[A-Za-z]+`)
+	// Row locks are dropped since SQLite transactions lock the whole database
+	rowLockPattern = regexp.MustCompile(`\s+FOR UPDATE(\s+OF\s+\w+)?(\s+SKIP LOCKED)?`)
+)
+
+// rebound caches rewritten queries keyed by the original
+var rebound sync.Map
+
+// rebind rewrites a PostgreSQL query for SQLite
+func rebind(query string) string {
+	if cached, ok := rebound.Load(query); ok {
+		return cached.(string)
+	}
+
+	rewritten := placeholderPattern.ReplaceAllString(query, "?$1")
+	rewritten = castPattern.ReplaceAllString(rewritten, "")
+	rewritten = rowLockPattern.ReplaceAllString(rewritten, "")
+	rewritten = strings.ReplaceAll(rewritten, "NOW()", sqliteNow)
+
+	rebound.Store(query, rewritten)
+	return rewritten
+}
+
+// sqliteDriver opens SQLite connections that rebind every query
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// sqliteConn is a SQLite connection that rebinds queries and stores times in UTC
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.SQLiteConn.Prepare(rebind(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.SQLiteConn.PrepareContext(ctx, rebind(query))
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, rebind(query), args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, rebind(query), args)
+}
+
+// CheckNamedValue converts times to UTC so they sort and compare as text
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	nv.Value = value
+	return nil
+}
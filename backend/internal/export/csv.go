@@ -8,154 +8,125 @@ import (
 	"time"
 
 	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/risk"
+	"cyber-risk-monitor/internal/store"
 )
 
+// ExceptionSource provides the risk exceptions active on an asset
+type ExceptionSource interface {
+	ActiveForAsset(assetID int) ([]*risk.Exception, error)
+}
+
 // CSVExporter handles CSV export functionality
 type CSVExporter struct {
-	db *db.DB
+	assets     store.AssetStore
+	scans      store.ScanStore
+	results    store.ResultStore
+	exceptions ExceptionSource
 }
 
-// NewCSVExporter creates a new CSV exporter
-func NewCSVExporter(database *db.DB) *CSVExporter {
+// NewCSVExporter creates a new CSV exporter reading from the stores
+func NewCSVExporter(stores *store.Store, exceptions ExceptionSource) *CSVExporter {
 	return &CSVExporter{
-		db: database,
+		assets:     stores.Assets,
+		scans:      stores.Scans,
+		results:    stores.Results,
+		exceptions: exceptions,
 	}
 }
 
-// ExportScanResults exports scan results to CSV format
-func (e *CSVExporter) ExportScanResults(assetID string) (string, error) {
-	// Get scan results from database
-	query := `
-		SELECT 
-			a.name as asset_name,
-			a.target as asset_target,
-			s.id as scan_id,
-			s.status as scan_status,
-			s.started_at,
-			s.completed_at,
-			sr.port,
-			sr.protocol,
-			sr.state,
-			sr.service,
-			sr.version,
-			sr.banner
-		FROM assets a
-		JOIN scans s ON a.id = s.asset_id
-		JOIN scan_results sr ON s.id = sr.scan_id
-		WHERE a.id = $1
-		ORDER BY s.started_at DESC, sr.port ASC
-	`
+// ExportScanResults exports the scan results of one of an organization's assets
+// to CSV format, leaving out results whose risk has been accepted by an active
+// exception. Assets of other organizations are reported as store.ErrAssetNotFound.
+func (e *CSVExporter) ExportScanResults(organizationID int, assetID string) (string, error) {
+	id, err := strconv.Atoi(assetID)
+	if err != nil {
+		return "", fmt.Errorf("invalid asset ID")
+	}
+
+	asset, err := e.assets.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if asset.OrganizationID != organizationID {
+		return "", store.ErrAssetNotFound
+	}
+
+	return e.export([]*db.Asset{asset}, false)
+}
 
-	rows, err := e.db.Query(query, assetID)
+// ExportAllScans exports the results of all of an organization's scans to CSV
+// format, leaving out results whose risk has been accepted by an active exception
+func (e *CSVExporter) ExportAllScans(organizationID int) (string, error) {
+	assets, err := e.assets.ListByOrganization(organizationID)
 	if err != nil {
-		return "", fmt.Errorf("failed to query scan results: %w", err)
+		return "", err
 	}
-	defer rows.Close()
 
+	return e.export(assets, true)
+}
+
+// export writes every result of the assets' scans, newest scan first, with the
+// asset type column when includeType is set
+func (e *CSVExporter) export(assets []*db.Asset, includeType bool) (string, error) {
 	// Create CSV buffer
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
 
 	// Write CSV header
-	header := []string{
-		"Asset Name",
-		"Asset Target",
+	header := []string{"Asset Name", "Asset Target"}
+	if includeType {
+		header = append(header, "Asset Type")
+	}
+	header = append(header,
 		"Scan ID",
 		"Scan Status",
 		"Scan Started",
 		"Scan Completed",
+		"Host",
+		"Hostname",
 		"Port",
 		"Protocol",
 		"State",
 		"Service",
 		"Version",
 		"Banner",
-	}
+		"Risk Score",
+		"Risk Level",
+	)
 	if err := writer.Write(header); err != nil {
 		return "", fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
-	// Write data rows
-	for rows.Next() {
-		var (
-			assetName     string
-			assetTarget   string
-			scanID        string
-			scanStatus    string
-			startedAt     time.Time
-			completedAt   *time.Time
-			port          int
-			protocol      string
-			state         string
-			service       *string
-			version       *string
-			banner        *string
-		)
-
-		err := rows.Scan(
-			&assetName,
-			&assetTarget,
-			&scanID,
-			&scanStatus,
-			&startedAt,
-			&completedAt,
-			&port,
-			&protocol,
-			&state,
-			&service,
-			&version,
-			&banner,
-		)
+	for _, asset := range assets {
+		scans, err := e.scans.ListByAsset(asset.ID)
 		if err != nil {
-			return "", fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		// Format completed time
-		completedStr := ""
-		if completedAt != nil {
-			completedStr = completedAt.Format("2006-01-02 15:04:05")
+			return "", fmt.Errorf("failed to get scans: %w", err)
 		}
 
-		// Handle nullable fields
-		serviceStr := ""
-		if service != nil {
-			serviceStr = *service
-		}
-
-		versionStr := ""
-		if version != nil {
-			versionStr = *version
-		}
-
-		bannerStr := ""
-		if banner != nil {
-			bannerStr = *banner
+		exceptions, err := e.exceptions.ActiveForAsset(asset.ID)
+		if err != nil {
+			return "", err
 		}
 
-		record := []string{
-			assetName,
-			assetTarget,
-			scanID,
-			scanStatus,
-			startedAt.Format("2006-01-02 15:04:05"),
-			completedStr,
-			strconv.Itoa(port),
-			protocol,
-			state,
-			serviceStr,
-			versionStr,
-			bannerStr,
-		}
+		for _, scan := range scans {
+			prefix := []string{asset.Name, asset.Target}
+			if includeType {
+				prefix = append(prefix, asset.AssetType)
+			}
+			prefix = append(prefix,
+				strconv.Itoa(scan.ID),
+				string(scan.Status),
+				formatTime(scan.StartedAt),
+				formatTime(scan.CompletedAt),
+			)
 
-		if err := writer.Write(record); err != nil {
-			return "", fmt.Errorf("failed to write CSV record: %w", err)
+			if err := e.writeResults(writer, scan.ID, prefix, exceptions); err != nil {
+				return "", err
+			}
 		}
 	}
 
-	if err := rows.Err(); err != nil {
-		return "", fmt.Errorf("error iterating rows: %w", err)
-	}
-
 	writer.Flush()
 	if err := writer.Error(); err != nil {
 		return "", fmt.Errorf("failed to flush CSV writer: %w", err)
@@ -164,177 +135,58 @@ func (e *CSVExporter) ExportScanResults(assetID string) (string, error) {
 	return buf.String(), nil
 }
 
-// ExportAllScans exports all scan results to CSV format
-func (e *CSVExporter) ExportAllScans() (string, error) {
-	// Get all scan results from database
-	query := `
-		SELECT 
-			a.name as asset_name,
-			a.target as asset_target,
-			a.asset_type,
-			s.id as scan_id,
-			s.status as scan_status,
-			s.started_at,
-			s.completed_at,
-			sr.port,
-			sr.protocol,
-			sr.state,
-			sr.service,
-			sr.version,
-			sr.banner
-		FROM assets a
-		JOIN scans s ON a.id = s.asset_id
-		JOIN scan_results sr ON s.id = sr.scan_id
-		ORDER BY a.name, s.started_at DESC, sr.port ASC
-	`
-
-	rows, err := e.db.Query(query)
+// writeResults writes one record per result of the scan, each starting with
+// prefix, skipping results covered by one of the asset's exceptions
+func (e *CSVExporter) writeResults(writer *csv.Writer, scanID int, prefix []string, exceptions []*risk.Exception) error {
+	results, err := e.results.ListByScan(scanID)
 	if err != nil {
-		return "", fmt.Errorf("failed to query all scan results: %w", err)
+		return fmt.Errorf("failed to query scan results: %w", err)
 	}
-	defer rows.Close()
 
-	// Create CSV buffer
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
-
-	// Write CSV header
-	header := []string{
-		"Asset Name",
-		"Asset Target",
-		"Asset Type",
-		"Scan ID",
-		"Scan Status",
-		"Scan Started",
-		"Scan Completed",
-		"Port",
-		"Protocol",
-		"State",
-		"Service",
-		"Version",
-		"Banner",
-		"Risk Level",
-	}
-	if err := writer.Write(header); err != nil {
-		return "", fmt.Errorf("failed to write CSV header: %w", err)
-	}
-
-	// Write data rows
-	for rows.Next() {
-		var (
-			assetName     string
-			assetTarget   string
-			assetType     string
-			scanID        string
-			scanStatus    string
-			startedAt     time.Time
-			completedAt   *time.Time
-			port          int
-			protocol      string
-			state         string
-			service       *string
-			version       *string
-			banner        *string
-		)
-
-		err := rows.Scan(
-			&assetName,
-			&assetTarget,
-			&assetType,
-			&scanID,
-			&scanStatus,
-			&startedAt,
-			&completedAt,
-			&port,
-			&protocol,
-			&state,
-			&service,
-			&version,
-			&banner,
-		)
-		if err != nil {
-			return "", fmt.Errorf("failed to scan row: %w", err)
+	for _, result := range results {
+		if risk.Covering(exceptions, result.Port, result.Protocol, result.Service) != nil {
+			continue
 		}
 
-		// Format completed time
-		completedStr := ""
-		if completedAt != nil {
-			completedStr = completedAt.Format("2006-01-02 15:04:05")
+		// Results are scored when their scan completes
+		riskScore := ""
+		if result.RiskScore != nil {
+			riskScore = strconv.FormatFloat(*result.RiskScore, 'f', 1, 64)
 		}
 
-		// Handle nullable fields
-		serviceStr := ""
-		if service != nil {
-			serviceStr = *service
-		}
-
-		versionStr := ""
-		if version != nil {
-			versionStr = *version
-		}
-
-		bannerStr := ""
-		if banner != nil {
-			bannerStr = *banner
-		}
-
-		// Determine risk level based on service
-		riskLevel := getRiskLevel(serviceStr)
-
-		record := []string{
-			assetName,
-			assetTarget,
-			assetType,
-			scanID,
-			scanStatus,
-			startedAt.Format("2006-01-02 15:04:05"),
-			completedStr,
-			strconv.Itoa(port),
-			protocol,
-			state,
-			serviceStr,
-			versionStr,
-			bannerStr,
-			riskLevel,
-		}
+		record := append(append([]string{}, prefix...),
+			result.Host,
+			result.Hostname,
+			strconv.Itoa(result.Port),
+			result.Protocol,
+			result.State,
+			result.Service,
+			result.Version,
+			result.Banner,
+			riskScore,
+			stringValue(result.RiskSeverity),
+		)
 
 		if err := writer.Write(record); err != nil {
-			return "", fmt.Errorf("failed to write CSV record: %w", err)
+			return fmt.Errorf("failed to write CSV record: %w", err)
 		}
 	}
 
-	if err := rows.Err(); err != nil {
-		return "", fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		return "", fmt.Errorf("failed to flush CSV writer: %w", err)
-	}
-
-	return buf.String(), nil
+	return nil
 }
 
-// getRiskLevel determines the risk level based on the service
-func getRiskLevel(service string) string {
-	if service == "" {
-		return "low"
-	}
-
-	highRiskServices := []string{"ssh", "telnet", "ftp", "smtp", "pop3", "imap"}
-	mediumRiskServices := []string{"http", "https", "dns", "snmp"}
-
-	for _, highRisk := range highRiskServices {
-		if service == highRisk {
-			return "high"
-		}
+// stringValue returns the value of a nullable column, or "" when it is NULL
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
 	}
+	return *value
+}
 
-	for _, mediumRisk := range mediumRiskServices {
-		if service == mediumRisk {
-			return "medium"
-		}
+// formatTime formats a nullable timestamp, or returns "" when it is NULL
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
 	}
-
-	return "low"
+	return t.Format("2006-01-02 15:04:05")
 }
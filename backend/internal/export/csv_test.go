@@ -0,0 +1,113 @@
+package export
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/risk"
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+// noExceptions is an ExceptionSource with no active exceptions
+type noExceptions struct{}
+
+func (noExceptions) ActiveForAsset(int) ([]*risk.Exception, error) {
+	return nil, nil
+}
+
+// newTenants stores the storetest tenants in memory, with an exporter reading them
+func newTenants(t *testing.T) (*storetest.Tenants, *CSVExporter) {
+	t.Helper()
+
+	stores := store.NewMemory()
+	return storetest.NewTenants(t, stores), NewCSVExporter(stores, noExceptions{})
+}
+
+func TestExportAllScansOnlyIncludesOrganizationAssets(t *testing.T) {
+	tt, exporter := newTenants(t)
+
+	tests := []struct {
+		name    string
+		orgID   int
+		want    []string
+		notWant []string
+	}{
+		{"alpha", tt.Alpha.ID, storetest.Data(tt.AlphaAsset), storetest.Data(tt.BetaAsset)},
+		{"beta", tt.Beta.ID, storetest.Data(tt.BetaAsset), storetest.Data(tt.AlphaAsset)},
+		{"unknown organization", 0, nil, append(storetest.Data(tt.AlphaAsset), storetest.Data(tt.BetaAsset)...)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			csv, err := exporter.ExportAllScans(test.orgID)
+			if err != nil {
+				t.Fatalf("ExportAllScans: %v", err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(csv, want) {
+					t.Errorf("export is missing %q:\n%s", want, csv)
+				}
+			}
+			for _, leaked := range test.notWant {
+				if strings.Contains(csv, leaked) {
+					t.Errorf("export leaks %q from another organization:\n%s", leaked, csv)
+				}
+			}
+		})
+	}
+}
+
+func TestExportScanResultsRequiresOrganizationAsset(t *testing.T) {
+	tt, exporter := newTenants(t)
+
+	tests := []struct {
+		name    string
+		orgID   int
+		asset   *db.Asset
+		allowed bool
+	}{
+		{"own asset", tt.Alpha.ID, tt.AlphaAsset, true},
+		{"other organization's asset", tt.Beta.ID, tt.AlphaAsset, false},
+		{"asset read from the other side", tt.Alpha.ID, tt.BetaAsset, false},
+		{"unknown organization", 0, tt.BetaAsset, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			csv, err := exporter.ExportScanResults(test.orgID, strconv.Itoa(test.asset.ID))
+			if !test.allowed {
+				if !errors.Is(err, store.ErrAssetNotFound) {
+					t.Fatalf("ExportScanResults error = %v, want %v", err, store.ErrAssetNotFound)
+				}
+				if csv != "" {
+					t.Fatalf("ExportScanResults returned data for a denied export:\n%s", csv)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ExportScanResults: %v", err)
+			}
+			for _, want := range storetest.Data(test.asset) {
+				if !strings.Contains(csv, want) {
+					t.Errorf("export is missing %q:\n%s", want, csv)
+				}
+			}
+		})
+	}
+}
+
+func TestExportScanResultsUnknownAsset(t *testing.T) {
+	tt, exporter := newTenants(t)
+
+	if _, err := exporter.ExportScanResults(tt.Alpha.ID, "999999"); !errors.Is(err, store.ErrAssetNotFound) {
+		t.Fatalf("ExportScanResults error = %v, want %v", err, store.ErrAssetNotFound)
+	}
+	if _, err := exporter.ExportScanResults(tt.Alpha.ID, "abc"); err == nil {
+		t.Fatal("ExportScanResults accepted an invalid asset ID")
+	}
+}
@@ -0,0 +1,554 @@
+package findings
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/risk"
+)
+
+// Finding statuses
+const (
+	StatusOpen          = "open"
+	StatusAcknowledged  = "acknowledged"
+	StatusInProgress    = "in_progress"
+	StatusResolved      = "resolved"
+	StatusFalsePositive = "false_positive"
+	StatusAcceptedRisk  = "accepted_risk"
+)
+
+// ValidStatus reports whether status is a known finding status
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusOpen, StatusAcknowledged, StatusInProgress, StatusResolved, StatusFalsePositive, StatusAcceptedRisk:
+		return true
+	}
+	return false
+}
+
+// OpenPortRuleID identifies findings for open ports that no risk rule matched
+const OpenPortRuleID = "open-port"
+
+// Finding is a triageable issue derived from scan results, deduplicated by
+// asset, host, port, protocol and rule
+type Finding struct {
+	ID           int        `json:"id"`
+	AssetID      int        `json:"assetId"`
+	Host         string     `json:"host"`
+	Port         int        `json:"port"`
+	Protocol     string     `json:"protocol"`
+	RuleID       string     `json:"ruleId"`
+	Title        string     `json:"title"`
+	Severity     string     `json:"severity"`
+	Remediation  string     `json:"remediation,omitempty"`
+	Service      string     `json:"service,omitempty"`
+	Version      string     `json:"version,omitempty"`
+	Status       string     `json:"status"`
+	AssigneeID   *int       `json:"assigneeId,omitempty"`
+	ScanResultID *int       `json:"scanResultId,omitempty"`
+	LastScanID   *int       `json:"lastScanId,omitempty"`
+	ExceptionID  *int       `json:"exceptionId,omitempty"`
+	FirstSeenAt  time.Time  `json:"firstSeenAt"`
+	LastSeenAt   time.Time  `json:"lastSeenAt"`
+	ResolvedAt   *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// Comment is a note left on a finding
+type Comment struct {
+	ID        int       `json:"id"`
+	FindingID int       `json:"findingId"`
+	UserID    *int      `json:"userId,omitempty"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Filter narrows the findings returned by List
+type Filter struct {
+	AssetID *int
+	Status  string
+}
+
+// AssetScorer re-scores an asset when the risks accepted on it change
+type AssetScorer interface {
+	RescoreAsset(assetID int) error
+}
+
+// Tracker derives findings from completed scans and manages their lifecycle
+type Tracker struct {
+	db     *db.DB
+	rules  risk.RuleSource
+	scorer AssetScorer
+}
+
+// NewTracker creates a new Tracker that raises findings for the given rules
+func NewTracker(database *db.DB, rules risk.RuleSource) *Tracker {
+	return &Tracker{
+		db:    database,
+		rules: rules,
+	}
+}
+
+// SetScorer sets the scorer used to re-score assets whose exceptions end
+func (t *Tracker) SetScorer(scorer AssetScorer) {
+	t.scorer = scorer
+}
+
+// observation is a finding seen in a scan
+type observation struct {
+	resultID    int
+	host        string
+	port        int
+	protocol    string
+	ruleID      string
+	title       string
+	severity    string
+	remediation string
+	service     string
+	version     string
+}
+
+// SyncScan records the findings seen by a completed scan. New findings are
+// opened, resolved findings seen again are re-opened and active findings the
+// scan no longer sees are resolved.
+func (t *Tracker) SyncScan(scanID int) error {
+	var assetID int
+	var assetType string
+	err := t.db.QueryRow(`
+		SELECT a.id, a.asset_type FROM scans s JOIN assets a ON a.id = s.asset_id WHERE s.id = $1
+	`, scanID).Scan(&assetID, &assetType)
+	if err != nil {
+		return fmt.Errorf("failed to get scanned asset: %v", err)
+	}
+
+	observations, err := t.observe(scanID, assetType)
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Findings that were resolved, manually or automatically, re-open when seen again
+	upsert, err := tx.Prepare(`
+		INSERT INTO findings (asset_id, host, port, protocol, rule_id, title, severity, remediation, service, version,
+			status, scan_result_id, last_scan_id, first_seen_at, last_seen_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13, $14, $14, $14, $14)
+		ON CONFLICT (asset_id, host, port, protocol, rule_id) DO UPDATE SET
+			title = EXCLUDED.title,
+			severity = EXCLUDED.severity,
+			remediation = EXCLUDED.remediation,
+			service = EXCLUDED.service,
+			version = EXCLUDED.version,
+			scan_result_id = EXCLUDED.scan_result_id,
+			last_scan_id = EXCLUDED.last_scan_id,
+			last_seen_at = EXCLUDED.last_seen_at,
+			status = CASE WHEN findings.status = $15 THEN $11 ELSE findings.status END,
+			resolved_at = CASE WHEN findings.status = $15 THEN NULL ELSE findings.resolved_at END,
+			updated_at = EXCLUDED.updated_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer upsert.Close()
+
+	now := time.Now()
+	for _, o := range observations {
+		_, err := upsert.Exec(assetID, o.host, o.port, o.protocol, o.ruleID, o.title, o.severity, o.remediation,
+			o.service, o.version, StatusOpen, o.resultID, scanID, now, StatusResolved)
+		if err != nil {
+			return fmt.Errorf("failed to store finding: %v", err)
+		}
+	}
+
+	// Only findings still awaiting remediation are resolved automatically;
+	// false positives and accepted risks keep their status
+	_, err = tx.Exec(`
+		UPDATE findings SET status = $1, resolved_at = $2, updated_at = $2
+		WHERE asset_id = $3 AND (last_scan_id IS NULL OR last_scan_id <> $4) AND status IN ($5, $6, $7)
+	`, StatusResolved, now, assetID, scanID, StatusOpen, StatusAcknowledged, StatusInProgress)
+	if err != nil {
+		return fmt.Errorf("failed to resolve missing findings: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// New findings may already be covered by an exception
+	_, err = t.ApplyExceptions(assetID)
+	return err
+}
+
+// ApplyExceptions marks the asset's active findings that an active exception
+// covers as accepted risks, returning how many findings changed
+func (t *Tracker) ApplyExceptions(assetID int) (int, error) {
+	result, err := t.db.Exec(`
+		UPDATE findings AS f SET status = $1, exception_id = x.id, updated_at = $2
+		FROM assets a, risk_exceptions x
+		WHERE f.asset_id = $3 AND a.id = f.asset_id
+			AND f.status IN ($4, $5, $6)
+			AND (x.asset_id = a.id OR x.group_id = a.group_id)
+			AND x.port = f.port
+			AND (x.protocol IS NULL OR x.protocol = f.protocol)
+			AND (x.service IS NULL OR LOWER(x.service) = LOWER(COALESCE(f.service, '')))
+			AND x.revoked_at IS NULL AND x.expires_at > NOW()
+	`, StatusAcceptedRisk, time.Now(), assetID, StatusOpen, StatusAcknowledged, StatusInProgress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply exceptions: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return int(affected), nil
+}
+
+// ReopenExpired re-opens findings accepted by exceptions that have expired or
+// been revoked, unless another active exception still covers them. It returns
+// the assets whose findings were re-opened.
+func (t *Tracker) ReopenExpired() ([]int, error) {
+	rows, err := t.db.Query(`
+		UPDATE findings SET status = $1, exception_id = NULL, updated_at = $2
+		WHERE status = $3 AND exception_id IN (
+			SELECT id FROM risk_exceptions WHERE revoked_at IS NOT NULL OR expires_at <= NOW()
+		)
+		RETURNING asset_id
+	`, StatusOpen, time.Now(), StatusAcceptedRisk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen findings: %v", err)
+	}
+
+	seen := make(map[int]bool)
+	var assetIDs []int
+	for rows.Next() {
+		var assetID int
+		if err := rows.Scan(&assetID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if !seen[assetID] {
+			seen[assetID] = true
+			assetIDs = append(assetIDs, assetID)
+		}
+	}
+	rows.Close()
+
+	for _, assetID := range assetIDs {
+		if _, err := t.ApplyExceptions(assetID); err != nil {
+			return nil, err
+		}
+	}
+
+	return assetIDs, nil
+}
+
+// ExpireExceptions re-opens findings whose exceptions have ended and re-scores
+// the affected assets
+func (t *Tracker) ExpireExceptions() error {
+	assetIDs, err := t.ReopenExpired()
+	if err != nil {
+		return err
+	}
+
+	if t.scorer != nil {
+		for _, assetID := range assetIDs {
+			if err := t.scorer.RescoreAsset(assetID); err != nil {
+				return fmt.Errorf("failed to score asset %d: %v", assetID, err)
+			}
+		}
+	}
+
+	if len(assetIDs) > 0 {
+		log.Printf("Re-opened findings on %d assets after their exceptions ended", len(assetIDs))
+	}
+	return nil
+}
+
+// Start checks for ended exceptions every interval until ctx is cancelled
+func (t *Tracker) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := t.ExpireExceptions(); err != nil {
+				log.Printf("Failed to expire risk exceptions: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	log.Printf("Risk exception expiry started (checking every %v)", interval)
+}
+
+// observe evaluates the risk rules against every result of a scan. Open ports
+// that no rule matched are reported as generic open-port findings.
+func (t *Tracker) observe(scanID int, assetType string) ([]observation, error) {
+	rows, err := t.db.Query(`
+		SELECT id, COALESCE(host, ''), port, protocol, state, COALESCE(service, ''), COALESCE(version, ''),
+			COALESCE(banner, ''), COALESCE(risk_severity, $2)
+		FROM scan_results
+		WHERE scan_id = $1
+		ORDER BY id
+	`, scanID, risk.SeverityInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan results: %v", err)
+	}
+	defer rows.Close()
+
+	var rules *risk.RuleSet
+	if t.rules != nil {
+		rules = t.rules.Rules()
+	}
+
+	var observations []observation
+	for rows.Next() {
+		var resultID int
+		var host, severity string
+		input := &risk.Input{AssetType: assetType}
+		err := rows.Scan(&resultID, &host, &input.Port, &input.Protocol, &input.State, &input.Service,
+			&input.Version, &input.Banner, &severity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		base := observation{
+			resultID: resultID,
+			host:     host,
+			port:     input.Port,
+			protocol: input.Protocol,
+			service:  input.Service,
+			version:  input.Version,
+		}
+
+		matched := rules.Match(input)
+		for _, rule := range matched {
+			o := base
+			o.ruleID = rule.ID
+			o.title = rule.Title
+			o.severity = string(rule.Severity)
+			o.remediation = rule.Remediation
+			observations = append(observations, o)
+		}
+
+		if len(matched) == 0 {
+			o := base
+			o.ruleID = OpenPortRuleID
+			o.title = openPortTitle(input)
+			o.severity = severity
+			observations = append(observations, o)
+		}
+	}
+
+	return observations, rows.Err()
+}
+
+// openPortTitle describes an open port without a matching rule
+func openPortTitle(in *risk.Input) string {
+	if in.Service != "" {
+		return fmt.Sprintf("Open port %d/%s (%s)", in.Port, in.Protocol, in.Service)
+	}
+	return fmt.Sprintf("Open port %d/%s", in.Port, in.Protocol)
+}
+
+const findingColumns = `f.id, f.asset_id, f.host, f.port, f.protocol, f.rule_id, f.title, f.severity,
+	COALESCE(f.remediation, ''), COALESCE(f.service, ''), COALESCE(f.version, ''), f.status, f.assignee_id,
+	f.scan_result_id, f.last_scan_id, f.exception_id, f.first_seen_at, f.last_seen_at, f.resolved_at, f.created_at, f.updated_at`
+
+// scanFinding scans a findings row selected with findingColumns
+func scanFinding(row interface{ Scan(...any) error }) (*Finding, error) {
+	var f Finding
+	err := row.Scan(
+		&f.ID,
+		&f.AssetID,
+		&f.Host,
+		&f.Port,
+		&f.Protocol,
+		&f.RuleID,
+		&f.Title,
+		&f.Severity,
+		&f.Remediation,
+		&f.Service,
+		&f.Version,
+		&f.Status,
+		&f.AssigneeID,
+		&f.ScanResultID,
+		&f.LastScanID,
+		&f.ExceptionID,
+		&f.FirstSeenAt,
+		&f.LastSeenAt,
+		&f.ResolvedAt,
+		&f.CreatedAt,
+		&f.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Get retrieves a finding on one of the organization's assets
+func (t *Tracker) Get(organizationID, findingID int) (*Finding, error) {
+	query := `SELECT ` + findingColumns + `
+		FROM findings f JOIN assets a ON a.id = f.asset_id
+		WHERE f.id = $1 AND a.organization_id = $2`
+
+	finding, err := scanFinding(t.db.QueryRow(query, findingID, organizationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("finding not found")
+		}
+		return nil, fmt.Errorf("failed to get finding: %v", err)
+	}
+
+	return finding, nil
+}
+
+// List retrieves the findings on the organization's assets, most recently seen first
+func (t *Tracker) List(organizationID int, filter Filter) ([]*Finding, error) {
+	query := `SELECT ` + findingColumns + `
+		FROM findings f JOIN assets a ON a.id = f.asset_id
+		WHERE a.organization_id = $1
+			AND ($2::INTEGER IS NULL OR f.asset_id = $2)
+			AND ($3 = '' OR f.status = $3)
+		ORDER BY f.last_seen_at DESC, f.id DESC`
+
+	rows, err := t.db.Query(query, organizationID, filter.AssetID, filter.Status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get findings: %v", err)
+	}
+	defer rows.Close()
+
+	var findings []*Finding
+	for rows.Next() {
+		finding, err := scanFinding(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		findings = append(findings, finding)
+	}
+
+	return findings, rows.Err()
+}
+
+// SetStatus changes the status of one of the organization's findings
+func (t *Tracker) SetStatus(organizationID, findingID int, status string) (*Finding, error) {
+	if !ValidStatus(status) {
+		return nil, fmt.Errorf("invalid finding status %q", status)
+	}
+
+	if _, err := t.Get(organizationID, findingID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var resolvedAt *time.Time
+	if status == StatusResolved {
+		resolvedAt = &now
+	}
+
+	// Only an exception keeps a finding linked to it; a manual change ends the link
+	_, err := t.db.Exec(`
+		UPDATE findings SET status = $1, resolved_at = $2, exception_id = NULL, updated_at = $3 WHERE id = $4
+	`, status, resolvedAt, now, findingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update finding: %v", err)
+	}
+
+	return t.Get(organizationID, findingID)
+}
+
+// Assign sets or clears the assignee of one of the organization's findings.
+// Assignees must be members of the organization.
+func (t *Tracker) Assign(organizationID, findingID int, assigneeID *int) (*Finding, error) {
+	if _, err := t.Get(organizationID, findingID); err != nil {
+		return nil, err
+	}
+
+	if assigneeID != nil {
+		var exists bool
+		err := t.db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM organization_members WHERE organization_id = $1 AND user_id = $2)
+		`, organizationID, *assigneeID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get assignee: %v", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("assignee not found")
+		}
+	}
+
+	_, err := t.db.Exec(`UPDATE findings SET assignee_id = $1, updated_at = $2 WHERE id = $3`, assigneeID, time.Now(), findingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign finding: %v", err)
+	}
+
+	return t.Get(organizationID, findingID)
+}
+
+// AddComment adds a comment by the user to one of the organization's findings
+func (t *Tracker) AddComment(organizationID, userID, findingID int, body string) (*Comment, error) {
+	if body == "" {
+		return nil, fmt.Errorf("comment cannot be empty")
+	}
+
+	if _, err := t.Get(organizationID, findingID); err != nil {
+		return nil, err
+	}
+
+	comment := Comment{FindingID: findingID, UserID: &userID, Body: body}
+	err := t.db.QueryRow(`
+		INSERT INTO finding_comments (finding_id, user_id, body, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, findingID, userID, body, time.Now()).Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add comment: %v", err)
+	}
+
+	return &comment, nil
+}
+
+// Comments retrieves the comments on a finding, oldest first
+func (t *Tracker) Comments(findingID int) ([]*Comment, error) {
+	rows, err := t.db.Query(`
+		SELECT id, finding_id, user_id, body, created_at
+		FROM finding_comments
+		WHERE finding_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, findingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %v", err)
+	}
+	defer rows.Close()
+
+	var comments []*Comment
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.FindingID, &c.UserID, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		comments = append(comments, &c)
+	}
+
+	return comments, rows.Err()
+}
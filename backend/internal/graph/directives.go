@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"cyber-risk-monitor/internal/auth"
+)
+
+// HasRole implements the @hasRole directive from the caller's current role in
+// the organization their token is scoped to, so a demoted or removed member
+// loses access before their token expires
+func (r *Resolver) HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, roles []string) (interface{}, error) {
+	if _, err := r.getAuthorizedUser(ctx, roles...); err != nil {
+		return nil, err
+	}
+
+	return next(ctx)
+}
+
+// checkRole returns the error HasRole reports when the user has none of roles
+func checkRole(user *auth.Claims, roles []string) error {
+	if !user.HasRole(roles...) {
+		return fmt.Errorf("access denied: requires the %s role", strings.Join(roles, " or "))
+	}
+	return nil
+}
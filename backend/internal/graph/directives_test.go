@@ -0,0 +1,225 @@
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cyber-risk-monitor/internal/auth"
+	"cyber-risk-monitor/internal/store"
+)
+
+// userContext returns a request context authenticated as user, or an
+// unauthenticated one when user is nil
+func userContext(user *auth.Claims) context.Context {
+	ctx := context.Background()
+	if user != nil {
+		ctx = context.WithValue(ctx, auth.UserContextKey, user)
+	}
+	return ctx
+}
+
+func TestHasRole(t *testing.T) {
+	rm := newRoleMembers(t)
+
+	tests := []struct {
+		name    string
+		role    string
+		roles   []string
+		allowed bool
+	}{
+		{"admin passes an admin check", auth.RoleAdmin, []string{auth.RoleAdmin}, true},
+		{"admin passes an analyst check", auth.RoleAdmin, []string{auth.RoleAnalyst}, true},
+		{"admin passes an export check", auth.RoleAdmin, []string{auth.RoleAnalyst, auth.RoleAuditor}, true},
+		{"analyst passes an analyst check", auth.RoleAnalyst, []string{auth.RoleAnalyst}, true},
+		{"auditor passes an export check", auth.RoleAuditor, []string{auth.RoleAnalyst, auth.RoleAuditor}, true},
+		{"analyst is denied an admin check", auth.RoleAnalyst, []string{auth.RoleAdmin}, false},
+		{"auditor is denied an analyst check", auth.RoleAuditor, []string{auth.RoleAnalyst}, false},
+		{"viewer is denied an export check", auth.RoleViewer, []string{auth.RoleAnalyst, auth.RoleAuditor}, false},
+		{"viewer is denied an admin check", auth.RoleViewer, []string{auth.RoleAdmin}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			called := false
+			next := func(ctx context.Context) (interface{}, error) {
+				called = true
+				return "ok", nil
+			}
+
+			res, err := rm.resolver.HasRole(userContext(rm.claims[test.role]), nil, next, test.roles)
+			if test.allowed {
+				if err != nil || res != "ok" {
+					t.Fatalf("HasRole = %v, %v; want the field resolved", res, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "access denied") {
+				t.Fatalf("HasRole error = %v, want access denied", err)
+			}
+			if called {
+				t.Fatal("HasRole resolved a field the role may not access")
+			}
+		})
+	}
+
+	t.Run("role in the token is ignored", func(t *testing.T) {
+		next := func(ctx context.Context) (interface{}, error) {
+			t.Fatal("HasRole resolved a field from the role in the token")
+			return nil, nil
+		}
+		forged := *rm.claims[auth.RoleViewer]
+		forged.Role = auth.RoleAdmin
+		if _, err := rm.resolver.HasRole(userContext(&forged), nil, next, []string{auth.RoleAdmin}); err == nil {
+			t.Fatal("HasRole allowed a viewer whose token claims admin")
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		next := func(ctx context.Context) (interface{}, error) {
+			t.Fatal("HasRole resolved a field for an unauthenticated request")
+			return nil, nil
+		}
+		if _, err := rm.resolver.HasRole(userContext(nil), nil, next, []string{auth.RoleViewer}); err == nil {
+			t.Fatal("HasRole allowed an unauthenticated request")
+		}
+	})
+}
+
+// roleMembers is an organization with one member of each role
+type roleMembers struct {
+	resolver *Resolver
+	stores   *store.Store
+	claims   map[string]*auth.Claims
+}
+
+func newRoleMembers(t *testing.T) *roleMembers {
+	t.Helper()
+
+	stores := store.NewMemory()
+	rm := &roleMembers{
+		resolver: &Resolver{Store: stores},
+		stores:   stores,
+		claims:   make(map[string]*auth.Claims),
+	}
+
+	var orgID int
+	for _, role := range auth.Roles {
+		email := role + "@example.com"
+		user, err := stores.Users.Create(email, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+
+		// The admin creates the organization and the others are added to it
+		if role == auth.RoleAdmin {
+			org, err := stores.Organizations.Create("Roles", user.ID)
+			if err != nil {
+				t.Fatalf("create organization: %v", err)
+			}
+			orgID = org.ID
+		} else if _, err := stores.Organizations.AddMember(orgID, user.ID, role); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+
+		rm.claims[role] = &auth.Claims{UserID: user.ID, Email: email, OrgID: orgID, Role: role}
+	}
+	return rm
+}
+
+func TestAdminMutationsRequireAdminRole(t *testing.T) {
+	rm := newRoleMembers(t)
+	if _, err := rm.stores.Users.Create("new@example.com", "hash"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	viewerID := strconv.Itoa(rm.claims[auth.RoleViewer].UserID)
+	mutation := rm.resolver.Mutation()
+	query := rm.resolver.Query()
+
+	calls := map[string]func(ctx context.Context) error{
+		"users": func(ctx context.Context) error {
+			_, err := query.Users(ctx)
+			return err
+		},
+		"setUserRole": func(ctx context.Context) error {
+			_, err := mutation.SetUserRole(ctx, viewerID, auth.RoleAdmin)
+			return err
+		},
+		"addOrganizationMember": func(ctx context.Context) error {
+			_, err := mutation.AddOrganizationMember(ctx, "new@example.com", auth.RoleAdmin)
+			return err
+		},
+		"removeOrganizationMember": func(ctx context.Context) error {
+			_, err := mutation.RemoveOrganizationMember(ctx, viewerID)
+			return err
+		},
+		"reloadRiskRules": func(ctx context.Context) error {
+			_, err := mutation.ReloadRiskRules(ctx)
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		for _, role := range []string{auth.RoleAnalyst, auth.RoleAuditor, auth.RoleViewer} {
+			t.Run(name+" as "+role, func(t *testing.T) {
+				err := call(userContext(rm.claims[role]))
+				if err == nil || !strings.Contains(err.Error(), "access denied") {
+					t.Fatalf("%s error = %v, want access denied", name, err)
+				}
+			})
+		}
+	}
+
+	// Nothing was changed by the denied calls
+	member, err := rm.stores.Organizations.Member(rm.claims[auth.RoleViewer].OrgID, rm.claims[auth.RoleViewer].UserID)
+	if err != nil {
+		t.Fatalf("viewer was removed by a denied call: %v", err)
+	}
+	if member.Role != auth.RoleViewer {
+		t.Fatalf("viewer's role = %q after denied calls, want %q", member.Role, auth.RoleViewer)
+	}
+}
+
+func TestAdminManagesMembers(t *testing.T) {
+	rm := newRoleMembers(t)
+	if _, err := rm.stores.Users.Create("new@example.com", "hash"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	ctx := userContext(rm.claims[auth.RoleAdmin])
+	mutation := rm.resolver.Mutation()
+
+	users, err := rm.resolver.Query().Users(ctx)
+	if err != nil {
+		t.Fatalf("users: %v", err)
+	}
+	if len(users) != len(auth.Roles) {
+		t.Fatalf("users returned %d members, want %d", len(users), len(auth.Roles))
+	}
+
+	user, err := mutation.SetUserRole(ctx, strconv.Itoa(rm.claims[auth.RoleViewer].UserID), auth.RoleAuditor)
+	if err != nil {
+		t.Fatalf("setUserRole: %v", err)
+	}
+	if user.Role == nil || *user.Role != auth.RoleAuditor {
+		t.Fatalf("setUserRole role = %v, want %q", user.Role, auth.RoleAuditor)
+	}
+
+	if _, err := mutation.AddOrganizationMember(ctx, "new@example.com", auth.RoleViewer); err != nil {
+		t.Fatalf("addOrganizationMember: %v", err)
+	}
+
+	removed, err := mutation.RemoveOrganizationMember(ctx, strconv.Itoa(rm.claims[auth.RoleAnalyst].UserID))
+	if err != nil || !removed {
+		t.Fatalf("removeOrganizationMember = %v, %v; want the analyst removed", removed, err)
+	}
+
+	// Another admin has to demote or remove an admin
+	if _, err := mutation.SetUserRole(ctx, strconv.Itoa(rm.claims[auth.RoleAdmin].UserID), auth.RoleViewer); err == nil {
+		t.Fatal("setUserRole let an admin change their own role")
+	}
+	if _, err := mutation.RemoveOrganizationMember(ctx, strconv.Itoa(rm.claims[auth.RoleAdmin].UserID)); err == nil {
+		t.Fatal("removeOrganizationMember let an admin remove themselves")
+	}
+}
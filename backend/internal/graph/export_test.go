@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cyber-risk-monitor/internal/auth"
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/risk"
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+// exportTenants is a resolver over a fresh SQLite database holding the
+// storetest tenants, with a token for each of them
+type exportTenants struct {
+	*storetest.Tenants
+	resolver *Resolver
+	stores   *store.Store
+	alice    *auth.Claims
+	bob      *auth.Claims
+	carol    *auth.Claims
+	victor   *auth.Claims
+}
+
+func newExportTenants(t *testing.T) *exportTenants {
+	t.Helper()
+
+	database := storetest.NewSQLite(t)
+	stores := store.NewSQL(database)
+	tt := storetest.NewTenants(t, stores)
+
+	return &exportTenants{
+		Tenants:  tt,
+		resolver: &Resolver{DB: database, Store: stores, Exceptions: risk.NewExceptions(database)},
+		stores:   stores,
+		alice:    claims(tt.Alice, tt.Alpha, auth.RoleAdmin),
+		bob:      claims(tt.Bob, tt.Beta, auth.RoleAdmin),
+		carol:    claims(tt.Carol, tt.Alpha, auth.RoleAuditor),
+		victor:   claims(tt.Victor, tt.Alpha, auth.RoleViewer),
+	}
+}
+
+// claims are the claims of a token issued to user for working in org with role
+func claims(user *db.User, org *db.Organization, role string) *auth.Claims {
+	return &auth.Claims{UserID: user.ID, Email: user.Email, OrgID: org.ID, Role: role}
+}
+
+// export calls the exportScans mutation as user, or unauthenticated when user is nil
+func (tt *exportTenants) export(user *auth.Claims, asset *db.Asset) (string, error) {
+	var assetID *string
+	if asset != nil {
+		id := strconv.Itoa(asset.ID)
+		assetID = &id
+	}
+	return tt.resolver.Mutation().ExportScans(userContext(user), assetID)
+}
+
+func TestExportScansIsolatesOrganizations(t *testing.T) {
+	tt := newExportTenants(t)
+
+	alphaData := storetest.Data(tt.AlphaAsset)
+	betaData := storetest.Data(tt.BetaAsset)
+
+	tests := []struct {
+		name    string
+		user    *auth.Claims
+		asset   *db.Asset
+		want    []string
+		notWant []string
+	}{
+		{"owner exports all scans", tt.alice, nil, alphaData, betaData},
+		{"owner exports own asset", tt.alice, tt.AlphaAsset, alphaData, betaData},
+		{"auditor exports all scans", tt.carol, nil, alphaData, betaData},
+		{"auditor exports organization asset", tt.carol, tt.AlphaAsset, alphaData, betaData},
+		{"other tenant exports all scans", tt.bob, nil, betaData, alphaData},
+		{"other tenant exports own asset", tt.bob, tt.BetaAsset, betaData, alphaData},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			csv, err := tt.export(test.user, test.asset)
+			if err != nil {
+				t.Fatalf("exportScans: %v", err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(csv, want) {
+					t.Errorf("export is missing %q:\n%s", want, csv)
+				}
+			}
+			for _, leaked := range test.notWant {
+				if strings.Contains(csv, leaked) {
+					t.Errorf("export leaks %q from another organization:\n%s", leaked, csv)
+				}
+			}
+		})
+	}
+}
+
+func TestExportScansDeniesOtherTenants(t *testing.T) {
+	tt := newExportTenants(t)
+
+	// Carol's token is still scoped to Alpha after she leaves it
+	if _, err := tt.stores.Organizations.RemoveMember(tt.Alpha.ID, tt.Carol.ID); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	// Tokens issued before organizations existed carry none
+	unscoped := &auth.Claims{UserID: tt.Alice.ID, Email: tt.Alice.Email}
+
+	accessDenied := errors.New("access denied")
+	tests := []struct {
+		name    string
+		user    *auth.Claims
+		asset   *db.Asset
+		wantErr error
+	}{
+		{"other tenant's asset", tt.bob, tt.AlphaAsset, store.ErrAssetNotFound},
+		{"other tenant's asset read from the other side", tt.alice, tt.BetaAsset, store.ErrAssetNotFound},
+		{"viewer exports all scans", tt.victor, nil, accessDenied},
+		{"viewer exports organization asset", tt.victor, tt.AlphaAsset, accessDenied},
+		{"removed auditor exports all scans", tt.carol, nil, store.ErrMemberNotFound},
+		{"removed auditor exports organization asset", tt.carol, tt.AlphaAsset, store.ErrMemberNotFound},
+		{"token without organization or role", unscoped, nil, store.ErrMemberNotFound},
+		{"unauthenticated", nil, nil, nil},
+		{"unauthenticated asset export", nil, tt.AlphaAsset, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			csv, err := tt.export(test.user, test.asset)
+			if err == nil {
+				t.Fatalf("exportScans succeeded, want an error:\n%s", csv)
+			}
+			switch {
+			case test.wantErr == accessDenied:
+				if !strings.Contains(err.Error(), "access denied") {
+					t.Fatalf("exportScans error = %v, want access denied", err)
+				}
+			case test.wantErr != nil && !errors.Is(err, test.wantErr):
+				t.Fatalf("exportScans error = %v, want %v", err, test.wantErr)
+			}
+			if csv != "" {
+				t.Fatalf("exportScans returned data for a denied export:\n%s", csv)
+			}
+		})
+	}
+}
@@ -27,63 +27,325 @@ type Config struct {
 
 type ResolverRoot interface {
 	Asset() AssetResolver
+	AssetGroup() AssetGroupResolver
+	Finding() FindingResolver
+	FindingComment() FindingCommentResolver
 	Mutation() MutationResolver
+	Organization() OrganizationResolver
 	Query() QueryResolver
+	RiskException() RiskExceptionResolver
 	Scan() ScanResolver
+	ScanDiff() ScanDiffResolver
+	ScanResult() ScanResultResolver
+	ScanSchedule() ScanScheduleResolver
+	Subscription() SubscriptionResolver
 }
 
-type DirectiveRoot struct{}
+type DirectiveRoot struct {
+	HasRole func(ctx context.Context, obj interface{}, next graphql.Resolver, roles []string) (res interface{}, err error)
+}
 
 type ComplexityRoot struct {
 	Asset struct {
-		CreatedAt     func(childComplexity int) int
-		ID            func(childComplexity int) int
-		LastScannedAt func(childComplexity int) int
-		Name          func(childComplexity int) int
-		Scans         func(childComplexity int) int
-		Target        func(childComplexity int) int
-		AssetType     func(childComplexity int) int
+		CreatedAt       func(childComplexity int) int
+		ID              func(childComplexity int) int
+		LastScannedAt   func(childComplexity int) int
+		Name            func(childComplexity int) int
+		Scans           func(childComplexity int, first *int, after *string, last *int, before *string, filter *model.ScanFilter, orderBy *model.ScanOrder) int
+		Target          func(childComplexity int) int
+		AssetType       func(childComplexity int) int
+		ScanEngine      func(childComplexity int) int
+		ScanProfile     func(childComplexity int) int
+		Group           func(childComplexity int) int
+		Criticality     func(childComplexity int) int
+		InternetFacing  func(childComplexity int) int
+		Risk            func(childComplexity int) int
+		Vulnerabilities func(childComplexity int) int
+	}
+
+	AssetConnection struct {
+		Edges      func(childComplexity int) int
+		PageInfo   func(childComplexity int) int
+		TotalCount func(childComplexity int) int
+	}
+
+	AssetEdge struct {
+		Cursor func(childComplexity int) int
+		Node   func(childComplexity int) int
+	}
+
+	AssetGroup struct {
+		Assets    func(childComplexity int) int
+		CreatedAt func(childComplexity int) int
+		ID        func(childComplexity int) int
+		Name      func(childComplexity int) int
 	}
 
 	AuthPayload struct {
-		Token func(childComplexity int) int
-		User  func(childComplexity int) int
+		Organization func(childComplexity int) int
+		Token        func(childComplexity int) int
+		User         func(childComplexity int) int
+	}
+
+	Finding struct {
+		Asset       func(childComplexity int) int
+		Assignee    func(childComplexity int) int
+		Comments    func(childComplexity int) int
+		Exception   func(childComplexity int) int
+		FirstSeenAt func(childComplexity int) int
+		Host        func(childComplexity int) int
+		ID          func(childComplexity int) int
+		LastSeenAt  func(childComplexity int) int
+		Port        func(childComplexity int) int
+		Protocol    func(childComplexity int) int
+		Remediation func(childComplexity int) int
+		ResolvedAt  func(childComplexity int) int
+		RuleID      func(childComplexity int) int
+		Service     func(childComplexity int) int
+		Severity    func(childComplexity int) int
+		Status      func(childComplexity int) int
+		Title       func(childComplexity int) int
+		UpdatedAt   func(childComplexity int) int
+		Version     func(childComplexity int) int
+	}
+
+	FindingComment struct {
+		Author    func(childComplexity int) int
+		Body      func(childComplexity int) int
+		CreatedAt func(childComplexity int) int
+		ID        func(childComplexity int) int
 	}
 
 	Mutation struct {
-		CreateAsset func(childComplexity int, input model.CreateAssetInput) int
-		DeleteAsset func(childComplexity int, id string) int
-		Login       func(childComplexity int, input model.LoginInput) int
-		Register    func(childComplexity int, input model.RegisterInput) int
-		StartScan   func(childComplexity int, assetID string) int
+		CreateAsset              func(childComplexity int, input model.CreateAssetInput) int
+		DeleteAsset              func(childComplexity int, id string) int
+		Login                    func(childComplexity int, input model.LoginInput) int
+		Register                 func(childComplexity int, input model.RegisterInput) int
+		StartScan                func(childComplexity int, assetID string, engine *string, profileID *string) int
+		CancelScan               func(childComplexity int, id string) int
+		ExportScans              func(childComplexity int, assetID *string) int
+		CreateScanProfile        func(childComplexity int, input model.ScanProfileInput) int
+		UpdateScanProfile        func(childComplexity int, id string, input model.ScanProfileInput) int
+		DeleteScanProfile        func(childComplexity int, id string) int
+		SetAssetScanProfile      func(childComplexity int, assetID string, profileID *string) int
+		CreateAssetGroup         func(childComplexity int, name string) int
+		DeleteAssetGroup         func(childComplexity int, id string) int
+		SetAssetGroup            func(childComplexity int, assetID string, groupID *string) int
+		CreateScanSchedule       func(childComplexity int, input model.ScanScheduleInput) int
+		UpdateScanSchedule       func(childComplexity int, id string, input model.ScanScheduleInput) int
+		DeleteScanSchedule       func(childComplexity int, id string) int
+		SetAssetRiskContext      func(childComplexity int, assetID string, criticality *string, internetFacing *bool) int
+		ReloadRiskRules          func(childComplexity int) int
+		SetFindingStatus         func(childComplexity int, id string, status string, comment *string) int
+		AssignFinding            func(childComplexity int, id string, assigneeID *string) int
+		AddFindingComment        func(childComplexity int, findingID string, body string) int
+		CreateRiskException      func(childComplexity int, input model.RiskExceptionInput) int
+		RevokeRiskException      func(childComplexity int, id string) int
+		CreateOrganization       func(childComplexity int, name string) int
+		SwitchOrganization       func(childComplexity int, organizationID string) int
+		AddOrganizationMember    func(childComplexity int, email string, role string) int
+		RemoveOrganizationMember func(childComplexity int, userID string) int
+		SetUserRole              func(childComplexity int, userID string, role string) int
+	}
+
+	Organization struct {
+		CreatedAt func(childComplexity int) int
+		ID        func(childComplexity int) int
+		Name      func(childComplexity int) int
+		Risk      func(childComplexity int) int
+		Role      func(childComplexity int) int
 	}
 
 	Query struct {
-		Asset  func(childComplexity int, id string) int
-		Assets func(childComplexity int) int
-		Me     func(childComplexity int) int
-		Scan   func(childComplexity int, id string) int
-		Scans  func(childComplexity int, assetID *string) int
+		Asset          func(childComplexity int, id string) int
+		Assets         func(childComplexity int, first *int, after *string, last *int, before *string, filter *model.AssetFilter, orderBy *model.AssetOrder) int
+		Me             func(childComplexity int) int
+		Scan           func(childComplexity int, id string) int
+		Scans          func(childComplexity int, assetID *string, first *int, after *string, last *int, before *string, filter *model.ScanFilter, orderBy *model.ScanOrder) int
+		ScanEngines    func(childComplexity int) int
+		ScanProfiles   func(childComplexity int) int
+		ScanProfile    func(childComplexity int, id string) int
+		AssetGroups    func(childComplexity int) int
+		ScanSchedules  func(childComplexity int) int
+		ScanSchedule   func(childComplexity int, id string) int
+		ScanDiff       func(childComplexity int, baseScanID string, targetScanID string) int
+		RiskRules      func(childComplexity int) int
+		TestRiskRules  func(childComplexity int, scanResultID string, rules *string) int
+		Findings       func(childComplexity int, assetID *string, status *string) int
+		Finding        func(childComplexity int, id string) int
+		RiskExceptions func(childComplexity int, includeInactive *bool) int
+		Organizations  func(childComplexity int) int
+		Organization   func(childComplexity int) int
+		Users          func(childComplexity int) int
 	}
 
 	Scan struct {
-		Asset        func(childComplexity int) int
-		CompletedAt  func(childComplexity int) int
-		ErrorMessage func(childComplexity int) int
-		ID           func(childComplexity int) int
-		Results      func(childComplexity int) int
-		StartedAt    func(childComplexity int) int
-		Status       func(childComplexity int) int
+		Asset               func(childComplexity int) int
+		CompletedAt         func(childComplexity int) int
+		Diff                func(childComplexity int) int
+		DurationMs          func(childComplexity int) int
+		Engine              func(childComplexity int) int
+		ErrorCode           func(childComplexity int) int
+		ErrorMessage        func(childComplexity int) int
+		EstimatedCompletion func(childComplexity int) int
+		Hosts               func(childComplexity int) int
+		ID                  func(childComplexity int) int
+		Profile             func(childComplexity int) int
+		Progress            func(childComplexity int) int
+		QueuedAt            func(childComplexity int) int
+		Results             func(childComplexity int) int
+		StartedAt           func(childComplexity int) int
+		Status              func(childComplexity int) int
+	}
+
+	ScanConnection struct {
+		Edges      func(childComplexity int) int
+		PageInfo   func(childComplexity int) int
+		TotalCount func(childComplexity int) int
+	}
+
+	ScanEdge struct {
+		Cursor func(childComplexity int) int
+		Node   func(childComplexity int) int
+	}
+
+	ScanProfile struct {
+		BuiltIn          func(childComplexity int) int
+		CreatedAt        func(childComplexity int) int
+		Description      func(childComplexity int) int
+		ID               func(childComplexity int) int
+		Name             func(childComplexity int) int
+		PortSpec         func(childComplexity int) int
+		ScanType         func(childComplexity int) int
+		TimingTemplate   func(childComplexity int) int
+		UpdatedAt        func(childComplexity int) int
+		VersionIntensity func(childComplexity int) int
+	}
+
+	ScanDiff struct {
+		BaseScan   func(childComplexity int) int
+		Changed    func(childComplexity int) int
+		Closed     func(childComplexity int) int
+		CreatedAt  func(childComplexity int) int
+		Opened     func(childComplexity int) int
+		TargetScan func(childComplexity int) int
+	}
+
+	ScanDiffEntry struct {
+		Host            func(childComplexity int) int
+		Hostname        func(childComplexity int) int
+		Port            func(childComplexity int) int
+		PreviousService func(childComplexity int) int
+		PreviousVersion func(childComplexity int) int
+		Protocol        func(childComplexity int) int
+		Service         func(childComplexity int) int
+		Version         func(childComplexity int) int
+	}
+
+	ScanSchedule struct {
+		Asset     func(childComplexity int) int
+		CreatedAt func(childComplexity int) int
+		Cron      func(childComplexity int) int
+		Enabled   func(childComplexity int) int
+		Engine    func(childComplexity int) int
+		Group     func(childComplexity int) int
+		ID        func(childComplexity int) int
+		LastRunAt func(childComplexity int) int
+		Name      func(childComplexity int) int
+		NextRunAt func(childComplexity int) int
+		Profile   func(childComplexity int) int
+		UpdatedAt func(childComplexity int) int
+	}
+
+	ScanHost struct {
+		Address  func(childComplexity int) int
+		Hostname func(childComplexity int) int
+		Results  func(childComplexity int) int
 	}
 
 	ScanResult struct {
-		Banner   func(childComplexity int) int
-		ID       func(childComplexity int) int
-		Port     func(childComplexity int) int
-		Protocol func(childComplexity int) int
-		Service  func(childComplexity int) int
-		State    func(childComplexity int) int
-		Version  func(childComplexity int) int
+		Banner          func(childComplexity int) int
+		Cpes            func(childComplexity int) int
+		Vulnerabilities func(childComplexity int) int
+		Host            func(childComplexity int) int
+		Hostname        func(childComplexity int) int
+		ID              func(childComplexity int) int
+		Port            func(childComplexity int) int
+		Protocol        func(childComplexity int) int
+		Risk            func(childComplexity int) int
+		Service         func(childComplexity int) int
+		State           func(childComplexity int) int
+		Version         func(childComplexity int) int
+	}
+
+	PageInfo struct {
+		EndCursor       func(childComplexity int) int
+		HasNextPage     func(childComplexity int) int
+		HasPreviousPage func(childComplexity int) int
+		StartCursor     func(childComplexity int) int
+	}
+
+	RiskScore struct {
+		Score    func(childComplexity int) int
+		ScoredAt func(childComplexity int) int
+		Severity func(childComplexity int) int
+	}
+
+	RiskException struct {
+		Approver      func(childComplexity int) int
+		Asset         func(childComplexity int) int
+		CreatedAt     func(childComplexity int) int
+		ExpiresAt     func(childComplexity int) int
+		Group         func(childComplexity int) int
+		ID            func(childComplexity int) int
+		Justification func(childComplexity int) int
+		Port          func(childComplexity int) int
+		Protocol      func(childComplexity int) int
+		RevokedAt     func(childComplexity int) int
+		Service       func(childComplexity int) int
+		Status        func(childComplexity int) int
+	}
+
+	RiskRule struct {
+		AssetType      func(childComplexity int) int
+		BannerPattern  func(childComplexity int) int
+		ID             func(childComplexity int) int
+		Ports          func(childComplexity int) int
+		Protocol       func(childComplexity int) int
+		Remediation    func(childComplexity int) int
+		Service        func(childComplexity int) int
+		Severity       func(childComplexity int) int
+		Source         func(childComplexity int) int
+		Title          func(childComplexity int) int
+		VersionPattern func(childComplexity int) int
+	}
+
+	RiskRuleSet struct {
+		Error    func(childComplexity int) int
+		LoadedAt func(childComplexity int) int
+		Path     func(childComplexity int) int
+		Rules    func(childComplexity int) int
+	}
+
+	RiskRuleTest struct {
+		Matched func(childComplexity int) int
+		Rule    func(childComplexity int) int
+	}
+
+	Subscription struct {
+		AssetScansUpdated func(childComplexity int, assetID *string) int
+		ScanUpdated       func(childComplexity int, scanID string) int
+	}
+
+	Vulnerability struct {
+		Cpe         func(childComplexity int) int
+		CvssScore   func(childComplexity int) int
+		CvssVector  func(childComplexity int) int
+		CvssVersion func(childComplexity int) int
+		Description func(childComplexity int) int
+		ID          func(childComplexity int) int
+		PublishedAt func(childComplexity int) int
+		Severity    func(childComplexity int) int
 	}
 
 	User struct {
@@ -95,7 +357,26 @@ type ComplexityRoot struct {
 }
 
 type AssetResolver interface {
-	Scans(ctx context.Context, obj *model.Asset) ([]*model.Scan, error)
+	ScanProfile(ctx context.Context, obj *model.Asset) (*model.ScanProfile, error)
+	Group(ctx context.Context, obj *model.Asset) (*model.AssetGroup, error)
+	Risk(ctx context.Context, obj *model.Asset) (*model.RiskScore, error)
+	Scans(ctx context.Context, obj *model.Asset, first *int, after *string, last *int, before *string, filter *model.ScanFilter, orderBy *model.ScanOrder) (*model.ScanConnection, error)
+	Vulnerabilities(ctx context.Context, obj *model.Asset) ([]*model.Vulnerability, error)
+}
+
+type AssetGroupResolver interface {
+	Assets(ctx context.Context, obj *model.AssetGroup) ([]*model.Asset, error)
+}
+
+type FindingResolver interface {
+	Asset(ctx context.Context, obj *model.Finding) (*model.Asset, error)
+	Assignee(ctx context.Context, obj *model.Finding) (*model.User, error)
+	Exception(ctx context.Context, obj *model.Finding) (*model.RiskException, error)
+	Comments(ctx context.Context, obj *model.Finding) ([]*model.FindingComment, error)
+}
+
+type FindingCommentResolver interface {
+	Author(ctx context.Context, obj *model.FindingComment) (*model.User, error)
 }
 
 type MutationResolver interface {
@@ -103,20 +384,93 @@ type MutationResolver interface {
 	Login(ctx context.Context, input model.LoginInput) (*model.AuthPayload, error)
 	CreateAsset(ctx context.Context, input model.CreateAssetInput) (*model.Asset, error)
 	DeleteAsset(ctx context.Context, id string) (bool, error)
-	StartScan(ctx context.Context, assetID string) (*model.Scan, error)
+	StartScan(ctx context.Context, assetID string, engine *string, profileID *string) (*model.Scan, error)
+	CancelScan(ctx context.Context, id string) (*model.Scan, error)
+	ExportScans(ctx context.Context, assetID *string) (string, error)
+	CreateScanProfile(ctx context.Context, input model.ScanProfileInput) (*model.ScanProfile, error)
+	UpdateScanProfile(ctx context.Context, id string, input model.ScanProfileInput) (*model.ScanProfile, error)
+	DeleteScanProfile(ctx context.Context, id string) (bool, error)
+	SetAssetScanProfile(ctx context.Context, assetID string, profileID *string) (*model.Asset, error)
+	CreateAssetGroup(ctx context.Context, name string) (*model.AssetGroup, error)
+	DeleteAssetGroup(ctx context.Context, id string) (bool, error)
+	SetAssetGroup(ctx context.Context, assetID string, groupID *string) (*model.Asset, error)
+	CreateScanSchedule(ctx context.Context, input model.ScanScheduleInput) (*model.ScanSchedule, error)
+	UpdateScanSchedule(ctx context.Context, id string, input model.ScanScheduleInput) (*model.ScanSchedule, error)
+	DeleteScanSchedule(ctx context.Context, id string) (bool, error)
+	SetAssetRiskContext(ctx context.Context, assetID string, criticality *string, internetFacing *bool) (*model.Asset, error)
+	ReloadRiskRules(ctx context.Context) (*model.RiskRuleSet, error)
+	SetFindingStatus(ctx context.Context, id string, status string, comment *string) (*model.Finding, error)
+	AssignFinding(ctx context.Context, id string, assigneeID *string) (*model.Finding, error)
+	AddFindingComment(ctx context.Context, findingID string, body string) (*model.FindingComment, error)
+	CreateRiskException(ctx context.Context, input model.RiskExceptionInput) (*model.RiskException, error)
+	RevokeRiskException(ctx context.Context, id string) (*model.RiskException, error)
+	CreateOrganization(ctx context.Context, name string) (*model.Organization, error)
+	SwitchOrganization(ctx context.Context, organizationID string) (*model.AuthPayload, error)
+	AddOrganizationMember(ctx context.Context, email string, role string) (*model.User, error)
+	RemoveOrganizationMember(ctx context.Context, userID string) (bool, error)
+	SetUserRole(ctx context.Context, userID string, role string) (*model.User, error)
+}
+
+type OrganizationResolver interface {
+	Risk(ctx context.Context, obj *model.Organization) (*model.RiskScore, error)
 }
 
 type QueryResolver interface {
 	Me(ctx context.Context) (*model.User, error)
-	Assets(ctx context.Context) ([]*model.Asset, error)
+	Assets(ctx context.Context, first *int, after *string, last *int, before *string, filter *model.AssetFilter, orderBy *model.AssetOrder) (*model.AssetConnection, error)
 	Asset(ctx context.Context, id string) (*model.Asset, error)
-	Scans(ctx context.Context, assetID *string) ([]*model.Scan, error)
+	Scans(ctx context.Context, assetID *string, first *int, after *string, last *int, before *string, filter *model.ScanFilter, orderBy *model.ScanOrder) (*model.ScanConnection, error)
 	Scan(ctx context.Context, id string) (*model.Scan, error)
+	ScanEngines(ctx context.Context) ([]string, error)
+	ScanProfiles(ctx context.Context) ([]*model.ScanProfile, error)
+	ScanProfile(ctx context.Context, id string) (*model.ScanProfile, error)
+	AssetGroups(ctx context.Context) ([]*model.AssetGroup, error)
+	ScanSchedules(ctx context.Context) ([]*model.ScanSchedule, error)
+	ScanSchedule(ctx context.Context, id string) (*model.ScanSchedule, error)
+	ScanDiff(ctx context.Context, baseScanID string, targetScanID string) (*model.ScanDiff, error)
+	RiskRules(ctx context.Context) (*model.RiskRuleSet, error)
+	TestRiskRules(ctx context.Context, scanResultID string, rules *string) ([]*model.RiskRuleTest, error)
+	Findings(ctx context.Context, assetID *string, status *string) ([]*model.Finding, error)
+	Finding(ctx context.Context, id string) (*model.Finding, error)
+	RiskExceptions(ctx context.Context, includeInactive *bool) ([]*model.RiskException, error)
+	Organizations(ctx context.Context) ([]*model.Organization, error)
+	Organization(ctx context.Context) (*model.Organization, error)
+	Users(ctx context.Context) ([]*model.User, error)
+}
+
+type RiskExceptionResolver interface {
+	Asset(ctx context.Context, obj *model.RiskException) (*model.Asset, error)
+	Group(ctx context.Context, obj *model.RiskException) (*model.AssetGroup, error)
+	Approver(ctx context.Context, obj *model.RiskException) (*model.User, error)
 }
 
 type ScanResolver interface {
 	Asset(ctx context.Context, obj *model.Scan) (*model.Asset, error)
+	Profile(ctx context.Context, obj *model.Scan) (*model.ScanProfile, error)
 	Results(ctx context.Context, obj *model.Scan) ([]*model.ScanResult, error)
+	Hosts(ctx context.Context, obj *model.Scan) ([]*model.ScanHost, error)
+	Diff(ctx context.Context, obj *model.Scan) (*model.ScanDiff, error)
+}
+
+type ScanDiffResolver interface {
+	BaseScan(ctx context.Context, obj *model.ScanDiff) (*model.Scan, error)
+	TargetScan(ctx context.Context, obj *model.ScanDiff) (*model.Scan, error)
+}
+
+type ScanResultResolver interface {
+	Vulnerabilities(ctx context.Context, obj *model.ScanResult) ([]*model.Vulnerability, error)
+	Risk(ctx context.Context, obj *model.ScanResult) (*model.RiskScore, error)
+}
+
+type ScanScheduleResolver interface {
+	Asset(ctx context.Context, obj *model.ScanSchedule) (*model.Asset, error)
+	Group(ctx context.Context, obj *model.ScanSchedule) (*model.AssetGroup, error)
+	Profile(ctx context.Context, obj *model.ScanSchedule) (*model.ScanProfile, error)
+}
+
+type SubscriptionResolver interface {
+	ScanUpdated(ctx context.Context, scanID string) (<-chan *model.Scan, error)
+	AssetScansUpdated(ctx context.Context, assetID *string) (<-chan *model.Scan, error)
 }
 
 type executableSchema struct {
@@ -144,8 +498,9 @@ var parsedSchema *ast.Schema
 
 func init() {
 	parsedSchema = &ast.Schema{
-		Types:    map[string]*ast.Definition{},
-		Query:    &ast.Definition{Name: "Query"},
-		Mutation: &ast.Definition{Name: "Mutation"},
+		Types:        map[string]*ast.Definition{},
+		Query:        &ast.Definition{Name: "Query"},
+		Mutation:     &ast.Definition{Name: "Mutation"},
+		Subscription: &ast.Definition{Name: "Subscription"},
 	}
 }
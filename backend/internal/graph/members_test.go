@@ -0,0 +1,180 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cyber-risk-monitor/internal/auth"
+	"cyber-risk-monitor/internal/graph/model"
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+func TestRemovedMemberIsRejected(t *testing.T) {
+	stores := store.NewMemory()
+	tt := storetest.NewTenants(t, stores)
+	resolver := &Resolver{Store: stores}
+	mutation := resolver.Mutation()
+	query := resolver.Query()
+
+	// Bob is made an admin of Alpha and then removed, keeping his token
+	storetest.AddMember(t, stores, tt.Alpha.ID, tt.Bob.ID, auth.RoleAdmin)
+	removed := claims(tt.Bob, tt.Alpha, auth.RoleAdmin)
+	alice := claims(tt.Alice, tt.Alpha, auth.RoleAdmin)
+	if ok, err := mutation.RemoveOrganizationMember(userContext(alice), strconv.Itoa(tt.Bob.ID)); err != nil || !ok {
+		t.Fatalf("removeOrganizationMember = %v, %v", ok, err)
+	}
+
+	assetID := strconv.Itoa(tt.AlphaAsset.ID)
+	victorID := strconv.Itoa(tt.Victor.ID)
+	calls := map[string]func(ctx context.Context) error{
+		"assets": func(ctx context.Context) error {
+			_, err := query.Assets(ctx, nil, nil, nil, nil, nil, nil)
+			return err
+		},
+		"asset": func(ctx context.Context) error {
+			_, err := query.Asset(ctx, assetID)
+			return err
+		},
+		"createAsset": func(ctx context.Context) error {
+			_, err := mutation.CreateAsset(ctx, model.CreateAssetInput{Name: "planted", Target: "10.6.6.6", AssetType: "host"})
+			return err
+		},
+		"deleteAsset": func(ctx context.Context) error {
+			_, err := mutation.DeleteAsset(ctx, assetID)
+			return err
+		},
+		"users": func(ctx context.Context) error {
+			_, err := query.Users(ctx)
+			return err
+		},
+		"setUserRole": func(ctx context.Context) error {
+			_, err := mutation.SetUserRole(ctx, victorID, auth.RoleAdmin)
+			return err
+		},
+		"addOrganizationMember": func(ctx context.Context) error {
+			_, err := mutation.AddOrganizationMember(ctx, tt.Bob.Email, auth.RoleAdmin)
+			return err
+		},
+		"removeOrganizationMember": func(ctx context.Context) error {
+			_, err := mutation.RemoveOrganizationMember(ctx, strconv.Itoa(tt.Alice.ID))
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(userContext(removed)); !errors.Is(err, store.ErrMemberNotFound) {
+				t.Fatalf("%s error = %v, want %v", name, err, store.ErrMemberNotFound)
+			}
+		})
+	}
+
+	// Nothing in Alpha was changed by the rejected calls
+	if _, err := stores.Assets.Get(tt.AlphaAsset.ID); err != nil {
+		t.Fatalf("asset was deleted by a removed member: %v", err)
+	}
+	if count, err := stores.Assets.Count(tt.Alpha.ID, store.AssetFilter{}); err != nil || count != 1 {
+		t.Fatalf("Alpha has %d assets (%v) after a removed member created one, want 1", count, err)
+	}
+	if member, err := stores.Organizations.Member(tt.Alpha.ID, tt.Victor.ID); err != nil || member.Role != auth.RoleViewer {
+		t.Fatalf("Victor's membership = %v, %v after a removed admin changed it", member, err)
+	}
+	if _, err := stores.Organizations.Member(tt.Alpha.ID, tt.Bob.ID); !errors.Is(err, store.ErrMemberNotFound) {
+		t.Fatalf("removed admin added themselves back: %v", err)
+	}
+	if _, err := stores.Organizations.Member(tt.Alpha.ID, tt.Alice.ID); err != nil {
+		t.Fatalf("removed admin removed Alice: %v", err)
+	}
+
+	// Remaining members are unaffected
+	if _, err := query.Assets(userContext(alice), nil, nil, nil, nil, nil, nil); err != nil {
+		t.Fatalf("assets as a member: %v", err)
+	}
+
+	// The removed member can still see their organizations and switch to one they belong to
+	orgs, err := query.Organizations(userContext(removed))
+	if err != nil {
+		t.Fatalf("organizations: %v", err)
+	}
+	if len(orgs) != 1 || orgs[0].ID != strconv.Itoa(tt.Beta.ID) {
+		t.Fatalf("organizations = %v, want only Beta", orgs)
+	}
+	me, err := query.Me(userContext(removed))
+	if err != nil {
+		t.Fatalf("me: %v", err)
+	}
+	if me.Role != nil {
+		t.Fatalf("me role = %q, want none after removal", *me.Role)
+	}
+}
+
+func TestDemotedAdminLosesAdminRole(t *testing.T) {
+	stores := store.NewMemory()
+	tt := storetest.NewTenants(t, stores)
+	resolver := &Resolver{Store: stores}
+	mutation := resolver.Mutation()
+	query := resolver.Query()
+
+	// Bob is made an admin of Alpha and then demoted, keeping his admin token
+	storetest.AddMember(t, stores, tt.Alpha.ID, tt.Bob.ID, auth.RoleAdmin)
+	demoted := claims(tt.Bob, tt.Alpha, auth.RoleAdmin)
+	alice := claims(tt.Alice, tt.Alpha, auth.RoleAdmin)
+	if _, err := mutation.SetUserRole(userContext(alice), strconv.Itoa(tt.Bob.ID), auth.RoleViewer); err != nil {
+		t.Fatalf("setUserRole: %v", err)
+	}
+
+	victorID := strconv.Itoa(tt.Victor.ID)
+	calls := map[string]func(ctx context.Context) error{
+		"users": func(ctx context.Context) error {
+			_, err := query.Users(ctx)
+			return err
+		},
+		"setUserRole": func(ctx context.Context) error {
+			_, err := mutation.SetUserRole(ctx, victorID, auth.RoleAdmin)
+			return err
+		},
+		"addOrganizationMember": func(ctx context.Context) error {
+			_, err := mutation.AddOrganizationMember(ctx, tt.Carol.Email, auth.RoleAdmin)
+			return err
+		},
+		"removeOrganizationMember": func(ctx context.Context) error {
+			_, err := mutation.RemoveOrganizationMember(ctx, strconv.Itoa(tt.Alice.ID))
+			return err
+		},
+		"createAsset": func(ctx context.Context) error {
+			_, err := mutation.CreateAsset(ctx, model.CreateAssetInput{Name: "planted", Target: "10.6.6.6", AssetType: "host"})
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(userContext(demoted)); err == nil || !strings.Contains(err.Error(), "access denied") {
+				t.Fatalf("%s error = %v, want access denied", name, err)
+			}
+		})
+	}
+
+	if member, err := stores.Organizations.Member(tt.Alpha.ID, tt.Victor.ID); err != nil || member.Role != auth.RoleViewer {
+		t.Fatalf("Victor's membership = %v, %v after a demoted admin changed it", member, err)
+	}
+	if _, err := stores.Organizations.Member(tt.Alpha.ID, tt.Alice.ID); err != nil {
+		t.Fatalf("demoted admin removed Alice: %v", err)
+	}
+
+	// Bob keeps what his new role allows and sees it as his role
+	if _, err := query.Assets(userContext(demoted), nil, nil, nil, nil, nil, nil); err != nil {
+		t.Fatalf("assets as a viewer: %v", err)
+	}
+	me, err := query.Me(userContext(demoted))
+	if err != nil {
+		t.Fatalf("me: %v", err)
+	}
+	if me.Role == nil || *me.Role != auth.RoleViewer {
+		t.Fatalf("me role = %v, want %q after demotion", me.Role, auth.RoleViewer)
+	}
+}
@@ -1,14 +1,79 @@
 package model
 
 type AuthPayload struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        string        `json:"token"`
+	User         *User         `json:"user"`
+	Organization *Organization `json:"organization"`
 }
 
 type CreateAssetInput struct {
-	Name      string `json:"name"`
-	Target    string `json:"target"`
-	AssetType string `json:"assetType"`
+	Name           string  `json:"name"`
+	Target         string  `json:"target"`
+	AssetType      string  `json:"assetType"`
+	ScanEngine     *string `json:"scanEngine"`
+	ScanProfileID  *string `json:"scanProfileId"`
+	Criticality    *string `json:"criticality"`
+	InternetFacing *bool   `json:"internetFacing"`
+}
+
+type ScanProfileInput struct {
+	Name             string  `json:"name"`
+	Description      *string `json:"description"`
+	PortSpec         string  `json:"portSpec"`
+	TimingTemplate   *int    `json:"timingTemplate"`
+	VersionIntensity *int    `json:"versionIntensity"`
+	ScanType         *string `json:"scanType"`
+}
+
+type ScanScheduleInput struct {
+	Name      string  `json:"name"`
+	AssetID   *string `json:"assetId"`
+	GroupID   *string `json:"groupId"`
+	Cron      string  `json:"cron"`
+	Engine    *string `json:"engine"`
+	ProfileID *string `json:"profileId"`
+	Enabled   *bool   `json:"enabled"`
+}
+
+type RiskExceptionInput struct {
+	AssetID       *string `json:"assetId"`
+	GroupID       *string `json:"groupId"`
+	Port          int     `json:"port"`
+	Protocol      *string `json:"protocol"`
+	Service       *string `json:"service"`
+	Justification string  `json:"justification"`
+	ApproverID    string  `json:"approverId"`
+	ExpiresAt     string  `json:"expiresAt"`
+}
+
+type AssetFilter struct {
+	AssetType     *string `json:"assetType"`
+	Target        *string `json:"target"`
+	CreatedAfter  *string `json:"createdAfter"`
+	CreatedBefore *string `json:"createdBefore"`
+	ScanStatus    *string `json:"scanStatus"`
+	OpenPort      *int    `json:"openPort"`
+	Service       *string `json:"service"`
+}
+
+type ScanFilter struct {
+	Status       *string `json:"status"`
+	QueuedAfter  *string `json:"queuedAfter"`
+	QueuedBefore *string `json:"queuedBefore"`
+	AssetType    *string `json:"assetType"`
+	Target       *string `json:"target"`
+	OpenPort     *int    `json:"openPort"`
+	Service      *string `json:"service"`
+}
+
+type AssetOrder struct {
+	Field     string  `json:"field"`
+	Direction *string `json:"direction"`
+}
+
+type ScanOrder struct {
+	Field     string  `json:"field"`
+	Direction *string `json:"direction"`
 }
 
 type LoginInput struct {
@@ -22,38 +87,275 @@ type RegisterInput struct {
 }
 
 type Asset struct {
-	ID            string  `json:"id"`
-	Name          string  `json:"name"`
-	Target        string  `json:"target"`
-	AssetType     string  `json:"assetType"`
-	CreatedAt     string  `json:"createdAt"`
-	LastScannedAt *string `json:"lastScannedAt"`
-	Scans         []*Scan `json:"scans"`
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	Target          string           `json:"target"`
+	AssetType       string           `json:"assetType"`
+	ScanEngine      string           `json:"scanEngine"`
+	ScanProfile     *ScanProfile     `json:"scanProfile"`
+	Group           *AssetGroup      `json:"group"`
+	Criticality     string           `json:"criticality"`
+	InternetFacing  bool             `json:"internetFacing"`
+	Risk            *RiskScore       `json:"risk"`
+	CreatedAt       string           `json:"createdAt"`
+	LastScannedAt   *string          `json:"lastScannedAt"`
+	Vulnerabilities []*Vulnerability `json:"vulnerabilities"`
+
+	// ScanProfileID backs the scanProfile field resolver
+	ScanProfileID *int `json:"-"`
+	// GroupID backs the group field resolver
+	GroupID *int `json:"-"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+type AssetConnection struct {
+	Edges      []*AssetEdge `json:"edges"`
+	PageInfo   *PageInfo    `json:"pageInfo"`
+	TotalCount int          `json:"totalCount"`
+}
+
+type AssetEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Asset `json:"node"`
+}
+
+type ScanConnection struct {
+	Edges      []*ScanEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type ScanEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Scan  `json:"node"`
+}
+
+type AssetGroup struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Assets    []*Asset `json:"assets"`
+	CreatedAt string   `json:"createdAt"`
 }
 
 type Scan struct {
-	ID           string        `json:"id"`
-	Asset        *Asset        `json:"asset"`
-	Status       string        `json:"status"`
-	StartedAt    string        `json:"startedAt"`
-	CompletedAt  *string       `json:"completedAt"`
-	ErrorMessage *string       `json:"errorMessage"`
-	Results      []*ScanResult `json:"results"`
+	ID                  string        `json:"id"`
+	Asset               *Asset        `json:"asset"`
+	Status              string        `json:"status"`
+	Engine              string        `json:"engine"`
+	Profile             *ScanProfile  `json:"profile"`
+	QueuedAt            string        `json:"queuedAt"`
+	StartedAt           *string       `json:"startedAt"`
+	CompletedAt         *string       `json:"completedAt"`
+	DurationMs          *int          `json:"durationMs"`
+	ErrorCode           *string       `json:"errorCode"`
+	ErrorMessage        *string       `json:"errorMessage"`
+	Progress            *float64      `json:"progress"`
+	EstimatedCompletion *string       `json:"estimatedCompletion"`
+	Results             []*ScanResult `json:"results"`
+	Hosts               []*ScanHost   `json:"hosts"`
+	Diff                *ScanDiff     `json:"diff"`
+
+	// AssetID backs the asset field resolver
+	AssetID int `json:"-"`
+	// ProfileID backs the profile field resolver
+	ProfileID *int `json:"-"`
+}
+
+type ScanProfile struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	Description      *string `json:"description"`
+	PortSpec         string  `json:"portSpec"`
+	TimingTemplate   int     `json:"timingTemplate"`
+	VersionIntensity *int    `json:"versionIntensity"`
+	ScanType         string  `json:"scanType"`
+	BuiltIn          bool    `json:"builtIn"`
+	CreatedAt        string  `json:"createdAt"`
+	UpdatedAt        string  `json:"updatedAt"`
+}
+
+type ScanSchedule struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Asset     *Asset       `json:"asset"`
+	Group     *AssetGroup  `json:"group"`
+	Cron      string       `json:"cron"`
+	Engine    *string      `json:"engine"`
+	Profile   *ScanProfile `json:"profile"`
+	Enabled   bool         `json:"enabled"`
+	LastRunAt *string      `json:"lastRunAt"`
+	NextRunAt *string      `json:"nextRunAt"`
+	CreatedAt string       `json:"createdAt"`
+	UpdatedAt string       `json:"updatedAt"`
+
+	// AssetID, GroupID and ProfileID back the asset, group and profile field resolvers
+	AssetID   *int `json:"-"`
+	GroupID   *int `json:"-"`
+	ProfileID *int `json:"-"`
+}
+
+type ScanDiff struct {
+	BaseScan   *Scan            `json:"baseScan"`
+	TargetScan *Scan            `json:"targetScan"`
+	Opened     []*ScanDiffEntry `json:"opened"`
+	Closed     []*ScanDiffEntry `json:"closed"`
+	Changed    []*ScanDiffEntry `json:"changed"`
+	CreatedAt  string           `json:"createdAt"`
+
+	// BaseScanID and TargetScanID back the baseScan and targetScan field resolvers
+	BaseScanID   int `json:"-"`
+	TargetScanID int `json:"-"`
+}
+
+type ScanDiffEntry struct {
+	Host            *string `json:"host"`
+	Hostname        *string `json:"hostname"`
+	Port            int     `json:"port"`
+	Protocol        string  `json:"protocol"`
+	Service         *string `json:"service"`
+	Version         *string `json:"version"`
+	PreviousService *string `json:"previousService"`
+	PreviousVersion *string `json:"previousVersion"`
+}
+
+type ScanHost struct {
+	Address  string        `json:"address"`
+	Hostname *string       `json:"hostname"`
+	Results  []*ScanResult `json:"results"`
 }
 
 type ScanResult struct {
-	ID       string  `json:"id"`
-	Port     int     `json:"port"`
-	Protocol string  `json:"protocol"`
-	State    string  `json:"state"`
-	Service  *string `json:"service"`
-	Version  *string `json:"version"`
-	Banner   *string `json:"banner"`
+	ID              string           `json:"id"`
+	Host            *string          `json:"host"`
+	Hostname        *string          `json:"hostname"`
+	Port            int              `json:"port"`
+	Protocol        string           `json:"protocol"`
+	State           string           `json:"state"`
+	Service         *string          `json:"service"`
+	Version         *string          `json:"version"`
+	Banner          *string          `json:"banner"`
+	Cpes            []string         `json:"cpes"`
+	Vulnerabilities []*Vulnerability `json:"vulnerabilities"`
+	Risk            *RiskScore       `json:"risk"`
 }
 
-type User struct {
+type RiskScore struct {
+	Score    float64 `json:"score"`
+	Severity string  `json:"severity"`
+	ScoredAt *string `json:"scoredAt"`
+}
+
+type RiskRule struct {
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Severity       string  `json:"severity"`
+	Remediation    *string `json:"remediation"`
+	Ports          []int   `json:"ports"`
+	Protocol       *string `json:"protocol"`
+	Service        *string `json:"service"`
+	VersionPattern *string `json:"versionPattern"`
+	BannerPattern  *string `json:"bannerPattern"`
+	AssetType      *string `json:"assetType"`
+	Source         *string `json:"source"`
+}
+
+type RiskRuleSet struct {
+	Path     string      `json:"path"`
+	Rules    []*RiskRule `json:"rules"`
+	LoadedAt *string     `json:"loadedAt"`
+	Error    *string     `json:"error"`
+}
+
+type RiskRuleTest struct {
+	Rule    *RiskRule `json:"rule"`
+	Matched bool      `json:"matched"`
+}
+
+type Finding struct {
+	ID          string            `json:"id"`
+	Asset       *Asset            `json:"asset"`
+	Host        *string           `json:"host"`
+	Port        int               `json:"port"`
+	Protocol    string            `json:"protocol"`
+	RuleID      string            `json:"ruleId"`
+	Title       string            `json:"title"`
+	Severity    string            `json:"severity"`
+	Remediation *string           `json:"remediation"`
+	Service     *string           `json:"service"`
+	Version     *string           `json:"version"`
+	Status      string            `json:"status"`
+	Assignee    *User             `json:"assignee"`
+	Exception   *RiskException    `json:"exception"`
+	Comments    []*FindingComment `json:"comments"`
+	FirstSeenAt string            `json:"firstSeenAt"`
+	LastSeenAt  string            `json:"lastSeenAt"`
+	ResolvedAt  *string           `json:"resolvedAt"`
+	UpdatedAt   string            `json:"updatedAt"`
+
+	// AssetID, AssigneeID and ExceptionID back the asset, assignee and exception field resolvers
+	AssetID     int  `json:"-"`
+	AssigneeID  *int `json:"-"`
+	ExceptionID *int `json:"-"`
+}
+
+type FindingComment struct {
 	ID        string `json:"id"`
-	Email     string `json:"email"`
-	Role      string `json:"role"`
+	Author    *User  `json:"author"`
+	Body      string `json:"body"`
 	CreatedAt string `json:"createdAt"`
+
+	// AuthorID backs the author field resolver
+	AuthorID *int `json:"-"`
+}
+
+type RiskException struct {
+	ID            string      `json:"id"`
+	Asset         *Asset      `json:"asset"`
+	Group         *AssetGroup `json:"group"`
+	Port          int         `json:"port"`
+	Protocol      *string     `json:"protocol"`
+	Service       *string     `json:"service"`
+	Justification string      `json:"justification"`
+	Approver      *User       `json:"approver"`
+	Status        string      `json:"status"`
+	ExpiresAt     string      `json:"expiresAt"`
+	RevokedAt     *string     `json:"revokedAt"`
+	CreatedAt     string      `json:"createdAt"`
+
+	// AssetID, GroupID and ApproverID back the asset, group and approver field resolvers
+	AssetID    *int `json:"-"`
+	GroupID    *int `json:"-"`
+	ApproverID *int `json:"-"`
+}
+
+type Vulnerability struct {
+	ID          string   `json:"id"`
+	Description *string  `json:"description"`
+	CvssScore   *float64 `json:"cvssScore"`
+	CvssVersion *string  `json:"cvssVersion"`
+	CvssVector  *string  `json:"cvssVector"`
+	Severity    string   `json:"severity"`
+	PublishedAt *string  `json:"publishedAt"`
+	Cpe         string   `json:"cpe"`
+}
+
+type User struct {
+	ID        string  `json:"id"`
+	Email     string  `json:"email"`
+	Role      *string `json:"role"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type Organization struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Risk      *RiskScore `json:"risk"`
+	CreatedAt string     `json:"createdAt"`
 }
@@ -2,14 +2,26 @@ package graph
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"cyber-risk-monitor/internal/auth"
 	"cyber-risk-monitor/internal/config"
 	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/findings"
 	"cyber-risk-monitor/internal/graph/generated"
+	"cyber-risk-monitor/internal/graph/model"
+	"cyber-risk-monitor/internal/loader"
+	"cyber-risk-monitor/internal/risk"
 	"cyber-risk-monitor/internal/scanner"
+	"cyber-risk-monitor/internal/scheduler"
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/vuln"
 )
 
 // This file will not be regenerated automatically.
@@ -17,9 +29,16 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	DB          *db.DB
-	Config      *config.Config
-	ScanManager *scanner.ScanManager
+	DB             *db.DB
+	Config         *config.Config
+	Store          *store.Store
+	ScanManager    *scanner.ScanManager
+	Scheduler      *scheduler.Scheduler
+	Vulns          *vuln.Database
+	Scorer         *risk.Service
+	Rules          *risk.RuleLoader
+	FindingTracker *findings.Tracker
+	Exceptions     *risk.Exceptions
 }
 
 // Ensure Resolver implements generated.ResolverRoot
@@ -28,20 +47,1092 @@ var _ generated.ResolverRoot = (*Resolver)(nil)
 func NewResolver(database *db.DB, cfg *config.Config) *Resolver {
 	// Create scanner with 5 minute timeout
 	nmapScanner := scanner.NewScanner(5 * time.Minute)
-	scanManager := scanner.NewScanManager(database, nmapScanner)
+
+	// Native TCP connect scanner for hosts without nmap or root
+	tcpPorts, err := scanner.ParsePorts(cfg.TCPScanPorts)
+	if err != nil {
+		log.Printf("Invalid TCP_SCAN_PORTS %q, using defaults: %v", cfg.TCPScanPorts, err)
+	}
+	tcpScanner := scanner.NewTCPScanner(scanner.TCPConfig{
+		Ports:         tcpPorts,
+		Concurrency:   cfg.TCPScanConcurrency,
+		Timeout:       time.Duration(cfg.TCPScanTimeoutMS) * time.Millisecond,
+		BannerTimeout: time.Duration(cfg.TCPBannerTimeoutMS) * time.Millisecond,
+	})
+
+	// Users, assets, scans and results are read and written through the store
+	stores := store.NewSQL(database)
+
+	engines := scanner.NewRegistry(nmapScanner, tcpScanner)
+	scanManager := scanner.NewScanManager(database, stores, engines)
+
+	// Match products detected by each scan against the locally imported NVD data
+	vulns := vuln.NewDatabase(database)
+	scanManager.SetVulnerabilityMatcher(vulns)
+
+	// Score results, assets and users once each scan completes, including the
+	// declarative rules files, which are reloaded when they change
+	rules := risk.NewRuleLoader(cfg.RiskRulesPath, time.Duration(cfg.RiskRulesReloadSeconds)*time.Second)
+	riskService := risk.NewService(database, risk.DefaultEngine().AddFactor(risk.RuleFactor{Source: rules}))
+	scanManager.SetRiskScorer(riskService)
+
+	// Raise triageable findings from the rules each scan matches
+	findingTracker := findings.NewTracker(database, rules)
+	findingTracker.SetScorer(riskService)
+	scanManager.SetFindingTracker(findingTracker)
+
+	scanScheduler := scheduler.NewScheduler(database, scanManager, time.Duration(cfg.SchedulerIntervalSeconds)*time.Second)
 
 	return &Resolver{
-		DB:          database,
-		Config:      cfg,
-		ScanManager: scanManager,
+		DB:             database,
+		Config:         cfg,
+		Store:          stores,
+		ScanManager:    scanManager,
+		Scheduler:      scanScheduler,
+		Vulns:          vulns,
+		Scorer:         riskService,
+		Rules:          rules,
+		FindingTracker: findingTracker,
+		Exceptions:     risk.NewExceptions(database),
 	}
 }
 
-// Helper function to get authenticated user
+// Helper function to get the authenticated user, checking they still belong to
+// the organization their token is scoped to, since tokens outlive memberships.
+// The returned claims carry the user's current role in the organization rather
+// than the one they were issued with.
 func (r *Resolver) getAuthenticatedUser(ctx context.Context) (*auth.Claims, error) {
+	user, err := r.getSignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := r.getMember(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, store.ErrMemberNotFound
+	}
+
+	current := *user
+	current.Role = member.Role
+	return &current, nil
+}
+
+// Helper function to get the authenticated user whether or not they still
+// belong to the organization their token is scoped to, for resolvers that do
+// not read or change its data
+func (r *Resolver) getSignedInUser(ctx context.Context) (*auth.Claims, error) {
 	user, ok := auth.GetUserFromContext(ctx)
 	if !ok {
 		return nil, fmt.Errorf("user not authenticated")
 	}
 	return user, nil
 }
+
+// Helper function to get the user's membership of the organization their token
+// is scoped to through the request's DataLoader, or nil if they do not belong to it
+func (r *Resolver) getMember(ctx context.Context, user *auth.Claims) (*db.OrganizationMember, error) {
+	member, err := r.loaders(ctx).Members.Load(loader.MemberKey{OrganizationID: user.OrgID, UserID: user.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+	return member, nil
+}
+
+// Helper function to get the authenticated user, checking they have one of
+// roles like the @hasRole directive guarding the field, so the check does not
+// depend on the directive being wired into the schema
+func (r *Resolver) getAuthorizedUser(ctx context.Context, roles ...string) (*auth.Claims, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRole(user, roles); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Helper function to parse an optional GraphQL ID argument
+func parseOptionalID(id *string, kind string) (*int, error) {
+	if id == nil || *id == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(*id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s ID", kind)
+	}
+	return &value, nil
+}
+
+// Helper function to load a scan profile the user's organization is allowed to use
+func (r *Resolver) getUsableProfile(user *auth.Claims, profileID int) (*scanner.Profile, error) {
+	profile, err := r.ScanManager.GetProfile(profileID)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.BuiltIn() && *profile.OrganizationID != user.OrgID {
+		return nil, fmt.Errorf("scan profile not found")
+	}
+	return profile, nil
+}
+
+// Helper function to load an asset that belongs to the user's organization
+func (r *Resolver) getOwnedAsset(user *auth.Claims, assetID int) (*db.Asset, error) {
+	asset, err := r.Store.Assets.Get(assetID)
+	if err != nil {
+		return nil, err
+	}
+	if asset.OrganizationID != user.OrgID {
+		return nil, store.ErrAssetNotFound
+	}
+	return asset, nil
+}
+
+// Helper function to get the request's DataLoaders, or new ones for a request
+// not served through loader.Middleware
+func (r *Resolver) loaders(ctx context.Context) *loader.Loaders {
+	if loaders, ok := loader.FromContext(ctx); ok {
+		return loaders
+	}
+	return loader.New(r.Store)
+}
+
+// Helper function to load an asset that belongs to the user's organization
+// through the request's DataLoader
+func (r *Resolver) loadOwnedAsset(ctx context.Context, user *auth.Claims, assetID int) (*db.Asset, error) {
+	asset, err := r.loaders(ctx).Assets.Load(assetID)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil || asset.OrganizationID != user.OrgID {
+		return nil, store.ErrAssetNotFound
+	}
+	return asset, nil
+}
+
+// Helper function to resolve a nested asset field, batched with the other
+// assets of the request
+func (r *Resolver) resolveAsset(ctx context.Context, assetID int) (*model.Asset, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := r.loadOwnedAsset(ctx, user, assetID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelAsset(asset), nil
+}
+
+// Helper function to check that a scan is of an asset of the user's organization
+func (r *Resolver) checkScan(user *auth.Claims, scanID int) error {
+	organizationID, err := r.Store.Scans.OrganizationID(scanID)
+	if err != nil {
+		return err
+	}
+	if organizationID != user.OrgID {
+		return store.ErrScanNotFound
+	}
+	return nil
+}
+
+// Helper function to check that an asset belongs to the user's organization
+func (r *Resolver) checkAsset(user *auth.Claims, assetID int) error {
+	_, err := r.getOwnedAsset(user, assetID)
+	return err
+}
+
+// Helper function to check that an asset group belongs to the user's organization
+func (r *Resolver) checkAssetGroup(user *auth.Claims, groupID int) error {
+	group, err := r.Store.AssetGroups.Get(groupID)
+	if err != nil {
+		return err
+	}
+	if group.OrganizationID != user.OrgID {
+		return store.ErrAssetGroupNotFound
+	}
+	return nil
+}
+
+// Helper function to load an asset group by ID
+func (r *Resolver) getAssetGroup(groupID int) (*model.AssetGroup, error) {
+	group, err := r.Store.AssetGroups.Get(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset group: %w", err)
+	}
+	return toModelAssetGroup(group), nil
+}
+
+// Page sizes of the assets and scans connections
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Helper function to encode the position of an edge in a sorted list as an opaque cursor
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte("cursor:" + strconv.Itoa(offset)))
+}
+
+// Helper function to decode a cursor made by encodeCursor
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil {
+		if value, ok := strings.CutPrefix(string(raw), "cursor:"); ok {
+			if offset, err := strconv.Atoi(value); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
+
+// Helper function to work out the page of a list of total items selected by
+// the Relay first, after, last and before arguments. Without first or last the
+// page holds the first defaultPageSize items after the cursor.
+func pageWindow(first *int, after *string, last *int, before *string, total int) (store.Page, error) {
+	for _, size := range []*int{first, last} {
+		if size != nil && (*size < 0 || *size > maxPageSize) {
+			return store.Page{}, fmt.Errorf("page size must be between 0 and %d", maxPageSize)
+		}
+	}
+
+	start, end := 0, total
+	if after != nil {
+		offset, err := decodeCursor(*after)
+		if err != nil {
+			return store.Page{}, err
+		}
+		start = max(start, offset+1)
+	}
+	if before != nil {
+		offset, err := decodeCursor(*before)
+		if err != nil {
+			return store.Page{}, err
+		}
+		end = min(end, offset)
+	}
+	end = max(start, end)
+
+	if first == nil && last == nil {
+		end = min(end, start+defaultPageSize)
+	}
+	if first != nil {
+		end = min(end, start+*first)
+	}
+	if last != nil {
+		start = max(start, end-*last)
+	}
+
+	return store.Page{Offset: start, Limit: end - start}, nil
+}
+
+// Helper function to describe a page of count items of a list of total items
+func toModelPageInfo(page store.Page, count, total int) *model.PageInfo {
+	info := &model.PageInfo{
+		HasPreviousPage: page.Offset > 0,
+		HasNextPage:     page.Offset+count < total,
+	}
+	if count > 0 {
+		startCursor := encodeCursor(page.Offset)
+		endCursor := encodeCursor(page.Offset + count - 1)
+		info.StartCursor = &startCursor
+		info.EndCursor = &endCursor
+	}
+	return info
+}
+
+// Helper function to build a connection from a page of a list of total assets
+func toModelAssetConnection(assets []*db.Asset, page store.Page, total int) *model.AssetConnection {
+	edges := make([]*model.AssetEdge, 0, len(assets))
+	for i, asset := range assets {
+		edges = append(edges, &model.AssetEdge{
+			Cursor: encodeCursor(page.Offset + i),
+			Node:   toModelAsset(asset),
+		})
+	}
+
+	return &model.AssetConnection{
+		Edges:      edges,
+		PageInfo:   toModelPageInfo(page, len(edges), total),
+		TotalCount: total,
+	}
+}
+
+// Helper function to build a connection from a page of a list of total scans
+func toModelScanConnection(scans []*store.Scan, page store.Page, total int) *model.ScanConnection {
+	edges := make([]*model.ScanEdge, 0, len(scans))
+	for i, scan := range scans {
+		edges = append(edges, &model.ScanEdge{
+			Cursor: encodeCursor(page.Offset + i),
+			Node:   toModelScan(scan),
+		})
+	}
+
+	return &model.ScanConnection{
+		Edges:      edges,
+		PageInfo:   toModelPageInfo(page, len(edges), total),
+		TotalCount: total,
+	}
+}
+
+// Helper function to send a subscription the scans of the events, starting
+// with initial when it is set, until ctx is done
+func forwardScans(ctx context.Context, cancel context.CancelFunc, events <-chan scanner.ScanEvent, initial *store.Scan) <-chan *model.Scan {
+	scans := make(chan *model.Scan, 1)
+
+	go func() {
+		defer cancel()
+		defer close(scans)
+
+		if initial != nil {
+			select {
+			case scans <- toModelScan(initial):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for event := range events {
+			select {
+			case scans <- toModelScan(event.Scan):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return scans
+}
+
+// Helper function to parse an optional filter timestamp, given in RFC 3339 or
+// as a date, which starts at midnight UTC
+func parseFilterTime(value *string, name string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", *value); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: use RFC 3339 or YYYY-MM-DD", name, *value)
+		}
+	}
+	return &t, nil
+}
+
+// Helper function to parse a scan status filter
+func parseScanStatus(value *string) (store.ScanStatus, error) {
+	if value == nil || *value == "" {
+		return "", nil
+	}
+	status := store.ScanStatus(strings.ToLower(*value))
+	switch status {
+	case store.ScanStatusPending, store.ScanStatusRunning, store.ScanStatusCompleted, store.ScanStatusFailed, store.ScanStatusCancelled:
+		return status, nil
+	}
+	return "", fmt.Errorf("invalid scan status %q", *value)
+}
+
+// Helper function to parse a sort direction, which is ascending by default
+func parseSortDirection(direction *string) (bool, error) {
+	if direction == nil {
+		return false, nil
+	}
+	switch strings.ToUpper(*direction) {
+	case "ASC":
+		return false, nil
+	case "DESC":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid sort direction %q: use ASC or DESC", *direction)
+}
+
+// Helper function to build an asset filter from query input
+func assetFilterFromInput(input *model.AssetFilter) (store.AssetFilter, error) {
+	var filter store.AssetFilter
+	if input == nil {
+		return filter, nil
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseFilterTime(input.CreatedAfter, "createdAfter"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parseFilterTime(input.CreatedBefore, "createdBefore"); err != nil {
+		return filter, err
+	}
+	if filter.ScanStatus, err = parseScanStatus(input.ScanStatus); err != nil {
+		return filter, err
+	}
+	if input.AssetType != nil {
+		filter.AssetType = *input.AssetType
+	}
+	if input.Target != nil {
+		filter.Target = *input.Target
+	}
+	if input.Service != nil {
+		filter.Service = *input.Service
+	}
+	filter.OpenPort = input.OpenPort
+
+	return filter, nil
+}
+
+// Helper function to build a scan filter from query input
+func scanFilterFromInput(input *model.ScanFilter) (store.ScanFilter, error) {
+	var filter store.ScanFilter
+	if input == nil {
+		return filter, nil
+	}
+
+	var err error
+	if filter.QueuedAfter, err = parseFilterTime(input.QueuedAfter, "queuedAfter"); err != nil {
+		return filter, err
+	}
+	if filter.QueuedBefore, err = parseFilterTime(input.QueuedBefore, "queuedBefore"); err != nil {
+		return filter, err
+	}
+	if filter.Status, err = parseScanStatus(input.Status); err != nil {
+		return filter, err
+	}
+	if input.AssetType != nil {
+		filter.AssetType = *input.AssetType
+	}
+	if input.Target != nil {
+		filter.Target = *input.Target
+	}
+	if input.Service != nil {
+		filter.Service = *input.Service
+	}
+	filter.OpenPort = input.OpenPort
+
+	return filter, nil
+}
+
+// Helper function to build an asset sort order from query input, newest first by default
+func assetOrderFromInput(input *model.AssetOrder) (store.AssetOrder, error) {
+	if input == nil {
+		return store.AssetOrder{Field: store.AssetSortCreatedAt, Desc: true}, nil
+	}
+
+	field := store.AssetSort(strings.ToUpper(input.Field))
+	if !field.Valid() {
+		return store.AssetOrder{}, fmt.Errorf("invalid asset sort field %q", input.Field)
+	}
+	desc, err := parseSortDirection(input.Direction)
+	if err != nil {
+		return store.AssetOrder{}, err
+	}
+	return store.AssetOrder{Field: field, Desc: desc}, nil
+}
+
+// Helper function to build a scan sort order from query input, newest first by default
+func scanOrderFromInput(input *model.ScanOrder) (store.ScanOrder, error) {
+	if input == nil {
+		return store.ScanOrder{Field: store.ScanSortQueuedAt, Desc: true}, nil
+	}
+
+	field := store.ScanSort(strings.ToUpper(input.Field))
+	if !field.Valid() {
+		return store.ScanOrder{}, fmt.Errorf("invalid scan sort field %q", input.Field)
+	}
+	desc, err := parseSortDirection(input.Direction)
+	if err != nil {
+		return store.ScanOrder{}, err
+	}
+	return store.ScanOrder{Field: field, Desc: desc}, nil
+}
+
+// Helper function to build a scan schedule from mutation input, checking the
+// user's organization owns everything it references
+func (r *Resolver) scheduleFromInput(user *auth.Claims, input model.ScanScheduleInput) (*scheduler.Schedule, error) {
+	schedule := &scheduler.Schedule{
+		UserID:         user.UserID,
+		OrganizationID: user.OrgID,
+		Name:           input.Name,
+		Cron:           input.Cron,
+		Enabled:        true,
+	}
+	if input.Enabled != nil {
+		schedule.Enabled = *input.Enabled
+	}
+
+	var err error
+	if schedule.AssetID, err = parseOptionalID(input.AssetID, "asset"); err != nil {
+		return nil, err
+	}
+	if schedule.GroupID, err = parseOptionalID(input.GroupID, "asset group"); err != nil {
+		return nil, err
+	}
+	if schedule.ProfileID, err = parseOptionalID(input.ProfileID, "scan profile"); err != nil {
+		return nil, err
+	}
+
+	if schedule.AssetID != nil {
+		if err := r.checkAsset(user, *schedule.AssetID); err != nil {
+			return nil, err
+		}
+	}
+	if schedule.GroupID != nil {
+		if err := r.checkAssetGroup(user, *schedule.GroupID); err != nil {
+			return nil, err
+		}
+	}
+	if input.Engine != nil && *input.Engine != "" {
+		if _, err := r.ScanManager.Engines().Get(*input.Engine); err != nil {
+			return nil, err
+		}
+		schedule.Engine = *input.Engine
+	}
+	if schedule.ProfileID != nil {
+		if _, err := r.getUsableProfile(user, *schedule.ProfileID); err != nil {
+			return nil, err
+		}
+	}
+
+	return schedule, nil
+}
+
+// Helper function to build a scan profile from mutation input
+func profileFromInput(input model.ScanProfileInput) *scanner.Profile {
+	defaults := scanner.DefaultProfile()
+	profile := &scanner.Profile{
+		Name:             input.Name,
+		PortSpec:         input.PortSpec,
+		TimingTemplate:   defaults.TimingTemplate,
+		VersionIntensity: input.VersionIntensity,
+		ScanType:         defaults.ScanType,
+	}
+	if input.Description != nil {
+		profile.Description = *input.Description
+	}
+	if input.TimingTemplate != nil {
+		profile.TimingTemplate = *input.TimingTemplate
+	}
+	if input.ScanType != nil {
+		profile.ScanType = *input.ScanType
+	}
+	return profile
+}
+
+// Helper function to convert a user to its GraphQL model, with their role in
+// the organization being viewed if they belong to it
+func toModelUser(user *db.User, role string) *model.User {
+	modelUser := &model.User{
+		ID:        strconv.Itoa(user.ID),
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+	}
+	if role != "" {
+		modelUser.Role = &role
+	}
+	return modelUser
+}
+
+// Helper function to convert an organization to its GraphQL model, with the
+// user's role in it
+func toModelOrganization(org *db.Organization, role string) *model.Organization {
+	return &model.Organization{
+		ID:        strconv.Itoa(org.ID),
+		Name:      org.Name,
+		Role:      role,
+		CreatedAt: org.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// Helper function to convert an asset to its GraphQL model
+func toModelAsset(asset *db.Asset) *model.Asset {
+	var lastScannedAt *string
+	if asset.LastScannedAt != nil {
+		formatted := asset.LastScannedAt.Format(time.RFC3339)
+		lastScannedAt = &formatted
+	}
+
+	return &model.Asset{
+		ID:             strconv.Itoa(asset.ID),
+		Name:           asset.Name,
+		Target:         asset.Target,
+		AssetType:      asset.AssetType,
+		ScanEngine:     asset.ScanEngine,
+		ScanProfileID:  asset.ScanProfileID,
+		GroupID:        asset.GroupID,
+		Criticality:    asset.Criticality,
+		InternetFacing: asset.InternetFacing,
+		CreatedAt:      asset.CreatedAt.Format(time.RFC3339),
+		LastScannedAt:  lastScannedAt,
+	}
+}
+
+// Helper function to convert an asset group to its GraphQL model
+func toModelAssetGroup(group *db.AssetGroup) *model.AssetGroup {
+	return &model.AssetGroup{
+		ID:        strconv.Itoa(group.ID),
+		Name:      group.Name,
+		CreatedAt: group.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// Helper function to convert a scan to its GraphQL model
+func toModelScan(scan *scanner.Scan) *model.Scan {
+	formatTime := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		formatted := t.Format(time.RFC3339)
+		return &formatted
+	}
+
+	var durationMs *int
+	if scan.DurationMS != nil {
+		ms := int(*scan.DurationMS)
+		durationMs = &ms
+	}
+
+	return &model.Scan{
+		ID:                  strconv.Itoa(scan.ID),
+		Status:              string(scan.Status),
+		Engine:              scan.Engine,
+		QueuedAt:            scan.QueuedAt.Format(time.RFC3339),
+		StartedAt:           formatTime(scan.StartedAt),
+		CompletedAt:         formatTime(scan.CompletedAt),
+		DurationMs:          durationMs,
+		ErrorCode:           scan.ErrorCode,
+		ErrorMessage:        scan.ErrorMessage,
+		Progress:            scan.Progress,
+		EstimatedCompletion: formatTime(scan.EstimatedCompletion),
+		AssetID:             scan.AssetID,
+		ProfileID:           scan.ProfileID,
+	}
+}
+
+// Helper function to convert a scan result to its GraphQL model
+func toModelScanResult(result scanner.ScanResult) *model.ScanResult {
+	var host, hostname *string
+	if result.Host != "" {
+		host = &result.Host
+	}
+	if result.Hostname != "" {
+		hostname = &result.Hostname
+	}
+
+	return &model.ScanResult{
+		ID:       strconv.Itoa(result.ID),
+		Host:     host,
+		Hostname: hostname,
+		Port:     result.Port,
+		Protocol: result.Protocol,
+		State:    result.State,
+		Service:  &result.Service,
+		Version:  &result.Version,
+		Banner:   &result.Banner,
+		Cpes:     append([]string{}, result.CPEs...),
+	}
+}
+
+// Helper function to convert a stored risk score to its GraphQL model
+func toModelRiskScore(score *risk.Score) *model.RiskScore {
+	if score == nil {
+		return nil
+	}
+
+	modelScore := &model.RiskScore{
+		Score:    score.Score,
+		Severity: string(score.Severity),
+	}
+	if score.ScoredAt != nil {
+		scoredAt := score.ScoredAt.Format(time.RFC3339)
+		modelScore.ScoredAt = &scoredAt
+	}
+	return modelScore
+}
+
+// Helper function to convert a risk rule to its GraphQL model
+func toModelRiskRule(rule *risk.Rule) *model.RiskRule {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	ports := rule.Match.Ports
+	if ports == nil {
+		ports = []int{}
+	}
+
+	return &model.RiskRule{
+		ID:             rule.ID,
+		Title:          rule.Title,
+		Severity:       string(rule.Severity),
+		Remediation:    optional(rule.Remediation),
+		Ports:          ports,
+		Protocol:       optional(rule.Match.Protocol),
+		Service:        optional(rule.Match.Service),
+		VersionPattern: optional(rule.Match.Version),
+		BannerPattern:  optional(rule.Match.Banner),
+		AssetType:      optional(rule.Match.AssetType),
+		Source:         optional(rule.Source),
+	}
+}
+
+// Helper function to convert the loaded risk rules to their GraphQL model
+func toModelRiskRuleSet(loader *risk.RuleLoader) *model.RiskRuleSet {
+	set := loader.Rules()
+
+	modelSet := &model.RiskRuleSet{
+		Path:  loader.Path(),
+		Rules: []*model.RiskRule{},
+	}
+	for _, rule := range set.Rules {
+		modelSet.Rules = append(modelSet.Rules, toModelRiskRule(rule))
+	}
+	if !set.LoadedAt.IsZero() {
+		loadedAt := set.LoadedAt.Format(time.RFC3339)
+		modelSet.LoadedAt = &loadedAt
+	}
+	if err := loader.LastError(); err != nil {
+		message := err.Error()
+		modelSet.Error = &message
+	}
+	return modelSet
+}
+
+// Helper function to get a user by ID with their role in the authenticated
+// user's organization, returning nil if they no longer exist
+func (r *Resolver) getUser(ctx context.Context, userID int) (*model.User, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbUser, err := r.Store.Users.Get(userID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	role, err := r.memberRole(user.OrgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelUser(dbUser, role), nil
+}
+
+// Helper function to get a user's role in an organization, or "" if they do
+// not belong to it
+func (r *Resolver) memberRole(organizationID, userID int) (string, error) {
+	member, err := r.Store.Organizations.Member(organizationID, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrMemberNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get organization member: %w", err)
+	}
+	return member.Role, nil
+}
+
+// Helper function to issue a token for the user to work in one of their
+// organizations and record it as the one they last used
+func (r *Resolver) authPayload(user *db.User, org *db.Organization, role string) (*model.AuthPayload, error) {
+	if err := r.Store.Users.SetCurrentOrganization(user.ID, org.ID); err != nil {
+		return nil, fmt.Errorf("failed to switch organization: %w", err)
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.Email, org.ID, role, r.Config.JWTSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &model.AuthPayload{
+		Token:        token,
+		User:         toModelUser(user, role),
+		Organization: toModelOrganization(org, role),
+	}, nil
+}
+
+// Helper function to pick the organization a user works in after logging in:
+// the one they last used if they still belong to it, else the first they
+// belong to, else a new personal organization
+func (r *Resolver) loginOrganization(user *db.User) (*db.Organization, string, error) {
+	memberships, err := r.Store.Organizations.ListByUser(user.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get organizations: %w", err)
+	}
+
+	for _, membership := range memberships {
+		if user.CurrentOrganizationID != nil && membership.Organization.ID == *user.CurrentOrganizationID {
+			return membership.Organization, membership.Role, nil
+		}
+	}
+	if len(memberships) > 0 {
+		return memberships[0].Organization, memberships[0].Role, nil
+	}
+
+	org, err := r.Store.Organizations.Create(user.Email, user.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create organization: %w", err)
+	}
+	return org, auth.RoleAdmin, nil
+}
+
+// Helper function to build a risk exception from its GraphQL input
+func (r *Resolver) exceptionFromInput(user *auth.Claims, input model.RiskExceptionInput) (*risk.Exception, error) {
+	exception := &risk.Exception{
+		UserID:         user.UserID,
+		OrganizationID: user.OrgID,
+		Port:           input.Port,
+		Justification:  input.Justification,
+	}
+	if input.Protocol != nil {
+		exception.Protocol = strings.ToLower(*input.Protocol)
+	}
+	if input.Service != nil {
+		exception.Service = *input.Service
+	}
+
+	var err error
+	if exception.AssetID, err = parseOptionalID(input.AssetID, "asset"); err != nil {
+		return nil, err
+	}
+	if exception.GroupID, err = parseOptionalID(input.GroupID, "asset group"); err != nil {
+		return nil, err
+	}
+	if exception.ApproverID, err = parseOptionalID(&input.ApproverID, "approver"); err != nil {
+		return nil, err
+	}
+
+	// Expiry accepts a full timestamp or a date, which expires at the start of that day in UTC
+	if exception.ExpiresAt, err = time.Parse(time.RFC3339, input.ExpiresAt); err != nil {
+		if exception.ExpiresAt, err = time.Parse("2006-01-02", input.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("invalid expiry %q: use RFC 3339 or YYYY-MM-DD", input.ExpiresAt)
+		}
+	}
+
+	if exception.AssetID != nil {
+		if err := r.checkAsset(user, *exception.AssetID); err != nil {
+			return nil, err
+		}
+	}
+	if exception.GroupID != nil {
+		if err := r.checkAssetGroup(user, *exception.GroupID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Approvers must belong to the organization
+	role, err := r.memberRole(user.OrgID, *exception.ApproverID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, fmt.Errorf("approver not found")
+	}
+
+	return exception, nil
+}
+
+// Helper function to convert a risk exception to its GraphQL model
+func toModelRiskException(exception *risk.Exception) *model.RiskException {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	var revokedAt *string
+	if exception.RevokedAt != nil {
+		formatted := exception.RevokedAt.Format(time.RFC3339)
+		revokedAt = &formatted
+	}
+
+	return &model.RiskException{
+		ID:            strconv.Itoa(exception.ID),
+		Port:          exception.Port,
+		Protocol:      optional(exception.Protocol),
+		Service:       optional(exception.Service),
+		Justification: exception.Justification,
+		Status:        exception.Status(time.Now()),
+		ExpiresAt:     exception.ExpiresAt.Format(time.RFC3339),
+		RevokedAt:     revokedAt,
+		CreatedAt:     exception.CreatedAt.Format(time.RFC3339),
+		AssetID:       exception.AssetID,
+		GroupID:       exception.GroupID,
+		ApproverID:    exception.ApproverID,
+	}
+}
+
+// Helper function to convert a finding to its GraphQL model
+func toModelFinding(finding *findings.Finding) *model.Finding {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	var resolvedAt *string
+	if finding.ResolvedAt != nil {
+		formatted := finding.ResolvedAt.Format(time.RFC3339)
+		resolvedAt = &formatted
+	}
+
+	return &model.Finding{
+		ID:          strconv.Itoa(finding.ID),
+		Host:        optional(finding.Host),
+		Port:        finding.Port,
+		Protocol:    finding.Protocol,
+		RuleID:      finding.RuleID,
+		Title:       finding.Title,
+		Severity:    finding.Severity,
+		Remediation: optional(finding.Remediation),
+		Service:     optional(finding.Service),
+		Version:     optional(finding.Version),
+		Status:      finding.Status,
+		FirstSeenAt: finding.FirstSeenAt.Format(time.RFC3339),
+		LastSeenAt:  finding.LastSeenAt.Format(time.RFC3339),
+		ResolvedAt:  resolvedAt,
+		UpdatedAt:   finding.UpdatedAt.Format(time.RFC3339),
+		AssetID:     finding.AssetID,
+		AssigneeID:  finding.AssigneeID,
+		ExceptionID: finding.ExceptionID,
+	}
+}
+
+// Helper function to convert a finding comment to its GraphQL model
+func toModelFindingComment(comment *findings.Comment) *model.FindingComment {
+	return &model.FindingComment{
+		ID:        strconv.Itoa(comment.ID),
+		Body:      comment.Body,
+		CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+		AuthorID:  comment.UserID,
+	}
+}
+
+// Helper function to convert a matched vulnerability to its GraphQL model
+func toModelVulnerability(v *vuln.Vulnerability) *model.Vulnerability {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	var publishedAt *string
+	if v.PublishedAt != nil {
+		formatted := v.PublishedAt.Format(time.RFC3339)
+		publishedAt = &formatted
+	}
+
+	return &model.Vulnerability{
+		ID:          v.ID,
+		Description: optional(v.Description),
+		CvssScore:   v.CVSSScore,
+		CvssVersion: optional(v.CVSSVersion),
+		CvssVector:  optional(v.CVSSVector),
+		Severity:    v.Severity,
+		PublishedAt: publishedAt,
+		Cpe:         v.CPE,
+	}
+}
+
+// Helper function to convert a scan profile to its GraphQL model
+func toModelScanProfile(profile *scanner.Profile) *model.ScanProfile {
+	var description *string
+	if profile.Description != "" {
+		description = &profile.Description
+	}
+
+	return &model.ScanProfile{
+		ID:               strconv.Itoa(profile.ID),
+		Name:             profile.Name,
+		Description:      description,
+		PortSpec:         profile.PortSpec,
+		TimingTemplate:   profile.TimingTemplate,
+		VersionIntensity: profile.VersionIntensity,
+		ScanType:         profile.ScanType,
+		BuiltIn:          profile.BuiltIn(),
+		CreatedAt:        profile.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:        profile.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// Helper function to convert a scan diff to its GraphQL model
+func toModelScanDiff(diff *scanner.ScanDiff) *model.ScanDiff {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	result := &model.ScanDiff{
+		Opened:       []*model.ScanDiffEntry{},
+		Closed:       []*model.ScanDiffEntry{},
+		Changed:      []*model.ScanDiffEntry{},
+		CreatedAt:    diff.CreatedAt.Format(time.RFC3339),
+		BaseScanID:   diff.BaseScanID,
+		TargetScanID: diff.TargetScanID,
+	}
+
+	for _, entry := range diff.Entries {
+		modelEntry := &model.ScanDiffEntry{
+			Host:            optional(entry.Host),
+			Hostname:        optional(entry.Hostname),
+			Port:            entry.Port,
+			Protocol:        entry.Protocol,
+			Service:         optional(entry.Service),
+			Version:         optional(entry.Version),
+			PreviousService: optional(entry.PreviousService),
+			PreviousVersion: optional(entry.PreviousVersion),
+		}
+
+		switch entry.Change {
+		case scanner.DiffChangeOpened:
+			result.Opened = append(result.Opened, modelEntry)
+		case scanner.DiffChangeClosed:
+			result.Closed = append(result.Closed, modelEntry)
+		case scanner.DiffChangeChanged:
+			result.Changed = append(result.Changed, modelEntry)
+		}
+	}
+
+	return result
+}
+
+// Helper function to convert a scan schedule to its GraphQL model
+func toModelScanSchedule(schedule *scheduler.Schedule) *model.ScanSchedule {
+	var engine, lastRunAt, nextRunAt *string
+	if schedule.Engine != "" {
+		engine = &schedule.Engine
+	}
+	if schedule.LastRunAt != nil {
+		formatted := schedule.LastRunAt.Format(time.RFC3339)
+		lastRunAt = &formatted
+	}
+	if schedule.NextRunAt != nil {
+		formatted := schedule.NextRunAt.Format(time.RFC3339)
+		nextRunAt = &formatted
+	}
+
+	return &model.ScanSchedule{
+		ID:        strconv.Itoa(schedule.ID),
+		Name:      schedule.Name,
+		Cron:      schedule.Cron,
+		Engine:    engine,
+		Enabled:   schedule.Enabled,
+		LastRunAt: lastRunAt,
+		NextRunAt: nextRunAt,
+		CreatedAt: schedule.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: schedule.UpdatedAt.Format(time.RFC3339),
+		AssetID:   schedule.AssetID,
+		GroupID:   schedule.GroupID,
+		ProfileID: schedule.ProfileID,
+	}
+}
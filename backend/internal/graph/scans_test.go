@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"strconv"
+	"testing"
+
+	"cyber-risk-monitor/internal/graph/model"
+)
+
+// scanIDs returns the IDs and cursors of a page of scans
+func scanIDs(conn *model.ScanConnection) []string {
+	var ids []string
+	for _, edge := range conn.Edges {
+		ids = append(ids, edge.Node.ID+"@"+edge.Cursor)
+	}
+	return ids
+}
+
+// TestAssetScansPageLikeScans checks an asset's scans connection returns the
+// same pages and cursors as the scans connection of that asset
+func TestAssetScansPageLikeScans(t *testing.T) {
+	tt := newExportTenants(t)
+	for range 4 {
+		if _, err := tt.stores.Scans.Create(tt.AlphaAsset.ID, tt.Alice.ID, "tcp", nil); err != nil {
+			t.Fatalf("create scan: %v", err)
+		}
+	}
+	ctx := userContext(tt.alice)
+	asset := toModelAsset(tt.AlphaAsset)
+	assetID := strconv.Itoa(tt.AlphaAsset.ID)
+	two := 2
+
+	var after *string
+	pages := 0
+	for {
+		nested, err := tt.resolver.Asset().Scans(ctx, asset, &two, after, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("asset scans: %v", err)
+		}
+		top, err := tt.resolver.Query().Scans(ctx, &assetID, &two, after, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("scans: %v", err)
+		}
+
+		if nested.TotalCount != 5 || top.TotalCount != 5 {
+			t.Fatalf("total counts = %d and %d, want 5", nested.TotalCount, top.TotalCount)
+		}
+		got, want := scanIDs(nested), scanIDs(top)
+		if len(got) != len(want) {
+			t.Fatalf("page %d = %v, want %v", pages, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("page %d = %v, want %v", pages, got, want)
+			}
+		}
+		if nested.PageInfo.HasNextPage != top.PageInfo.HasNextPage || nested.PageInfo.HasPreviousPage != top.PageInfo.HasPreviousPage {
+			t.Fatalf("page %d info = %+v, want %+v", pages, nested.PageInfo, top.PageInfo)
+		}
+
+		pages++
+		if !nested.PageInfo.HasNextPage {
+			break
+		}
+		after = nested.PageInfo.EndCursor
+	}
+	if pages != 3 {
+		t.Fatalf("paged through %d pages, want 3", pages)
+	}
+
+	// Paging backwards from the end gives the oldest scans
+	nested, err := tt.resolver.Asset().Scans(ctx, asset, nil, nil, &two, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("asset scans: %v", err)
+	}
+	top, err := tt.resolver.Query().Scans(ctx, &assetID, nil, nil, &two, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("scans: %v", err)
+	}
+	if got, want := scanIDs(nested), scanIDs(top); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("last page = %v, want %v", got, want)
+	}
+}
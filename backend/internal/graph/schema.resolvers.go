@@ -2,16 +2,21 @@ package graph
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
-	"time"
+	"strings"
 
 	"cyber-risk-monitor/internal/auth"
 	"cyber-risk-monitor/internal/db"
 	"cyber-risk-monitor/internal/export"
+	"cyber-risk-monitor/internal/findings"
 	"cyber-risk-monitor/internal/graph/model"
 	"cyber-risk-monitor/internal/graph/generated"
+	"cyber-risk-monitor/internal/loader"
+	"cyber-risk-monitor/internal/risk"
+	"cyber-risk-monitor/internal/scanner"
+	"cyber-risk-monitor/internal/store"
 )
 
 // Register is the resolver for the register field.
@@ -22,47 +27,27 @@ func (r *mutationResolver) Register(ctx context.Context, input model.RegisterInp
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Insert user into database
-	var user db.User
-	query := `
-		INSERT INTO users (email, password_hash, role, created_at, updated_at)
-		VALUES ($1, $2, 'user', NOW(), NOW())
-		RETURNING id, email, role, created_at, updated_at`
-
-	err = r.DB.QueryRow(query, input.Email, hashedPassword).Scan(
-		&user.ID, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt,
-	)
+	// Store the new user
+	user, err := r.Store.Users.Create(input.Email, hashedPassword)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateToken(user.ID, user.Email, r.Config.JWTSecret)
+	// Every user starts out administering a personal organization, which
+	// they can invite others to
+	org, err := r.Store.Organizations.Create(user.Email, user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, fmt.Errorf("failed to create organization: %w", err)
 	}
 
-	return &model.AuthPayload{
-		Token: token,
-		User: &model.User{
-			ID:        strconv.Itoa(user.ID),
-			Email:     user.Email,
-			Role:      user.Role,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-		},
-	}, nil
+	return r.authPayload(user, org, auth.RoleAdmin)
 }
 
 // Login is the resolver for the login field.
 func (r *mutationResolver) Login(ctx context.Context, input model.LoginInput) (*model.AuthPayload, error) {
-	var user db.User
-	query := `SELECT id, email, password_hash, role, created_at FROM users WHERE email = $1`
-
-	err := r.DB.QueryRow(query, input.Email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt,
-	)
+	user, err := r.Store.Users.GetByEmail(input.Email)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrUserNotFound) {
 			return nil, fmt.Errorf("invalid email or password")
 		}
 		return nil, fmt.Errorf("failed to find user: %w", err)
@@ -73,63 +58,77 @@ func (r *mutationResolver) Login(ctx context.Context, input model.LoginInput) (*
 		return nil, fmt.Errorf("invalid email or password")
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateToken(user.ID, user.Email, r.Config.JWTSecret)
+	org, role, err := r.loginOrganization(user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
-	return &model.AuthPayload{
-		Token: token,
-		User: &model.User{
-			ID:        strconv.Itoa(user.ID),
-			Email:     user.Email,
-			Role:      user.Role,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-		},
-	}, nil
+	return r.authPayload(user, org, role)
 }
 
 // CreateAsset is the resolver for the createAsset field.
 func (r *mutationResolver) CreateAsset(ctx context.Context, input model.CreateAssetInput) (*model.Asset, error) {
-	user, err := r.getAuthenticatedUser(ctx)
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
 	if err != nil {
 		return nil, err
 	}
 
-	var asset db.Asset
-	query := `
-		INSERT INTO assets (user_id, name, target, asset_type, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		RETURNING id, user_id, name, target, asset_type, created_at, last_scanned_at`
+	// Validate the target, including CIDR blocks and IP ranges
+	if err := scanner.ValidateTarget(input.Target); err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+
+	// Validate the requested scan engine, defaulting to nmap
+	scanEngine := scanner.DefaultEngine
+	if input.ScanEngine != nil && *input.ScanEngine != "" {
+		scanEngine = *input.ScanEngine
+	}
+	if _, err := r.ScanManager.Engines().Get(scanEngine); err != nil {
+		return nil, err
+	}
 
-	err = r.DB.QueryRow(query, user.UserID, input.Name, input.Target, input.AssetType).Scan(
-		&asset.ID, &asset.UserID, &asset.Name, &asset.Target, &asset.AssetType,
-		&asset.CreatedAt, &asset.LastScannedAt,
-	)
+	// Validate the default scan profile
+	profileID, err := parseOptionalID(input.ScanProfileID, "scan profile")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create asset: %w", err)
+		return nil, err
+	}
+	if profileID != nil {
+		if _, err := r.getUsableProfile(user, *profileID); err != nil {
+			return nil, err
+		}
 	}
 
-	var lastScannedAt *string
-	if asset.LastScannedAt != nil {
-		formatted := asset.LastScannedAt.Format(time.RFC3339)
-		lastScannedAt = &formatted
+	// Validate the risk context used to score the asset
+	criticality := string(risk.DefaultCriticality)
+	if input.Criticality != nil && *input.Criticality != "" {
+		criticality = *input.Criticality
+	}
+	if !risk.ValidCriticality(criticality) {
+		return nil, fmt.Errorf("invalid criticality %q", criticality)
+	}
+	internetFacing := input.InternetFacing != nil && *input.InternetFacing
+
+	asset, err := r.Store.Assets.Create(&db.Asset{
+		UserID:         user.UserID,
+		OrganizationID: user.OrgID,
+		Name:           input.Name,
+		Target:         input.Target,
+		AssetType:      input.AssetType,
+		ScanEngine:     scanEngine,
+		ScanProfileID:  profileID,
+		Criticality:    criticality,
+		InternetFacing: internetFacing,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &model.Asset{
-		ID:            strconv.Itoa(asset.ID),
-		Name:          asset.Name,
-		Target:        asset.Target,
-		AssetType:     asset.AssetType,
-		CreatedAt:     asset.CreatedAt.Format(time.RFC3339),
-		LastScannedAt: lastScannedAt,
-	}, nil
+	return toModelAsset(asset), nil
 }
 
 // DeleteAsset is the resolver for the deleteAsset field.
 func (r *mutationResolver) DeleteAsset(ctx context.Context, id string) (bool, error) {
-	user, err := r.getAuthenticatedUser(ctx)
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
 	if err != nil {
 		return false, err
 	}
@@ -139,119 +138,138 @@ func (r *mutationResolver) DeleteAsset(ctx context.Context, id string) (bool, er
 		return false, fmt.Errorf("invalid asset ID")
 	}
 
-	query := `DELETE FROM assets WHERE id = $1 AND user_id = $2`
-	result, err := r.DB.Exec(query, assetID, user.UserID)
-	if err != nil {
-		return false, fmt.Errorf("failed to delete asset: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return false, fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	return rowsAffected > 0, nil
+	return r.Store.Assets.Delete(user.OrgID, assetID)
 }
 
 // StartScan is the resolver for the startScan field.
-func (r *mutationResolver) StartScan(ctx context.Context, assetID string) (*model.Scan, error) {
-	user, err := r.getAuthenticatedUser(ctx)
+func (r *mutationResolver) StartScan(ctx context.Context, assetID string, engine *string, profileID *string) (*model.Scan, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
 	if err != nil {
 		return nil, err
 	}
 
-	// Verify asset belongs to user
+	// Verify asset belongs to the user's organization
 	assetIDInt, err := strconv.Atoi(assetID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid asset ID")
 	}
 
-	var asset db.Asset
-	query := `SELECT id, name, target FROM assets WHERE id = $1 AND user_id = $2`
-	err = r.DB.QueryRow(query, assetIDInt, user.UserID).Scan(&asset.ID, &asset.Name, &asset.Target)
+	if _, err := r.getOwnedAsset(user, assetIDInt); err != nil {
+		return nil, err
+	}
+
+	// Verify the profile override is usable by the organization
+	profileIDInt, err := parseOptionalID(profileID, "scan profile")
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("asset not found")
+		return nil, err
+	}
+	if profileIDInt != nil {
+		if _, err := r.getUsableProfile(user, *profileIDInt); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to find asset: %w", err)
 	}
 
 	// Start scan using ScanManager
-	scan, err := r.ScanManager.StartScan(assetIDInt)
+	engineName := ""
+	if engine != nil {
+		engineName = *engine
+	}
+	scan, err := r.ScanManager.StartScan(assetIDInt, user.UserID, engineName, profileIDInt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start scan: %w", err)
 	}
 
-	return &model.Scan{
-		ID:        strconv.Itoa(scan.ID),
-		Status:    string(scan.Status),
-		StartedAt: scan.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return toModelScan(scan), nil
+}
+
+// CancelScan is the resolver for the cancelScan field.
+func (r *mutationResolver) CancelScan(ctx context.Context, id string) (*model.Scan, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	scanID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan ID")
+	}
+
+	// Verify scan belongs to the organization's asset
+	if err := r.checkScan(user, scanID); err != nil {
+		return nil, err
+	}
+
+	if err := r.ScanManager.CancelScan(scanID); err != nil {
+		return nil, fmt.Errorf("failed to cancel scan: %w", err)
+	}
+
+	return r.Query().Scan(ctx, id)
 }
 
 // Me is the resolver for the me field.
 func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
-	user, err := r.getAuthenticatedUser(ctx)
+	user, err := r.getSignedInUser(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var dbUser db.User
-	query := `SELECT id, email, role, created_at FROM users WHERE id = $1`
-	err = r.DB.QueryRow(query, user.UserID).Scan(
-		&dbUser.ID, &dbUser.Email, &dbUser.Role, &dbUser.CreatedAt,
-	)
+	dbUser, err := r.Store.Users.Get(user.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
 
-	return &model.User{
-		ID:        strconv.Itoa(dbUser.ID),
-		Email:     dbUser.Email,
-		Role:      dbUser.Role,
-		CreatedAt: dbUser.CreatedAt.Format(time.RFC3339),
-	}, nil
+	// Users removed from the organization since the token was issued have no role in it
+	member, err := r.getMember(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	role := ""
+	if member != nil {
+		role = member.Role
+	}
+
+	return toModelUser(dbUser, role), nil
 }
 
 // Assets is the resolver for the assets field.
-func (r *queryResolver) Assets(ctx context.Context) ([]*model.Asset, error) {
+func (r *queryResolver) Assets(ctx context.Context, first *int, after *string, last *int, before *string, filter *model.AssetFilter, orderBy *model.AssetOrder) (*model.AssetConnection, error) {
 	user, err := r.getAuthenticatedUser(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	query := `SELECT id, name, target, asset_type, created_at, last_scanned_at FROM assets WHERE user_id = $1 ORDER BY created_at DESC`
-	rows, err := r.DB.Query(query, user.UserID)
+	assetFilter, err := assetFilterFromInput(filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query assets: %w", err)
+		return nil, err
+	}
+	order, err := assetOrderFromInput(orderBy)
+	if err != nil {
+		return nil, err
 	}
-	defer rows.Close()
 
-	var assets []*model.Asset
-	for rows.Next() {
-		var asset db.Asset
-		err := rows.Scan(&asset.ID, &asset.Name, &asset.Target, &asset.AssetType, &asset.CreatedAt, &asset.LastScannedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan asset: %w", err)
-		}
+	total, err := r.Store.Assets.Count(user.OrgID, assetFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count assets: %w", err)
+	}
+	page, err := pageWindow(first, after, last, before, total)
+	if err != nil {
+		return nil, err
+	}
 
-		var lastScannedAt *string
-		if asset.LastScannedAt != nil {
-			formatted := asset.LastScannedAt.Format(time.RFC3339)
-			lastScannedAt = &formatted
+	var assets []*db.Asset
+	if page.Limit > 0 {
+		if assets, err = r.Store.Assets.Find(user.OrgID, assetFilter, order, page); err != nil {
+			return nil, fmt.Errorf("failed to query assets: %w", err)
 		}
+	}
 
-		assets = append(assets, &model.Asset{
-			ID:            strconv.Itoa(asset.ID),
-			Name:          asset.Name,
-			Target:        asset.Target,
-			AssetType:     asset.AssetType,
-			CreatedAt:     asset.CreatedAt.Format(time.RFC3339),
-			LastScannedAt: lastScannedAt,
-		})
+	// Nested resolvers that need these assets find them already loaded
+	loaders := r.loaders(ctx)
+	for _, asset := range assets {
+		loaders.Assets.Prime(asset.ID, asset)
 	}
 
-	return assets, nil
+	return toModelAssetConnection(assets, page, total), nil
 }
 
 // Asset is the resolver for the asset field.
@@ -266,132 +284,57 @@ func (r *queryResolver) Asset(ctx context.Context, id string) (*model.Asset, err
 		return nil, fmt.Errorf("invalid asset ID")
 	}
 
-	var asset db.Asset
-	query := `SELECT id, name, target, asset_type, created_at, last_scanned_at FROM assets WHERE id = $1 AND user_id = $2`
-	err = r.DB.QueryRow(query, assetID, user.UserID).Scan(
-		&asset.ID, &asset.Name, &asset.Target, &asset.AssetType, &asset.CreatedAt, &asset.LastScannedAt,
-	)
+	asset, err := r.getOwnedAsset(user, assetID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("asset not found")
-		}
-		return nil, fmt.Errorf("failed to find asset: %w", err)
-	}
-
-	var lastScannedAt *string
-	if asset.LastScannedAt != nil {
-		formatted := asset.LastScannedAt.Format(time.RFC3339)
-		lastScannedAt = &formatted
+		return nil, err
 	}
 
-	return &model.Asset{
-		ID:            strconv.Itoa(asset.ID),
-		Name:          asset.Name,
-		Target:        asset.Target,
-		AssetType:     asset.AssetType,
-		CreatedAt:     asset.CreatedAt.Format(time.RFC3339),
-		LastScannedAt: lastScannedAt,
-	}, nil
+	return toModelAsset(asset), nil
 }
 
 // Scans is the resolver for the scans field.
-func (r *queryResolver) Scans(ctx context.Context, assetID *string) ([]*model.Scan, error) {
+func (r *queryResolver) Scans(ctx context.Context, assetID *string, first *int, after *string, last *int, before *string, filter *model.ScanFilter, orderBy *model.ScanOrder) (*model.ScanConnection, error) {
 	user, err := r.getAuthenticatedUser(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if assetID != nil {
-		// Get scans for specific asset
-		assetIDInt, err := strconv.Atoi(*assetID)
-		if err != nil {
-			return nil, fmt.Errorf("invalid asset ID")
-		}
-
-		// Verify asset belongs to user
-		var userID int
-		query := `SELECT user_id FROM assets WHERE id = $1`
-		err = r.DB.QueryRow(query, assetIDInt).Scan(&userID)
-		if err != nil {
-			return nil, fmt.Errorf("asset not found")
-		}
-		if userID != user.UserID {
-			return nil, fmt.Errorf("unauthorized")
-		}
-
-		// Get scans using ScanManager
-		scans, err := r.ScanManager.GetScansByAsset(assetIDInt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get scans: %w", err)
-		}
-
-		var result []*model.Scan
-		for _, scan := range scans {
-			var completedAt *string
-			if scan.Status == "completed" || scan.Status == "failed" {
-				formatted := scan.UpdatedAt.Format(time.RFC3339)
-				completedAt = &formatted
-			}
-
-			var errorMessage *string
-			if scan.Error != nil {
-				errorMessage = scan.Error
-			}
+	scanFilter, err := scanFilterFromInput(filter)
+	if err != nil {
+		return nil, err
+	}
+	order, err := scanOrderFromInput(orderBy)
+	if err != nil {
+		return nil, err
+	}
 
-			result = append(result, &model.Scan{
-				ID:           strconv.Itoa(scan.ID),
-				Status:       string(scan.Status),
-				StartedAt:    scan.CreatedAt.Format(time.RFC3339),
-				CompletedAt:  completedAt,
-				ErrorMessage: errorMessage,
-			})
+	// Limit to the scans of one asset, which must belong to the user
+	if scanFilter.AssetID, err = parseOptionalID(assetID, "asset"); err != nil {
+		return nil, err
+	}
+	if scanFilter.AssetID != nil {
+		if _, err := r.loadOwnedAsset(ctx, user, *scanFilter.AssetID); err != nil {
+			return nil, err
 		}
-
-		return result, nil
 	}
 
-	// Get all scans for user's assets
-	query := `
-		SELECT s.id, s.asset_id, s.status, s.created_at, s.updated_at, s.error
-		FROM scans s
-		JOIN assets a ON s.asset_id = a.id
-		WHERE a.user_id = $1
-		ORDER BY s.created_at DESC`
-
-	rows, err := r.DB.Query(query, user.UserID)
+	total, err := r.Store.Scans.Count(user.OrgID, scanFilter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query scans: %w", err)
+		return nil, fmt.Errorf("failed to count scans: %w", err)
+	}
+	page, err := pageWindow(first, after, last, before, total)
+	if err != nil {
+		return nil, err
 	}
-	defer rows.Close()
-
-	var scans []*model.Scan
-	for rows.Next() {
-		var scanID, assetID int
-		var status string
-		var createdAt, updatedAt time.Time
-		var errorMsg *string
-
-		err := rows.Scan(&scanID, &assetID, &status, &createdAt, &updatedAt, &errorMsg)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
 
-		var completedAt *string
-		if status == "completed" || status == "failed" {
-			formatted := updatedAt.Format(time.RFC3339)
-			completedAt = &formatted
+	var scans []*store.Scan
+	if page.Limit > 0 {
+		if scans, err = r.Store.Scans.Find(user.OrgID, scanFilter, order, page); err != nil {
+			return nil, fmt.Errorf("failed to get scans: %w", err)
 		}
-
-		scans = append(scans, &model.Scan{
-			ID:           strconv.Itoa(scanID),
-			Status:       status,
-			StartedAt:    createdAt.Format(time.RFC3339),
-			CompletedAt:  completedAt,
-			ErrorMessage: errorMsg,
-		})
 	}
 
-	return scans, nil
+	return toModelScanConnection(scans, page, total), nil
 }
 
 // Scan is the resolver for the scan field.
@@ -406,20 +349,9 @@ func (r *queryResolver) Scan(ctx context.Context, id string) (*model.Scan, error
 		return nil, fmt.Errorf("invalid scan ID")
 	}
 
-	// Verify scan belongs to user's asset
-	var userID int
-	query := `
-		SELECT a.user_id 
-		FROM scans s
-		JOIN assets a ON s.asset_id = a.id
-		WHERE s.id = $1`
-
-	err = r.DB.QueryRow(query, scanID).Scan(&userID)
-	if err != nil {
-		return nil, fmt.Errorf("scan not found")
-	}
-	if userID != user.UserID {
-		return nil, fmt.Errorf("unauthorized")
+	// Verify scan belongs to the organization's asset
+	if err := r.checkScan(user, scanID); err != nil {
+		return nil, err
 	}
 
 	// Get scan using ScanManager
@@ -428,114 +360,1382 @@ func (r *queryResolver) Scan(ctx context.Context, id string) (*model.Scan, error
 		return nil, fmt.Errorf("failed to get scan: %w", err)
 	}
 
-	var completedAt *string
-	if scan.Status == "completed" || scan.Status == "failed" {
-		formatted := scan.UpdatedAt.Format(time.RFC3339)
-		completedAt = &formatted
-	}
+	return toModelScan(scan), nil
+}
 
-	var errorMessage *string
-	if scan.Error != nil {
-		errorMessage = scan.Error
+// ScanEngines is the resolver for the scanEngines field.
+func (r *queryResolver) ScanEngines(ctx context.Context) ([]string, error) {
+	if _, err := r.getAuthenticatedUser(ctx); err != nil {
+		return nil, err
 	}
 
-	return &model.Scan{
-		ID:           strconv.Itoa(scan.ID),
-		Status:       string(scan.Status),
-		StartedAt:    scan.CreatedAt.Format(time.RFC3339),
-		CompletedAt:  completedAt,
-		ErrorMessage: errorMessage,
-	}, nil
+	return r.ScanManager.Engines().Names(), nil
 }
 
 // Scans is the resolver for the scans field.
-func (r *assetResolver) Scans(ctx context.Context, obj *model.Asset) ([]*model.Scan, error) {
-	assetID := obj.ID
-	return r.Query().Scans(ctx, &assetID)
+func (r *assetResolver) Scans(ctx context.Context, obj *model.Asset, first *int, after *string, last *int, before *string, filter *model.ScanFilter, orderBy *model.ScanOrder) (*model.ScanConnection, error) {
+	// Filtered or sorted scans are queried per asset
+	if filter != nil || orderBy != nil {
+		id := obj.ID
+		return r.Query().Scans(ctx, &id, first, after, last, before, filter, orderBy)
+	}
+
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assetID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset ID")
+	}
+	if _, err := r.loadOwnedAsset(ctx, user, assetID); err != nil {
+		return nil, err
+	}
+
+	// Otherwise every asset's scans are counted and paged in one batch, newest
+	// first like the scans connection
+	total, err := r.loaders(ctx).ScanCountsByAsset.Load(assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count scans: %w", err)
+	}
+	page, err := pageWindow(first, after, last, before, total)
+	if err != nil {
+		return nil, err
+	}
+
+	var scans []*store.Scan
+	if page.Limit > 0 {
+		if scans, err = r.loaders(ctx).ScansByAsset.Load(loader.ScanPageKey{AssetID: assetID, Page: page}); err != nil {
+			return nil, fmt.Errorf("failed to get scans: %w", err)
+		}
+	}
+
+	return toModelScanConnection(scans, page, total), nil
 }
 
 // Asset is the resolver for the asset field.
 func (r *scanResolver) Asset(ctx context.Context, obj *model.Scan) (*model.Asset, error) {
-	// Get asset ID from scan
+	return r.resolveAsset(ctx, obj.AssetID)
+}
+
+// Results is the resolver for the results field.
+func (r *scanResolver) Results(ctx context.Context, obj *model.Scan) ([]*model.ScanResult, error) {
 	scanID, err := strconv.Atoi(obj.ID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid scan ID")
 	}
 
-	var assetID int
-	query := `SELECT asset_id FROM scans WHERE id = $1`
-	err = r.DB.QueryRow(query, scanID).Scan(&assetID)
+	results, err := r.loaders(ctx).ResultsByScan.Load(scanID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find asset for scan: %w", err)
+		return nil, fmt.Errorf("failed to get scan results: %w", err)
+	}
+
+	var modelResults []*model.ScanResult
+	for _, result := range results {
+		modelResults = append(modelResults, toModelScanResult(result))
 	}
 
-	return r.Query().Asset(ctx, strconv.Itoa(assetID))
+	return modelResults, nil
 }
 
-// Results is the resolver for the results field.
-func (r *scanResolver) Results(ctx context.Context, obj *model.Scan) ([]*model.ScanResult, error) {
+// Hosts is the resolver for the hosts field.
+func (r *scanResolver) Hosts(ctx context.Context, obj *model.Scan) ([]*model.ScanHost, error) {
 	scanID, err := strconv.Atoi(obj.ID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid scan ID")
 	}
 
-	// Get scan results using ScanManager
-	results, err := r.ScanManager.GetScanResults(scanID)
+	results, err := r.loaders(ctx).ResultsByScan.Load(scanID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get scan results: %w", err)
 	}
 
-	var modelResults []*model.ScanResult
+	// Results are ordered by host, so each host's results are contiguous
+	var hosts []*model.ScanHost
 	for _, result := range results {
-		modelResults = append(modelResults, &model.ScanResult{
-			ID:       strconv.Itoa(result.Port), // Using port as ID for now
-			Port:     result.Port,
-			Protocol: result.Protocol,
-			State:    result.State,
-			Service:  &result.Service,
-			Version:  &result.Version,
-			Banner:   &result.Banner,
-		})
+		if len(hosts) == 0 || hosts[len(hosts)-1].Address != result.Host {
+			var hostname *string
+			if result.Hostname != "" {
+				hostname = &result.Hostname
+			}
+			hosts = append(hosts, &model.ScanHost{
+				Address:  result.Host,
+				Hostname: hostname,
+			})
+		}
+		current := hosts[len(hosts)-1]
+		current.Results = append(current.Results, toModelScanResult(result))
 	}
 
-	return modelResults, nil
+	return hosts, nil
 }
 
 // ExportScans is the resolver for the exportScans field.
 func (r *mutationResolver) ExportScans(ctx context.Context, assetID *string) (string, error) {
 	// Get authenticated user
-	user, err := r.getAuthenticatedUser(ctx)
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst, auth.RoleAuditor)
 	if err != nil {
 		return "", err
 	}
-	_ = user // User is authenticated, proceed
 
 	// Create CSV exporter
-	csvExporter := export.NewCSVExporter(r.DB)
+	csvExporter := export.NewCSVExporter(r.Store, r.Exceptions)
 
 	// Export scans based on assetID parameter
 	if assetID != nil {
-		// Export scans for specific asset
-		return csvExporter.ExportScanResults(*assetID)
+		// Export scans for specific asset of the organization
+		return csvExporter.ExportScanResults(user.OrgID, *assetID)
 	}
 
-	// Export all scans
-	return csvExporter.ExportAllScans()
+	// Export all of the organization's scans
+	return csvExporter.ExportAllScans(user.OrgID)
 }
 
-// Mutation returns MutationResolver implementation.
-func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
+// ScanProfiles is the resolver for the scanProfiles field.
+func (r *queryResolver) ScanProfiles(ctx context.Context) ([]*model.ScanProfile, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-// Query returns QueryResolver implementation.
-func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
+	profiles, err := r.ScanManager.ListProfiles(user.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan profiles: %w", err)
+	}
 
-// Asset returns AssetResolver implementation.
-func (r *Resolver) Asset() generated.AssetResolver { return &assetResolver{r} }
+	var result []*model.ScanProfile
+	for _, profile := range profiles {
+		result = append(result, toModelScanProfile(profile))
+	}
 
-// Scan returns ScanResolver implementation.
-func (r *Resolver) Scan() generated.ScanResolver { return &scanResolver{r} }
+	return result, nil
+}
+
+// ScanProfile is the resolver for the scanProfile field.
+func (r *queryResolver) ScanProfile(ctx context.Context, id string) (*model.ScanProfile, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profileID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan profile ID")
+	}
+
+	profile, err := r.getUsableProfile(user, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelScanProfile(profile), nil
+}
+
+// CreateScanProfile is the resolver for the createScanProfile field.
+func (r *mutationResolver) CreateScanProfile(ctx context.Context, input model.ScanProfileInput) (*model.ScanProfile, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := r.ScanManager.CreateProfile(user.OrgID, user.UserID, profileFromInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan profile: %w", err)
+	}
+
+	return toModelScanProfile(profile), nil
+}
+
+// UpdateScanProfile is the resolver for the updateScanProfile field.
+func (r *mutationResolver) UpdateScanProfile(ctx context.Context, id string, input model.ScanProfileInput) (*model.ScanProfile, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	profileID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan profile ID")
+	}
+
+	profile := profileFromInput(input)
+	profile.ID = profileID
+
+	updated, err := r.ScanManager.UpdateProfile(user.OrgID, profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update scan profile: %w", err)
+	}
+
+	return toModelScanProfile(updated), nil
+}
+
+// DeleteScanProfile is the resolver for the deleteScanProfile field.
+func (r *mutationResolver) DeleteScanProfile(ctx context.Context, id string) (bool, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return false, err
+	}
+
+	profileID, err := strconv.Atoi(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid scan profile ID")
+	}
+
+	return r.ScanManager.DeleteProfile(user.OrgID, profileID)
+}
+
+// SetAssetScanProfile is the resolver for the setAssetScanProfile field.
+func (r *mutationResolver) SetAssetScanProfile(ctx context.Context, assetID string, profileID *string) (*model.Asset, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	assetIDInt, err := strconv.Atoi(assetID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset ID")
+	}
+
+	profileIDInt, err := parseOptionalID(profileID, "scan profile")
+	if err != nil {
+		return nil, err
+	}
+	if profileIDInt != nil {
+		if _, err := r.getUsableProfile(user, *profileIDInt); err != nil {
+			return nil, err
+		}
+	}
+
+	asset, err := r.getOwnedAsset(user, assetIDInt)
+	if err != nil {
+		return nil, err
+	}
+	asset.ScanProfileID = profileIDInt
+	if err := r.Store.Assets.Update(asset); err != nil {
+		return nil, err
+	}
+
+	return toModelAsset(asset), nil
+}
+
+// ScanProfile is the resolver for the scanProfile field.
+func (r *assetResolver) ScanProfile(ctx context.Context, obj *model.Asset) (*model.ScanProfile, error) {
+	if obj.ScanProfileID == nil {
+		return nil, nil
+	}
+
+	profile, err := r.ScanManager.GetProfile(*obj.ScanProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan profile: %w", err)
+	}
+
+	return toModelScanProfile(profile), nil
+}
+
+// Profile is the resolver for the profile field.
+func (r *scanResolver) Profile(ctx context.Context, obj *model.Scan) (*model.ScanProfile, error) {
+	if obj.ProfileID == nil {
+		return nil, nil
+	}
+
+	profile, err := r.ScanManager.GetProfile(*obj.ProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan profile: %w", err)
+	}
+
+	return toModelScanProfile(profile), nil
+}
+
+// AssetGroups is the resolver for the assetGroups field.
+func (r *queryResolver) AssetGroups(ctx context.Context) ([]*model.AssetGroup, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := r.Store.AssetGroups.ListByOrganization(user.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query asset groups: %w", err)
+	}
+
+	var result []*model.AssetGroup
+	for _, group := range groups {
+		result = append(result, toModelAssetGroup(group))
+	}
+
+	return result, nil
+}
+
+// CreateAssetGroup is the resolver for the createAssetGroup field.
+func (r *mutationResolver) CreateAssetGroup(ctx context.Context, name string) (*model.AssetGroup, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("asset group name cannot be empty")
+	}
+
+	group, err := r.Store.AssetGroups.Create(user.UserID, user.OrgID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create asset group: %w", err)
+	}
+
+	return toModelAssetGroup(group), nil
+}
+
+// DeleteAssetGroup is the resolver for the deleteAssetGroup field.
+func (r *mutationResolver) DeleteAssetGroup(ctx context.Context, id string) (bool, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return false, err
+	}
+
+	groupID, err := strconv.Atoi(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid asset group ID")
+	}
+
+	// Member assets are kept and simply leave the group
+	deleted, err := r.Store.AssetGroups.Delete(user.OrgID, groupID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete asset group: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// SetAssetGroup is the resolver for the setAssetGroup field.
+func (r *mutationResolver) SetAssetGroup(ctx context.Context, assetID string, groupID *string) (*model.Asset, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	assetIDInt, err := strconv.Atoi(assetID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset ID")
+	}
+
+	groupIDInt, err := parseOptionalID(groupID, "asset group")
+	if err != nil {
+		return nil, err
+	}
+	if groupIDInt != nil {
+		if err := r.checkAssetGroup(user, *groupIDInt); err != nil {
+			return nil, err
+		}
+	}
+
+	asset, err := r.getOwnedAsset(user, assetIDInt)
+	if err != nil {
+		return nil, err
+	}
+	asset.GroupID = groupIDInt
+	if err := r.Store.Assets.Update(asset); err != nil {
+		return nil, err
+	}
+
+	return toModelAsset(asset), nil
+}
+
+// SetAssetRiskContext is the resolver for the setAssetRiskContext field.
+func (r *mutationResolver) SetAssetRiskContext(ctx context.Context, assetID string, criticality *string, internetFacing *bool) (*model.Asset, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	assetIDInt, err := strconv.Atoi(assetID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset ID")
+	}
+
+	if criticality != nil && !risk.ValidCriticality(*criticality) {
+		return nil, fmt.Errorf("invalid criticality %q", *criticality)
+	}
+
+	asset, err := r.getOwnedAsset(user, assetIDInt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unset arguments keep their current values
+	if criticality != nil {
+		asset.Criticality = *criticality
+	}
+	if internetFacing != nil {
+		asset.InternetFacing = *internetFacing
+	}
+	if err := r.Store.Assets.Update(asset); err != nil {
+		return nil, err
+	}
+
+	// Re-score the latest scan with the new context
+	if err := r.Scorer.RescoreAsset(assetIDInt); err != nil {
+		return nil, fmt.Errorf("failed to score asset: %w", err)
+	}
+
+	return r.Query().Asset(ctx, assetID)
+}
+
+// Group is the resolver for the group field.
+func (r *assetResolver) Group(ctx context.Context, obj *model.Asset) (*model.AssetGroup, error) {
+	if obj.GroupID == nil {
+		return nil, nil
+	}
+
+	return r.getAssetGroup(*obj.GroupID)
+}
+
+// Assets is the resolver for the assets field.
+func (r *assetGroupResolver) Assets(ctx context.Context, obj *model.AssetGroup) ([]*model.Asset, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groupID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset group ID")
+	}
+
+	assets, err := r.Store.Assets.ListByGroup(user.OrgID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assets: %w", err)
+	}
+
+	var result []*model.Asset
+	for _, asset := range assets {
+		result = append(result, toModelAsset(asset))
+	}
+
+	return result, nil
+}
+
+// ScanSchedules is the resolver for the scanSchedules field.
+func (r *queryResolver) ScanSchedules(ctx context.Context) ([]*model.ScanSchedule, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := r.Scheduler.ListSchedules(user.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*model.ScanSchedule
+	for _, schedule := range schedules {
+		result = append(result, toModelScanSchedule(schedule))
+	}
+
+	return result, nil
+}
+
+// ScanSchedule is the resolver for the scanSchedule field.
+func (r *queryResolver) ScanSchedule(ctx context.Context, id string) (*model.ScanSchedule, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule ID")
+	}
+
+	schedule, err := r.Scheduler.GetSchedule(user.OrgID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelScanSchedule(schedule), nil
+}
+
+// CreateScanSchedule is the resolver for the createScanSchedule field.
+func (r *mutationResolver) CreateScanSchedule(ctx context.Context, input model.ScanScheduleInput) (*model.ScanSchedule, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := r.scheduleFromInput(user, input)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := r.Scheduler.CreateSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelScanSchedule(created), nil
+}
+
+// UpdateScanSchedule is the resolver for the updateScanSchedule field.
+func (r *mutationResolver) UpdateScanSchedule(ctx context.Context, id string, input model.ScanScheduleInput) (*model.ScanSchedule, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule ID")
+	}
+
+	schedule, err := r.scheduleFromInput(user, input)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = scheduleID
+
+	updated, err := r.Scheduler.UpdateSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelScanSchedule(updated), nil
+}
+
+// DeleteScanSchedule is the resolver for the deleteScanSchedule field.
+func (r *mutationResolver) DeleteScanSchedule(ctx context.Context, id string) (bool, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return false, err
+	}
+
+	scheduleID, err := strconv.Atoi(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid schedule ID")
+	}
+
+	return r.Scheduler.DeleteSchedule(user.OrgID, scheduleID)
+}
+
+// Asset is the resolver for the asset field.
+func (r *scanScheduleResolver) Asset(ctx context.Context, obj *model.ScanSchedule) (*model.Asset, error) {
+	if obj.AssetID == nil {
+		return nil, nil
+	}
+
+	return r.resolveAsset(ctx, *obj.AssetID)
+}
+
+// Group is the resolver for the group field.
+func (r *scanScheduleResolver) Group(ctx context.Context, obj *model.ScanSchedule) (*model.AssetGroup, error) {
+	if obj.GroupID == nil {
+		return nil, nil
+	}
+
+	return r.getAssetGroup(*obj.GroupID)
+}
+
+// Profile is the resolver for the profile field.
+func (r *scanScheduleResolver) Profile(ctx context.Context, obj *model.ScanSchedule) (*model.ScanProfile, error) {
+	if obj.ProfileID == nil {
+		return nil, nil
+	}
+
+	profile, err := r.ScanManager.GetProfile(*obj.ProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan profile: %w", err)
+	}
+
+	return toModelScanProfile(profile), nil
+}
+
+// ScanDiff is the resolver for the scanDiff field.
+func (r *queryResolver) ScanDiff(ctx context.Context, baseScanID string, targetScanID string) (*model.ScanDiff, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	baseID, err := strconv.Atoi(baseScanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base scan ID")
+	}
+	targetID, err := strconv.Atoi(targetScanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target scan ID")
+	}
+
+	// Both scans must belong to assets of the user's organization
+	for _, scanID := range []int{baseID, targetID} {
+		if err := r.checkScan(user, scanID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Prefer the stored diff, computing one on demand for arbitrary pairs
+	diff, err := r.ScanManager.GetScanDiff(baseID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if diff == nil {
+		if diff, err = r.ScanManager.DiffScans(baseID, targetID); err != nil {
+			return nil, err
+		}
+	}
+
+	return toModelScanDiff(diff), nil
+}
+
+// Diff is the resolver for the diff field.
+func (r *scanResolver) Diff(ctx context.Context, obj *model.Scan) (*model.ScanDiff, error) {
+	scanID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan ID")
+	}
+
+	diff, err := r.ScanManager.GetLatestScanDiff(scanID)
+	if err != nil {
+		return nil, err
+	}
+	if diff == nil {
+		return nil, nil
+	}
+
+	return toModelScanDiff(diff), nil
+}
+
+// BaseScan is the resolver for the baseScan field.
+func (r *scanDiffResolver) BaseScan(ctx context.Context, obj *model.ScanDiff) (*model.Scan, error) {
+	return r.Query().Scan(ctx, strconv.Itoa(obj.BaseScanID))
+}
+
+// TargetScan is the resolver for the targetScan field.
+func (r *scanDiffResolver) TargetScan(ctx context.Context, obj *model.ScanDiff) (*model.Scan, error) {
+	return r.Query().Scan(ctx, strconv.Itoa(obj.TargetScanID))
+}
+
+// Vulnerabilities is the resolver for the vulnerabilities field.
+func (r *assetResolver) Vulnerabilities(ctx context.Context, obj *model.Asset) ([]*model.Vulnerability, error) {
+	assetID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset ID")
+	}
+
+	vulnerabilities, err := r.Vulns.GetAssetVulnerabilities(assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*model.Vulnerability{}
+	for _, v := range vulnerabilities {
+		result = append(result, toModelVulnerability(v))
+	}
+
+	return result, nil
+}
+
+// Vulnerabilities is the resolver for the vulnerabilities field.
+func (r *scanResultResolver) Vulnerabilities(ctx context.Context, obj *model.ScanResult) ([]*model.Vulnerability, error) {
+	resultID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan result ID")
+	}
+
+	vulnerabilities, err := r.Vulns.GetResultVulnerabilities(resultID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*model.Vulnerability{}
+	for _, v := range vulnerabilities {
+		result = append(result, toModelVulnerability(v))
+	}
+
+	return result, nil
+}
+
+// Risk is the resolver for the risk field.
+func (r *assetResolver) Risk(ctx context.Context, obj *model.Asset) (*model.RiskScore, error) {
+	assetID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset ID")
+	}
+
+	score, err := r.Scorer.GetAssetScore(assetID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelRiskScore(score), nil
+}
+
+// Risk is the resolver for the risk field.
+func (r *scanResultResolver) Risk(ctx context.Context, obj *model.ScanResult) (*model.RiskScore, error) {
+	resultID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan result ID")
+	}
+
+	score, err := r.Scorer.GetResultScore(resultID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelRiskScore(score), nil
+}
+
+// RiskRules is the resolver for the riskRules field.
+func (r *queryResolver) RiskRules(ctx context.Context) (*model.RiskRuleSet, error) {
+	if _, err := r.getAuthenticatedUser(ctx); err != nil {
+		return nil, err
+	}
+
+	return toModelRiskRuleSet(r.Rules), nil
+}
+
+// TestRiskRules is the resolver for the testRiskRules field.
+func (r *queryResolver) TestRiskRules(ctx context.Context, scanResultID string, rules *string) ([]*model.RiskRuleTest, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultID, err := strconv.Atoi(scanResultID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan result ID")
+	}
+
+	// Draft rules are validated exactly as rules files are, without being loaded
+	set := r.Rules.Rules()
+	if rules != nil {
+		set, err = risk.ParseRules([]byte(*rules), "draft")
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules: %w", err)
+		}
+	}
+
+	input, err := r.Scorer.LoadResultInput(resultID, user.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if input == nil {
+		return nil, fmt.Errorf("scan result not found")
+	}
+
+	tests := []*model.RiskRuleTest{}
+	for _, rule := range set.Rules {
+		tests = append(tests, &model.RiskRuleTest{
+			Rule:    toModelRiskRule(rule),
+			Matched: rule.Matches(input),
+		})
+	}
+
+	return tests, nil
+}
+
+// ReloadRiskRules is the resolver for the reloadRiskRules field.
+func (r *mutationResolver) ReloadRiskRules(ctx context.Context) (*model.RiskRuleSet, error) {
+	if _, err := r.getAuthorizedUser(ctx, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Rules.Reload(); err != nil {
+		return nil, fmt.Errorf("failed to reload risk rules: %w", err)
+	}
+
+	return toModelRiskRuleSet(r.Rules), nil
+}
+
+// Findings is the resolver for the findings field.
+func (r *queryResolver) Findings(ctx context.Context, assetID *string, status *string) ([]*model.Finding, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter findings.Filter
+	if filter.AssetID, err = parseOptionalID(assetID, "asset"); err != nil {
+		return nil, err
+	}
+	if status != nil {
+		filter.Status = *status
+	}
+
+	list, err := r.FindingTracker.List(user.OrgID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*model.Finding{}
+	for _, finding := range list {
+		result = append(result, toModelFinding(finding))
+	}
+	return result, nil
+}
+
+// Finding is the resolver for the finding field.
+func (r *queryResolver) Finding(ctx context.Context, id string) (*model.Finding, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	findingID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finding ID")
+	}
+
+	finding, err := r.FindingTracker.Get(user.OrgID, findingID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelFinding(finding), nil
+}
+
+// SetFindingStatus is the resolver for the setFindingStatus field.
+func (r *mutationResolver) SetFindingStatus(ctx context.Context, id string, status string, comment *string) (*model.Finding, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	findingID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finding ID")
+	}
+
+	finding, err := r.FindingTracker.SetStatus(user.OrgID, findingID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record why the status changed alongside the other comments
+	if comment != nil && *comment != "" {
+		if _, err := r.FindingTracker.AddComment(user.OrgID, user.UserID, findingID, *comment); err != nil {
+			return nil, err
+		}
+	}
+
+	return toModelFinding(finding), nil
+}
+
+// AssignFinding is the resolver for the assignFinding field.
+func (r *mutationResolver) AssignFinding(ctx context.Context, id string, assigneeID *string) (*model.Finding, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	findingID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finding ID")
+	}
+
+	assignee, err := parseOptionalID(assigneeID, "assignee")
+	if err != nil {
+		return nil, err
+	}
+
+	finding, err := r.FindingTracker.Assign(user.OrgID, findingID, assignee)
+	if err != nil {
+		return nil, err
+	}
+	return toModelFinding(finding), nil
+}
+
+// AddFindingComment is the resolver for the addFindingComment field.
+func (r *mutationResolver) AddFindingComment(ctx context.Context, findingID string, body string) (*model.FindingComment, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	findingIDInt, err := strconv.Atoi(findingID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finding ID")
+	}
+
+	comment, err := r.FindingTracker.AddComment(user.OrgID, user.UserID, findingIDInt, strings.TrimSpace(body))
+	if err != nil {
+		return nil, err
+	}
+	return toModelFindingComment(comment), nil
+}
+
+// Asset is the resolver for the asset field.
+func (r *findingResolver) Asset(ctx context.Context, obj *model.Finding) (*model.Asset, error) {
+	return r.resolveAsset(ctx, obj.AssetID)
+}
+
+// Assignee is the resolver for the assignee field.
+func (r *findingResolver) Assignee(ctx context.Context, obj *model.Finding) (*model.User, error) {
+	if obj.AssigneeID == nil {
+		return nil, nil
+	}
+	return r.getUser(ctx, *obj.AssigneeID)
+}
+
+// Comments is the resolver for the comments field.
+func (r *findingResolver) Comments(ctx context.Context, obj *model.Finding) ([]*model.FindingComment, error) {
+	findingID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finding ID")
+	}
+
+	comments, err := r.FindingTracker.Comments(findingID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*model.FindingComment{}
+	for _, comment := range comments {
+		result = append(result, toModelFindingComment(comment))
+	}
+	return result, nil
+}
+
+// Author is the resolver for the author field.
+func (r *findingCommentResolver) Author(ctx context.Context, obj *model.FindingComment) (*model.User, error) {
+	if obj.AuthorID == nil {
+		return nil, nil
+	}
+	return r.getUser(ctx, *obj.AuthorID)
+}
+
+// RiskExceptions is the resolver for the riskExceptions field.
+func (r *queryResolver) RiskExceptions(ctx context.Context, includeInactive *bool) ([]*model.RiskException, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exceptions, err := r.Exceptions.List(user.OrgID, includeInactive != nil && *includeInactive)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*model.RiskException{}
+	for _, exception := range exceptions {
+		result = append(result, toModelRiskException(exception))
+	}
+	return result, nil
+}
+
+// CreateRiskException is the resolver for the createRiskException field.
+func (r *mutationResolver) CreateRiskException(ctx context.Context, input model.RiskExceptionInput) (*model.RiskException, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	exception, err := r.exceptionFromInput(user, input)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := r.Exceptions.Create(exception)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accept the covered findings and drop them from the affected assets' scores
+	assetIDs, err := r.Exceptions.AffectedAssets(created)
+	if err != nil {
+		return nil, err
+	}
+	for _, assetID := range assetIDs {
+		if _, err := r.FindingTracker.ApplyExceptions(assetID); err != nil {
+			return nil, err
+		}
+		if err := r.Scorer.RescoreAsset(assetID); err != nil {
+			return nil, fmt.Errorf("failed to score asset: %w", err)
+		}
+	}
+
+	return toModelRiskException(created), nil
+}
+
+// RevokeRiskException is the resolver for the revokeRiskException field.
+func (r *mutationResolver) RevokeRiskException(ctx context.Context, id string) (*model.RiskException, error) {
+	user, err := r.getAuthorizedUser(ctx, auth.RoleAnalyst)
+	if err != nil {
+		return nil, err
+	}
+
+	exceptionID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exception ID")
+	}
+
+	exception, err := r.Exceptions.Revoke(user.OrgID, exceptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-open the findings it accepted straight away rather than on the next expiry check
+	if err := r.FindingTracker.ExpireExceptions(); err != nil {
+		return nil, err
+	}
+
+	return toModelRiskException(exception), nil
+}
+
+// Exception is the resolver for the exception field.
+func (r *findingResolver) Exception(ctx context.Context, obj *model.Finding) (*model.RiskException, error) {
+	if obj.ExceptionID == nil {
+		return nil, nil
+	}
+
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exception, err := r.Exceptions.Get(user.OrgID, *obj.ExceptionID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelRiskException(exception), nil
+}
+
+// Asset is the resolver for the asset field.
+func (r *riskExceptionResolver) Asset(ctx context.Context, obj *model.RiskException) (*model.Asset, error) {
+	if obj.AssetID == nil {
+		return nil, nil
+	}
+
+	return r.resolveAsset(ctx, *obj.AssetID)
+}
+
+// Group is the resolver for the group field.
+func (r *riskExceptionResolver) Group(ctx context.Context, obj *model.RiskException) (*model.AssetGroup, error) {
+	if obj.GroupID == nil {
+		return nil, nil
+	}
+
+	return r.getAssetGroup(*obj.GroupID)
+}
+
+// Approver is the resolver for the approver field.
+func (r *riskExceptionResolver) Approver(ctx context.Context, obj *model.RiskException) (*model.User, error) {
+	if obj.ApproverID == nil {
+		return nil, nil
+	}
+	return r.getUser(ctx, *obj.ApproverID)
+}
+
+// ScanUpdated is the resolver for the scanUpdated field.
+func (r *subscriptionResolver) ScanUpdated(ctx context.Context, scanID string) (<-chan *model.Scan, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(scanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan ID")
+	}
+
+	// Verify scan belongs to the organization's asset
+	if err := r.checkScan(user, id); err != nil {
+		return nil, err
+	}
+
+	// Subscribe before reading the scan so no change in between is missed
+	subCtx, cancel := context.WithCancel(ctx)
+	events := r.ScanManager.SubscribeScan(subCtx, id)
+	scan, err := r.ScanManager.GetScan(id)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to get scan: %w", err)
+	}
+
+	return forwardScans(subCtx, cancel, events, scan), nil
+}
+
+// AssetScansUpdated is the resolver for the assetScansUpdated field.
+func (r *subscriptionResolver) AssetScansUpdated(ctx context.Context, assetID *string) (<-chan *model.Scan, error) {
+	user, err := r.getAuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseOptionalID(assetID, "asset")
+	if err != nil {
+		return nil, err
+	}
+	if id != nil {
+		if err := r.checkAsset(user, *id); err != nil {
+			return nil, err
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	var events <-chan scanner.ScanEvent
+	if id != nil {
+		events = r.ScanManager.SubscribeAsset(subCtx, *id)
+	} else {
+		events = r.ScanManager.SubscribeOrganization(subCtx, user.OrgID)
+	}
+	return forwardScans(subCtx, cancel, events, nil), nil
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
+	admin, err := r.getAuthorizedUser(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := r.Store.Organizations.ListMembers(admin.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.User, 0, len(members))
+	for _, member := range members {
+		user, err := r.Store.Users.Get(member.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		result = append(result, toModelUser(user, member.Role))
+	}
+	return result, nil
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, userID string, role string) (*model.User, error) {
+	admin, err := r.getAuthorizedUser(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	if !auth.ValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q: must be one of %s", role, strings.Join(auth.Roles, ", "))
+	}
+
+	// Another admin has to demote an admin, so there is always one left
+	if id == admin.UserID {
+		return nil, fmt.Errorf("admins can't change their own role")
+	}
+
+	member, err := r.Store.Organizations.SetMemberRole(admin.OrgID, id, role)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.Store.Users.Get(member.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return toModelUser(user, member.Role), nil
+}
+
+// Organizations is the resolver for the organizations field.
+func (r *queryResolver) Organizations(ctx context.Context) ([]*model.Organization, error) {
+	user, err := r.getSignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := r.Store.Organizations.ListByUser(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Organization, 0, len(memberships))
+	for _, membership := range memberships {
+		result = append(result, toModelOrganization(membership.Organization, membership.Role))
+	}
+	return result, nil
+}
+
+// Organization is the resolver for the organization field.
+func (r *queryResolver) Organization(ctx context.Context) (*model.Organization, error) {
+	user, err := r.getSignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user may have been removed since the token was issued
+	role, err := r.memberRole(user.OrgID, user.UserID)
+	if err != nil || role == "" {
+		return nil, err
+	}
+
+	org, err := r.Store.Organizations.Get(user.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelOrganization(org, role), nil
+}
+
+// CreateOrganization is the resolver for the createOrganization field.
+func (r *mutationResolver) CreateOrganization(ctx context.Context, name string) (*model.Organization, error) {
+	user, err := r.getSignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("organization name cannot be empty")
+	}
+
+	org, err := r.Store.Organizations.Create(name, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelOrganization(org, auth.RoleAdmin), nil
+}
+
+// SwitchOrganization is the resolver for the switchOrganization field.
+func (r *mutationResolver) SwitchOrganization(ctx context.Context, organizationID string) (*model.AuthPayload, error) {
+	user, err := r.getSignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid organization ID")
+	}
+
+	// Users can only switch to organizations they belong to
+	member, err := r.Store.Organizations.Member(id, user.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrMemberNotFound) {
+			return nil, store.ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+
+	org, err := r.Store.Organizations.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	dbUser, err := r.Store.Users.Get(user.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return r.authPayload(dbUser, org, member.Role)
+}
+
+// AddOrganizationMember is the resolver for the addOrganizationMember field.
+func (r *mutationResolver) AddOrganizationMember(ctx context.Context, email string, role string) (*model.User, error) {
+	admin, err := r.getAuthorizedUser(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.ValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q: must be one of %s", role, strings.Join(auth.Roles, ", "))
+	}
+
+	// Members are added by the email they registered with
+	user, err := r.Store.Users.GetByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := r.Store.Organizations.AddMember(admin.OrgID, user.ID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return toModelUser(user, member.Role), nil
+}
+
+// RemoveOrganizationMember is the resolver for the removeOrganizationMember field.
+func (r *mutationResolver) RemoveOrganizationMember(ctx context.Context, userID string) (bool, error) {
+	admin, err := r.getAuthorizedUser(ctx, auth.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID")
+	}
+
+	// As with roles, another admin has to remove an admin
+	if id == admin.UserID {
+		return false, fmt.Errorf("admins can't remove themselves from the organization")
+	}
+
+	return r.Store.Organizations.RemoveMember(admin.OrgID, id)
+}
+
+// Risk is the resolver for the risk field.
+func (r *organizationResolver) Risk(ctx context.Context, obj *model.Organization) (*model.RiskScore, error) {
+	organizationID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid organization ID")
+	}
+
+	score, err := r.Scorer.GetOrganizationScore(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelRiskScore(score), nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
+
+// Asset returns AssetResolver implementation.
+func (r *Resolver) Asset() generated.AssetResolver { return &assetResolver{r} }
+
+// AssetGroup returns AssetGroupResolver implementation.
+func (r *Resolver) AssetGroup() generated.AssetGroupResolver { return &assetGroupResolver{r} }
+
+// Scan returns ScanResolver implementation.
+func (r *Resolver) Scan() generated.ScanResolver { return &scanResolver{r} }
+
+// ScanDiff returns ScanDiffResolver implementation.
+func (r *Resolver) ScanDiff() generated.ScanDiffResolver { return &scanDiffResolver{r} }
+
+// ScanResult returns ScanResultResolver implementation.
+func (r *Resolver) ScanResult() generated.ScanResultResolver { return &scanResultResolver{r} }
+
+// Finding returns FindingResolver implementation.
+func (r *Resolver) Finding() generated.FindingResolver { return &findingResolver{r} }
+
+// FindingComment returns FindingCommentResolver implementation.
+func (r *Resolver) FindingComment() generated.FindingCommentResolver { return &findingCommentResolver{r} }
+
+// RiskException returns RiskExceptionResolver implementation.
+func (r *Resolver) RiskException() generated.RiskExceptionResolver { return &riskExceptionResolver{r} }
+
+// ScanSchedule returns ScanScheduleResolver implementation.
+func (r *Resolver) ScanSchedule() generated.ScanScheduleResolver { return &scanScheduleResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Organization returns OrganizationResolver implementation.
+func (r *Resolver) Organization() generated.OrganizationResolver { return &organizationResolver{r} }
 
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type assetResolver struct{ *Resolver }
 type scanResolver struct{ *Resolver }
+type assetGroupResolver struct{ *Resolver }
+type scanScheduleResolver struct{ *Resolver }
+type scanDiffResolver struct{ *Resolver }
+type scanResultResolver struct{ *Resolver }
+type findingResolver struct{ *Resolver }
+type findingCommentResolver struct{ *Resolver }
+type riskExceptionResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type organizationResolver struct{ *Resolver }
@@ -0,0 +1,111 @@
+package loader
+
+import (
+	"sync"
+	"time"
+)
+
+// Loader batches the keys loaded within a short wait of each other into one
+// fetch, and caches each key's value for the life of the loader
+type Loader[K comparable, V any] struct {
+	fetch    func(keys []K) (map[K]V, error)
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*call[V]
+	batch *batch[K, V]
+}
+
+// call is the pending or finished load of one key
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// batch is a set of keys waiting to be fetched together
+type batch[K comparable, V any] struct {
+	keys  []K
+	calls []*call[V]
+}
+
+// NewLoader creates a loader that fetches up to maxBatch keys at a time, once
+// wait has passed since the first key of the batch was loaded
+func NewLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error), wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    make(map[K]*call[V]),
+	}
+}
+
+// Load returns the value fetched for key, or the zero value if the fetch did
+// not return one
+func (l *Loader[K, V]) Load(key K) (V, error) {
+	l.mu.Lock()
+	c, ok := l.cache[key]
+	if !ok {
+		c = &call[V]{done: make(chan struct{})}
+		l.cache[key] = c
+		l.enqueue(key, c)
+	}
+	l.mu.Unlock()
+
+	<-c.done
+	return c.value, c.err
+}
+
+// Prime caches a value already read for key, unless the key has been loaded
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.cache[key]; !ok {
+		c := &call[V]{done: make(chan struct{}), value: value}
+		close(c.done)
+		l.cache[key] = c
+	}
+}
+
+// enqueue adds a key to the pending batch, starting a batch if there is none
+// and fetching it straight away once it is full; callers must hold mu
+func (l *Loader[K, V]) enqueue(key K, c *call[V]) {
+	if l.batch == nil {
+		b := &batch[K, V]{}
+		l.batch = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	l.batch.keys = append(l.batch.keys, key)
+	l.batch.calls = append(l.batch.calls, c)
+	if len(l.batch.keys) >= l.maxBatch {
+		b := l.batch
+		l.batch = nil
+		go l.run(b)
+	}
+}
+
+// dispatch fetches a batch once its wait is over, unless it filled up first
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.batch != b {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+
+	l.run(b)
+}
+
+// run fetches a batch's keys and hands each caller its value
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	values, err := l.fetch(b.keys)
+	for i, key := range b.keys {
+		c := b.calls[i]
+		c.value, c.err = values[key], err
+		close(c.done)
+	}
+}
@@ -0,0 +1,125 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/store"
+)
+
+// How long loaders wait for more keys before fetching, and how many keys they fetch at once
+const (
+	batchWait = 2 * time.Millisecond
+	maxBatch  = 500
+)
+
+type contextKey string
+
+const loadersContextKey contextKey = "loaders"
+
+// MemberKey identifies a user's membership of an organization
+type MemberKey struct {
+	OrganizationID int
+	UserID         int
+}
+
+// ScanPageKey identifies a page of an asset's scans
+type ScanPageKey struct {
+	AssetID int
+	Page    store.Page
+}
+
+// Loaders are the DataLoaders of one request, so nested resolvers read the
+// records of every parent in a single query
+type Loaders struct {
+	// Assets loads assets by ID, leaving out those that do not exist
+	Assets *Loader[int, *db.Asset]
+	// ScanCountsByAsset loads how many scans each asset has
+	ScanCountsByAsset *Loader[int, int]
+	// ScansByAsset loads a page of each asset's scans, newest first
+	ScansByAsset *Loader[ScanPageKey, []*store.Scan]
+	// ResultsByScan loads each scan's results ordered by host, port and protocol
+	ResultsByScan *Loader[int, []store.ScanResult]
+	// Members loads organization memberships, leaving out users who do not belong
+	Members *Loader[MemberKey, *db.OrganizationMember]
+}
+
+// New creates a set of loaders reading from the stores
+func New(stores *store.Store) *Loaders {
+	return &Loaders{
+		Assets:            NewLoader(stores.Assets.GetMany, batchWait, maxBatch),
+		ScanCountsByAsset: NewLoader(stores.Scans.CountByAssets, batchWait, maxBatch),
+		ScansByAsset:      NewLoader(findScanPages(stores.Scans), batchWait, maxBatch),
+		ResultsByScan:     NewLoader(stores.Results.ListByScans, batchWait, maxBatch),
+		Members:           NewLoader(listMembers(stores.Organizations), batchWait, maxBatch),
+	}
+}
+
+// findScanPages fetches the assets asking for the same page of their scans
+// together, since the assets of one connection are all paged alike
+func findScanPages(scans store.ScanStore) func(keys []ScanPageKey) (map[ScanPageKey][]*store.Scan, error) {
+	return func(keys []ScanPageKey) (map[ScanPageKey][]*store.Scan, error) {
+		assetsByPage := make(map[store.Page][]int)
+		for _, key := range keys {
+			assetsByPage[key.Page] = append(assetsByPage[key.Page], key.AssetID)
+		}
+
+		pages := make(map[ScanPageKey][]*store.Scan, len(keys))
+		for page, assetIDs := range assetsByPage {
+			byAsset, err := scans.FindByAssets(assetIDs, page)
+			if err != nil {
+				return nil, err
+			}
+			for assetID, found := range byAsset {
+				pages[ScanPageKey{AssetID: assetID, Page: page}] = found
+			}
+		}
+		return pages, nil
+	}
+}
+
+// listMembers fetches memberships one at a time, since a request only checks
+// its caller's
+func listMembers(organizations store.OrganizationStore) func(keys []MemberKey) (map[MemberKey]*db.OrganizationMember, error) {
+	return func(keys []MemberKey) (map[MemberKey]*db.OrganizationMember, error) {
+		members := make(map[MemberKey]*db.OrganizationMember, len(keys))
+		for _, key := range keys {
+			member, err := organizations.Member(key.OrganizationID, key.UserID)
+			if err != nil {
+				if errors.Is(err, store.ErrMemberNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			members[key] = member
+		}
+		return members, nil
+	}
+}
+
+// Middleware gives each request its own loaders, so nothing is cached between
+// requests. Websocket connections get none, since their subscriptions run for
+// as long as they are open and would keep reading the records first cached.
+func Middleware(stores *store.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), loadersContextKey, New(stores))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FromContext returns the loaders Middleware added to the request
+func FromContext(ctx context.Context) (*Loaders, bool) {
+	loaders, ok := ctx.Value(loadersContextKey).(*Loaders)
+	return loaders, ok
+}
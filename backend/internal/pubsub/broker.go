@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Broker delivers the messages published to a topic in this process to that
+// topic's subscribers. Every subscriber has its own queue, so a slow subscriber
+// never blocks the publisher or the other subscribers, and only messages made
+// redundant by a newer one are dropped.
+type Broker[K comparable, T any] struct {
+	mu     sync.Mutex
+	topics map[K]map[*subscriber[T]]struct{}
+	// coalesce returns the key a message shares with the messages it makes redundant, or is nil
+	coalesce func(msg T) (K, bool)
+}
+
+// subscriber is the queue of messages not yet received by one subscriber
+type subscriber[T any] struct {
+	queue []T
+	// ready is signalled when a message is queued
+	ready chan struct{}
+}
+
+// NewBroker creates a broker with no subscribers. When coalesce returns a key
+// for a message, the message replaces the one with the same key still queued
+// for a subscriber, as a newer progress report replaces an older one. With a
+// nil coalesce every message is delivered.
+func NewBroker[K comparable, T any](coalesce func(msg T) (K, bool)) *Broker[K, T] {
+	return &Broker[K, T]{
+		topics:   make(map[K]map[*subscriber[T]]struct{}),
+		coalesce: coalesce,
+	}
+}
+
+// Subscribe returns a channel receiving the messages published to topic until
+// ctx is done, when the channel is closed
+func (b *Broker[K, T]) Subscribe(ctx context.Context, topic K) <-chan T {
+	sub := &subscriber[T]{ready: make(chan struct{}, 1)}
+
+	b.mu.Lock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*subscriber[T]]struct{})
+	}
+	b.topics[topic][sub] = struct{}{}
+	b.mu.Unlock()
+
+	ch := make(chan T)
+	go func() {
+		defer close(ch)
+		defer b.unsubscribe(topic, sub)
+
+		for {
+			msg, ok := b.next(sub)
+			if !ok {
+				select {
+				case <-sub.ready:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case ch <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Publish queues a message for every subscriber of topic
+func (b *Broker[K, T]) Publish(topic K, msg T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.topics[topic] {
+		b.enqueue(sub, msg)
+	}
+}
+
+// enqueue appends msg to the subscriber's queue, removing the queued message
+// with the same coalescing key; callers must hold mu
+func (b *Broker[K, T]) enqueue(sub *subscriber[T], msg T) {
+	if b.coalesce != nil {
+		if key, ok := b.coalesce(msg); ok {
+			for i, queued := range sub.queue {
+				if queuedKey, ok := b.coalesce(queued); ok && queuedKey == key {
+					sub.queue = append(sub.queue[:i], sub.queue[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	sub.queue = append(sub.queue, msg)
+
+	select {
+	case sub.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next removes the oldest message queued for sub
+func (b *Broker[K, T]) next(sub *subscriber[T]) (T, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var zero T
+	if len(sub.queue) == 0 {
+		return zero, false
+	}
+	msg := sub.queue[0]
+	sub.queue[0] = zero
+	sub.queue = sub.queue[1:]
+	return msg, true
+}
+
+// unsubscribe stops queueing messages for sub
+func (b *Broker[K, T]) unsubscribe(topic K, sub *subscriber[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.topics[topic], sub)
+	if len(b.topics[topic]) == 0 {
+		delete(b.topics, topic)
+	}
+}
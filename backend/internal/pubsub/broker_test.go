@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// message is a test message; progress messages of the same task coalesce
+type message struct {
+	task     int
+	seq      int
+	progress bool
+}
+
+func newTestBroker() *Broker[int, message] {
+	return NewBroker(func(msg message) (int, bool) {
+		return msg.task, msg.progress
+	})
+}
+
+// receive reads n messages from ch, failing the test if they don't arrive
+func receive(t *testing.T, ch <-chan message, n int) []message {
+	t.Helper()
+
+	var received []message
+	for len(received) < n {
+		select {
+		case msg := <-ch:
+			received = append(received, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d messages, want %d", len(received), n)
+		}
+	}
+	return received
+}
+
+func TestBrokerOnlyDeliversTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := newTestBroker()
+	one := broker.Subscribe(ctx, 1)
+	two := broker.Subscribe(ctx, 2)
+
+	broker.Publish(1, message{task: 1, seq: 1})
+	broker.Publish(2, message{task: 2, seq: 2})
+
+	if got := receive(t, one, 1); got[0].seq != 1 {
+		t.Fatalf("topic 1 received %+v", got)
+	}
+	if got := receive(t, two, 1); got[0].seq != 2 {
+		t.Fatalf("topic 2 received %+v", got)
+	}
+	select {
+	case msg := <-one:
+		t.Fatalf("topic 1 received %+v published to topic 2", msg)
+	default:
+	}
+}
+
+func TestBrokerKeepsEveryMessageForSlowSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := newTestBroker()
+	ch := broker.Subscribe(ctx, 1)
+
+	// Nothing is read while these are published
+	const published = 1000
+	for i := 0; i < published; i++ {
+		broker.Publish(1, message{task: i, seq: i})
+	}
+
+	for i, msg := range receive(t, ch, published) {
+		if msg.seq != i {
+			t.Fatalf("message %d is %+v, want them in order", i, msg)
+		}
+	}
+}
+
+func TestBrokerCoalescesQueuedProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := newTestBroker()
+	ch := broker.Subscribe(ctx, 1)
+
+	// The subscription holds the first message until it is read, so the rest stay queued
+	broker.Publish(1, message{task: 3, seq: 0})
+	broker.Publish(1, message{task: 1, seq: 1, progress: true})
+	broker.Publish(1, message{task: 2, seq: 2, progress: true})
+	broker.Publish(1, message{task: 1, seq: 3})
+	broker.Publish(1, message{task: 1, seq: 4, progress: true})
+	broker.Publish(1, message{task: 1, seq: 5, progress: true})
+	broker.Publish(1, message{task: 1, seq: 6})
+
+	// Task 1's first progress is replaced by its last one, which stays after
+	// the status message published before it
+	want := []int{0, 2, 3, 5, 6}
+	for i, msg := range receive(t, ch, len(want)) {
+		if msg.seq != want[i] {
+			t.Fatalf("message %d is %+v, want seq %d", i, msg, want[i])
+		}
+	}
+	select {
+	case msg := <-ch:
+		t.Fatalf("received superseded message %+v", msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestBrokerClosesOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	broker := newTestBroker()
+	ch := broker.Subscribe(ctx, 1)
+	broker.Publish(1, message{task: 1})
+	cancel()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				broker.mu.Lock()
+				defer broker.mu.Unlock()
+				if len(broker.topics) != 0 {
+					t.Fatalf("broker still has %d topics after its subscriber left", len(broker.topics))
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("subscription was not closed")
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package risk
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity is the qualitative rating of a risk score
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// MaxScore is the highest score the engine produces
+const MaxScore = 100.0
+
+// SeverityForScore maps a 0-100 risk score to a severity
+func SeverityForScore(score float64) Severity {
+	switch {
+	case score >= 80:
+		return SeverityCritical
+	case score >= 60:
+		return SeverityHigh
+	case score >= 30:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityInfo
+}
+
+// Criticality is how important an asset is to its owner
+type Criticality string
+
+const (
+	CriticalityLow      Criticality = "low"
+	CriticalityMedium   Criticality = "medium"
+	CriticalityHigh     Criticality = "high"
+	CriticalityCritical Criticality = "critical"
+)
+
+// DefaultCriticality is assigned to assets that have not been classified
+const DefaultCriticality = CriticalityMedium
+
+// ValidCriticality reports whether c is a known criticality
+func ValidCriticality(c string) bool {
+	switch Criticality(c) {
+	case CriticalityLow, CriticalityMedium, CriticalityHigh, CriticalityCritical:
+		return true
+	}
+	return false
+}
+
+// CVE is a vulnerability matched to a result
+type CVE struct {
+	ID          string
+	CVSSScore   *float64
+	PublishedAt *time.Time
+}
+
+// Input is everything the engine knows about a single open port
+type Input struct {
+	Port     int
+	Protocol string
+	State    string
+	Service  string
+	Version  string
+	Banner   string
+	CVEs     []CVE
+
+	// Asset context
+	AssetType      string
+	Criticality    Criticality
+	InternetFacing bool
+
+	// Now is the reference time for age calculations; zero means time.Now
+	Now time.Time
+}
+
+// VersionAge estimates how long the detected version has gone unpatched: the
+// time since the oldest matched CVE was published. It is zero without CVEs.
+func (in *Input) VersionAge() time.Duration {
+	now := in.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var oldest *time.Time
+	for _, cve := range in.CVEs {
+		if cve.PublishedAt != nil && (oldest == nil || cve.PublishedAt.Before(*oldest)) {
+			oldest = cve.PublishedAt
+		}
+	}
+	if oldest == nil || oldest.After(now) {
+		return 0
+	}
+	return now.Sub(*oldest)
+}
+
+// Factor contributes points towards a result's base score
+type Factor interface {
+	// Name identifies the factor in score breakdowns
+	Name() string
+	// Score returns the points contributed for the input
+	Score(in *Input) float64
+}
+
+// Modifier scales a result's base score, e.g. for asset context
+type Modifier interface {
+	Name() string
+	Multiplier(in *Input) float64
+}
+
+// Contribution is one factor's or modifier's part of a score
+type Contribution struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// Result is a scored input
+type Result struct {
+	Score     float64        `json:"score"`
+	Severity  Severity       `json:"severity"`
+	Breakdown []Contribution `json:"breakdown"`
+}
+
+// Engine combines factors and modifiers into a 0-100 score
+type Engine struct {
+	factors   []Factor
+	modifiers []Modifier
+}
+
+// NewEngine creates an engine from the given factors and modifiers
+func NewEngine(factors []Factor, modifiers []Modifier) *Engine {
+	return &Engine{factors: factors, modifiers: modifiers}
+}
+
+// DefaultEngine scores service exposure, matched CVEs and version age, scaled
+// by asset criticality and internet exposure
+func DefaultEngine() *Engine {
+	return NewEngine(
+		[]Factor{ServiceExposure{}, CVESeverity{}, VersionAge{}},
+		[]Modifier{AssetCriticality{}, InternetFacing{}},
+	)
+}
+
+// AddFactor adds a factor to the engine's base score
+func (e *Engine) AddFactor(factor Factor) *Engine {
+	e.factors = append(e.factors, factor)
+	return e
+}
+
+// ScoreResult scores a single open port
+func (e *Engine) ScoreResult(in *Input) Result {
+	var result Result
+
+	base := 0.0
+	for _, factor := range e.factors {
+		points := factor.Score(in)
+		base += points
+		result.Breakdown = append(result.Breakdown, Contribution{Name: factor.Name(), Value: round(points)})
+	}
+
+	score := base
+	for _, modifier := range e.modifiers {
+		multiplier := modifier.Multiplier(in)
+		score *= multiplier
+		result.Breakdown = append(result.Breakdown, Contribution{Name: modifier.Name(), Value: round(multiplier)})
+	}
+
+	result.Score = round(math.Min(MaxScore, math.Max(0, score)))
+	result.Severity = SeverityForScore(result.Score)
+	return result
+}
+
+// AggregateAsset combines an asset's result scores: the worst result plus one
+// point for every other scored result, capped at ten, so many exposures rank
+// above a single one of the same severity
+func AggregateAsset(scores []float64) Result {
+	if len(scores) == 0 {
+		return Result{Severity: SeverityInfo}
+	}
+
+	sorted := append([]float64{}, scores...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+
+	breadth := 0.0
+	for _, score := range sorted[1:] {
+		if score > 0 {
+			breadth++
+		}
+	}
+	breadth = math.Min(10, breadth)
+
+	score := round(math.Min(MaxScore, sorted[0]+breadth))
+	return Result{
+		Score:    score,
+		Severity: SeverityForScore(score),
+		Breakdown: []Contribution{
+			{Name: "worst result", Value: sorted[0]},
+			{Name: "breadth", Value: breadth},
+		},
+	}
+}
+
+// AggregateOrganization combines an organization's asset scores, weighting the
+// worst asset at 60% and the average across all assets at 40%
+func AggregateOrganization(scores []float64) Result {
+	if len(scores) == 0 {
+		return Result{Severity: SeverityInfo}
+	}
+
+	worst, total := 0.0, 0.0
+	for _, score := range scores {
+		worst = math.Max(worst, score)
+		total += score
+	}
+	mean := total / float64(len(scores))
+
+	score := round(0.6*worst + 0.4*mean)
+	return Result{
+		Score:    score,
+		Severity: SeverityForScore(score),
+		Breakdown: []Contribution{
+			{Name: "worst asset", Value: round(worst)},
+			{Name: "average asset", Value: round(mean)},
+		},
+	}
+}
+
+// round keeps scores to one decimal place
+func round(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// ServiceExposure scores how dangerous it is to expose the service at all
+type ServiceExposure struct{}
+
+// serviceExposure lists base points for commonly exposed services. Cleartext and
+// remote administration protocols score highest.
+var serviceExposure = map[string]float64{
+	"telnet":        35,
+	"ftp":           25,
+	"tftp":          25,
+	"microsoft-ds":  30,
+	"netbios-ssn":   30,
+	"ms-wbt-server": 30,
+	"rdp":           30,
+	"vnc":           30,
+	"snmp":          25,
+	"mysql":         25,
+	"postgresql":    25,
+	"ms-sql-s":      25,
+	"mongodb":       25,
+	"redis":         25,
+	"elasticsearch": 25,
+	"ldap":          20,
+	"smtp":          15,
+	"pop3":          15,
+	"imap":          15,
+	"ssh":           15,
+	"domain":        10,
+	"dns":           10,
+	"http":          10,
+	"http-proxy":    10,
+	"https":         5,
+	"ssl/http":      5,
+}
+
+// unknownServiceExposure scores open ports whose service was not identified
+const unknownServiceExposure = 10.0
+
+// Name implements Factor
+func (ServiceExposure) Name() string { return "service exposure" }
+
+// Score implements Factor
+func (ServiceExposure) Score(in *Input) float64 {
+	points, ok := serviceExposure[strings.ToLower(in.Service)]
+	if !ok {
+		points = unknownServiceExposure
+	}
+
+	// Ports that may be filtered are less certain to be reachable
+	if in.State == "open|filtered" {
+		points /= 2
+	}
+	return points
+}
+
+// CVESeverity scores the worst matched CVE, plus a little for each additional one
+type CVESeverity struct{}
+
+// Name implements Factor
+func (CVESeverity) Name() string { return "matched CVEs" }
+
+// Score implements Factor
+func (CVESeverity) Score(in *Input) float64 {
+	worst := 0.0
+	scored := 0
+	for _, cve := range in.CVEs {
+		if cve.CVSSScore != nil {
+			worst = math.Max(worst, *cve.CVSSScore)
+			scored++
+		}
+	}
+	if scored == 0 {
+		// Unscored CVEs still indicate a known-vulnerable version
+		return math.Min(float64(len(in.CVEs))*5, 15)
+	}
+
+	return worst*5 + math.Min(float64(len(in.CVEs)-1), 10)
+}
+
+// VersionAge scores long-unpatched versions: two points per year since the
+// oldest matched CVE, up to ten
+type VersionAge struct{}
+
+// Name implements Factor
+func (VersionAge) Name() string { return "version age" }
+
+// Score implements Factor
+func (VersionAge) Score(in *Input) float64 {
+	years := in.VersionAge().Hours() / (24 * 365)
+	return math.Min(years*2, 10)
+}
+
+// AssetCriticality scales scores by how important the asset is
+type AssetCriticality struct{}
+
+// Name implements Modifier
+func (AssetCriticality) Name() string { return "asset criticality" }
+
+// Multiplier implements Modifier
+func (AssetCriticality) Multiplier(in *Input) float64 {
+	switch in.Criticality {
+	case CriticalityLow:
+		return 0.75
+	case CriticalityHigh:
+		return 1.25
+	case CriticalityCritical:
+		return 1.5
+	}
+	return 1
+}
+
+// InternetFacing raises scores for assets reachable from the internet
+type InternetFacing struct{}
+
+// Name implements Modifier
+func (InternetFacing) Name() string { return "internet facing" }
+
+// Multiplier implements Modifier
+func (InternetFacing) Multiplier(in *Input) float64 {
+	if in.InternetFacing {
+		return 1.5
+	}
+	return 1
+}
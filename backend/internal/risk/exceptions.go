@@ -0,0 +1,247 @@
+package risk
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+)
+
+// Exception statuses, derived from an exception's expiry and revocation
+const (
+	ExceptionActive  = "active"
+	ExceptionExpired = "expired"
+	ExceptionRevoked = "revoked"
+)
+
+// Exception accepts the risk of a port, and optionally a specific service, on
+// an asset or on every asset in a group until it expires. UserID is the user
+// who created it.
+type Exception struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"userId"`
+	OrganizationID int        `json:"organizationId"`
+	AssetID        *int       `json:"assetId,omitempty"`
+	GroupID        *int       `json:"groupId,omitempty"`
+	Port           int        `json:"port"`
+	Protocol       string     `json:"protocol,omitempty"`
+	Service        string     `json:"service,omitempty"`
+	Justification  string     `json:"justification"`
+	ApproverID     *int       `json:"approverId,omitempty"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Status reports whether the exception is active, expired or revoked at now
+func (e *Exception) Status(now time.Time) string {
+	switch {
+	case e.RevokedAt != nil:
+		return ExceptionRevoked
+	case !e.ExpiresAt.After(now):
+		return ExceptionExpired
+	}
+	return ExceptionActive
+}
+
+// Validate checks that the exception targets exactly one asset or group and is justified
+func (e *Exception) Validate(now time.Time) error {
+	if (e.AssetID == nil) == (e.GroupID == nil) {
+		return fmt.Errorf("exception must target either an asset or an asset group")
+	}
+	if e.Port < 1 || e.Port > 65535 {
+		return fmt.Errorf("invalid port %d", e.Port)
+	}
+	if e.Protocol != "" && e.Protocol != "tcp" && e.Protocol != "udp" {
+		return fmt.Errorf("protocol must be tcp or udp")
+	}
+	if strings.TrimSpace(e.Justification) == "" {
+		return fmt.Errorf("exception justification cannot be empty")
+	}
+	if !e.ExpiresAt.After(now) {
+		return fmt.Errorf("exception expiry must be in the future")
+	}
+	return nil
+}
+
+// Covers reports whether the exception applies to a port and service on one of
+// the assets it targets
+func (e *Exception) Covers(port int, protocol, service string) bool {
+	if e.Port != port {
+		return false
+	}
+	if e.Protocol != "" && !strings.EqualFold(e.Protocol, protocol) {
+		return false
+	}
+	if e.Service != "" && !strings.EqualFold(e.Service, service) {
+		return false
+	}
+	return true
+}
+
+// Exceptions stores risk acceptance exceptions in the risk_exceptions table
+type Exceptions struct {
+	db *db.DB
+}
+
+// NewExceptions creates a new exception store
+func NewExceptions(database *db.DB) *Exceptions {
+	return &Exceptions{db: database}
+}
+
+const exceptionColumns = `id, user_id, organization_id, asset_id, group_id, port, COALESCE(protocol, ''), COALESCE(service, ''),
+	justification, approver_id, expires_at, revoked_at, created_at`
+
+// scanException scans a risk_exceptions row selected with exceptionColumns
+func scanException(row interface{ Scan(...any) error }) (*Exception, error) {
+	var e Exception
+	err := row.Scan(
+		&e.ID,
+		&e.UserID,
+		&e.OrganizationID,
+		&e.AssetID,
+		&e.GroupID,
+		&e.Port,
+		&e.Protocol,
+		&e.Service,
+		&e.Justification,
+		&e.ApproverID,
+		&e.ExpiresAt,
+		&e.RevokedAt,
+		&e.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// queryExceptions runs a query selecting exceptionColumns
+func (s *Exceptions) queryExceptions(query string, args ...any) ([]*Exception, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exceptions: %v", err)
+	}
+	defer rows.Close()
+
+	var exceptions []*Exception
+	for rows.Next() {
+		exception, err := scanException(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		exceptions = append(exceptions, exception)
+	}
+
+	return exceptions, rows.Err()
+}
+
+// Create validates and stores a new exception
+func (s *Exceptions) Create(exception *Exception) (*Exception, error) {
+	now := time.Now()
+	if err := exception.Validate(now); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO risk_exceptions (user_id, organization_id, asset_id, group_id, port, protocol, service, justification, approver_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11)
+		RETURNING ` + exceptionColumns
+
+	created, err := scanException(s.db.QueryRow(query, exception.UserID, exception.OrganizationID, exception.AssetID, exception.GroupID, exception.Port,
+		strings.ToLower(exception.Protocol), exception.Service, strings.TrimSpace(exception.Justification), exception.ApproverID,
+		exception.ExpiresAt, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exception: %v", err)
+	}
+
+	return created, nil
+}
+
+// Get retrieves one of the organization's exceptions
+func (s *Exceptions) Get(organizationID, exceptionID int) (*Exception, error) {
+	query := `SELECT ` + exceptionColumns + ` FROM risk_exceptions WHERE id = $1 AND organization_id = $2`
+
+	exception, err := scanException(s.db.QueryRow(query, exceptionID, organizationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("exception not found")
+		}
+		return nil, fmt.Errorf("failed to get exception: %v", err)
+	}
+
+	return exception, nil
+}
+
+// List retrieves the organization's exceptions, optionally including expired and revoked ones
+func (s *Exceptions) List(organizationID int, includeInactive bool) ([]*Exception, error) {
+	query := `SELECT ` + exceptionColumns + ` FROM risk_exceptions
+		WHERE organization_id = $1 AND ($2 OR (revoked_at IS NULL AND expires_at > NOW()))
+		ORDER BY expires_at ASC, id ASC`
+
+	return s.queryExceptions(query, organizationID, includeInactive)
+}
+
+// Revoke ends one of the organization's exceptions early
+func (s *Exceptions) Revoke(organizationID, exceptionID int) (*Exception, error) {
+	query := `
+		UPDATE risk_exceptions SET revoked_at = $1
+		WHERE id = $2 AND organization_id = $3 AND revoked_at IS NULL
+		RETURNING ` + exceptionColumns
+
+	exception, err := scanException(s.db.QueryRow(query, time.Now(), exceptionID, organizationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("exception not found or already revoked")
+		}
+		return nil, fmt.Errorf("failed to revoke exception: %v", err)
+	}
+
+	return exception, nil
+}
+
+// ActiveForAsset retrieves the active exceptions that apply to an asset
+// directly or through its group
+func (s *Exceptions) ActiveForAsset(assetID int) ([]*Exception, error) {
+	query := `SELECT ` + exceptionColumns + ` FROM risk_exceptions
+		WHERE (asset_id = $1 OR group_id = (SELECT group_id FROM assets WHERE id = $1))
+			AND revoked_at IS NULL AND expires_at > NOW()`
+
+	return s.queryExceptions(query, assetID)
+}
+
+// AffectedAssets lists the assets an exception applies to
+func (s *Exceptions) AffectedAssets(exception *Exception) ([]int, error) {
+	if exception.AssetID != nil {
+		return []int{*exception.AssetID}, nil
+	}
+
+	rows, err := s.db.Query(`SELECT id FROM assets WHERE group_id = $1 ORDER BY id`, *exception.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group assets: %v", err)
+	}
+	defer rows.Close()
+
+	var assetIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		assetIDs = append(assetIDs, id)
+	}
+
+	return assetIDs, rows.Err()
+}
+
+// Covering returns the first of an asset's exceptions that covers the port and service, or nil
+func Covering(exceptions []*Exception, port int, protocol, service string) *Exception {
+	for _, exception := range exceptions {
+		if exception.Covers(port, protocol, service) {
+			return exception
+		}
+	}
+	return nil
+}
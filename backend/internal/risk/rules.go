@@ -0,0 +1,443 @@
+package risk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidSeverity reports whether s is a known severity
+func ValidSeverity(s string) bool {
+	switch Severity(s) {
+	case SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return true
+	}
+	return false
+}
+
+// RuleCriteria selects the results a rule applies to. Every criterion that is
+// set must match; ports match if any of them is the result's port.
+type RuleCriteria struct {
+	Ports     []int  `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Protocol  string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Service   string `json:"service,omitempty" yaml:"service,omitempty"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	Banner    string `json:"banner,omitempty" yaml:"banner,omitempty"`
+	AssetType string `json:"assetType,omitempty" yaml:"assetType,omitempty"`
+}
+
+// Rule is a declarative risk rule loaded from a rules file
+type Rule struct {
+	ID          string       `json:"id" yaml:"id"`
+	Title       string       `json:"title" yaml:"title"`
+	Severity    Severity     `json:"severity" yaml:"severity"`
+	Remediation string       `json:"remediation,omitempty" yaml:"remediation,omitempty"`
+	Match       RuleCriteria `json:"match" yaml:"match"`
+
+	// Source is the file the rule was loaded from
+	Source string `json:"source,omitempty" yaml:"-"`
+
+	version *regexp.Regexp
+	banner  *regexp.Regexp
+}
+
+// ruleFile is the layout of a YAML or JSON rules file
+type ruleFile struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Validate checks the rule's fields and compiles its patterns
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.ID) == "" {
+		return fmt.Errorf("rule id cannot be empty")
+	}
+	if strings.TrimSpace(r.Title) == "" {
+		return fmt.Errorf("rule %s: title cannot be empty", r.ID)
+	}
+	if !ValidSeverity(string(r.Severity)) {
+		return fmt.Errorf("rule %s: invalid severity %q", r.ID, r.Severity)
+	}
+
+	m := r.Match
+	if len(m.Ports) == 0 && m.Protocol == "" && m.Service == "" && m.Version == "" && m.Banner == "" && m.AssetType == "" {
+		return fmt.Errorf("rule %s: match must set at least one criterion", r.ID)
+	}
+	for _, port := range m.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("rule %s: invalid port %d", r.ID, port)
+		}
+	}
+	if m.Protocol != "" && m.Protocol != "tcp" && m.Protocol != "udp" {
+		return fmt.Errorf("rule %s: protocol must be tcp or udp", r.ID)
+	}
+
+	var err error
+	r.version, r.banner = nil, nil
+	if m.Version != "" {
+		if r.version, err = regexp.Compile(m.Version); err != nil {
+			return fmt.Errorf("rule %s: invalid version pattern: %v", r.ID, err)
+		}
+	}
+	if m.Banner != "" {
+		if r.banner, err = regexp.Compile(m.Banner); err != nil {
+			return fmt.Errorf("rule %s: invalid banner pattern: %v", r.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether the rule applies to the input
+func (r *Rule) Matches(in *Input) bool {
+	m := r.Match
+
+	if len(m.Ports) > 0 {
+		found := false
+		for _, port := range m.Ports {
+			if port == in.Port {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if m.Protocol != "" && !strings.EqualFold(m.Protocol, in.Protocol) {
+		return false
+	}
+	if m.Service != "" && !strings.EqualFold(m.Service, in.Service) {
+		return false
+	}
+	if m.AssetType != "" && !strings.EqualFold(m.AssetType, in.AssetType) {
+		return false
+	}
+	if r.version != nil && !r.version.MatchString(in.Version) {
+		return false
+	}
+	if r.banner != nil && !r.banner.MatchString(in.Banner) {
+		return false
+	}
+
+	return true
+}
+
+// RuleSet is a validated set of rules
+type RuleSet struct {
+	Rules    []*Rule
+	LoadedAt time.Time
+}
+
+// Match returns the rules that apply to the input, most severe first
+func (rs *RuleSet) Match(in *Input) []*Rule {
+	if rs == nil {
+		return nil
+	}
+
+	var matched []*Rule
+	for _, rule := range rs.Rules {
+		if rule.Matches(in) {
+			matched = append(matched, rule)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return severityRank[matched[i].Severity] > severityRank[matched[j].Severity]
+	})
+	return matched
+}
+
+// Get returns the rule with the given ID, or nil
+func (rs *RuleSet) Get(id string) *Rule {
+	if rs == nil {
+		return nil
+	}
+	for _, rule := range rs.Rules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+// severityRank orders severities from least to most severe
+var severityRank = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// ParseRules parses and validates rules from YAML or JSON content. JSON is
+// detected from a .json source name or a leading brace.
+func ParseRules(data []byte, source string) (*RuleSet, error) {
+	var file ruleFile
+
+	trimmed := bytes.TrimSpace(data)
+	if strings.EqualFold(filepath.Ext(source), ".json") || bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("%s: invalid JSON: %v", source, err)
+		}
+	} else if len(trimmed) > 0 {
+		decoder := yaml.NewDecoder(bytes.NewReader(trimmed))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("%s: invalid YAML: %v", source, err)
+		}
+	}
+
+	set := &RuleSet{LoadedAt: time.Now()}
+	seen := make(map[string]bool)
+	for _, rule := range file.Rules {
+		if rule == nil {
+			continue
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("%s: duplicate rule id %s", source, rule.ID)
+		}
+		seen[rule.ID] = true
+		rule.Source = source
+		set.Rules = append(set.Rules, rule)
+	}
+
+	return set, nil
+}
+
+// LoadRules loads every .yaml, .yml and .json file at path, which may be a
+// single file or a directory. Rule IDs must be unique across all files.
+func LoadRules(path string) (*RuleSet, error) {
+	files, err := ruleFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &RuleSet{LoadedAt: time.Now()}
+	sources := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules file: %v", err)
+		}
+
+		fileSet, err := ParseRules(data, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range fileSet.Rules {
+			if other, ok := sources[rule.ID]; ok {
+				return nil, fmt.Errorf("%s: rule id %s is already defined in %s", file, rule.ID, other)
+			}
+			sources[rule.ID] = file
+			set.Rules = append(set.Rules, rule)
+		}
+	}
+
+	return set, nil
+}
+
+// ruleFiles lists the rules files at path in name order
+func ruleFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat rules path: %v", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules directory: %v", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// RuleLoader holds the current rule set and reloads it when the rules files change
+type RuleLoader struct {
+	path     string
+	interval time.Duration
+
+	mu          sync.RWMutex
+	rules       *RuleSet
+	fingerprint string
+	lastErr     error
+}
+
+// NewRuleLoader creates a loader for the rules at path, checking for changes every interval
+func NewRuleLoader(path string, interval time.Duration) *RuleLoader {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &RuleLoader{
+		path:     path,
+		interval: interval,
+		rules:    &RuleSet{},
+	}
+}
+
+// Path returns the rules file or directory being loaded
+func (l *RuleLoader) Path() string {
+	return l.path
+}
+
+// Rules returns the current rule set
+func (l *RuleLoader) Rules() *RuleSet {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.rules
+}
+
+// LastError returns the error from the most recent load, if it failed
+func (l *RuleLoader) LastError() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastErr
+}
+
+// Reload loads the rules files. An invalid file keeps the previous rules in
+// place, so a bad edit never drops every rule.
+func (l *RuleLoader) Reload() (*RuleSet, error) {
+	// A missing path means no rules are configured
+	if _, err := os.Stat(l.path); os.IsNotExist(err) {
+		l.mu.Lock()
+		l.rules, l.fingerprint, l.lastErr = &RuleSet{LoadedAt: time.Now()}, "", nil
+		l.mu.Unlock()
+		return l.Rules(), nil
+	}
+
+	fingerprint, _ := l.currentFingerprint()
+	rules, err := LoadRules(l.path)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fingerprint = fingerprint
+	l.lastErr = err
+	if err != nil {
+		return nil, err
+	}
+	l.rules = rules
+	return rules, nil
+}
+
+// Start loads the rules and reloads them whenever the files change until ctx is cancelled
+func (l *RuleLoader) Start(ctx context.Context) {
+	if rules, err := l.Reload(); err != nil {
+		log.Printf("Failed to load risk rules: %v", err)
+	} else {
+		log.Printf("Loaded %d risk rules from %s", len(rules.Rules), l.path)
+	}
+
+	go func() {
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			fingerprint, err := l.currentFingerprint()
+			l.mu.RLock()
+			changed := fingerprint != l.fingerprint
+			l.mu.RUnlock()
+			if err != nil || !changed {
+				continue
+			}
+
+			if rules, err := l.Reload(); err != nil {
+				log.Printf("Failed to reload risk rules, keeping previous rules: %v", err)
+			} else {
+				log.Printf("Reloaded %d risk rules from %s", len(rules.Rules), l.path)
+			}
+		}
+	}()
+}
+
+// currentFingerprint summarises the names, sizes and modification times of the rules files
+func (l *RuleLoader) currentFingerprint() (string, error) {
+	if _, err := os.Stat(l.path); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	files, err := ruleFiles(l.path)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// RuleSource provides the rules currently in effect
+type RuleSource interface {
+	Rules() *RuleSet
+}
+
+// severityPoints are the points a matching rule contributes to a result's score
+var severityPoints = map[Severity]float64{
+	SeverityInfo:     0,
+	SeverityLow:      10,
+	SeverityMedium:   20,
+	SeverityHigh:     40,
+	SeverityCritical: 60,
+}
+
+// RuleFactor scores the most severe matching risk rule
+type RuleFactor struct {
+	Source RuleSource
+}
+
+// Name implements Factor
+func (RuleFactor) Name() string { return "risk rules" }
+
+// Score implements Factor
+func (f RuleFactor) Score(in *Input) float64 {
+	if f.Source == nil {
+		return 0
+	}
+
+	matched := f.Source.Rules().Match(in)
+	if len(matched) == 0 {
+		return 0
+	}
+	return severityPoints[matched[0].Severity]
+}
@@ -0,0 +1,353 @@
+package risk
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+)
+
+// Score is a stored risk score
+type Score struct {
+	Score    float64    `json:"score"`
+	Severity Severity   `json:"severity"`
+	ScoredAt *time.Time `json:"scoredAt,omitempty"`
+}
+
+// Service scores scan results with an Engine and stores the scores of results,
+// assets and organizations
+type Service struct {
+	db         *db.DB
+	engine     *Engine
+	exceptions *Exceptions
+}
+
+// NewService creates a new Service using the given engine
+func NewService(database *db.DB, engine *Engine) *Service {
+	return &Service{
+		db:         database,
+		engine:     engine,
+		exceptions: NewExceptions(database),
+	}
+}
+
+// Engine returns the engine used to score results
+func (s *Service) Engine() *Engine {
+	return s.engine
+}
+
+// ScoreScan scores every result of a completed scan, then refreshes the scores
+// of the scanned asset and its organization
+func (s *Service) ScoreScan(scanID int) error {
+	var assetID, organizationID int
+	var assetType, criticality string
+	var internetFacing bool
+	err := s.db.QueryRow(`
+		SELECT a.id, a.organization_id, a.asset_type, COALESCE(a.criticality, $2), COALESCE(a.internet_facing, FALSE)
+		FROM scans s
+		JOIN assets a ON a.id = s.asset_id
+		WHERE s.id = $1
+	`, scanID, DefaultCriticality).Scan(&assetID, &organizationID, &assetType, &criticality, &internetFacing)
+	if err != nil {
+		return fmt.Errorf("failed to get scanned asset: %v", err)
+	}
+
+	inputs, err := s.loadInputs(scanID)
+	if err != nil {
+		return err
+	}
+
+	exceptions, err := s.exceptions.ActiveForAsset(assetID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE scan_results SET risk_score = $1, risk_severity = $2 WHERE id = $3`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for resultID, input := range inputs {
+		input.AssetType = assetType
+		input.Criticality = Criticality(criticality)
+		input.InternetFacing = internetFacing
+
+		// Accepted risks do not count towards any score until their exception ends
+		result := Result{Severity: SeverityInfo}
+		if Covering(exceptions, input.Port, input.Protocol, input.Service) == nil {
+			result = s.engine.ScoreResult(input)
+		}
+		if _, err := stmt.Exec(result.Score, result.Severity, resultID); err != nil {
+			return fmt.Errorf("failed to store result score: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if _, err := s.ScoreAsset(assetID); err != nil {
+		return err
+	}
+	if _, err := s.ScoreOrganization(organizationID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadInputs builds the engine inputs for every result of a scan, keyed by result ID
+func (s *Service) loadInputs(scanID int) (map[int]*Input, error) {
+	rows, err := s.db.Query(`
+		SELECT id, port, protocol, state, COALESCE(service, ''), COALESCE(version, ''), COALESCE(banner, '')
+		FROM scan_results
+		WHERE scan_id = $1
+	`, scanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan results: %v", err)
+	}
+
+	inputs := make(map[int]*Input)
+	for rows.Next() {
+		var resultID int
+		input := &Input{}
+		if err := rows.Scan(&resultID, &input.Port, &input.Protocol, &input.State, &input.Service, &input.Version, &input.Banner); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		inputs[resultID] = input
+	}
+	rows.Close()
+
+	rows, err = s.db.Query(`
+		SELECT v.scan_result_id, c.id, c.cvss_score, c.published_at
+		FROM scan_result_vulnerabilities v
+		JOIN cves c ON c.id = v.cve_id
+		JOIN scan_results sr ON sr.id = v.scan_result_id
+		WHERE sr.scan_id = $1
+	`, scanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matched vulnerabilities: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resultID int
+		var cve CVE
+		if err := rows.Scan(&resultID, &cve.ID, &cve.CVSSScore, &cve.PublishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if input, ok := inputs[resultID]; ok {
+			input.CVEs = append(input.CVEs, cve)
+		}
+	}
+
+	return inputs, rows.Err()
+}
+
+// LoadResultInput builds the engine input for a single stored result of one of
+// the organization's assets, returning nil if the result does not exist
+func (s *Service) LoadResultInput(resultID, organizationID int) (*Input, error) {
+	input := &Input{}
+	err := s.db.QueryRow(`
+		SELECT sr.port, sr.protocol, sr.state, COALESCE(sr.service, ''), COALESCE(sr.version, ''),
+			COALESCE(sr.banner, ''), a.asset_type, COALESCE(a.criticality, $3), COALESCE(a.internet_facing, FALSE)
+		FROM scan_results sr
+		JOIN scans s ON s.id = sr.scan_id
+		JOIN assets a ON a.id = s.asset_id
+		WHERE sr.id = $1 AND a.organization_id = $2
+	`, resultID, organizationID, DefaultCriticality).Scan(
+		&input.Port, &input.Protocol, &input.State, &input.Service, &input.Version,
+		&input.Banner, &input.AssetType, &input.Criticality, &input.InternetFacing,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get scan result: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT c.id, c.cvss_score, c.published_at
+		FROM scan_result_vulnerabilities v
+		JOIN cves c ON c.id = v.cve_id
+		WHERE v.scan_result_id = $1
+	`, resultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matched vulnerabilities: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cve CVE
+		if err := rows.Scan(&cve.ID, &cve.CVSSScore, &cve.PublishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		input.CVEs = append(input.CVEs, cve)
+	}
+
+	return input, rows.Err()
+}
+
+// ScoreAsset aggregates the result scores of an asset's latest completed scan
+// and stores the asset's score
+func (s *Service) ScoreAsset(assetID int) (*Result, error) {
+	rows, err := s.db.Query(`
+		SELECT COALESCE(risk_score, 0) FROM scan_results
+		WHERE scan_id = (
+			SELECT id FROM scans WHERE asset_id = $1 AND status = 'completed' ORDER BY id DESC LIMIT 1
+		)
+	`, assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get result scores: %v", err)
+	}
+	defer rows.Close()
+
+	var scores []float64
+	for rows.Next() {
+		var score float64
+		if err := rows.Scan(&score); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		scores = append(scores, score)
+	}
+
+	result := AggregateAsset(scores)
+	_, err = s.db.Exec(`
+		UPDATE assets SET risk_score = $1, risk_severity = $2, risk_scored_at = $3
+		WHERE id = $4
+	`, result.Score, result.Severity, time.Now(), assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store asset score: %v", err)
+	}
+
+	return &result, nil
+}
+
+// ScoreOrganization aggregates the scores of an organization's scored assets and
+// stores the organization's score
+func (s *Service) ScoreOrganization(organizationID int) (*Result, error) {
+	rows, err := s.db.Query(`
+		SELECT risk_score FROM assets
+		WHERE organization_id = $1 AND risk_score IS NOT NULL
+	`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset scores: %v", err)
+	}
+	defer rows.Close()
+
+	var scores []float64
+	for rows.Next() {
+		var score float64
+		if err := rows.Scan(&score); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		scores = append(scores, score)
+	}
+
+	result := AggregateOrganization(scores)
+	_, err = s.db.Exec(`
+		UPDATE organizations SET risk_score = $1, risk_severity = $2, risk_scored_at = $3
+		WHERE id = $4
+	`, result.Score, result.Severity, time.Now(), organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store organization score: %v", err)
+	}
+
+	return &result, nil
+}
+
+// RescoreAsset re-scores an asset's latest completed scan, e.g. after its
+// criticality changes. Assets without completed scans only refresh their organization.
+func (s *Service) RescoreAsset(assetID int) error {
+	var scanID, organizationID int
+	err := s.db.QueryRow(`
+		SELECT COALESCE((
+			SELECT id FROM scans WHERE asset_id = $1 AND status = 'completed' ORDER BY id DESC LIMIT 1
+		), 0), organization_id
+		FROM assets WHERE id = $1
+	`, assetID).Scan(&scanID, &organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get asset: %v", err)
+	}
+
+	if scanID != 0 {
+		return s.ScoreScan(scanID)
+	}
+	_, err = s.ScoreOrganization(organizationID)
+	return err
+}
+
+// RescoreAll re-scores the latest completed scan of every asset, returning how
+// many assets were scored
+func (s *Service) RescoreAll() (int, error) {
+	rows, err := s.db.Query(`SELECT id FROM assets ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get assets: %v", err)
+	}
+
+	var assetIDs []int
+	for rows.Next() {
+		var assetID int
+		if err := rows.Scan(&assetID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row: %v", err)
+		}
+		assetIDs = append(assetIDs, assetID)
+	}
+	rows.Close()
+
+	for i, assetID := range assetIDs {
+		if err := s.RescoreAsset(assetID); err != nil {
+			return i, fmt.Errorf("failed to score asset %d: %v", assetID, err)
+		}
+	}
+
+	return len(assetIDs), nil
+}
+
+// getScore reads a stored score, returning nil when the row has not been scored
+func (s *Service) getScore(query string, id int) (*Score, error) {
+	var score sql.NullFloat64
+	var severity sql.NullString
+	var scoredAt *time.Time
+
+	if err := s.db.QueryRow(query, id).Scan(&score, &severity, &scoredAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get risk score: %v", err)
+	}
+	if !score.Valid {
+		return nil, nil
+	}
+
+	return &Score{
+		Score:    score.Float64,
+		Severity: Severity(severity.String),
+		ScoredAt: scoredAt,
+	}, nil
+}
+
+// GetResultScore retrieves the stored score of a scan result
+func (s *Service) GetResultScore(resultID int) (*Score, error) {
+	return s.getScore(`SELECT risk_score, risk_severity, NULL::TIMESTAMP FROM scan_results WHERE id = $1`, resultID)
+}
+
+// GetAssetScore retrieves the stored score of an asset
+func (s *Service) GetAssetScore(assetID int) (*Score, error) {
+	return s.getScore(`SELECT risk_score, risk_severity, risk_scored_at FROM assets WHERE id = $1`, assetID)
+}
+
+// GetOrganizationScore retrieves the stored score of an organization
+func (s *Service) GetOrganizationScore(organizationID int) (*Score, error) {
+	return s.getScore(`SELECT risk_score, risk_severity, risk_scored_at FROM organizations WHERE id = $1`, organizationID)
+}
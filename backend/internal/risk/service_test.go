@@ -0,0 +1,50 @@
+package risk
+
+import (
+	"testing"
+
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+func TestScoreOrganizationOnlyAggregatesItsAssets(t *testing.T) {
+	database := storetest.NewSQLite(t)
+	tt := storetest.NewTenants(t, store.NewSQL(database))
+	service := NewService(database, DefaultEngine())
+
+	scores := map[int]float64{tt.AlphaAsset.ID: 80, tt.BetaAsset.ID: 10}
+	for assetID, score := range scores {
+		if _, err := database.Exec(`UPDATE assets SET risk_score = $1 WHERE id = $2`, score, assetID); err != nil {
+			t.Fatalf("set asset score: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		organizationID int
+		want           float64
+	}{
+		{"alpha", tt.Alpha.ID, 80},
+		{"beta", tt.Beta.ID, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := service.ScoreOrganization(test.organizationID)
+			if err != nil {
+				t.Fatalf("ScoreOrganization: %v", err)
+			}
+			if result.Score != test.want {
+				t.Errorf("ScoreOrganization = %v, want %v", result.Score, test.want)
+			}
+
+			stored, err := service.GetOrganizationScore(test.organizationID)
+			if err != nil {
+				t.Fatalf("GetOrganizationScore: %v", err)
+			}
+			if stored == nil || stored.Score != test.want {
+				t.Errorf("GetOrganizationScore = %+v, want %v", stored, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package scanner
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DiffChange describes how a port differs between two scans
+type DiffChange string
+
+const (
+	DiffChangeOpened  DiffChange = "opened"
+	DiffChangeClosed  DiffChange = "closed"
+	DiffChangeChanged DiffChange = "changed"
+)
+
+// DiffEntry is a single port that was opened, closed or changed between two scans.
+// Service and Version hold the target scan's values; for closed ports they hold
+// the base scan's values instead.
+type DiffEntry struct {
+	Change          DiffChange `json:"change"`
+	Host            string     `json:"host"`
+	Hostname        string     `json:"hostname"`
+	Port            int        `json:"port"`
+	Protocol        string     `json:"protocol"`
+	Service         string     `json:"service"`
+	Version         string     `json:"version"`
+	PreviousService string     `json:"previousService,omitempty"`
+	PreviousVersion string     `json:"previousVersion,omitempty"`
+}
+
+// ScanDiff is the comparison of a target scan against an earlier base scan
+type ScanDiff struct {
+	ID           int         `json:"id"`
+	AssetID      int         `json:"assetId"`
+	BaseScanID   int         `json:"baseScanId"`
+	TargetScanID int         `json:"targetScanId"`
+	Entries      []DiffEntry `json:"entries"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+// Count returns how many entries have the given change type
+func (d *ScanDiff) Count(change DiffChange) int {
+	count := 0
+	for _, entry := range d.Entries {
+		if entry.Change == change {
+			count++
+		}
+	}
+	return count
+}
+
+// Empty reports whether the two scans found the same services
+func (d *ScanDiff) Empty() bool {
+	return len(d.Entries) == 0
+}
+
+// diffKey identifies a port on a host across scans
+func diffKey(result ScanResult) string {
+	return result.Host + "|" + strconv.Itoa(result.Port) + "/" + result.Protocol
+}
+
+// DiffResults compares the results of a base scan with a later target scan,
+// reporting newly opened ports, closed ports and changed service or version strings
+func DiffResults(base, target []ScanResult) []DiffEntry {
+	previous := make(map[string]ScanResult, len(base))
+	for _, result := range base {
+		previous[diffKey(result)] = result
+	}
+
+	var entries []DiffEntry
+	seen := make(map[string]bool, len(target))
+	for _, result := range target {
+		key := diffKey(result)
+		seen[key] = true
+
+		old, ok := previous[key]
+		switch {
+		case !ok:
+			entries = append(entries, DiffEntry{
+				Change:   DiffChangeOpened,
+				Host:     result.Host,
+				Hostname: result.Hostname,
+				Port:     result.Port,
+				Protocol: result.Protocol,
+				Service:  result.Service,
+				Version:  result.Version,
+			})
+		case old.Service != result.Service || old.Version != result.Version:
+			entries = append(entries, DiffEntry{
+				Change:          DiffChangeChanged,
+				Host:            result.Host,
+				Hostname:        result.Hostname,
+				Port:            result.Port,
+				Protocol:        result.Protocol,
+				Service:         result.Service,
+				Version:         result.Version,
+				PreviousService: old.Service,
+				PreviousVersion: old.Version,
+			})
+		}
+	}
+
+	for _, result := range base {
+		if seen[diffKey(result)] {
+			continue
+		}
+		entries = append(entries, DiffEntry{
+			Change:   DiffChangeClosed,
+			Host:     result.Host,
+			Hostname: result.Hostname,
+			Port:     result.Port,
+			Protocol: result.Protocol,
+			Service:  result.Service,
+			Version:  result.Version,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Host != entries[j].Host {
+			return entries[i].Host < entries[j].Host
+		}
+		if entries[i].Port != entries[j].Port {
+			return entries[i].Port < entries[j].Port
+		}
+		return entries[i].Protocol < entries[j].Protocol
+	})
+
+	return entries
+}
+
+// DiffScans compares two stored scans without saving the result
+func (sm *ScanManager) DiffScans(baseScanID, targetScanID int) (*ScanDiff, error) {
+	target, err := sm.GetScan(targetScanID)
+	if err != nil {
+		return nil, err
+	}
+
+	baseResults, err := sm.GetScanResults(baseScanID)
+	if err != nil {
+		return nil, err
+	}
+	targetResults, err := sm.GetScanResults(targetScanID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScanDiff{
+		AssetID:      target.AssetID,
+		BaseScanID:   baseScanID,
+		TargetScanID: targetScanID,
+		Entries:      DiffResults(baseResults, targetResults),
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// SaveScanDiff stores a diff, replacing any earlier diff of the same pair of scans
+func (sm *ScanManager) SaveScanDiff(diff *ScanDiff) error {
+	tx, err := sm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM scan_diffs WHERE base_scan_id = $1 AND target_scan_id = $2`, diff.BaseScanID, diff.TargetScanID)
+	if err != nil {
+		return fmt.Errorf("failed to replace scan diff: %v", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO scan_diffs (asset_id, base_scan_id, target_scan_id, opened_count, closed_count, changed_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, diff.AssetID, diff.BaseScanID, diff.TargetScanID, diff.Count(DiffChangeOpened), diff.Count(DiffChangeClosed),
+		diff.Count(DiffChangeChanged), diff.CreatedAt).Scan(&diff.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert scan diff: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO scan_diff_entries (diff_id, change, host, hostname, port, protocol, service, version, previous_service, previous_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, entry := range diff.Entries {
+		_, err := stmt.Exec(diff.ID, entry.Change, entry.Host, entry.Hostname, entry.Port, entry.Protocol,
+			entry.Service, entry.Version, entry.PreviousService, entry.PreviousVersion)
+		if err != nil {
+			return fmt.Errorf("failed to insert scan diff entry: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// GetScanDiff retrieves the stored diff of two scans, returning nil when none has been saved
+func (sm *ScanManager) GetScanDiff(baseScanID, targetScanID int) (*ScanDiff, error) {
+	query := `
+		SELECT id, asset_id, base_scan_id, target_scan_id, created_at
+		FROM scan_diffs
+		WHERE base_scan_id = $1 AND target_scan_id = $2
+	`
+	return sm.loadScanDiff(query, baseScanID, targetScanID)
+}
+
+// GetLatestScanDiff retrieves the automatic diff of a scan against its predecessor,
+// returning nil when the scan has none
+func (sm *ScanManager) GetLatestScanDiff(targetScanID int) (*ScanDiff, error) {
+	query := `
+		SELECT id, asset_id, base_scan_id, target_scan_id, created_at
+		FROM scan_diffs
+		WHERE target_scan_id = $1
+		ORDER BY base_scan_id DESC
+		LIMIT 1
+	`
+	return sm.loadScanDiff(query, targetScanID)
+}
+
+// loadScanDiff reads a scan_diffs row and its entries
+func (sm *ScanManager) loadScanDiff(query string, args ...any) (*ScanDiff, error) {
+	var diff ScanDiff
+	err := sm.db.QueryRow(query, args...).Scan(&diff.ID, &diff.AssetID, &diff.BaseScanID, &diff.TargetScanID, &diff.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get scan diff: %v", err)
+	}
+
+	rows, err := sm.db.Query(`
+		SELECT change, COALESCE(host, ''), COALESCE(hostname, ''), port, protocol,
+		       COALESCE(service, ''), COALESCE(version, ''), COALESCE(previous_service, ''), COALESCE(previous_version, '')
+		FROM scan_diff_entries
+		WHERE diff_id = $1
+		ORDER BY id ASC
+	`, diff.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan diff entries: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry DiffEntry
+		err := rows.Scan(&entry.Change, &entry.Host, &entry.Hostname, &entry.Port, &entry.Protocol,
+			&entry.Service, &entry.Version, &entry.PreviousService, &entry.PreviousVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		diff.Entries = append(diff.Entries, entry)
+	}
+
+	return &diff, nil
+}
+
+// diffWithPrevious compares a completed scan with the asset's previous completed
+// scan and stores the result. The first scan of an asset has nothing to compare against.
+func (sm *ScanManager) diffWithPrevious(scanID int) (*ScanDiff, error) {
+	baseScanID, err := sm.scans.PreviousCompleted(scanID)
+	if err != nil || baseScanID == 0 {
+		return nil, err
+	}
+
+	diff, err := sm.DiffScans(baseScanID, scanID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.SaveScanDiff(diff); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Scan %d compared with scan %d: %d opened, %d closed, %d changed", scanID, baseScanID,
+		diff.Count(DiffChangeOpened), diff.Count(DiffChangeClosed), diff.Count(DiffChangeChanged))
+	return diff, nil
+}
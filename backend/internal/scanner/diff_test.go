@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	ssh := ScanResult{Host: "10.0.0.1", Port: 22, Protocol: "tcp", State: "open", Service: "ssh", Version: "OpenSSH 8.2p1"}
+	upgraded := ssh
+	upgraded.Version = "OpenSSH 9.6p1"
+	web := ScanResult{Host: "10.0.0.1", Hostname: "web", Port: 80, Protocol: "tcp", State: "open", Service: "http", Version: "nginx 1.24"}
+	dns := ScanResult{Host: "10.0.0.1", Port: 53, Protocol: "tcp", State: "open", Service: "domain"}
+	dnsUDP := dns
+	dnsUDP.Protocol = "udp"
+	other := ssh
+	other.Host = "10.0.0.2"
+
+	tests := []struct {
+		name         string
+		base, target []ScanResult
+		want         []DiffEntry
+	}{
+		{"no change", []ScanResult{ssh, web}, []ScanResult{web, ssh}, nil},
+		{"nothing scanned", nil, nil, nil},
+		{
+			"port opened",
+			[]ScanResult{ssh}, []ScanResult{ssh, web},
+			[]DiffEntry{{Change: DiffChangeOpened, Host: "10.0.0.1", Hostname: "web", Port: 80, Protocol: "tcp", Service: "http", Version: "nginx 1.24"}},
+		},
+		{
+			"port closed",
+			[]ScanResult{ssh, web}, []ScanResult{ssh},
+			[]DiffEntry{{Change: DiffChangeClosed, Host: "10.0.0.1", Hostname: "web", Port: 80, Protocol: "tcp", Service: "http", Version: "nginx 1.24"}},
+		},
+		{
+			"version changed",
+			[]ScanResult{ssh}, []ScanResult{upgraded},
+			[]DiffEntry{{
+				Change: DiffChangeChanged, Host: "10.0.0.1", Port: 22, Protocol: "tcp", Service: "ssh", Version: "OpenSSH 9.6p1",
+				PreviousService: "ssh", PreviousVersion: "OpenSSH 8.2p1",
+			}},
+		},
+		{
+			"same port on another protocol",
+			[]ScanResult{dns}, []ScanResult{dnsUDP},
+			[]DiffEntry{
+				{Change: DiffChangeClosed, Host: "10.0.0.1", Port: 53, Protocol: "tcp", Service: "domain"},
+				{Change: DiffChangeOpened, Host: "10.0.0.1", Port: 53, Protocol: "udp", Service: "domain"},
+			},
+		},
+		{
+			"same port on another host",
+			[]ScanResult{ssh}, []ScanResult{ssh, other},
+			[]DiffEntry{{Change: DiffChangeOpened, Host: "10.0.0.2", Port: 22, Protocol: "tcp", Service: "ssh", Version: "OpenSSH 8.2p1"}},
+		},
+		{
+			"sorted by host, port and protocol",
+			[]ScanResult{other, web, dns}, []ScanResult{upgraded, dnsUDP},
+			[]DiffEntry{
+				{Change: DiffChangeOpened, Host: "10.0.0.1", Port: 22, Protocol: "tcp", Service: "ssh", Version: "OpenSSH 9.6p1"},
+				{Change: DiffChangeClosed, Host: "10.0.0.1", Port: 53, Protocol: "tcp", Service: "domain"},
+				{Change: DiffChangeOpened, Host: "10.0.0.1", Port: 53, Protocol: "udp", Service: "domain"},
+				{Change: DiffChangeClosed, Host: "10.0.0.1", Hostname: "web", Port: 80, Protocol: "tcp", Service: "http", Version: "nginx 1.24"},
+				{Change: DiffChangeClosed, Host: "10.0.0.2", Port: 22, Protocol: "tcp", Service: "ssh", Version: "OpenSSH 8.2p1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := DiffResults(test.base, test.target)
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("DiffResults =\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
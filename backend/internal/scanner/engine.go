@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultEngine is the engine used when neither the asset nor the scan request names one
+const DefaultEngine = "nmap"
+
+// Engine is implemented by every scanning backend that ScanManager can drive
+type Engine interface {
+	// Name returns the identifier the engine is registered and selected by
+	Name() string
+	// Scan scans the target using the profile's options and returns the
+	// discovered ports along with run metadata. Cancelling ctx must stop the
+	// scan and return an error wrapping context.Canceled.
+	Scan(ctx context.Context, target string, profile *Profile) (*EngineResult, error)
+}
+
+// StreamingEngine is implemented by engines that can report progress and
+// results while a scan runs, which ScanManager prefers over Scan
+type StreamingEngine interface {
+	Engine
+	// ScanStreaming is Scan, reporting to observer as the scan goes. Results
+	// passed to observer.Results are left out of the returned EngineResult.
+	ScanStreaming(ctx context.Context, target string, profile *Profile, observer ScanObserver) (*EngineResult, error)
+}
+
+// ScanObserver receives what a StreamingEngine reports while a scan runs. Its
+// methods are called from one goroutine at a time.
+type ScanObserver interface {
+	// Progress reports how far the scan has got
+	Progress(progress ScanProgress)
+	// Results reports results found so far, such as those for hosts that have
+	// finished in a range scan
+	Results(results []ScanResult)
+}
+
+// ScanProgress is how far a running scan has got
+type ScanProgress struct {
+	// Percent is the share of the scan done, 0-100
+	Percent float64
+	// EstimatedCompletion is when the engine expects the scan to finish, if it knows
+	EstimatedCompletion *time.Time
+}
+
+// ScanMetadata describes how an engine run was performed
+type ScanMetadata struct {
+	Engine     string    `json:"engine"`
+	Profile    string    `json:"profile"`
+	Version    string    `json:"version,omitempty"`
+	Args       string    `json:"args,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Duration returns how long the engine run took
+func (m ScanMetadata) Duration() time.Duration {
+	return m.FinishedAt.Sub(m.StartedAt)
+}
+
+// EngineResult is the output of a single engine run
+type EngineResult struct {
+	Results  []ScanResult `json:"results"`
+	Metadata ScanMetadata `json:"metadata"`
+}
+
+// Registry holds the engines available to ScanManager keyed by name
+type Registry struct {
+	mu      sync.RWMutex
+	engines map[string]Engine
+}
+
+// NewRegistry creates a registry containing the given engines
+func NewRegistry(engines ...Engine) *Registry {
+	r := &Registry{
+		engines: make(map[string]Engine),
+	}
+	for _, engine := range engines {
+		r.Register(engine)
+	}
+	return r
+}
+
+// Register adds an engine, replacing any engine already registered under the same name
+func (r *Registry) Register(engine Engine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.engines[engine.Name()] = engine
+}
+
+// Get returns the engine registered under name, falling back to DefaultEngine when name is empty
+func (r *Registry) Get(name string) (Engine, error) {
+	if name == "" {
+		name = DefaultEngine
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	engine, ok := r.engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scan engine: %s", name)
+	}
+	return engine, nil
+}
+
+// Names returns the names of all registered engines in sorted order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.engines))
+	for name := range r.engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"context"
+	"log"
+
+	"cyber-risk-monitor/internal/pubsub"
+)
+
+// ScanEvent is published each time a scan changes status, reports progress or stores results
+type ScanEvent struct {
+	// Scan is the scan's state after the change
+	Scan *Scan
+	// Progress is set on events that only report progress or stored results. A
+	// subscriber that has fallen behind only receives the latest of a scan's.
+	Progress bool
+}
+
+// scanTopic is what scan events are published to: one scan, the scans of one
+// asset, or the scans of one organization's assets, with the other fields zero
+type scanTopic struct {
+	ScanID         int
+	AssetID        int
+	OrganizationID int
+}
+
+// newScanBroker creates the broker scan events are published through, coalescing
+// each scan's progress events
+func newScanBroker() *pubsub.Broker[scanTopic, ScanEvent] {
+	return pubsub.NewBroker(func(event ScanEvent) (scanTopic, bool) {
+		return scanTopic{ScanID: event.Scan.ID}, event.Progress
+	})
+}
+
+// SubscribeScan returns a channel receiving the events of one scan until ctx is done
+func (sm *ScanManager) SubscribeScan(ctx context.Context, scanID int) <-chan ScanEvent {
+	return sm.events.Subscribe(ctx, scanTopic{ScanID: scanID})
+}
+
+// SubscribeAsset returns a channel receiving the events of an asset's scans until ctx is done
+func (sm *ScanManager) SubscribeAsset(ctx context.Context, assetID int) <-chan ScanEvent {
+	return sm.events.Subscribe(ctx, scanTopic{AssetID: assetID})
+}
+
+// SubscribeOrganization returns a channel receiving the events of the scans of
+// an organization's assets until ctx is done
+func (sm *ScanManager) SubscribeOrganization(ctx context.Context, organizationID int) <-chan ScanEvent {
+	return sm.events.Subscribe(ctx, scanTopic{OrganizationID: organizationID})
+}
+
+// publish sends the scan's current state to subscribers after it changed status
+func (sm *ScanManager) publish(scanID int) {
+	sm.publishEvent(scanID, false)
+}
+
+// publishProgress sends the scan's current state to subscribers after it
+// reported progress or stored results
+func (sm *ScanManager) publishProgress(scanID int) {
+	sm.publishEvent(scanID, true)
+}
+
+// publishEvent loads the scan and the organization of its asset and publishes its state
+func (sm *ScanManager) publishEvent(scanID int, progress bool) {
+	scan, err := sm.scans.Get(scanID)
+	if err != nil {
+		log.Printf("Failed to load scan %d to publish: %v", scanID, err)
+		return
+	}
+	organizationID, err := sm.scans.OrganizationID(scanID)
+	if err != nil {
+		log.Printf("Failed to load the organization of scan %d to publish: %v", scanID, err)
+		return
+	}
+	sm.broadcast(ScanEvent{Scan: scan, Progress: progress}, organizationID)
+}
+
+// broadcast publishes an event to the topics of its scan, asset and organization
+func (sm *ScanManager) broadcast(event ScanEvent, organizationID int) {
+	sm.events.Publish(scanTopic{ScanID: event.Scan.ID}, event)
+	sm.events.Publish(scanTopic{AssetID: event.Scan.AssetID}, event)
+	sm.events.Publish(scanTopic{OrganizationID: organizationID}, event)
+}
@@ -1,52 +1,185 @@
 package scanner
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/pubsub"
+	"cyber-risk-monitor/internal/store"
 )
 
-// ScanStatus represents the current status of a scan
-type ScanStatus string
+// Scan lifecycle types are defined by the store so every implementation shares them
+type (
+	ScanStatus = store.ScanStatus
+	Scan       = store.Scan
+	ScanError  = store.ScanError
+)
+
+const (
+	ScanStatusPending   = store.ScanStatusPending
+	ScanStatusRunning   = store.ScanStatusRunning
+	ScanStatusCompleted = store.ScanStatusCompleted
+	ScanStatusFailed    = store.ScanStatusFailed
+	ScanStatusCancelled = store.ScanStatusCancelled
+)
 
+// Error codes recorded on failed scans
 const (
-	ScanStatusPending   ScanStatus = "pending"
-	ScanStatusRunning   ScanStatus = "running"
-	ScanStatusCompleted ScanStatus = "completed"
-	ScanStatusFailed    ScanStatus = "failed"
+	// ErrorCodeEngineUnavailable means the scan's engine or profile could not be loaded
+	ErrorCodeEngineUnavailable = "engine_unavailable"
+	// ErrorCodeEngineFailed means the engine ran but returned an error
+	ErrorCodeEngineFailed = "engine_failed"
+	// ErrorCodeSaveFailed means the scan finished but its results could not be stored
+	ErrorCodeSaveFailed = "save_failed"
+	// ErrorCodeInterrupted means the server stopped mid-scan too many times
+	ErrorCodeInterrupted = "interrupted"
 )
 
-// Scan represents a scan record
-type Scan struct {
-	ID        int        `json:"id"`
-	AssetID   int        `json:"assetId"`
-	Status    ScanStatus `json:"status"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
-	Error     *string    `json:"error,omitempty"`
+// errScanCancelled is the cancellation cause recorded when a user cancels a scan,
+// distinguishing it from the worker pool shutting down
+var errScanCancelled = errors.New("scan cancelled by user")
+
+// errLeaseLost is the cancellation cause recorded when this server's lease on a
+// running scan expired and another server recovered it
+var errLeaseLost = errors.New("scan lease lost")
+
+// VulnerabilityMatcher matches the products found by a completed scan against
+// known vulnerabilities and stores the matches
+type VulnerabilityMatcher interface {
+	MatchScan(scanID int) (int, error)
+}
+
+// RiskScorer scores the results of a completed scan and stores the scores
+type RiskScorer interface {
+	ScoreScan(scanID int) error
+}
+
+// FindingTracker records the findings raised by a completed scan
+type FindingTracker interface {
+	SyncScan(scanID int) error
 }
 
 // ScanManager handles scan operations and database interactions
 type ScanManager struct {
 	db      *db.DB
-	scanner *Scanner
+	assets  store.AssetStore
+	scans   store.ScanStore
+	results store.ResultStore
+	engines *Registry
+	matcher VulnerabilityMatcher
+	scorer  RiskScorer
+	tracker FindingTracker
+
+	// queue configures the worker pool; wake nudges idle workers when a scan is queued
+	queue QueueConfig
+	wake  chan struct{}
+
+	// workers tracks the goroutines started by Start so Wait can block on them
+	workers sync.WaitGroup
+
+	// running holds the cancel functions of in-flight scans keyed by scan ID
+	mu      sync.Mutex
+	running map[int]context.CancelCauseFunc
+
+	// events carries scan status changes, progress and stored results to subscribers
+	events *pubsub.Broker[scanTopic, ScanEvent]
 }
 
-// NewScanManager creates a new ScanManager instance
-func NewScanManager(database *db.DB, scanner *Scanner) *ScanManager {
+// NewScanManager creates a new ScanManager instance that keeps assets, scans
+// and results in stores. Queued scans are only executed once Start has been called.
+func NewScanManager(database *db.DB, stores *store.Store, engines *Registry) *ScanManager {
 	return &ScanManager{
 		db:      database,
-		scanner: scanner,
+		assets:  stores.Assets,
+		scans:   stores.Scans,
+		results: stores.Results,
+		engines: engines,
+		wake:    make(chan struct{}, 1),
+		running: make(map[int]context.CancelCauseFunc),
+		events:  newScanBroker(),
 	}
 }
 
-// StartScan initiates a new scan for the specified asset
-func (sm *ScanManager) StartScan(assetID int) (*Scan, error) {
+// SetVulnerabilityMatcher sets the matcher run over the results of every completed scan
+func (sm *ScanManager) SetVulnerabilityMatcher(matcher VulnerabilityMatcher) {
+	sm.matcher = matcher
+}
+
+// SetRiskScorer sets the scorer run over the results of every completed scan
+func (sm *ScanManager) SetRiskScorer(scorer RiskScorer) {
+	sm.scorer = scorer
+}
+
+// SetFindingTracker sets the tracker that raises and resolves findings for every completed scan
+func (sm *ScanManager) SetFindingTracker(tracker FindingTracker) {
+	sm.tracker = tracker
+}
+
+// Engines returns the registry of engines available to this manager
+func (sm *ScanManager) Engines() *Registry {
+	return sm.engines
+}
+
+// ScanRequest is a scan checked by PrepareScan, ready to be queued
+type ScanRequest struct {
+	AssetID        int
+	OrganizationID int
+	RequestedBy    int
+	Engine         string
+	ProfileID      *int
+}
+
+// StartScan queues a new scan for the specified asset on behalf of the
+// requesting user, whose running scans are capped by the queue. engineName and
+// profileID override the asset's configured engine and profile when set.
+func (sm *ScanManager) StartScan(assetID, requestedBy int, engineName string, profileID *int) (*Scan, error) {
+	request, err := sm.PrepareScan(assetID, requestedBy, engineName, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create scan record; a worker picks it up from the queue
+	scan, err := sm.scans.Create(request.AssetID, request.RequestedBy, request.Engine, request.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	sm.ScansQueued(request.OrganizationID, scan)
+
+	return scan, nil
+}
+
+// QueueScanTx queues a prepared scan as part of tx, so it is only run if tx
+// commits. Pass it to ScansQueued once tx has.
+func (sm *ScanManager) QueueScanTx(tx *sql.Tx, request *ScanRequest) (*Scan, error) {
+	return store.CreateScanTx(tx, request.AssetID, request.RequestedBy, request.Engine, request.ProfileID)
+}
+
+// HasActiveScanTx reports whether the asset has a scan that is queued or
+// running, including those queued by tx
+func (sm *ScanManager) HasActiveScanTx(tx *sql.Tx, assetID int) (bool, error) {
+	return store.HasActiveScanTx(tx, assetID)
+}
+
+// ScansQueued tells subscribers and idle workers about scans of the
+// organization's assets that were just queued
+func (sm *ScanManager) ScansQueued(organizationID int, scans ...*Scan) {
+	for _, scan := range scans {
+		sm.broadcast(ScanEvent{Scan: scan}, organizationID)
+	}
+	sm.notify()
+}
+
+// PrepareScan checks that the asset can be scanned and resolves the engine and
+// profile of a scan of it, without queueing it
+func (sm *ScanManager) PrepareScan(assetID, requestedBy int, engineName string, profileID *int) (*ScanRequest, error) {
 	// Get asset information
-	asset, err := sm.getAsset(assetID)
+	asset, err := sm.assets.Get(assetID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get asset: %v", err)
 	}
@@ -56,281 +189,223 @@ func (sm *ScanManager) StartScan(assetID int) (*Scan, error) {
 		return nil, fmt.Errorf("invalid target: %v", err)
 	}
 
-	// Create scan record
-	scan, err := sm.CreateScan(assetID)
+	// Resolve the engine, preferring the per-scan override over the asset default
+	if engineName == "" {
+		engineName = asset.ScanEngine
+	}
+	engine, err := sm.engines.Get(engineName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create scan: %v", err)
+		return nil, err
 	}
 
-	// Start async scanning
-	go sm.processScan(scan.ID, asset.Target)
+	// Resolve the profile the same way, falling back to the built-in defaults
+	if profileID == nil {
+		profileID = asset.ScanProfileID
+	}
+	if profileID != nil {
+		if _, err := sm.GetProfile(*profileID); err != nil {
+			return nil, err
+		}
+	}
 
-	return scan, nil
+	return &ScanRequest{
+		AssetID:        assetID,
+		OrganizationID: asset.OrganizationID,
+		RequestedBy:    requestedBy,
+		Engine:         engine.Name(),
+		ProfileID:      profileID,
+	}, nil
 }
 
-// CreateScan creates a new scan record in the database
-func (sm *ScanManager) CreateScan(assetID int) (*Scan, error) {
-	query := `
-		INSERT INTO scans (asset_id, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, asset_id, status, created_at, updated_at
-	`
-
-	now := time.Now()
-	var scan Scan
-
-	err := sm.db.QueryRow(query, assetID, ScanStatusPending, now, now).Scan(
-		&scan.ID,
-		&scan.AssetID,
-		&scan.Status,
-		&scan.CreatedAt,
-		&scan.UpdatedAt,
-	)
-
+// CancelScan removes a queued scan from the queue, or stops an in-flight scan
+// by killing its engine process, and marks it cancelled
+func (sm *ScanManager) CancelScan(scanID int) error {
+	// Queued scans only need their status changed so no worker claims them
+	cancelled, err := sm.scans.CancelPending(scanID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create scan: %v", err)
+		return err
+	}
+	if cancelled {
+		sm.publish(scanID)
+		return nil
 	}
 
-	return &scan, nil
-}
+	sm.mu.Lock()
+	cancel, ok := sm.running[scanID]
+	sm.mu.Unlock()
 
-// UpdateScanStatus updates the status of a scan
-func (sm *ScanManager) UpdateScanStatus(scanID int, status ScanStatus, errorMsg *string) error {
-	query := `
-		UPDATE scans 
-		SET status = $1, updated_at = $2, error = $3
-		WHERE id = $4
-	`
+	if !ok {
+		return fmt.Errorf("scan is not running")
+	}
 
-	_, err := sm.db.Exec(query, status, time.Now(), errorMsg, scanID)
+	// Record the status first so readers never see a cancelled scan as running.
+	// A scan whose engine already finished is left to complete or fail.
+	cancelled, err = sm.scans.Finish(scanID, ScanStatusCancelled, nil)
 	if err != nil {
-		return fmt.Errorf("failed to update scan status: %v", err)
+		return err
 	}
+	if !cancelled {
+		return fmt.Errorf("scan is not running")
+	}
+	cancel(errScanCancelled)
+	sm.publish(scanID)
 
 	return nil
 }
 
-// InsertScanResults inserts scan results into the database
-func (sm *ScanManager) InsertScanResults(scanID int, results []ScanResult) error {
-	if len(results) == 0 {
-		return nil
-	}
-
-	query := `
-		INSERT INTO scan_results (scan_id, port, protocol, state, service, version, banner, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`
+// HasActiveScan reports whether the asset has a scan that is queued or running
+func (sm *ScanManager) HasActiveScan(assetID int) (bool, error) {
+	return sm.scans.HasActive(assetID)
+}
 
-	tx, err := sm.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
-	}
-	defer tx.Rollback()
+// track registers the cancel function of an in-flight scan
+func (sm *ScanManager) track(scanID int, cancel context.CancelCauseFunc) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.running[scanID] = cancel
+}
 
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %v", err)
-	}
-	defer stmt.Close()
-
-	now := time.Now()
-	for _, result := range results {
-		_, err = stmt.Exec(
-			scanID,
-			result.Port,
-			result.Protocol,
-			result.State,
-			result.Service,
-			result.Version,
-			result.Banner,
-			now,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert scan result: %v", err)
-		}
-	}
+// untrack removes a finished scan from the in-flight registry and releases its context
+func (sm *ScanManager) untrack(scanID int) {
+	sm.mu.Lock()
+	cancel, ok := sm.running[scanID]
+	delete(sm.running, scanID)
+	sm.mu.Unlock()
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+	if ok {
+		cancel(nil)
 	}
-
-	return nil
 }
 
 // processScan handles the async scanning process
-func (sm *ScanManager) processScan(scanID int, target string) {
-	log.Printf("Starting scan %d for target: %s", scanID, target)
+func (sm *ScanManager) processScan(ctx context.Context, scanID, assetID int, engine Engine, target string, profile *Profile) {
+	defer sm.untrack(scanID)
+
+	// Publish the scan's final state however it ends
+	defer sm.publish(scanID)
+
+	// The worker has already marked the scan running
+	sm.publish(scanID)
+
+	log.Printf("Starting scan %d for target: %s using engine: %s, profile: %s", scanID, target, engine.Name(), profile.Name)
+
+	// Perform the scan, storing progress and results as they come in from
+	// engines that report them
+	observer := &scanObserver{sm: sm, scanID: scanID}
+	var engineResult *EngineResult
+	var err error
+	if streaming, ok := engine.(StreamingEngine); ok {
+		engineResult, err = streaming.ScanStreaming(ctx, target, profile, observer)
+	} else {
+		engineResult, err = engine.Scan(ctx, target, profile)
+	}
+	if ctx.Err() != nil {
+		if errors.Is(context.Cause(ctx), errScanCancelled) {
+			// CancelScan has already recorded the status
+			log.Printf("Scan %d cancelled", scanID)
+			return
+		}
+		if errors.Is(context.Cause(ctx), errLeaseLost) {
+			// The scan is back on the queue or running on another server
+			log.Printf("Scan %d stopped, its lease was lost to another server", scanID)
+			return
+		}
 
-	// Update status to running
-	if err := sm.UpdateScanStatus(scanID, ScanStatusRunning, nil); err != nil {
-		log.Printf("Failed to update scan status to running: %v", err)
+		// The worker pool is shutting down; put the scan back on the queue
+		log.Printf("Scan %d interrupted by shutdown, requeueing", scanID)
+		if updateErr := sm.scans.Requeue(scanID); updateErr != nil {
+			log.Printf("Failed to requeue scan: %v", updateErr)
+		}
 		return
 	}
-
-	// Perform the scan
-	results, err := sm.scanner.ScanTarget(target)
 	if err != nil {
 		log.Printf("Scan %d failed: %v", scanID, err)
-		errorMsg := err.Error()
-		if updateErr := sm.UpdateScanStatus(scanID, ScanStatusFailed, &errorMsg); updateErr != nil {
+		scanErr := &ScanError{Code: ErrorCodeEngineFailed, Message: err.Error()}
+		if _, updateErr := sm.scans.Finish(scanID, ScanStatusFailed, scanErr); updateErr != nil {
 			log.Printf("Failed to update scan status to failed: %v", updateErr)
 		}
 		return
 	}
 
-	// Insert scan results
-	if err := sm.InsertScanResults(scanID, results); err != nil {
+	results := engineResult.Results
+	log.Printf("Scan %d finished by %s %s in %v (args: %s)", scanID, engineResult.Metadata.Engine,
+		engineResult.Metadata.Version, engineResult.Metadata.Duration(), engineResult.Metadata.Args)
+
+	// Insert the scan results not already stored while it ran
+	err = observer.err
+	if err == nil {
+		err = sm.results.Insert(scanID, results)
+	}
+	if err != nil {
 		log.Printf("Failed to insert scan results for scan %d: %v", scanID, err)
-		errorMsg := fmt.Sprintf("Failed to save results: %v", err)
-		if updateErr := sm.UpdateScanStatus(scanID, ScanStatusFailed, &errorMsg); updateErr != nil {
+		scanErr := &ScanError{Code: ErrorCodeSaveFailed, Message: fmt.Sprintf("Failed to save results: %v", err)}
+		if _, updateErr := sm.scans.Finish(scanID, ScanStatusFailed, scanErr); updateErr != nil {
 			log.Printf("Failed to update scan status to failed: %v", updateErr)
 		}
 		return
 	}
+	sm.publish(scanID)
+
+	// Match detected products against known vulnerabilities
+	if sm.matcher != nil {
+		if matched, err := sm.matcher.MatchScan(scanID); err != nil {
+			log.Printf("Failed to match vulnerabilities for scan %d: %v", scanID, err)
+		} else if matched > 0 {
+			log.Printf("Scan %d matched %d known vulnerabilities", scanID, matched)
+		}
+	}
 
-	// Update status to completed
-	if err := sm.UpdateScanStatus(scanID, ScanStatusCompleted, nil); err != nil {
+	// Update status to completed, unless the scan was cancelled after its
+	// engine finished, in which case it must not replace the asset's score,
+	// findings or latest diff
+	completed, err := sm.scans.Finish(scanID, ScanStatusCompleted, nil)
+	if err != nil {
 		log.Printf("Failed to update scan status to completed: %v", err)
 		return
 	}
+	if !completed {
+		log.Printf("Scan %d was cancelled before it completed", scanID)
+		return
+	}
 
 	// Update asset's last scanned timestamp
-	if err := sm.updateAssetLastScanned(scanID); err != nil {
+	if err := sm.assets.SetLastScanned(assetID, time.Now()); err != nil {
 		log.Printf("Failed to update asset last scanned: %v", err)
 	}
 
-	log.Printf("Scan %d completed successfully with %d results", scanID, len(results))
-}
-
-// Asset represents an asset record
-type Asset struct {
-	ID     int    `json:"id"`
-	Target string `json:"target"`
-}
-
-// getAsset retrieves asset information by ID
-func (sm *ScanManager) getAsset(assetID int) (*Asset, error) {
-	query := `SELECT id, target FROM assets WHERE id = $1`
+	// Score the results now that this is the asset's latest completed scan
+	if sm.scorer != nil {
+		if err := sm.scorer.ScoreScan(scanID); err != nil {
+			log.Printf("Failed to score scan %d: %v", scanID, err)
+		}
+	}
 
-	var asset Asset
-	err := sm.db.QueryRow(query, assetID).Scan(&asset.ID, &asset.Target)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("asset not found")
+	// Open new findings and resolve those the scan no longer sees
+	if sm.tracker != nil {
+		if err := sm.tracker.SyncScan(scanID); err != nil {
+			log.Printf("Failed to sync findings for scan %d: %v", scanID, err)
 		}
-		return nil, fmt.Errorf("failed to get asset: %v", err)
 	}
 
-	return &asset, nil
-}
+	// Compare with the asset's previous scan so changes can be reported
+	if _, err := sm.diffWithPrevious(scanID); err != nil {
+		log.Printf("Failed to diff scan %d with previous scan: %v", scanID, err)
+	}
 
-// updateAssetLastScanned updates the last_scanned_at timestamp for an asset
-func (sm *ScanManager) updateAssetLastScanned(scanID int) error {
-	query := `
-		UPDATE assets 
-		SET last_scanned_at = $1, updated_at = $2
-		WHERE id = (SELECT asset_id FROM scans WHERE id = $3)
-	`
-
-	now := time.Now()
-	_, err := sm.db.Exec(query, now, now, scanID)
-	return err
+	log.Printf("Scan %d completed successfully with %d results", scanID, observer.stored+len(results))
 }
 
 // GetScan retrieves a scan by ID
 func (sm *ScanManager) GetScan(scanID int) (*Scan, error) {
-	query := `
-		SELECT id, asset_id, status, created_at, updated_at, error
-		FROM scans 
-		WHERE id = $1
-	`
-
-	var scan Scan
-	err := sm.db.QueryRow(query, scanID).Scan(
-		&scan.ID,
-		&scan.AssetID,
-		&scan.Status,
-		&scan.CreatedAt,
-		&scan.UpdatedAt,
-		&scan.Error,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("scan not found")
-		}
-		return nil, fmt.Errorf("failed to get scan: %v", err)
-	}
-
-	return &scan, nil
+	return sm.scans.Get(scanID)
 }
 
 // GetScansByAsset retrieves all scans for a specific asset
 func (sm *ScanManager) GetScansByAsset(assetID int) ([]*Scan, error) {
-	query := `
-		SELECT id, asset_id, status, created_at, updated_at, error
-		FROM scans 
-		WHERE asset_id = $1
-		ORDER BY created_at DESC
-	`
-
-	rows, err := sm.db.Query(query, assetID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get scans: %v", err)
-	}
-	defer rows.Close()
-
-	var scans []*Scan
-	for rows.Next() {
-		var scan Scan
-		err := rows.Scan(
-			&scan.ID,
-			&scan.AssetID,
-			&scan.Status,
-			&scan.CreatedAt,
-			&scan.UpdatedAt,
-			&scan.Error,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
-		}
-		scans = append(scans, &scan)
-	}
-
-	return scans, nil
+	return sm.scans.ListByAsset(assetID)
 }
 
 // GetScanResults retrieves all results for a specific scan
 func (sm *ScanManager) GetScanResults(scanID int) ([]ScanResult, error) {
-	query := `
-		SELECT port, protocol, state, service, version, banner
-		FROM scan_results 
-		WHERE scan_id = $1
-		ORDER BY port ASC
-	`
-
-	rows, err := sm.db.Query(query, scanID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get scan results: %v", err)
-	}
-	defer rows.Close()
-
-	var results []ScanResult
-	for rows.Next() {
-		var result ScanResult
-		err := rows.Scan(
-			&result.Port,
-			&result.Protocol,
-			&result.State,
-			&result.Service,
-			&result.Version,
-			&result.Banner,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
-		}
-		results = append(results, result)
-	}
-
-	return results, nil
+	return sm.results.ListByScan(scanID)
 }
@@ -1,28 +1,40 @@
 package scanner
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"math"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
+
+	"cyber-risk-monitor/internal/store"
+)
+
+// Port states reported for results. UDP ports that do not answer probes are
+// reported as open|filtered since nmap cannot tell the two apart.
+const (
+	PortStateOpen         = "open"
+	PortStateOpenFiltered = "open|filtered"
 )
 
 // ScanResult represents a single port scan result
-type ScanResult struct {
-	Port     int    `json:"port"`
-	Protocol string `json:"protocol"`
-	State    string `json:"state"`
-	Service  string `json:"service"`
-	Version  string `json:"version"`
-	Banner   string `json:"banner"`
-}
+type ScanResult = store.ScanResult
 
 // Scanner handles nmap scanning operations
 type Scanner struct {
 	timeout time.Duration
 }
 
+// Ensure Scanner implements StreamingEngine
+var _ StreamingEngine = (*Scanner)(nil)
+
+// statsInterval is how often nmap reports the progress of a running scan
+const statsInterval = "5s"
+
 // NewScanner creates a new Scanner instance
 func NewScanner(timeout time.Duration) *Scanner {
 	return &Scanner{
@@ -30,66 +42,265 @@ func NewScanner(timeout time.Duration) *Scanner {
 	}
 }
 
-// ScanTarget performs an nmap scan on the specified target
-func (s *Scanner) ScanTarget(target string) ([]ScanResult, error) {
-	// Validate target format (basic validation)
-	if target == "" {
-		return nil, fmt.Errorf("target cannot be empty")
+// Name returns the engine name the nmap scanner is registered under
+func (s *Scanner) Name() string {
+	return DefaultEngine
+}
+
+// Scan implements Engine by running nmap against the target
+func (s *Scanner) Scan(ctx context.Context, target string, profile *Profile) (*EngineResult, error) {
+	return s.ScanStreaming(ctx, target, profile, nil)
+}
+
+// ScanStreaming implements StreamingEngine by running nmap against the target,
+// reporting its task progress and each host's results as nmap prints them.
+// With a nil observer every result is returned at the end instead.
+func (s *Scanner) ScanStreaming(ctx context.Context, target string, profile *Profile, observer ScanObserver) (*EngineResult, error) {
+	if profile == nil {
+		profile = DefaultProfile()
+	}
+
+	startedAt := time.Now()
+
+	result, err := s.run(ctx, target, profile, observer)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build nmap command: -sS (SYN scan), -sV (version detection), -p 1-1000 (port range), -oX - (XML output to stdout)
-	args := []string{
-		"-sS",          // SYN scan
-		"-sV",          // Version detection
-		"-p", "1-1000", // Port range
+	result.Metadata.Engine = s.Name()
+	result.Metadata.Profile = profile.Name
+	result.Metadata.StartedAt = startedAt
+	result.Metadata.FinishedAt = time.Now()
+
+	return result, nil
+}
+
+// buildArgs translates a scan profile into nmap command-line arguments. Hosts
+// are read from stdin so expanded ranges never hit argument length limits.
+func (s *Scanner) buildArgs(profile *Profile) []string {
+	var args []string
+
+	// Scan technique
+	switch profile.ScanType {
+	case ScanTypeConnect:
+		args = append(args, "-sT") // TCP connect scan
+	case ScanTypeUDP:
+		args = append(args, "-sU") // UDP scan
+	default:
+		args = append(args, "-sS") // SYN scan
+	}
+
+	// Version detection
+	if profile.VersionIntensity != nil {
+		args = append(args, "-sV", "--version-intensity", strconv.Itoa(*profile.VersionIntensity))
+	}
+
+	// Port selection
+	if n, ok := profile.TopPorts(); ok {
+		args = append(args, "--top-ports", strconv.Itoa(n))
+	} else {
+		args = append(args, "-p", profile.PortSpec)
+	}
+
+	args = append(args,
+		fmt.Sprintf("-T%d", profile.TimingTemplate), // Timing template
 		"-oX", "-", // XML output to stdout
+		"--stats-every", statsInterval, // Progress reports in the XML output
 		"--host-timeout", fmt.Sprintf("%ds", int(s.timeout.Seconds())),
-		target,
+		"-iL", "-", // Read target hosts from stdin
+	)
+
+	return args
+}
+
+// run executes nmap against the target, reading its XML output as it is
+// written. The nmap process is killed when ctx is cancelled or the scanner
+// timeout expires.
+func (s *Scanner) run(ctx context.Context, target string, profile *Profile, observer ScanObserver) (*EngineResult, error) {
+	// Expand CIDR blocks and ranges into individual hosts
+	hosts, err := ExpandTarget(target)
+	if err != nil {
+		return nil, err
 	}
 
-	// Execute nmap command
-	cmd := exec.Command("nmap", args...)
+	args := s.buildArgs(profile)
 
 	// Set timeout for the command
-	done := make(chan error, 1)
-	var output []byte
-	var err error
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
-	go func() {
-		output, err = cmd.Output()
-		done <- err
-	}()
+	// Execute nmap command
+	cmd := exec.CommandContext(ctx, "nmap", args...)
+	cmd.Stdin = strings.NewReader(strings.Join(hosts, "\n"))
 
-	select {
-	case err := <-done:
-		if err != nil {
-			return nil, fmt.Errorf("nmap scan failed: %v", err)
-		}
-	case <-time.After(s.timeout):
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("nmap scan failed: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("nmap scan failed: %v", err)
+	}
+
+	result, parseErr := s.readOutput(stdout, len(hosts), observer)
+	if parseErr != nil {
+		// Stop nmap rather than leave it blocked writing output nobody reads
 		cmd.Process.Kill()
-		return nil, fmt.Errorf("nmap scan timed out after %v", s.timeout)
 	}
 
-	// Parse XML output
-	results, err := s.parseNmapXML(output)
+	err = cmd.Wait()
+	switch ctx.Err() {
+	case context.Canceled:
+		return nil, fmt.Errorf("nmap scan cancelled: %w", ctx.Err())
+	case context.DeadlineExceeded:
+		return nil, fmt.Errorf("nmap scan timed out after %v", s.timeout)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse nmap output: %v", parseErr)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse nmap output: %v", err)
+		return nil, fmt.Errorf("nmap scan failed: %v", err)
 	}
 
-	return results, nil
+	return result, nil
 }
 
-// NmapRun represents the root XML structure from nmap output
-type NmapRun struct {
-	XMLName xml.Name   `xml:"nmaprun"`
-	Hosts   []NmapHost `xml:"host"`
+// readOutput decodes nmap's XML output as it streams in, passing task progress
+// and host results to observer when there is one. Progress is that of nmap's
+// current task, scaled to the hosts of the scan that are still to finish.
+func (s *Scanner) readOutput(output io.Reader, hostCount int, observer ScanObserver) (*EngineResult, error) {
+	result := &EngineResult{}
+	finished := 0
+
+	decoder := xml.NewDecoder(output)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "nmaprun":
+			for _, attr := range start.Attr {
+				switch attr.Name.Local {
+				case "args":
+					result.Metadata.Args = attr.Value
+				case "version":
+					result.Metadata.Version = attr.Value
+				}
+			}
+
+		case "taskprogress":
+			var task NmapTaskProgress
+			if err := decoder.DecodeElement(&task, &start); err != nil {
+				return nil, err
+			}
+			if observer != nil {
+				observer.Progress(task.scanProgress(finished, hostCount))
+			}
+
+		case "host":
+			var host NmapHost
+			if err := decoder.DecodeElement(&host, &start); err != nil {
+				return nil, err
+			}
+			finished++
+
+			hostResults := s.hostResults(host)
+			if observer != nil {
+				if len(hostResults) > 0 {
+					observer.Results(hostResults)
+				}
+				continue
+			}
+			result.Results = append(result.Results, hostResults...)
+		}
+	}
+}
+
+// NmapTaskProgress is a progress report nmap prints for its current task
+// (host discovery, port scan, service detection...) when --stats-every is set
+type NmapTaskProgress struct {
+	XMLName xml.Name `xml:"taskprogress"`
+	Task    string   `xml:"task,attr"`
+	Percent float64  `xml:"percent,attr"`
+	// Etc is when nmap expects the task to finish, in seconds since the epoch
+	Etc int64 `xml:"etc,attr"`
+}
+
+// scanProgress converts the task's progress into the scan's, given how many of
+// its hosts have finished
+func (t NmapTaskProgress) scanProgress(finished, hostCount int) ScanProgress {
+	percent := t.Percent
+	if hostCount > 0 && finished < hostCount {
+		percent = (float64(finished) + t.Percent/100*float64(hostCount-finished)) / float64(hostCount) * 100
+	}
+
+	progress := ScanProgress{Percent: math.Min(percent, 100)}
+	if t.Etc > 0 {
+		etc := time.Unix(t.Etc, 0)
+		progress.EstimatedCompletion = &etc
+	}
+	return progress
 }
 
 // NmapHost represents a host in the nmap XML output
 type NmapHost struct {
-	XMLName xml.Name   `xml:"host"`
-	Ports   NmapPorts  `xml:"ports"`
-	Status  NmapStatus `xml:"status"`
+	XMLName   xml.Name      `xml:"host"`
+	Addresses []NmapAddress `xml:"address"`
+	Hostnames NmapHostnames `xml:"hostnames"`
+	Ports     NmapPorts     `xml:"ports"`
+	Status    NmapStatus    `xml:"status"`
+}
+
+// NmapAddress represents a host address
+type NmapAddress struct {
+	XMLName  xml.Name `xml:"address"`
+	Addr     string   `xml:"addr,attr"`
+	AddrType string   `xml:"addrtype,attr"`
+}
+
+// NmapHostnames represents the hostnames section
+type NmapHostnames struct {
+	XMLName   xml.Name       `xml:"hostnames"`
+	Hostnames []NmapHostname `xml:"hostname"`
+}
+
+// NmapHostname represents a single hostname of a host
+type NmapHostname struct {
+	XMLName xml.Name `xml:"hostname"`
+	Name    string   `xml:"name,attr"`
+	Type    string   `xml:"type,attr"`
+}
+
+// IP returns the host's IP address, ignoring MAC addresses
+func (h NmapHost) IP() string {
+	for _, address := range h.Addresses {
+		if address.AddrType == "ipv4" || address.AddrType == "ipv6" {
+			return address.Addr
+		}
+	}
+	return ""
+}
+
+// Hostname returns the host's first hostname, preferring the one the user supplied
+func (h NmapHost) Hostname() string {
+	for _, hostname := range h.Hostnames.Hostnames {
+		if hostname.Type == "user" {
+			return hostname.Name
+		}
+	}
+	if len(h.Hostnames.Hostnames) > 0 {
+		return h.Hostnames.Hostnames[0].Name
+	}
+	return ""
 }
 
 // NmapStatus represents host status
@@ -126,55 +337,67 @@ type NmapService struct {
 	Product string   `xml:"product,attr"`
 	Version string   `xml:"version,attr"`
 	Banner  string   `xml:"banner,attr"`
+	CPEs    []string `xml:"cpe"`
 }
 
-// parseNmapXML parses the XML output from nmap and returns structured results
-func (s *Scanner) parseNmapXML(xmlData []byte) ([]ScanResult, error) {
-	var nmapRun NmapRun
-
-	// Parse XML
-	err := xml.Unmarshal(xmlData, &nmapRun)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal XML: %v", err)
+// hostResults returns the open ports of a host, or nothing if it is not up
+func (s *Scanner) hostResults(host NmapHost) []ScanResult {
+	// Skip hosts that are not up
+	if host.Status.State != "up" {
+		return nil
 	}
 
 	var results []ScanResult
 
-	// Process each host
-	for _, host := range nmapRun.Hosts {
-		// Skip hosts that are not up
-		if host.Status.State != "up" {
-			continue
-		}
-
-		// Process each port
-		for _, port := range host.Ports.Ports {
-			// Only include open ports
-			if port.State.State == "open" {
-				version := port.Service.Version
-				if port.Service.Product != "" {
-					if version != "" {
-						version = fmt.Sprintf("%s %s", port.Service.Product, version)
-					} else {
-						version = port.Service.Product
-					}
-				}
-
-				result := ScanResult{
-					Port:     port.PortID,
-					Protocol: port.Protocol,
-					State:    port.State.State,
-					Service:  port.Service.Name,
-					Version:  version,
-					Banner:   port.Service.Banner,
+	// Process each port
+	for _, port := range host.Ports.Ports {
+		// Only include open ports, keeping UDP ports that may be open
+		if port.State.State == PortStateOpen || port.State.State == PortStateOpenFiltered {
+			version := port.Service.Version
+			if port.Service.Product != "" {
+				if version != "" {
+					version = fmt.Sprintf("%s %s", port.Service.Product, version)
+				} else {
+					version = port.Service.Product
 				}
+			}
 
-				results = append(results, result)
+			result := ScanResult{
+				Host:     host.IP(),
+				Hostname: host.Hostname(),
+				Port:     port.PortID,
+				Protocol: port.Protocol,
+				State:    port.State.State,
+				Service:  port.Service.Name,
+				Version:  version,
+				Banner:   port.Service.Banner,
+				CPEs:     completeCPEs(port.Service.CPEs, port.Service.Version),
 			}
+
+			results = append(results, result)
 		}
 	}
 
-	return results, nil
+	return results
+}
+
+// completeCPEs adds the detected version to application CPEs that nmap emitted
+// without one, so they can be matched against vulnerable version ranges
+func completeCPEs(cpes []string, version string) []string {
+	// nmap versions may carry distribution details ("8.2p1 Ubuntu 4ubuntu0.5")
+	if fields := strings.Fields(version); len(fields) > 0 {
+		version = fields[0]
+	}
+
+	completed := make([]string, 0, len(cpes))
+	for _, cpe := range cpes {
+		cpe = strings.TrimSpace(cpe)
+		if strings.HasPrefix(cpe, "cpe:/a:") && strings.Count(cpe, ":") == 3 && version != "" {
+			cpe += ":" + strings.ToLower(version)
+		}
+		completed = append(completed, cpe)
+	}
+	return completed
 }
 
 // ValidateTarget performs basic validation on the target string
@@ -188,5 +411,10 @@ func ValidateTarget(target string) error {
 		return fmt.Errorf("target contains invalid characters")
 	}
 
+	// Make sure CIDR blocks and ranges are well-formed and not too large
+	if _, err := ExpandTarget(target); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nmapOutput is the XML nmap streams for a scan of two hosts with
+// --stats-every, where the first host is up and the second down
+const nmapOutput = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -sS -sU -sV -oX - 10.0.0.1-2" start="1700000000" version="7.94" xmloutputversion="1.05">
+<taskprogress task="SYN Stealth Scan" time="1700000005" percent="50.00" remaining="5" etc="1700000010"/>
+<host starttime="1700000000" endtime="1700000020">
+<status state="up" reason="arp-response" reason_ttl="0"/>
+<address addr="10.0.0.1" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+<hostnames>
+<hostname name="gw.lan" type="PTR"/>
+<hostname name="gw.example.com" type="user"/>
+</hostnames>
+<ports>
+<extraports state="closed" count="996"/>
+<port protocol="tcp" portid="22"><state state="open" reason="syn-ack" reason_ttl="64"/><service name="ssh" product="OpenSSH" version="8.2p1 Ubuntu 4ubuntu0.5" method="probed" conf="10"><cpe>cpe:/a:openbsd:openssh</cpe><cpe>cpe:/o:linux:linux_kernel</cpe></service></port>
+<port protocol="tcp" portid="25"><state state="closed" reason="reset" reason_ttl="64"/><service name="smtp" method="table" conf="3"/></port>
+<port protocol="udp" portid="53"><state state="filtered" reason="no-response" reason_ttl="0"/><service name="domain" method="table" conf="3"/></port>
+<port protocol="udp" portid="161"><state state="open|filtered" reason="no-response" reason_ttl="0"/><service name="snmp" method="table" conf="3"/></port>
+</ports>
+</host>
+<taskprogress task="Service scan" time="1700000025" percent="50.00" remaining="5" etc="1700000030"/>
+<host starttime="1700000000" endtime="1700000030">
+<status state="down" reason="no-response" reason_ttl="0"/>
+<address addr="10.0.0.2" addrtype="ipv4"/>
+<ports><port protocol="tcp" portid="80"><state state="open" reason="syn-ack" reason_ttl="64"/><service name="http"/></port></ports>
+</host>
+<runstats><finished time="1700000030" elapsed="30.00" exit="success"/><hosts up="1" down="1" total="2"/></runstats>
+</nmaprun>
+`
+
+// recordingObserver keeps what a scan reports
+type recordingObserver struct {
+	progress []ScanProgress
+	results  [][]ScanResult
+}
+
+func (o *recordingObserver) Progress(progress ScanProgress) {
+	o.progress = append(o.progress, progress)
+}
+
+func (o *recordingObserver) Results(results []ScanResult) {
+	o.results = append(o.results, results)
+}
+
+// nmapResults are the results of the up host in nmapOutput
+var nmapResults = []ScanResult{
+	{
+		Host:     "10.0.0.1",
+		Hostname: "gw.example.com",
+		Port:     22,
+		Protocol: "tcp",
+		State:    PortStateOpen,
+		Service:  "ssh",
+		Version:  "OpenSSH 8.2p1 Ubuntu 4ubuntu0.5",
+		CPEs:     []string{"cpe:/a:openbsd:openssh:8.2p1", "cpe:/o:linux:linux_kernel"},
+	},
+	{
+		Host:     "10.0.0.1",
+		Hostname: "gw.example.com",
+		Port:     161,
+		Protocol: "udp",
+		State:    PortStateOpenFiltered,
+		Service:  "snmp",
+		CPEs:     []string{},
+	},
+}
+
+func TestReadOutputStreamsToObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	result, err := (&Scanner{}).readOutput(strings.NewReader(nmapOutput), 2, observer)
+	if err != nil {
+		t.Fatalf("readOutput: %v", err)
+	}
+
+	if result.Metadata.Version != "7.94" || result.Metadata.Args != "nmap -sS -sU -sV -oX - 10.0.0.1-2" {
+		t.Errorf("metadata = %+v, want nmap's version and args", result.Metadata)
+	}
+	if len(result.Results) != 0 {
+		t.Errorf("returned %d results already passed to the observer", len(result.Results))
+	}
+
+	// Each task's progress covers the hosts still to finish
+	wantProgress := []struct {
+		percent float64
+		etc     int64
+	}{{50, 1700000010}, {75, 1700000030}}
+	if len(observer.progress) != len(wantProgress) {
+		t.Fatalf("observer received %d progress reports, want %d", len(observer.progress), len(wantProgress))
+	}
+	for i, want := range wantProgress {
+		got := observer.progress[i]
+		if got.Percent != want.percent || got.EstimatedCompletion == nil || !got.EstimatedCompletion.Equal(time.Unix(want.etc, 0)) {
+			t.Errorf("progress %d = %v%% by %v, want %v%% by %v", i, got.Percent, got.EstimatedCompletion, want.percent, time.Unix(want.etc, 0))
+		}
+	}
+
+	// The down host reports nothing
+	if len(observer.results) != 1 {
+		t.Fatalf("observer received %d batches of results, want 1", len(observer.results))
+	}
+	if !reflect.DeepEqual(observer.results[0], nmapResults) {
+		t.Fatalf("results =\n%+v\nwant\n%+v", observer.results[0], nmapResults)
+	}
+}
+
+func TestReadOutputWithoutObserver(t *testing.T) {
+	result, err := (&Scanner{}).readOutput(strings.NewReader(nmapOutput), 2, nil)
+	if err != nil {
+		t.Fatalf("readOutput: %v", err)
+	}
+	if !reflect.DeepEqual(result.Results, nmapResults) {
+		t.Fatalf("results =\n%+v\nwant\n%+v", result.Results, nmapResults)
+	}
+}
+
+func TestReadOutputRejectsTruncatedXML(t *testing.T) {
+	truncated := nmapOutput[:strings.Index(nmapOutput, "</ports>")]
+	if _, err := (&Scanner{}).readOutput(strings.NewReader(truncated), 2, nil); err == nil {
+		t.Fatal("readOutput accepted output cut off inside a host")
+	}
+}
@@ -0,0 +1,250 @@
+package scanner
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scan types a profile can request
+const (
+	ScanTypeSYN     = "syn"
+	ScanTypeConnect = "connect"
+	ScanTypeUDP     = "udp"
+)
+
+// topPortsPrefix marks a port spec that selects the N most common ports, e.g. "top:100"
+const topPortsPrefix = "top:"
+
+// topTCPPorts lists the most common TCP ports in nmap-services frequency order
+var topTCPPorts = []int{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001, 10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+	26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646, 5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+	2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543, 544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051, 6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+}
+
+// Profile is a named set of scan options stored in the scan_profiles table.
+// UserID is the user who created it.
+type Profile struct {
+	ID               int       `json:"id"`
+	UserID           *int      `json:"userId,omitempty"`
+	OrganizationID   *int      `json:"organizationId,omitempty"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	PortSpec         string    `json:"portSpec"`
+	TimingTemplate   int       `json:"timingTemplate"`
+	VersionIntensity *int      `json:"versionIntensity,omitempty"`
+	ScanType         string    `json:"scanType"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// DefaultProfile returns the options used when neither the scan nor the asset selects a profile
+func DefaultProfile() *Profile {
+	intensity := 7
+	return &Profile{
+		Name:             "default",
+		PortSpec:         "1-1000",
+		TimingTemplate:   3,
+		VersionIntensity: &intensity,
+		ScanType:         ScanTypeSYN,
+	}
+}
+
+// BuiltIn reports whether the profile is shipped with the application rather
+// than defined by an organization
+func (p *Profile) BuiltIn() bool {
+	return p.OrganizationID == nil
+}
+
+// TopPorts returns N when the port spec is of the form "top:N"
+func (p *Profile) TopPorts() (int, bool) {
+	if !strings.HasPrefix(p.PortSpec, topPortsPrefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(p.PortSpec, topPortsPrefix))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Ports expands the port spec into an explicit port list. "top:N" specs are
+// limited to the built-in list of common TCP ports.
+func (p *Profile) Ports() ([]int, error) {
+	if n, ok := p.TopPorts(); ok {
+		if n > len(topTCPPorts) {
+			n = len(topTCPPorts)
+		}
+		return topTCPPorts[:n], nil
+	}
+	return ParsePorts(p.PortSpec)
+}
+
+// Validate checks that the profile's options are well-formed
+func (p *Profile) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+
+	if strings.HasPrefix(p.PortSpec, topPortsPrefix) {
+		n, ok := p.TopPorts()
+		if !ok || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid top ports spec %q", p.PortSpec)
+		}
+	} else if _, err := ParsePorts(p.PortSpec); err != nil {
+		return fmt.Errorf("invalid port spec: %v", err)
+	}
+
+	if p.TimingTemplate < 0 || p.TimingTemplate > 5 {
+		return fmt.Errorf("timing template must be between 0 and 5")
+	}
+
+	if p.VersionIntensity != nil && (*p.VersionIntensity < 0 || *p.VersionIntensity > 9) {
+		return fmt.Errorf("version intensity must be between 0 and 9")
+	}
+
+	switch p.ScanType {
+	case ScanTypeSYN, ScanTypeConnect, ScanTypeUDP:
+	default:
+		return fmt.Errorf("unsupported scan type: %s", p.ScanType)
+	}
+
+	return nil
+}
+
+const profileColumns = `id, user_id, organization_id, name, description, port_spec, timing_template, version_intensity, scan_type, created_at, updated_at`
+
+// scanProfile scans a scan_profiles row selected with profileColumns
+func scanProfile(row interface{ Scan(...any) error }) (*Profile, error) {
+	var profile Profile
+	var description sql.NullString
+
+	err := row.Scan(
+		&profile.ID,
+		&profile.UserID,
+		&profile.OrganizationID,
+		&profile.Name,
+		&description,
+		&profile.PortSpec,
+		&profile.TimingTemplate,
+		&profile.VersionIntensity,
+		&profile.ScanType,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	profile.Description = description.String
+
+	return &profile, nil
+}
+
+// GetProfile retrieves a scan profile by ID
+func (sm *ScanManager) GetProfile(profileID int) (*Profile, error) {
+	query := `SELECT ` + profileColumns + ` FROM scan_profiles WHERE id = $1`
+
+	profile, err := scanProfile(sm.db.QueryRow(query, profileID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("scan profile not found")
+		}
+		return nil, fmt.Errorf("failed to get scan profile: %v", err)
+	}
+
+	return profile, nil
+}
+
+// ListProfiles retrieves the built-in profiles and those of the organization
+func (sm *ScanManager) ListProfiles(organizationID int) ([]*Profile, error) {
+	query := `
+		SELECT ` + profileColumns + `
+		FROM scan_profiles
+		WHERE organization_id IS NULL OR organization_id = $1
+		ORDER BY organization_id NULLS FIRST, name ASC
+	`
+
+	rows, err := sm.db.Query(query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan profiles: %v", err)
+	}
+	defer rows.Close()
+
+	var profiles []*Profile
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, nil
+}
+
+// CreateProfile validates and stores a new profile of the organization, created by the user
+func (sm *ScanManager) CreateProfile(organizationID, userID int, profile *Profile) (*Profile, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO scan_profiles (user_id, organization_id, name, description, port_spec, timing_template, version_intensity, scan_type, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + profileColumns
+
+	now := time.Now()
+	created, err := scanProfile(sm.db.QueryRow(query, userID, organizationID, profile.Name, profile.Description, profile.PortSpec,
+		profile.TimingTemplate, profile.VersionIntensity, profile.ScanType, now, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan profile: %v", err)
+	}
+
+	return created, nil
+}
+
+// UpdateProfile validates and saves changes to one of the organization's profiles
+func (sm *ScanManager) UpdateProfile(organizationID int, profile *Profile) (*Profile, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE scan_profiles
+		SET name = $1, description = $2, port_spec = $3, timing_template = $4, version_intensity = $5, scan_type = $6, updated_at = $7
+		WHERE id = $8 AND organization_id = $9
+		RETURNING ` + profileColumns
+
+	updated, err := scanProfile(sm.db.QueryRow(query, profile.Name, profile.Description, profile.PortSpec,
+		profile.TimingTemplate, profile.VersionIntensity, profile.ScanType, time.Now(), profile.ID, organizationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("scan profile not found")
+		}
+		return nil, fmt.Errorf("failed to update scan profile: %v", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteProfile removes one of the organization's profiles. Built-in profiles cannot be deleted.
+func (sm *ScanManager) DeleteProfile(organizationID, profileID int) (bool, error) {
+	query := `DELETE FROM scan_profiles WHERE id = $1 AND organization_id = $2`
+
+	result, err := sm.db.Exec(query, profileID, organizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete scan profile: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
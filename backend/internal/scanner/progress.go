@@ -0,0 +1,37 @@
+package scanner
+
+import "log"
+
+// scanObserver stores the progress and results a StreamingEngine reports while
+// a scan runs, publishing each change
+type scanObserver struct {
+	sm     *ScanManager
+	scanID int
+	// stored counts the results already saved
+	stored int
+	// err is the first error saving results, after which the rest are dropped
+	err error
+}
+
+// Progress implements ScanObserver
+func (o *scanObserver) Progress(progress ScanProgress) {
+	if err := o.sm.scans.SetProgress(o.scanID, progress.Percent, progress.EstimatedCompletion); err != nil {
+		log.Printf("Failed to update progress of scan %d: %v", o.scanID, err)
+		return
+	}
+	o.sm.publishProgress(o.scanID)
+}
+
+// Results implements ScanObserver
+func (o *scanObserver) Results(results []ScanResult) {
+	if o.err != nil {
+		return
+	}
+
+	if err := o.sm.results.Insert(o.scanID, results); err != nil {
+		o.err = err
+		return
+	}
+	o.stored += len(results)
+	o.sm.publishProgress(o.scanID)
+}
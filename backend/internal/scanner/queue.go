@@ -0,0 +1,260 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// QueueConfig controls how queued scans are executed
+type QueueConfig struct {
+	// Workers is the number of scans executed concurrently by this server
+	Workers int
+	// PerUserLimit caps how many of the scans a single user requested may be
+	// running at once
+	PerUserLimit int
+	// PollInterval is how often idle workers look for pending scans
+	PollInterval time.Duration
+	// MaxAttempts is how many times a scan is retried after the server dies mid-scan
+	MaxAttempts int
+	// Owner identifies this server on the scans it claims; it defaults to the
+	// host name and process ID
+	Owner string
+	// LeaseDuration is how long a claimed scan stays leased to this server
+	// without being renewed before other servers may recover it
+	LeaseDuration time.Duration
+}
+
+// scanJob is a pending scan claimed by a worker
+type scanJob struct {
+	scanID    int
+	assetID   int
+	target    string
+	engine    string
+	profileID *int
+}
+
+// Start recovers scans orphaned by stopped servers and launches the worker
+// pool, along with a heartbeat that renews this server's scan leases. Both
+// stop when ctx is cancelled.
+func (sm *ScanManager) Start(ctx context.Context, config QueueConfig) error {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.PerUserLimit <= 0 {
+		config.PerUserLimit = 2
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 2 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.Owner == "" {
+		config.Owner = defaultQueueOwner()
+	}
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = time.Minute
+	}
+	sm.queue = config
+
+	if err := sm.RecoverOrphanedScans(); err != nil {
+		return err
+	}
+
+	sm.workers.Add(config.Workers + 1)
+	for i := 0; i < config.Workers; i++ {
+		go func() {
+			defer sm.workers.Done()
+			sm.worker(ctx, i+1)
+		}()
+	}
+	go func() {
+		defer sm.workers.Done()
+		sm.heartbeat(ctx)
+	}()
+
+	log.Printf("Scan queue %s started with %d workers (per-user limit %d)", config.Owner, config.Workers, config.PerUserLimit)
+	return nil
+}
+
+// Wait blocks until the workers and heartbeat launched by Start have stopped
+// after its context was cancelled, leaving interrupted scans requeued
+func (sm *ScanManager) Wait() {
+	sm.workers.Wait()
+}
+
+// defaultQueueOwner identifies this process among the servers sharing the queue
+func defaultQueueOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// heartbeat renews the leases of this server's running scans and recovers
+// scans whose server stopped renewing theirs, until ctx is cancelled
+func (sm *ScanManager) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(sm.queue.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sm.renewLeases()
+		if err := sm.RecoverOrphanedScans(); err != nil {
+			log.Printf("Failed to recover orphaned scans: %v", err)
+		}
+	}
+}
+
+// renewLeases extends the leases of this server's running scans and stops any
+// it no longer holds because another server recovered them
+func (sm *ScanManager) renewLeases() {
+	held, err := sm.scans.RenewLeases(sm.queue.Owner, sm.queue.LeaseDuration)
+	if err != nil {
+		log.Printf("Failed to renew scan leases: %v", err)
+		return
+	}
+
+	holding := make(map[int]bool, len(held))
+	for _, scanID := range held {
+		holding[scanID] = true
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for scanID, cancel := range sm.running {
+		if !holding[scanID] {
+			cancel(errLeaseLost)
+		}
+	}
+}
+
+// RecoverOrphanedScans re-queues scans left running by a server that stopped
+// mid-scan, failing those that have already used up their attempts. Only scans
+// whose lease has expired are recovered, so scans other live servers are
+// running are left alone.
+func (sm *ScanManager) RecoverOrphanedScans() error {
+	requeued, failed, err := sm.scans.RecoverOrphaned(sm.queue.MaxAttempts, ScanError{
+		Code:    ErrorCodeInterrupted,
+		Message: "Scan interrupted by server restart",
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 || requeued > 0 {
+		log.Printf("Recovered orphaned scans: %d requeued, %d failed", requeued, failed)
+	}
+
+	return nil
+}
+
+// notify wakes an idle worker so a newly queued scan starts without waiting for the next poll
+func (sm *ScanManager) notify() {
+	select {
+	case sm.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker repeatedly claims and runs pending scans until ctx is cancelled
+func (sm *ScanManager) worker(ctx context.Context, id int) {
+	ticker := time.NewTicker(sm.queue.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-sm.wake:
+		}
+
+		// Drain the queue before going back to sleep
+		for ctx.Err() == nil {
+			job, err := sm.claimNext()
+			if err != nil {
+				log.Printf("Scan worker %d failed to claim a scan: %v", id, err)
+				break
+			}
+			if job == nil {
+				break
+			}
+
+			// Let another idle worker check for more work while this one is busy
+			sm.notify()
+			sm.runJob(ctx, job)
+		}
+	}
+}
+
+// claimNext claims the oldest pending scan whose requester is below the per-user
+// limit and loads the target it scans
+func (sm *ScanManager) claimNext() (*scanJob, error) {
+	scan, err := sm.scans.ClaimNext(sm.queue.Owner, sm.queue.PerUserLimit, sm.queue.LeaseDuration)
+	if err != nil || scan == nil {
+		return nil, err
+	}
+
+	job := &scanJob{
+		scanID:    scan.ID,
+		assetID:   scan.AssetID,
+		engine:    scan.Engine,
+		profileID: scan.ProfileID,
+	}
+
+	asset, err := sm.assets.Get(scan.AssetID)
+	if err != nil {
+		// The scan is already running, so record the failure instead of leaving it claimed
+		scanErr := &ScanError{Code: ErrorCodeEngineUnavailable, Message: err.Error()}
+		if _, updateErr := sm.scans.Finish(scan.ID, ScanStatusFailed, scanErr); updateErr != nil {
+			log.Printf("Failed to update scan status to failed: %v", updateErr)
+		}
+		sm.publish(scan.ID)
+		return nil, err
+	}
+	job.target = asset.Target
+
+	return job, nil
+}
+
+// runJob resolves a claimed scan's engine and profile and executes it
+func (sm *ScanManager) runJob(ctx context.Context, job *scanJob) {
+	scanCtx, cancel := context.WithCancelCause(ctx)
+	sm.track(job.scanID, cancel)
+
+	fail := func(err error) {
+		sm.untrack(job.scanID)
+		log.Printf("Scan %d failed: %v", job.scanID, err)
+		scanErr := &ScanError{Code: ErrorCodeEngineUnavailable, Message: err.Error()}
+		if _, updateErr := sm.scans.Finish(job.scanID, ScanStatusFailed, scanErr); updateErr != nil {
+			log.Printf("Failed to update scan status to failed: %v", updateErr)
+		}
+		sm.publish(job.scanID)
+	}
+
+	engine, err := sm.engines.Get(job.engine)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	profile := DefaultProfile()
+	if job.profileID != nil {
+		if profile, err = sm.GetProfile(*job.profileID); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	sm.processScan(scanCtx, job.scanID, job.assetID, engine, job.target, profile)
+}
@@ -0,0 +1,119 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// MaxTargetHosts caps how many hosts a single range target may expand to
+const MaxTargetHosts = 65536
+
+// ExpandTarget expands a target into the individual hosts to scan. It accepts
+// a single IP or hostname, a CIDR block (10.0.0.0/24), a full IPv4 range
+// (10.0.0.1-10.0.0.20) or an nmap-style last-octet range (10.0.0.1-20).
+func ExpandTarget(target string) ([]string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("target cannot be empty")
+	}
+
+	if strings.Contains(target, "/") {
+		return expandCIDR(target)
+	}
+
+	if i := strings.Index(target, "-"); i >= 0 {
+		// Hostnames may contain dashes, so only treat this as a range when it starts with an IP
+		if start := net.ParseIP(target[:i]).To4(); start != nil {
+			return expandRange(start, target[i+1:])
+		}
+	}
+
+	return []string{target}, nil
+}
+
+// expandCIDR lists the usable host addresses of a CIDR block
+func expandCIDR(target string) ([]string, error) {
+	ip, network, err := net.ParseCIDR(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR target: %v", err)
+	}
+
+	ones, bits := network.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("CIDR target %s is larger than %d hosts", target, MaxTargetHosts)
+	}
+
+	if ip.To4() == nil {
+		// IPv6 blocks are walked as 128-bit values; the prefix limit above keeps them small
+		var hosts []string
+		current := make(net.IP, len(network.IP))
+		copy(current, network.IP)
+		for network.Contains(current) {
+			hosts = append(hosts, current.String())
+			current = nextIP(current)
+		}
+		return hosts, nil
+	}
+
+	first := binary.BigEndian.Uint32(network.IP.To4())
+	last := first | ^binary.BigEndian.Uint32(network.Mask)
+
+	// Skip the network and broadcast addresses for blocks that have them
+	if bits-ones >= 2 {
+		first++
+		last--
+	}
+
+	return ipv4Range(first, last), nil
+}
+
+// expandRange lists the addresses from start to the end of an IPv4 range
+func expandRange(start net.IP, end string) ([]string, error) {
+	var last net.IP
+	if octet, err := strconv.Atoi(end); err == nil {
+		if octet < 0 || octet > 255 {
+			return nil, fmt.Errorf("invalid range end %q", end)
+		}
+		last = net.IPv4(start[0], start[1], start[2], byte(octet)).To4()
+	} else if last = net.ParseIP(end).To4(); last == nil {
+		return nil, fmt.Errorf("invalid range end %q", end)
+	}
+
+	first := binary.BigEndian.Uint32(start)
+	final := binary.BigEndian.Uint32(last)
+	if final < first {
+		return nil, fmt.Errorf("range end %s is before range start %s", last, start)
+	}
+	if final-first >= MaxTargetHosts {
+		return nil, fmt.Errorf("range is larger than %d hosts", MaxTargetHosts)
+	}
+
+	return ipv4Range(first, final), nil
+}
+
+// ipv4Range lists every IPv4 address between first and last inclusive
+func ipv4Range(first, last uint32) []string {
+	hosts := make([]string, 0, last-first+1)
+	for n := uint64(first); n <= uint64(last); n++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(n))
+		hosts = append(hosts, ip.String())
+	}
+	return hosts
+}
+
+// nextIP returns the address following ip
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"10.0.0.1", []string{"10.0.0.1"}},
+		{"  db-01.example.com ", []string{"db-01.example.com"}},
+		{"10.0.0.7/32", []string{"10.0.0.7"}},
+		{"10.0.0.6/31", []string{"10.0.0.6", "10.0.0.7"}},
+		// The network and broadcast addresses are left out
+		{"10.0.0.0/30", []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.5/29", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}},
+		{"10.0.0.254-10.0.1.1", []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{"10.0.0.1-3", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{"10.0.0.4-4", []string{"10.0.0.4"}},
+		{"2001:db8::/127", []string{"2001:db8::", "2001:db8::1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			got, err := ExpandTarget(test.target)
+			if err != nil {
+				t.Fatalf("ExpandTarget(%q): %v", test.target, err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("ExpandTarget(%q) = %v, want %v", test.target, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpandTargetSizes(t *testing.T) {
+	hosts, err := ExpandTarget("10.1.0.0/16")
+	if err != nil {
+		t.Fatalf("ExpandTarget: %v", err)
+	}
+	if len(hosts) != MaxTargetHosts-2 || hosts[0] != "10.1.0.1" || hosts[len(hosts)-1] != "10.1.255.254" {
+		t.Fatalf("/16 expanded to %d hosts from %s to %s", len(hosts), hosts[0], hosts[len(hosts)-1])
+	}
+
+	hosts, err = ExpandTarget("10.1.0.0-10.1.255.255")
+	if err != nil {
+		t.Fatalf("ExpandTarget: %v", err)
+	}
+	if len(hosts) != MaxTargetHosts {
+		t.Fatalf("range expanded to %d hosts, want %d", len(hosts), MaxTargetHosts)
+	}
+}
+
+func TestExpandTargetRejectsInvalidTargets(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"10.0.0.0/33",
+		"10.0.0.0/x",
+		// Larger than MaxTargetHosts
+		"10.0.0.0/15",
+		"0.0.0.0/0",
+		"2001:db8::/64",
+		"10.0.0.0-10.1.0.0",
+		// Reversed ranges
+		"10.0.0.9-10.0.0.1",
+		"10.0.0.9-1",
+		"10.0.0.1-256",
+		"10.0.0.1-host",
+	}
+
+	for _, target := range tests {
+		t.Run(target, func(t *testing.T) {
+			if hosts, err := ExpandTarget(target); err == nil {
+				t.Fatalf("ExpandTarget(%q) = %d hosts, want an error", target, len(hosts))
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TCPEngine is the name the native TCP connect scanner is registered under
+const TCPEngine = "tcp"
+
+// TCPConfig configures the native TCP connect scanner
+type TCPConfig struct {
+	Ports         []int
+	Concurrency   int
+	Timeout       time.Duration
+	BannerTimeout time.Duration
+}
+
+// TCPScanner performs TCP connect scans without nmap or raw socket privileges
+type TCPScanner struct {
+	config TCPConfig
+}
+
+// tcpProbe is a single host and port pair to connect to
+type tcpProbe struct {
+	host     string
+	hostname string
+	port     int
+}
+
+// Ensure TCPScanner implements Engine
+var _ Engine = (*TCPScanner)(nil)
+
+// wellKnownServices maps common ports to the service name nmap would report
+var wellKnownServices = map[int]string{
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	25:    "smtp",
+	53:    "domain",
+	80:    "http",
+	110:   "pop3",
+	111:   "rpcbind",
+	135:   "msrpc",
+	139:   "netbios-ssn",
+	143:   "imap",
+	443:   "https",
+	445:   "microsoft-ds",
+	465:   "smtps",
+	587:   "submission",
+	993:   "imaps",
+	995:   "pop3s",
+	1433:  "ms-sql-s",
+	1521:  "oracle",
+	2049:  "nfs",
+	3306:  "mysql",
+	3389:  "ms-wbt-server",
+	5432:  "postgresql",
+	5900:  "vnc",
+	6379:  "redis",
+	8080:  "http-proxy",
+	8443:  "https-alt",
+	9200:  "elasticsearch",
+	11211: "memcache",
+	27017: "mongodb",
+}
+
+// NewTCPScanner creates a new TCPScanner, filling unset options with defaults
+func NewTCPScanner(config TCPConfig) *TCPScanner {
+	if len(config.Ports) == 0 {
+		config.Ports, _ = ParsePorts("1-1000")
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 100
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Second
+	}
+	if config.BannerTimeout <= 0 {
+		config.BannerTimeout = 2 * time.Second
+	}
+
+	return &TCPScanner{
+		config: config,
+	}
+}
+
+// Name returns the engine name the TCP scanner is registered under
+func (s *TCPScanner) Name() string {
+	return TCPEngine
+}
+
+// Scan implements Engine by attempting a full TCP handshake on every port
+// selected by the profile, or on the configured ports when no profile is given.
+// Timing and version options are nmap-specific and ignored.
+func (s *TCPScanner) Scan(ctx context.Context, target string, profile *Profile) (*EngineResult, error) {
+	if err := ValidateTarget(target); err != nil {
+		return nil, err
+	}
+
+	portList := s.config.Ports
+	profileName := ""
+	if profile != nil {
+		if profile.ScanType == ScanTypeUDP {
+			return nil, fmt.Errorf("the %s engine does not support UDP scans", s.Name())
+		}
+
+		var err error
+		if portList, err = profile.Ports(); err != nil {
+			return nil, fmt.Errorf("invalid profile ports: %v", err)
+		}
+		profileName = profile.Name
+	}
+
+	hosts, err := ExpandTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt := time.Now()
+
+	probes := make(chan tcpProbe)
+	found := make(chan ScanResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for probe := range probes {
+				if result, ok := s.probe(ctx, probe); ok {
+					found <- result
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			close(probes)
+			wg.Wait()
+			close(found)
+		}()
+		for _, host := range hosts {
+			address, hostname := resolveHost(ctx, host)
+			for _, port := range portList {
+				select {
+				case probes <- tcpProbe{host: address, hostname: hostname, port: port}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var results []ScanResult
+	for result := range found {
+		results = append(results, result)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("tcp scan cancelled: %w", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return results[i].Host < results[j].Host
+		}
+		return results[i].Port < results[j].Port
+	})
+
+	return &EngineResult{
+		Results: results,
+		Metadata: ScanMetadata{
+			Engine:  s.Name(),
+			Profile: profileName,
+			Args: fmt.Sprintf("hosts=%d ports=%d concurrency=%d timeout=%v banner-timeout=%v",
+				len(hosts), len(portList), s.config.Concurrency, s.config.Timeout, s.config.BannerTimeout),
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+		},
+	}, nil
+}
+
+// resolveHost returns the IP address to connect to for a host and, when the
+// host was given by name, that name
+func resolveHost(ctx context.Context, host string) (string, string) {
+	if net.ParseIP(host) != nil {
+		return host, ""
+	}
+
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		// Let the dial fail on its own so the host simply reports no open ports
+		return host, host
+	}
+	return addresses[0], host
+}
+
+// probe connects to a single port and reads any banner the service sends unprompted
+func (s *TCPScanner) probe(ctx context.Context, probe tcpProbe) (ScanResult, bool) {
+	address := net.JoinHostPort(probe.host, strconv.Itoa(probe.port))
+
+	dialer := net.Dialer{Timeout: s.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return ScanResult{}, false
+	}
+	defer conn.Close()
+
+	result := ScanResult{
+		Host:     probe.host,
+		Hostname: probe.hostname,
+		Port:     probe.port,
+		Protocol: "tcp",
+		State:    PortStateOpen,
+		Service:  wellKnownServices[probe.port],
+	}
+
+	conn.SetReadDeadline(time.Now().Add(s.config.BannerTimeout))
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf)
+	if n > 0 {
+		result.Banner = sanitizeBanner(buf[:n])
+
+		// SSH identification strings carry the server software, e.g. SSH-2.0-OpenSSH_8.9p1
+		if parts := strings.SplitN(result.Banner, "-", 3); len(parts) == 3 && parts[0] == "SSH" {
+			result.Service = "ssh"
+			result.Version = strings.SplitN(parts[2], " ", 2)[0]
+			if version, ok := strings.CutPrefix(result.Version, "OpenSSH_"); ok {
+				result.CPEs = []string{"cpe:/a:openbsd:openssh:" + strings.ToLower(version)}
+			}
+		}
+	}
+
+	return result, true
+}
+
+// sanitizeBanner keeps the first line of a banner and strips non-printable bytes
+func sanitizeBanner(raw []byte) string {
+	line := string(raw)
+	if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+		line = line[:i]
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+// ParsePorts parses a port specification such as "22,80,443,8000-8100" into a sorted list of unique ports
+func ParsePorts(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			start, end = part[:i], part[i+1:]
+		}
+
+		low, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", start)
+		}
+		high, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", end)
+		}
+		if low < 1 || high > 65535 || low > high {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+
+		for port := low; port <= high; port++ {
+			seen[port] = true
+		}
+	}
+
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("port specification is empty")
+	}
+
+	ports := make([]int, 0, len(seen))
+	for port := range seen {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	return ports, nil
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+	}{
+		{"22", []int{22}},
+		{"22,80-82", []int{22, 80, 81, 82}},
+		{" 443 , 22 ", []int{22, 443}},
+		{"80-82,81,22,", []int{22, 80, 81, 82}},
+		{"1,65535", []int{1, 65535}},
+		{"8080-8080", []int{8080}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			got, err := ParsePorts(test.spec)
+			if err != nil {
+				t.Fatalf("ParsePorts(%q): %v", test.spec, err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("ParsePorts(%q) = %v, want %v", test.spec, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParsePortsRejectsInvalidSpecs(t *testing.T) {
+	tests := []string{
+		"",
+		" , ",
+		"0",
+		"65536",
+		"-1",
+		"22,70000",
+		"65530-65536",
+		"0-10",
+		"82-80",
+		"ssh",
+		"22-",
+		"22-x",
+	}
+
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if ports, err := ParsePorts(spec); err == nil {
+				t.Fatalf("ParsePorts(%q) = %v, want an error", spec, ports)
+			}
+		})
+	}
+}
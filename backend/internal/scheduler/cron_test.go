@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpecNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", at(1, 1, 0, 0), at(1, 1, 0, 1)},
+		{"5,10 * * * *", at(1, 1, 0, 5), at(1, 1, 0, 10)},
+		{"*/15 * * * *", at(1, 1, 0, 7), at(1, 1, 0, 15)},
+		{"30 2 * * *", at(1, 1, 3, 0), at(1, 2, 2, 30)},
+		{"0 9-17/4 * * *", at(1, 1, 10, 0), at(1, 1, 13, 0)},
+		{"0 20/2 * * *", at(1, 1, 21, 0), at(1, 1, 22, 0)},
+		{"0 0 1-3 * *", at(1, 3, 12, 0), at(2, 1, 0, 0)},
+		{"0 0 * 3 *", at(1, 1, 0, 0), at(3, 1, 0, 0)},
+		{"0 0 15 * *", at(1, 1, 0, 0), at(1, 15, 0, 0)},
+		{"0 0 * * 5", at(1, 1, 0, 0), at(1, 5, 0, 0)},
+		{"0 0 * * 1-5", at(1, 5, 12, 0), at(1, 8, 0, 0)},
+		{"0 0 * * 0", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"0 0 * * 7", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		// Both day fields restricted: either may match
+		{"0 0 15 * 5", at(1, 1, 0, 0), at(1, 5, 0, 0)},
+		{"0 0 15 * 5", at(1, 13, 0, 0), at(1, 15, 0, 0)},
+		// Only day of month restricted: the star day of week doesn't widen it
+		{"0 0 13 * *", at(1, 1, 0, 0), at(1, 13, 0, 0)},
+		{"0 0 29 2 *", at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", at(1, 1, 0, 30), at(1, 1, 1, 0)},
+		{"@daily", at(1, 1, 0, 30), at(1, 2, 0, 0)},
+		{"@weekly", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"@monthly", at(1, 1, 0, 0), at(2, 1, 0, 0)},
+		{"@every 6h", at(1, 1, 10, 0).Add(30 * time.Second), at(1, 1, 16, 0).Add(30 * time.Second)},
+		// Expressions that never fire
+		{"0 0 30 2 *", at(1, 1, 0, 0), time.Time{}},
+		{"0 0 31 11 *", at(1, 1, 0, 0), time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			spec, err := ParseSpec(test.expr)
+			if err != nil {
+				t.Fatalf("ParseSpec(%q): %v", test.expr, err)
+			}
+			if got := spec.Next(test.from); !got.Equal(test.want) {
+				t.Fatalf("Next(%v) = %v, want %v", test.from, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSpecRejectsInvalidExpressions(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"1-x * * * *",
+		"a * * * *",
+		"@yearly",
+		"@every 30s",
+		"@every soon",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseSpec(expr); err == nil {
+				t.Fatalf("ParseSpec(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsSchedulesThatNeverFire(t *testing.T) {
+	assetID := 1
+	schedule := &Schedule{Name: "never", AssetID: &assetID, Cron: "0 0 30 2 *"}
+	if err := schedule.Validate(); err == nil {
+		t.Fatal("Validate accepted a schedule that never fires")
+	}
+}
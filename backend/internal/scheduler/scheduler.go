@@ -0,0 +1,364 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/scanner"
+)
+
+// Schedule is a recurring scan of an asset or asset group stored in the
+// scan_schedules table. UserID is the user who created it.
+type Schedule struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"userId"`
+	OrganizationID int        `json:"organizationId"`
+	Name           string     `json:"name"`
+	AssetID        *int       `json:"assetId,omitempty"`
+	GroupID        *int       `json:"groupId,omitempty"`
+	Cron           string     `json:"cron"`
+	Engine         string     `json:"engine"`
+	ProfileID      *int       `json:"profileId,omitempty"`
+	Enabled        bool       `json:"enabled"`
+	LastRunAt      *time.Time `json:"lastRunAt,omitempty"`
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// Validate checks that the schedule targets exactly one asset or group and has a usable expression
+func (s *Schedule) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("schedule name cannot be empty")
+	}
+	if (s.AssetID == nil) == (s.GroupID == nil) {
+		return fmt.Errorf("schedule must target either an asset or an asset group")
+	}
+
+	spec, err := ParseSpec(s.Cron)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+	if spec.Next(time.Now()).IsZero() {
+		return fmt.Errorf("invalid schedule: expression never fires")
+	}
+
+	return nil
+}
+
+// Scheduler enqueues scans for due schedules through the ScanManager
+type Scheduler struct {
+	db       *db.DB
+	scans    *scanner.ScanManager
+	interval time.Duration
+}
+
+// NewScheduler creates a new Scheduler that checks for due schedules every interval
+func NewScheduler(database *db.DB, scans *scanner.ScanManager, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Scheduler{
+		db:       database,
+		scans:    scans,
+		interval: interval,
+	}
+}
+
+// Start runs the scheduler loop until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunDue(time.Now()); err != nil {
+				log.Printf("Scheduler run failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	log.Printf("Scan scheduler started (checking every %v)", s.interval)
+}
+
+// RunDue enqueues scans for every enabled schedule whose next run is at or
+// before now. The scans are queued in the transaction that advances the
+// schedules, so a run is either fully queued and recorded or retried next time.
+func (s *Scheduler) RunDue(now time.Time) error {
+	// Schedule times are stored and evaluated in UTC
+	now = now.UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock due schedules so multiple servers never fire the same run twice
+	rows, err := tx.Query(`
+		SELECT `+scheduleColumns+`
+		FROM scan_schedules
+		WHERE enabled = TRUE AND next_run_at <= $1
+		ORDER BY next_run_at ASC
+		FOR UPDATE SKIP LOCKED
+	`, now)
+	if err != nil {
+		return fmt.Errorf("failed to query due schedules: %v", err)
+	}
+
+	var due []*Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		due = append(due, schedule)
+	}
+	rows.Close()
+
+	queued := make(map[*Schedule][]*scanner.Scan)
+	for _, schedule := range due {
+		scans, err := s.fire(tx, schedule)
+		if err != nil {
+			return fmt.Errorf("failed to fire schedule %d: %v", schedule.ID, err)
+		}
+		queued[schedule] = scans
+
+		_, err = tx.Exec(`
+			UPDATE scan_schedules SET last_run_at = $1, next_run_at = $2, updated_at = $3
+			WHERE id = $4
+		`, now, nextRun(schedule, now), time.Now(), schedule.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update schedule %d: %v", schedule.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	for schedule, scans := range queued {
+		for _, scan := range scans {
+			log.Printf("Schedule %d: queued scan %d for asset %d", schedule.ID, scan.ID, scan.AssetID)
+		}
+		s.scans.ScansQueued(schedule.OrganizationID, scans...)
+	}
+
+	return nil
+}
+
+// fire enqueues a scan in tx for each asset covered by the schedule, skipping
+// assets whose previous scan has not finished yet or that can't be scanned.
+// Errors are those of tx, which can no longer be used.
+func (s *Scheduler) fire(tx *sql.Tx, schedule *Schedule) ([]*scanner.Scan, error) {
+	assetIDs, err := scheduleAssets(tx, schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve assets: %v", err)
+	}
+
+	var scans []*scanner.Scan
+	for _, assetID := range assetIDs {
+		active, err := s.scans.HasActiveScanTx(tx, assetID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check asset %d: %v", assetID, err)
+		}
+		if active {
+			log.Printf("Schedule %d: skipping asset %d, previous scan still in progress", schedule.ID, assetID)
+			continue
+		}
+
+		request, err := s.scans.PrepareScan(assetID, schedule.UserID, schedule.Engine, schedule.ProfileID)
+		if err != nil {
+			log.Printf("Schedule %d: failed to queue scan for asset %d: %v", schedule.ID, assetID, err)
+			continue
+		}
+		scan, err := s.scans.QueueScanTx(tx, request)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, scan)
+	}
+
+	return scans, nil
+}
+
+// scheduleAssets lists the assets a schedule covers
+func scheduleAssets(tx *sql.Tx, schedule *Schedule) ([]int, error) {
+	if schedule.AssetID != nil {
+		return []int{*schedule.AssetID}, nil
+	}
+
+	rows, err := tx.Query(`SELECT id FROM assets WHERE group_id = $1 AND organization_id = $2 ORDER BY id`, *schedule.GroupID, schedule.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assetIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		assetIDs = append(assetIDs, id)
+	}
+
+	return assetIDs, rows.Err()
+}
+
+const scheduleColumns = `id, user_id, organization_id, name, asset_id, group_id, cron, engine, profile_id, enabled, last_run_at, next_run_at, created_at, updated_at`
+
+// scanSchedule scans a scan_schedules row selected with scheduleColumns
+func scanSchedule(row interface{ Scan(...any) error }) (*Schedule, error) {
+	var schedule Schedule
+	var engine sql.NullString
+
+	err := row.Scan(
+		&schedule.ID,
+		&schedule.UserID,
+		&schedule.OrganizationID,
+		&schedule.Name,
+		&schedule.AssetID,
+		&schedule.GroupID,
+		&schedule.Cron,
+		&engine,
+		&schedule.ProfileID,
+		&schedule.Enabled,
+		&schedule.LastRunAt,
+		&schedule.NextRunAt,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Engine = engine.String
+
+	return &schedule, nil
+}
+
+// nextRun computes the first activation after now for an enabled schedule
+func nextRun(schedule *Schedule, now time.Time) *time.Time {
+	if !schedule.Enabled {
+		return nil
+	}
+	spec, err := ParseSpec(schedule.Cron)
+	if err != nil {
+		return nil
+	}
+	next := spec.Next(now.UTC())
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// GetSchedule retrieves one of the organization's schedules
+func (s *Scheduler) GetSchedule(organizationID, scheduleID int) (*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM scan_schedules WHERE id = $1 AND organization_id = $2`
+
+	schedule, err := scanSchedule(s.db.QueryRow(query, scheduleID, organizationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("schedule not found")
+		}
+		return nil, fmt.Errorf("failed to get schedule: %v", err)
+	}
+
+	return schedule, nil
+}
+
+// ListSchedules retrieves every schedule of the organization
+func (s *Scheduler) ListSchedules(organizationID int) ([]*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM scan_schedules WHERE organization_id = $1 ORDER BY name ASC`
+
+	rows, err := s.db.Query(query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedules: %v", err)
+	}
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+// CreateSchedule validates and stores a new schedule, computing its first run
+func (s *Scheduler) CreateSchedule(schedule *Schedule) (*Schedule, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO scan_schedules (user_id, organization_id, name, asset_id, group_id, cron, engine, profile_id, enabled, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + scheduleColumns
+
+	now := time.Now()
+	created, err := scanSchedule(s.db.QueryRow(query, schedule.UserID, schedule.OrganizationID, schedule.Name, schedule.AssetID, schedule.GroupID,
+		schedule.Cron, schedule.Engine, schedule.ProfileID, schedule.Enabled, nextRun(schedule, now), now, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %v", err)
+	}
+
+	return created, nil
+}
+
+// UpdateSchedule validates and saves changes to a schedule, recomputing its next run
+func (s *Scheduler) UpdateSchedule(schedule *Schedule) (*Schedule, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE scan_schedules
+		SET name = $1, asset_id = $2, group_id = $3, cron = $4, engine = $5, profile_id = $6, enabled = $7, next_run_at = $8, updated_at = $9
+		WHERE id = $10 AND organization_id = $11
+		RETURNING ` + scheduleColumns
+
+	now := time.Now()
+	updated, err := scanSchedule(s.db.QueryRow(query, schedule.Name, schedule.AssetID, schedule.GroupID, schedule.Cron,
+		schedule.Engine, schedule.ProfileID, schedule.Enabled, nextRun(schedule, now), now, schedule.ID, schedule.OrganizationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("schedule not found")
+		}
+		return nil, fmt.Errorf("failed to update schedule: %v", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteSchedule removes one of the organization's schedules
+func (s *Scheduler) DeleteSchedule(organizationID, scheduleID int) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM scan_schedules WHERE id = $1 AND organization_id = $2`, scheduleID, organizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/scanner"
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+// dueSchedule is a scheduler over a SQLite database holding the storetest
+// tenants, with Alice scanning AlphaAsset every minute
+type dueSchedule struct {
+	*storetest.Tenants
+	database  *db.DB
+	stores    *store.Store
+	scheduler *Scheduler
+	schedule  *Schedule
+}
+
+func newDueSchedule(t *testing.T) *dueSchedule {
+	t.Helper()
+
+	database := storetest.NewSQLite(t)
+	stores := store.NewSQL(database)
+	tt := storetest.NewTenants(t, stores)
+	engines := scanner.NewRegistry(scanner.NewTCPScanner(scanner.TCPConfig{}))
+	scheduler := NewScheduler(database, scanner.NewScanManager(database, stores, engines), time.Minute)
+
+	schedule, err := scheduler.CreateSchedule(&Schedule{
+		UserID:         tt.Alice.ID,
+		OrganizationID: tt.Alpha.ID,
+		Name:           "every minute",
+		AssetID:        &tt.AlphaAsset.ID,
+		Cron:           "* * * * *",
+		Engine:         "tcp",
+		Enabled:        true,
+	})
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+
+	return &dueSchedule{Tenants: tt, database: database, stores: stores, scheduler: scheduler, schedule: schedule}
+}
+
+// pending returns the asset's queued scans
+func (d *dueSchedule) pending(t *testing.T) []*store.Scan {
+	t.Helper()
+
+	scans, err := d.stores.Scans.ListByAsset(d.AlphaAsset.ID)
+	if err != nil {
+		t.Fatalf("list scans: %v", err)
+	}
+	var pending []*store.Scan
+	for _, scan := range scans {
+		if scan.Status == store.ScanStatusPending {
+			pending = append(pending, scan)
+		}
+	}
+	return pending
+}
+
+func TestRunDueQueuesScansOnSQLite(t *testing.T) {
+	d := newDueSchedule(t)
+	now := d.schedule.NextRunAt.Add(time.Second)
+
+	started := time.Now()
+	if err := d.scheduler.RunDue(now); err != nil {
+		t.Fatalf("RunDue: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("RunDue took %v, waiting on its own transaction", elapsed)
+	}
+
+	pending := d.pending(t)
+	if len(pending) != 1 {
+		t.Fatalf("RunDue queued %d scans, want 1", len(pending))
+	}
+	if pending[0].RequestedBy == nil || *pending[0].RequestedBy != d.Alice.ID {
+		t.Errorf("scan requested by %v, want the schedule's creator %d", pending[0].RequestedBy, d.Alice.ID)
+	}
+
+	schedule, err := d.scheduler.GetSchedule(d.Alpha.ID, d.schedule.ID)
+	if err != nil {
+		t.Fatalf("get schedule: %v", err)
+	}
+	if schedule.NextRunAt == nil || !schedule.NextRunAt.After(now) {
+		t.Fatalf("next run = %v, want after %v", schedule.NextRunAt, now)
+	}
+	if schedule.LastRunAt == nil || !schedule.LastRunAt.Equal(now.UTC()) {
+		t.Fatalf("last run = %v, want %v", schedule.LastRunAt, now)
+	}
+
+	// The run is not fired again
+	if err := d.scheduler.RunDue(now); err != nil {
+		t.Fatalf("RunDue: %v", err)
+	}
+	if pending := d.pending(t); len(pending) != 1 {
+		t.Fatalf("second RunDue left %d scans queued, want 1", len(pending))
+	}
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+// TestAssetGroupDeleteKeepsAssets checks groups are scoped to their
+// organization and deleting one only takes its assets out of it
+func TestAssetGroupDeleteKeepsAssets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, stores *store.Store, tt *storetest.Tenants) {
+		web, err := stores.AssetGroups.Create(tt.Alice.ID, tt.Alpha.ID, "web")
+		if err != nil {
+			t.Fatalf("create group: %v", err)
+		}
+		if _, err := stores.AssetGroups.Create(tt.Alice.ID, tt.Alpha.ID, "databases"); err != nil {
+			t.Fatalf("create group: %v", err)
+		}
+		if _, err := stores.AssetGroups.Create(tt.Bob.ID, tt.Beta.ID, "beta"); err != nil {
+			t.Fatalf("create group: %v", err)
+		}
+
+		groups, err := stores.AssetGroups.ListByOrganization(tt.Alpha.ID)
+		if err != nil {
+			t.Fatalf("list groups: %v", err)
+		}
+		if len(groups) != 2 || groups[0].Name != "databases" || groups[1].Name != "web" {
+			t.Fatalf("Alpha's groups = %+v, want databases and web", groups)
+		}
+
+		asset, err := stores.Assets.Get(tt.AlphaAsset.ID)
+		if err != nil {
+			t.Fatalf("get asset: %v", err)
+		}
+		asset.GroupID = &web.ID
+		if err := stores.Assets.Update(asset); err != nil {
+			t.Fatalf("update asset: %v", err)
+		}
+
+		// Another organization cannot delete the group
+		if deleted, err := stores.AssetGroups.Delete(tt.Beta.ID, web.ID); err != nil || deleted {
+			t.Fatalf("Delete from Beta = %v, %v; want nothing deleted", deleted, err)
+		}
+
+		if deleted, err := stores.AssetGroups.Delete(tt.Alpha.ID, web.ID); err != nil || !deleted {
+			t.Fatalf("Delete = %v, %v; want the group deleted", deleted, err)
+		}
+		if _, err := stores.AssetGroups.Get(web.ID); !errors.Is(err, store.ErrAssetGroupNotFound) {
+			t.Fatalf("Get after delete error = %v, want %v", err, store.ErrAssetGroupNotFound)
+		}
+		asset, err = stores.Assets.Get(tt.AlphaAsset.ID)
+		if err != nil {
+			t.Fatalf("asset was deleted with its group: %v", err)
+		}
+		if asset.GroupID != nil {
+			t.Fatalf("asset group = %d after the group was deleted, want none", *asset.GroupID)
+		}
+	})
+}
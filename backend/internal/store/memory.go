@@ -0,0 +1,1050 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cyber-risk-monitor/internal/auth"
+	"cyber-risk-monitor/internal/db"
+)
+
+// NewMemory creates stores that keep everything in memory, for running the
+// API and scan manager without a database
+func NewMemory() *Store {
+	m := &memory{
+		users:         make(map[int]*db.User),
+		organizations: make(map[int]*db.Organization),
+		members:       make(map[memberKey]*db.OrganizationMember),
+		assets:        make(map[int]*db.Asset),
+		groups:        make(map[int]*db.AssetGroup),
+		scans:         make(map[int]*Scan),
+		results:       make(map[int][]ScanResult),
+	}
+	return &Store{
+		Users:         &memUsers{m},
+		Organizations: &memOrganizations{m},
+		Assets:        &memAssets{m},
+		AssetGroups:   &memAssetGroups{m},
+		Scans:         &memScans{m},
+		Results:       &memResults{m},
+	}
+}
+
+// memory is the state shared by the in-memory stores. Records are copied in
+// and out so callers never share them.
+type memory struct {
+	mu            sync.Mutex
+	lastID        int
+	users         map[int]*db.User
+	organizations map[int]*db.Organization
+	members       map[memberKey]*db.OrganizationMember
+	assets        map[int]*db.Asset
+	groups        map[int]*db.AssetGroup
+	scans         map[int]*Scan
+	results       map[int][]ScanResult
+}
+
+// memberKey identifies a user's membership of an organization
+type memberKey struct {
+	organizationID, userID int
+}
+
+// nextID returns a new record ID; callers must hold mu
+func (m *memory) nextID() int {
+	m.lastID++
+	return m.lastID
+}
+
+// latestScan returns the asset's most recently queued scan that matches keep,
+// or nil; callers must hold mu
+func (m *memory) latestScan(assetID int, keep func(*Scan) bool) *Scan {
+	var latest *Scan
+	for _, scan := range m.scans {
+		if scan.AssetID != assetID || !keep(scan) {
+			continue
+		}
+		if latest == nil || scan.QueuedAt.After(latest.QueuedAt) || (scan.QueuedAt.Equal(latest.QueuedAt) && scan.ID > latest.ID) {
+			latest = scan
+		}
+	}
+	return latest
+}
+
+// hasOpenResult reports whether the scan found an open port matching the port
+// and service, ignoring case, that are set; callers must hold mu
+func (m *memory) hasOpenResult(scanID int, port *int, service string) bool {
+	for _, result := range m.results[scanID] {
+		if result.State != "open" || (port != nil && result.Port != *port) {
+			continue
+		}
+		if service == "" || strings.EqualFold(result.Service, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted reports whether a record sorts before another given how their sort
+// fields compare, falling back to their IDs, in descending order when desc is set
+func sorted(compared, aID, bID int, desc bool) bool {
+	if compared == 0 {
+		compared = aID - bID
+	}
+	if desc {
+		return compared > 0
+	}
+	return compared < 0
+}
+
+// compareTimes compares nullable times so that nil sorts last in either direction
+func compareTimes(a, b *time.Time, desc bool) int {
+	if a == nil || b == nil {
+		return compareNulls(a == nil, b == nil, desc)
+	}
+	return a.Compare(*b)
+}
+
+// compareNullable compares nullable numbers so that nil sorts last in either direction
+func compareNullable[T int64 | float64](a, b *T, desc bool) int {
+	if a == nil || b == nil {
+		return compareNulls(a == nil, b == nil, desc)
+	}
+	return cmp.Compare(*a, *b)
+}
+
+// compareNulls orders a nil value after a set one, flipping the result for
+// descending sorts, which flip it back
+func compareNulls(aNil, bNil, desc bool) int {
+	result := 0
+	if aNil && !bNil {
+		result = 1
+	} else if !aNil && bNil {
+		result = -1
+	}
+	if desc {
+		result = -result
+	}
+	return result
+}
+
+// window returns the page of a sorted list
+func window[T any](records []T, page Page) []T {
+	if page.Offset >= len(records) {
+		return nil
+	}
+	records = records[page.Offset:]
+	if page.Limit < len(records) {
+		records = records[:page.Limit]
+	}
+	return records
+}
+
+// memUsers is the in-memory UserStore
+type memUsers struct {
+	*memory
+}
+
+func (s *memUsers) Create(email, passwordHash string) (*db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return nil, fmt.Errorf("failed to create user: email %q is already registered", email)
+		}
+	}
+
+	now := time.Now()
+	user := &db.User{
+		ID:           s.nextID(),
+		Email:        email,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.users[user.ID] = user
+
+	created := *user
+	return &created, nil
+}
+
+func (s *memUsers) Get(id int) (*db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (s *memUsers) GetByEmail(email string) (*db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *memUsers) SetCurrentOrganization(id, organizationID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.CurrentOrganizationID = &organizationID
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// memOrganizations is the in-memory OrganizationStore
+type memOrganizations struct {
+	*memory
+}
+
+func (s *memOrganizations) Create(name string, ownerID int) (*db.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	org := &db.Organization{ID: s.nextID(), Name: name, CreatedAt: now}
+	s.organizations[org.ID] = org
+	s.members[memberKey{org.ID, ownerID}] = &db.OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         ownerID,
+		Role:           auth.RoleAdmin,
+		CreatedAt:      now,
+	}
+
+	created := *org
+	return &created, nil
+}
+
+func (s *memOrganizations) Get(id int) (*db.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, ok := s.organizations[id]
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	found := *org
+	return &found, nil
+}
+
+func (s *memOrganizations) ListByUser(userID int) ([]*Membership, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var memberships []*Membership
+	for key, member := range s.members {
+		if key.userID != userID {
+			continue
+		}
+		org := *s.organizations[key.organizationID]
+		memberships = append(memberships, &Membership{Organization: &org, Role: member.Role})
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		a, b := memberships[i].Organization, memberships[j].Organization
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return memberships, nil
+}
+
+func (s *memOrganizations) Member(organizationID, userID int) (*db.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	member, ok := s.members[memberKey{organizationID, userID}]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	found := *member
+	return &found, nil
+}
+
+func (s *memOrganizations) ListMembers(organizationID int) ([]*db.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var members []*db.OrganizationMember
+	for key, member := range s.members {
+		if key.organizationID == organizationID {
+			found := *member
+			members = append(members, &found)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return s.users[members[i].UserID].Email < s.users[members[j].UserID].Email
+	})
+	return members, nil
+}
+
+func (s *memOrganizations) AddMember(organizationID, userID int, role string) (*db.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{organizationID, userID}
+	if _, ok := s.members[key]; ok {
+		return nil, ErrMemberExists
+	}
+	if _, ok := s.organizations[organizationID]; !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	if _, ok := s.users[userID]; !ok {
+		return nil, ErrUserNotFound
+	}
+
+	member := &db.OrganizationMember{OrganizationID: organizationID, UserID: userID, Role: role, CreatedAt: time.Now()}
+	s.members[key] = member
+
+	added := *member
+	return &added, nil
+}
+
+func (s *memOrganizations) SetMemberRole(organizationID, userID int, role string) (*db.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	member, ok := s.members[memberKey{organizationID, userID}]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	member.Role = role
+	updated := *member
+	return &updated, nil
+}
+
+func (s *memOrganizations) RemoveMember(organizationID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{organizationID, userID}
+	if _, ok := s.members[key]; !ok {
+		return false, nil
+	}
+	delete(s.members, key)
+	return true, nil
+}
+
+// memAssets is the in-memory AssetStore
+type memAssets struct {
+	*memory
+}
+
+func (s *memAssets) Create(asset *db.Asset) (*db.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := *asset
+	created.ID = s.nextID()
+	created.CreatedAt = time.Now()
+	created.LastScannedAt = nil
+	s.assets[created.ID] = &created
+
+	result := created
+	return &result, nil
+}
+
+func (s *memAssets) Get(id int) (*db.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	asset, ok := s.assets[id]
+	if !ok {
+		return nil, ErrAssetNotFound
+	}
+	found := *asset
+	return &found, nil
+}
+
+func (s *memAssets) GetMany(ids []int) (map[int]*db.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := make(map[int]*db.Asset, len(ids))
+	for _, id := range ids {
+		if asset, ok := s.assets[id]; ok {
+			copied := *asset
+			found[id] = &copied
+		}
+	}
+	return found, nil
+}
+
+// list copies the assets matching keep, sorted by less; callers must hold mu
+func (s *memAssets) list(keep func(*db.Asset) bool, less func(a, b *db.Asset) bool) []*db.Asset {
+	var assets []*db.Asset
+	for _, asset := range s.assets {
+		if keep(asset) {
+			found := *asset
+			assets = append(assets, &found)
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool { return less(assets[i], assets[j]) })
+	return assets
+}
+
+// byName orders assets by name, then ID
+func byName(a, b *db.Asset) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.ID < b.ID
+}
+
+// matches reports whether one of the organization's assets matches filter; callers must hold mu
+func (s *memAssets) matches(organizationID int, filter AssetFilter) func(*db.Asset) bool {
+	return func(a *db.Asset) bool {
+		if a.OrganizationID != organizationID || (filter.AssetType != "" && a.AssetType != filter.AssetType) {
+			return false
+		}
+		if filter.Target != "" && !strings.Contains(strings.ToLower(a.Target), strings.ToLower(filter.Target)) {
+			return false
+		}
+		if filter.CreatedAfter != nil && a.CreatedAt.Before(*filter.CreatedAfter) {
+			return false
+		}
+		if filter.CreatedBefore != nil && !a.CreatedAt.Before(*filter.CreatedBefore) {
+			return false
+		}
+		if filter.ScanStatus != "" {
+			latest := s.latestScan(a.ID, func(*Scan) bool { return true })
+			if latest == nil || latest.Status != filter.ScanStatus {
+				return false
+			}
+		}
+		if filter.OpenPort != nil || filter.Service != "" {
+			completed := s.latestScan(a.ID, func(scan *Scan) bool { return scan.Status == ScanStatusCompleted })
+			if completed == nil || !s.hasOpenResult(completed.ID, filter.OpenPort, filter.Service) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (s *memAssets) Count(organizationID int, filter AssetFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.list(s.matches(organizationID, filter), byName)), nil
+}
+
+func (s *memAssets) Find(organizationID int, filter AssetFilter, order AssetOrder, page Page) ([]*db.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	less := func(a, b *db.Asset) int {
+		switch order.Field {
+		case AssetSortName:
+			return strings.Compare(a.Name, b.Name)
+		case AssetSortTarget:
+			return strings.Compare(a.Target, b.Target)
+		case AssetSortAssetType:
+			return strings.Compare(a.AssetType, b.AssetType)
+		case AssetSortLastScannedAt:
+			return compareTimes(a.LastScannedAt, b.LastScannedAt, order.Desc)
+		case AssetSortRiskScore:
+			return compareNullable(a.RiskScore, b.RiskScore, order.Desc)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+	assets := s.list(s.matches(organizationID, filter), func(a, b *db.Asset) bool {
+		return sorted(less(a, b), a.ID, b.ID, order.Desc)
+	})
+	return window(assets, page), nil
+}
+
+func (s *memAssets) ListByGroup(organizationID, groupID int) ([]*db.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(func(a *db.Asset) bool {
+		return a.OrganizationID == organizationID && a.GroupID != nil && *a.GroupID == groupID
+	}, byName), nil
+}
+
+func (s *memAssets) ListByOrganization(organizationID int) ([]*db.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(func(a *db.Asset) bool { return a.OrganizationID == organizationID }, byName), nil
+}
+
+func (s *memAssets) Update(asset *db.Asset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.assets[asset.ID]
+	if !ok {
+		return ErrAssetNotFound
+	}
+	stored.Name = asset.Name
+	stored.Target = asset.Target
+	stored.AssetType = asset.AssetType
+	stored.ScanEngine = asset.ScanEngine
+	stored.ScanProfileID = asset.ScanProfileID
+	stored.GroupID = asset.GroupID
+	stored.Criticality = asset.Criticality
+	stored.InternetFacing = asset.InternetFacing
+	return nil
+}
+
+func (s *memAssets) Delete(organizationID, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	asset, ok := s.assets[id]
+	if !ok || asset.OrganizationID != organizationID {
+		return false, nil
+	}
+
+	// Scans and their results go with the asset, as in the database
+	delete(s.assets, id)
+	for scanID, scan := range s.scans {
+		if scan.AssetID == id {
+			delete(s.scans, scanID)
+			delete(s.results, scanID)
+		}
+	}
+	return true, nil
+}
+
+func (s *memAssets) SetLastScanned(id int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if asset, ok := s.assets[id]; ok {
+		asset.LastScannedAt = &at
+	}
+	return nil
+}
+
+// memAssetGroups is the in-memory AssetGroupStore
+type memAssetGroups struct {
+	*memory
+}
+
+func (s *memAssetGroups) Create(userID, organizationID int, name string) (*db.AssetGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group := &db.AssetGroup{
+		ID:             s.nextID(),
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Name:           name,
+		CreatedAt:      time.Now(),
+	}
+	s.groups[group.ID] = group
+
+	created := *group
+	return &created, nil
+}
+
+func (s *memAssetGroups) Get(id int) (*db.AssetGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, ErrAssetGroupNotFound
+	}
+	found := *group
+	return &found, nil
+}
+
+func (s *memAssetGroups) ListByOrganization(organizationID int) ([]*db.AssetGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var groups []*db.AssetGroup
+	for _, group := range s.groups {
+		if group.OrganizationID == organizationID {
+			found := *group
+			groups = append(groups, &found)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
+
+func (s *memAssetGroups) Delete(organizationID, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[id]
+	if !ok || group.OrganizationID != organizationID {
+		return false, nil
+	}
+
+	// Assets leave the group, as in the database
+	delete(s.groups, id)
+	for _, asset := range s.assets {
+		if asset.GroupID != nil && *asset.GroupID == id {
+			asset.GroupID = nil
+		}
+	}
+	return true, nil
+}
+
+// memScans is the in-memory ScanStore
+type memScans struct {
+	*memory
+}
+
+// copyScan returns a copy of a scan; callers must hold mu
+func copyScan(scan *Scan) *Scan {
+	found := *scan
+	return &found
+}
+
+// list copies the scans matching keep, newest first; callers must hold mu
+func (s *memScans) list(keep func(*Scan) bool) []*Scan {
+	var scans []*Scan
+	for _, scan := range s.scans {
+		if keep(scan) {
+			scans = append(scans, copyScan(scan))
+		}
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		if !scans[i].QueuedAt.Equal(scans[j].QueuedAt) {
+			return scans[i].QueuedAt.After(scans[j].QueuedAt)
+		}
+		return scans[i].ID > scans[j].ID
+	})
+	return scans
+}
+
+func (s *memScans) Create(assetID, requestedBy int, engine string, profileID *int) (*Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.assets[assetID]; !ok {
+		return nil, fmt.Errorf("failed to create scan: %w", ErrAssetNotFound)
+	}
+
+	scan := &Scan{
+		ID:          s.nextID(),
+		AssetID:     assetID,
+		RequestedBy: &requestedBy,
+		Status:      ScanStatusPending,
+		Engine:      engine,
+		ProfileID:   profileID,
+		QueuedAt:    time.Now(),
+	}
+	s.scans[scan.ID] = scan
+
+	return copyScan(scan), nil
+}
+
+func (s *memScans) Get(id int) (*Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[id]
+	if !ok {
+		return nil, ErrScanNotFound
+	}
+	return copyScan(scan), nil
+}
+
+func (s *memScans) ListByAsset(assetID int) ([]*Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(func(scan *Scan) bool { return scan.AssetID == assetID }), nil
+}
+
+func (s *memScans) CountByAssets(assetIDs []int) (map[int]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[int]bool, len(assetIDs))
+	for _, id := range assetIDs {
+		wanted[id] = true
+	}
+
+	counts := make(map[int]int, len(assetIDs))
+	for _, scan := range s.scans {
+		if wanted[scan.AssetID] {
+			counts[scan.AssetID]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *memScans) FindByAssets(assetIDs []int, page Page) (map[int][]*Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[int]bool, len(assetIDs))
+	for _, id := range assetIDs {
+		wanted[id] = true
+	}
+
+	byAsset := make(map[int][]*Scan, len(assetIDs))
+	for _, scan := range s.list(func(scan *Scan) bool { return wanted[scan.AssetID] }) {
+		byAsset[scan.AssetID] = append(byAsset[scan.AssetID], scan)
+	}
+	for assetID, scans := range byAsset {
+		if paged := window(scans, page); len(paged) > 0 {
+			byAsset[assetID] = paged
+		} else {
+			delete(byAsset, assetID)
+		}
+	}
+	return byAsset, nil
+}
+
+// matches reports whether a scan of one of the organization's assets matches filter; callers must hold mu
+func (s *memScans) matches(organizationID int, filter ScanFilter) func(*Scan) bool {
+	return func(scan *Scan) bool {
+		asset, ok := s.assets[scan.AssetID]
+		if !ok || asset.OrganizationID != organizationID {
+			return false
+		}
+		if filter.AssetID != nil && scan.AssetID != *filter.AssetID {
+			return false
+		}
+		if filter.Status != "" && scan.Status != filter.Status {
+			return false
+		}
+		if filter.QueuedAfter != nil && scan.QueuedAt.Before(*filter.QueuedAfter) {
+			return false
+		}
+		if filter.QueuedBefore != nil && !scan.QueuedAt.Before(*filter.QueuedBefore) {
+			return false
+		}
+		if filter.AssetType != "" && asset.AssetType != filter.AssetType {
+			return false
+		}
+		if filter.Target != "" && !strings.Contains(strings.ToLower(asset.Target), strings.ToLower(filter.Target)) {
+			return false
+		}
+		if (filter.OpenPort != nil || filter.Service != "") && !s.hasOpenResult(scan.ID, filter.OpenPort, filter.Service) {
+			return false
+		}
+		return true
+	}
+}
+
+func (s *memScans) Count(organizationID int, filter ScanFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.list(s.matches(organizationID, filter))), nil
+}
+
+func (s *memScans) Find(organizationID int, filter ScanFilter, order ScanOrder, page Page) ([]*Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	less := func(a, b *Scan) int {
+		switch order.Field {
+		case ScanSortStartedAt:
+			return compareTimes(a.StartedAt, b.StartedAt, order.Desc)
+		case ScanSortCompletedAt:
+			return compareTimes(a.CompletedAt, b.CompletedAt, order.Desc)
+		case ScanSortDuration:
+			return compareNullable(a.DurationMS, b.DurationMS, order.Desc)
+		case ScanSortStatus:
+			return strings.Compare(string(a.Status), string(b.Status))
+		default:
+			return a.QueuedAt.Compare(b.QueuedAt)
+		}
+	}
+	scans := s.list(s.matches(organizationID, filter))
+	sort.Slice(scans, func(i, j int) bool {
+		return sorted(less(scans[i], scans[j]), scans[i].ID, scans[j].ID, order.Desc)
+	})
+	return window(scans, page), nil
+}
+
+func (s *memScans) OrganizationID(scanID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[scanID]
+	if !ok {
+		return 0, ErrScanNotFound
+	}
+	if asset, ok := s.assets[scan.AssetID]; ok {
+		return asset.OrganizationID, nil
+	}
+	return 0, nil
+}
+
+func (s *memScans) HasActive(assetID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, scan := range s.scans {
+		if scan.AssetID == assetID && (scan.Status == ScanStatusPending || scan.Status == ScanStatusRunning) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memScans) PreviousCompleted(scanID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.scans[scanID]
+	if !ok {
+		return 0, nil
+	}
+
+	previousID := 0
+	for _, scan := range s.scans {
+		if scan.AssetID == current.AssetID && scan.Status == ScanStatusCompleted && scan.ID < scanID && scan.ID > previousID {
+			previousID = scan.ID
+		}
+	}
+	return previousID, nil
+}
+
+func (s *memScans) ClaimNext(owner string, perUserLimit int, lease time.Duration) (*Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	running := make(map[int]int)
+	for _, scan := range s.scans {
+		if scan.Status == ScanStatusRunning && scan.RequestedBy != nil {
+			running[*scan.RequestedBy]++
+		}
+	}
+
+	var next *Scan
+	for _, scan := range s.scans {
+		if scan.Status != ScanStatusPending || (scan.RequestedBy != nil && running[*scan.RequestedBy] >= perUserLimit) {
+			continue
+		}
+		if next == nil || scan.QueuedAt.Before(next.QueuedAt) || (scan.QueuedAt.Equal(next.QueuedAt) && scan.ID < next.ID) {
+			next = scan
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	expires := now.Add(lease)
+	next.Status = ScanStatusRunning
+	next.Attempts++
+	next.StartedAt = &now
+	next.CompletedAt = nil
+	next.DurationMS = nil
+	next.Progress, next.EstimatedCompletion = nil, nil
+	next.ClaimedBy, next.LeaseExpiresAt = &owner, &expires
+
+	return copyScan(next), nil
+}
+
+func (s *memScans) CancelPending(scanID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[scanID]
+	if !ok || scan.Status != ScanStatusPending {
+		return false, nil
+	}
+
+	now := time.Now()
+	scan.Status = ScanStatusCancelled
+	scan.CompletedAt = &now
+	return true, nil
+}
+
+func (s *memScans) SetProgress(scanID int, progress float64, estimatedCompletion *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if scan, ok := s.scans[scanID]; ok && scan.Status == ScanStatusRunning {
+		scan.Progress = &progress
+		scan.EstimatedCompletion = nil
+		if estimatedCompletion != nil {
+			at := *estimatedCompletion
+			scan.EstimatedCompletion = &at
+		}
+	}
+	return nil
+}
+
+// requeue puts a scan back on the queue, dropping its results; callers must hold mu
+func (s *memScans) requeue(scan *Scan) {
+	scan.Status = ScanStatusPending
+	scan.StartedAt = nil
+	scan.CompletedAt = nil
+	scan.DurationMS = nil
+	scan.Progress, scan.EstimatedCompletion = nil, nil
+	scan.ClaimedBy, scan.LeaseExpiresAt = nil, nil
+	delete(s.results, scan.ID)
+}
+
+func (s *memScans) Requeue(scanID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if scan, ok := s.scans[scanID]; ok && scan.Status == ScanStatusRunning {
+		s.requeue(scan)
+	}
+	return nil
+}
+
+// finish moves a scan to a final status; callers must hold mu
+func finish(scan *Scan, status ScanStatus, scanErr *ScanError) {
+	now := time.Now()
+	scan.Status = status
+	scan.CompletedAt = &now
+	scan.DurationMS = nil
+	if scan.StartedAt != nil {
+		duration := now.Sub(*scan.StartedAt).Milliseconds()
+		scan.DurationMS = &duration
+	}
+
+	// Completed scans are all the way done; others keep the progress they reached
+	if status == ScanStatusCompleted {
+		progress := 100.0
+		scan.Progress = &progress
+	}
+	scan.EstimatedCompletion = nil
+	scan.LeaseExpiresAt = nil
+
+	scan.ErrorCode, scan.ErrorMessage = nil, nil
+	if scanErr != nil {
+		code, message := scanErr.Code, scanErr.Message
+		scan.ErrorCode, scan.ErrorMessage = &code, &message
+	}
+}
+
+func (s *memScans) Finish(scanID int, status ScanStatus, scanErr *ScanError) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[scanID]
+	if !ok || scan.Status != ScanStatusRunning {
+		return false, nil
+	}
+	finish(scan, status, scanErr)
+	return true, nil
+}
+
+func (s *memScans) RenewLeases(owner string, lease time.Duration) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := time.Now().Add(lease)
+	var scanIDs []int
+	for _, scan := range s.scans {
+		if scan.Status == ScanStatusRunning && scan.ClaimedBy != nil && *scan.ClaimedBy == owner {
+			scan.LeaseExpiresAt = &expires
+			scanIDs = append(scanIDs, scan.ID)
+		}
+	}
+	return scanIDs, nil
+}
+
+func (s *memScans) RecoverOrphaned(maxAttempts int, scanErr ScanError) (requeued, failed int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, scan := range s.scans {
+		if scan.Status != ScanStatusRunning || (scan.LeaseExpiresAt != nil && !scan.LeaseExpiresAt.Before(now)) {
+			continue
+		}
+		if scan.Attempts >= maxAttempts {
+			finish(scan, ScanStatusFailed, &scanErr)
+			failed++
+			continue
+		}
+		s.requeue(scan)
+		requeued++
+	}
+	return requeued, failed, nil
+}
+
+// memResults is the in-memory ResultStore
+type memResults struct {
+	*memory
+}
+
+func (s *memResults) Insert(scanID int, results []ScanResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.scans[scanID]; !ok {
+		return fmt.Errorf("failed to insert scan result: %w", ErrScanNotFound)
+	}
+
+	for _, result := range results {
+		result.ID = s.nextID()
+		if result.Protocol == "" {
+			result.Protocol = "tcp"
+		}
+		result.CPEs = append([]string(nil), result.CPEs...)
+		s.results[scanID] = append(s.results[scanID], result)
+	}
+	return nil
+}
+
+func (s *memResults) ListByScan(scanID int) ([]ScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(scanID), nil
+}
+
+func (s *memResults) ListByScans(scanIDs []int) (map[int][]ScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make(map[int][]ScanResult, len(scanIDs))
+	for _, scanID := range scanIDs {
+		if found := s.list(scanID); len(found) > 0 {
+			results[scanID] = found
+		}
+	}
+	return results, nil
+}
+
+// list copies a scan's results ordered by host, port and protocol; callers must hold mu
+func (s *memResults) list(scanID int) []ScanResult {
+	results := append([]ScanResult(nil), s.results[scanID]...)
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Protocol < b.Protocol
+	})
+	return results
+}
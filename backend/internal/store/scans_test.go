@@ -0,0 +1,219 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"cyber-risk-monitor/internal/store"
+	"cyber-risk-monitor/internal/store/storetest"
+)
+
+// forEachBackend runs test against the storetest tenants in each store backend
+func forEachBackend(t *testing.T, test func(t *testing.T, stores *store.Store, tt *storetest.Tenants)) {
+	backends := map[string]func(t *testing.T) *store.Store{
+		"memory": func(t *testing.T) *store.Store { return store.NewMemory() },
+		"sqlite": func(t *testing.T) *store.Store { return store.NewSQL(storetest.NewSQLite(t)) },
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			stores := newStore(t)
+			test(t, stores, storetest.NewTenants(t, stores))
+		})
+	}
+}
+
+// TestClaimNextCapsRequesters checks the running cap counts the scans each
+// user requested, not the scans of the assets they created
+func TestClaimNextCapsRequesters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, stores *store.Store, tt *storetest.Tenants) {
+		// Alice created the asset; Victor and Carol each queue a scan of it
+		// and Victor queues a second one
+		queue := func(requestedBy int) *store.Scan {
+			scan, err := stores.Scans.Create(tt.AlphaAsset.ID, requestedBy, "tcp", nil)
+			if err != nil {
+				t.Fatalf("create scan: %v", err)
+			}
+			return scan
+		}
+		victor := queue(tt.Victor.ID)
+		carol := queue(tt.Carol.ID)
+		queue(tt.Victor.ID)
+
+		for _, want := range []*store.Scan{victor, carol} {
+			claimed, err := stores.Scans.ClaimNext("worker", 1, time.Minute)
+			if err != nil {
+				t.Fatalf("ClaimNext: %v", err)
+			}
+			if claimed == nil || claimed.ID != want.ID {
+				t.Fatalf("ClaimNext = %+v, want scan %d requested by user %d", claimed, want.ID, *want.RequestedBy)
+			}
+		}
+
+		// Victor already has a scan running
+		claimed, err := stores.Scans.ClaimNext("worker", 1, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimNext: %v", err)
+		}
+		if claimed != nil {
+			t.Fatalf("ClaimNext = scan %d, want none while its requester is at the limit", claimed.ID)
+		}
+	})
+}
+
+// TestFindByAssetsPagesEachAsset checks each asset's scans are counted and
+// paged newest first in one call, like ListByAsset
+func TestFindByAssetsPagesEachAsset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, stores *store.Store, tt *storetest.Tenants) {
+		// Each asset already has the scan that scanned it
+		for range 4 {
+			if _, err := stores.Scans.Create(tt.AlphaAsset.ID, tt.Alice.ID, "tcp", nil); err != nil {
+				t.Fatalf("create scan: %v", err)
+			}
+		}
+		if _, err := stores.Scans.Create(tt.BetaAsset.ID, tt.Bob.ID, "tcp", nil); err != nil {
+			t.Fatalf("create scan: %v", err)
+		}
+		assetIDs := []int{tt.AlphaAsset.ID, tt.BetaAsset.ID, 0}
+
+		counts, err := stores.Scans.CountByAssets(assetIDs)
+		if err != nil {
+			t.Fatalf("CountByAssets: %v", err)
+		}
+		want := map[int]int{tt.AlphaAsset.ID: 5, tt.BetaAsset.ID: 2}
+		if len(counts) != len(want) || counts[tt.AlphaAsset.ID] != 5 || counts[tt.BetaAsset.ID] != 2 {
+			t.Fatalf("CountByAssets = %v, want %v", counts, want)
+		}
+
+		page := store.Page{Offset: 1, Limit: 2}
+		paged, err := stores.Scans.FindByAssets(assetIDs, page)
+		if err != nil {
+			t.Fatalf("FindByAssets: %v", err)
+		}
+		for _, assetID := range []int{tt.AlphaAsset.ID, tt.BetaAsset.ID} {
+			all, err := stores.Scans.ListByAsset(assetID)
+			if err != nil {
+				t.Fatalf("list scans: %v", err)
+			}
+			expected := all[page.Offset:min(len(all), page.Offset+page.Limit)]
+			got := paged[assetID]
+			if len(got) != len(expected) {
+				t.Fatalf("asset %d page has %d scans, want %d", assetID, len(got), len(expected))
+			}
+			for i := range expected {
+				if got[i].ID != expected[i].ID {
+					t.Fatalf("asset %d page scan %d = %d, want %d", assetID, i, got[i].ID, expected[i].ID)
+				}
+			}
+		}
+
+		// Pages past the end are empty
+		paged, err = stores.Scans.FindByAssets(assetIDs, store.Page{Offset: 5, Limit: 2})
+		if err != nil {
+			t.Fatalf("FindByAssets: %v", err)
+		}
+		if len(paged) != 0 {
+			t.Fatalf("FindByAssets past the end = %v, want nothing", paged)
+		}
+	})
+}
+
+// TestFinishOnlyEndsRunningScans checks a scan cancelled while its engine was
+// finishing is not then recorded as completed or put back on the queue
+func TestFinishOnlyEndsRunningScans(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, stores *store.Store, tt *storetest.Tenants) {
+		scan, err := stores.Scans.Create(tt.AlphaAsset.ID, tt.Alice.ID, "tcp", nil)
+		if err != nil {
+			t.Fatalf("create scan: %v", err)
+		}
+		if finished, err := stores.Scans.Finish(scan.ID, store.ScanStatusCompleted, nil); err != nil || finished {
+			t.Fatalf("Finish of a queued scan = %v, %v, want false", finished, err)
+		}
+
+		if claimed, err := stores.Scans.ClaimNext("worker", 1, time.Minute); err != nil || claimed == nil || claimed.ID != scan.ID {
+			t.Fatalf("ClaimNext = %v, %v, want scan %d", claimed, err, scan.ID)
+		}
+		if cancelled, err := stores.Scans.Finish(scan.ID, store.ScanStatusCancelled, nil); err != nil || !cancelled {
+			t.Fatalf("Finish of a running scan = %v, %v, want true", cancelled, err)
+		}
+		if completed, err := stores.Scans.Finish(scan.ID, store.ScanStatusCompleted, nil); err != nil || completed {
+			t.Fatalf("Finish of a cancelled scan = %v, %v, want false", completed, err)
+		}
+		if err := stores.Scans.Requeue(scan.ID); err != nil {
+			t.Fatalf("Requeue: %v", err)
+		}
+
+		got, err := stores.Scans.Get(scan.ID)
+		if err != nil {
+			t.Fatalf("get scan: %v", err)
+		}
+		if got.Status != store.ScanStatusCancelled {
+			t.Fatalf("scan status = %s, want %s", got.Status, store.ScanStatusCancelled)
+		}
+	})
+}
+
+// TestRecoverOrphanedOnlyRecoversExpiredLeases checks scans another live server
+// holds are left running while those of a stopped server are requeued, or
+// failed once they have used up their attempts
+func TestRecoverOrphanedOnlyRecoversExpiredLeases(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, stores *store.Store, tt *storetest.Tenants) {
+		claim := func(owner string, lease time.Duration) *store.Scan {
+			if _, err := stores.Scans.Create(tt.AlphaAsset.ID, tt.Alice.ID, "tcp", nil); err != nil {
+				t.Fatalf("create scan: %v", err)
+			}
+			scan, err := stores.Scans.ClaimNext(owner, 10, lease)
+			if err != nil || scan == nil {
+				t.Fatalf("ClaimNext = %v, %v", scan, err)
+			}
+			return scan
+		}
+		live := claim("live", time.Hour)
+		orphaned := claim("stopped", -time.Second)
+
+		result := store.ScanResult{Host: "10.0.0.1", Port: 22, Protocol: "tcp", State: "open"}
+		if err := stores.Results.Insert(orphaned.ID, []store.ScanResult{result}); err != nil {
+			t.Fatalf("insert results: %v", err)
+		}
+
+		held, err := stores.Scans.RenewLeases("live", time.Hour)
+		if err != nil {
+			t.Fatalf("RenewLeases: %v", err)
+		}
+		if len(held) != 1 || held[0] != live.ID {
+			t.Fatalf("RenewLeases = %v, want only scan %d", held, live.ID)
+		}
+
+		scanErr := store.ScanError{Code: "interrupted", Message: "interrupted"}
+		requeued, failed, err := stores.Scans.RecoverOrphaned(2, scanErr)
+		if err != nil {
+			t.Fatalf("RecoverOrphaned: %v", err)
+		}
+		if requeued != 1 || failed != 0 {
+			t.Fatalf("RecoverOrphaned = %d requeued, %d failed, want 1 requeued", requeued, failed)
+		}
+		results, err := stores.Results.ListByScan(orphaned.ID)
+		if err != nil || len(results) != 0 {
+			t.Fatalf("requeued scan kept %d partial results (%v)", len(results), err)
+		}
+
+		// The requeued scan is claimed again by a server that also stops
+		if again, err := stores.Scans.ClaimNext("stopped", 10, -time.Second); err != nil || again == nil || again.ID != orphaned.ID {
+			t.Fatalf("ClaimNext = %v, %v, want scan %d", again, err, orphaned.ID)
+		}
+		if requeued, failed, err = stores.Scans.RecoverOrphaned(2, scanErr); err != nil || requeued != 0 || failed != 1 {
+			t.Fatalf("RecoverOrphaned = %d requeued, %d failed (%v), want 1 failed", requeued, failed, err)
+		}
+
+		want := map[int]store.ScanStatus{live.ID: store.ScanStatusRunning, orphaned.ID: store.ScanStatusFailed}
+		for id, status := range want {
+			scan, err := stores.Scans.Get(id)
+			if err != nil {
+				t.Fatalf("get scan: %v", err)
+			}
+			if scan.Status != status {
+				t.Errorf("scan %d status = %s, want %s", id, scan.Status, status)
+			}
+		}
+	})
+}
@@ -0,0 +1,1249 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cyber-risk-monitor/internal/auth"
+	"cyber-risk-monitor/internal/db"
+)
+
+// rowQuerier is a *db.DB or a *sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// NewSQL creates stores backed by the PostgreSQL or SQLite database
+func NewSQL(database *db.DB) *Store {
+	return &Store{
+		Users:         &sqlUsers{db: database},
+		Organizations: &sqlOrganizations{db: database},
+		Assets:        &sqlAssets{db: database},
+		AssetGroups:   &sqlAssetGroups{db: database},
+		Scans:         &sqlScans{db: database},
+		Results:       &sqlResults{db: database},
+	}
+}
+
+// sqlUsers stores users in the users table
+type sqlUsers struct {
+	db *db.DB
+}
+
+const userColumns = `id, email, password_hash, current_organization_id, created_at, updated_at`
+
+// scanUser scans a users row selected with userColumns
+func scanUser(row interface{ Scan(...any) error }) (*db.User, error) {
+	var user db.User
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CurrentOrganizationID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *sqlUsers) Create(email, passwordHash string) (*db.User, error) {
+	query := `
+		INSERT INTO users (email, password_hash, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING ` + userColumns
+
+	user, err := scanUser(s.db.QueryRow(query, email, passwordHash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	return user, nil
+}
+
+func (s *sqlUsers) Get(id int) (*db.User, error) {
+	return s.get(`SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+func (s *sqlUsers) GetByEmail(email string) (*db.User, error) {
+	return s.get(`SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+}
+
+func (s *sqlUsers) SetCurrentOrganization(id, organizationID int) error {
+	result, err := s.db.Exec(`UPDATE users SET current_organization_id = $2, updated_at = NOW() WHERE id = $1`, id, organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// get runs a query selecting a single user
+func (s *sqlUsers) get(query string, args ...any) (*db.User, error) {
+	user, err := scanUser(s.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	return user, nil
+}
+
+// sqlOrganizations stores organizations in the organizations table and their
+// members in organization_members
+type sqlOrganizations struct {
+	db *db.DB
+}
+
+const memberColumns = `organization_id, user_id, role, created_at`
+
+// scanMember scans an organization_members row selected with memberColumns
+func scanMember(row interface{ Scan(...any) error }) (*db.OrganizationMember, error) {
+	var member db.OrganizationMember
+	err := row.Scan(&member.OrganizationID, &member.UserID, &member.Role, &member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (s *sqlOrganizations) Create(name string, ownerID int) (*db.Organization, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var org db.Organization
+	err = tx.QueryRow(`
+		INSERT INTO organizations (name, created_at)
+		VALUES ($1, NOW())
+		RETURNING id, name, created_at
+	`, name).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`, org.ID, ownerID, auth.RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add organization owner: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return &org, nil
+}
+
+func (s *sqlOrganizations) Get(id int) (*db.Organization, error) {
+	var org db.Organization
+	err := s.db.QueryRow(`SELECT id, name, created_at FROM organizations WHERE id = $1`, id).
+		Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %v", err)
+	}
+
+	return &org, nil
+}
+
+func (s *sqlOrganizations) ListByUser(userID int) ([]*Membership, error) {
+	rows, err := s.db.Query(`
+		SELECT o.id, o.name, o.created_at, m.role
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.user_id = $1
+		ORDER BY o.name ASC, o.id ASC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations: %v", err)
+	}
+	defer rows.Close()
+
+	var memberships []*Membership
+	for rows.Next() {
+		var org db.Organization
+		membership := &Membership{Organization: &org}
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt, &membership.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (s *sqlOrganizations) Member(organizationID, userID int) (*db.OrganizationMember, error) {
+	return s.getMember(`
+		SELECT `+memberColumns+` FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2
+	`, organizationID, userID)
+}
+
+func (s *sqlOrganizations) ListMembers(organizationID int) ([]*db.OrganizationMember, error) {
+	rows, err := s.db.Query(`
+		SELECT m.organization_id, m.user_id, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY u.email ASC
+	`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization members: %v", err)
+	}
+	defer rows.Close()
+
+	var members []*db.OrganizationMember
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (s *sqlOrganizations) AddMember(organizationID, userID int, role string) (*db.OrganizationMember, error) {
+	member, err := scanMember(s.db.QueryRow(`
+		INSERT INTO organization_members (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+		RETURNING `+memberColumns, organizationID, userID, role))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMemberExists
+		}
+		return nil, fmt.Errorf("failed to add organization member: %v", err)
+	}
+
+	return member, nil
+}
+
+func (s *sqlOrganizations) SetMemberRole(organizationID, userID int, role string) (*db.OrganizationMember, error) {
+	return s.getMember(`
+		UPDATE organization_members SET role = $3
+		WHERE organization_id = $1 AND user_id = $2
+		RETURNING `+memberColumns, organizationID, userID, role)
+}
+
+func (s *sqlOrganizations) RemoveMember(organizationID, userID int) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`, organizationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove organization member: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// getMember runs a query selecting a single organization member
+func (s *sqlOrganizations) getMember(query string, args ...any) (*db.OrganizationMember, error) {
+	member, err := scanMember(s.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization member: %v", err)
+	}
+
+	return member, nil
+}
+
+// sqlAssets stores assets in the assets table
+type sqlAssets struct {
+	db *db.DB
+}
+
+const assetColumns = `id, user_id, organization_id, name, target, asset_type, scan_engine, scan_profile_id, group_id,
+	criticality, internet_facing, risk_score, risk_severity, risk_scored_at, created_at, last_scanned_at`
+
+// scanAsset scans an assets row selected with assetColumns
+func scanAsset(row interface{ Scan(...any) error }) (*db.Asset, error) {
+	var asset db.Asset
+	err := row.Scan(
+		&asset.ID,
+		&asset.UserID,
+		&asset.OrganizationID,
+		&asset.Name,
+		&asset.Target,
+		&asset.AssetType,
+		&asset.ScanEngine,
+		&asset.ScanProfileID,
+		&asset.GroupID,
+		&asset.Criticality,
+		&asset.InternetFacing,
+		&asset.RiskScore,
+		&asset.RiskSeverity,
+		&asset.RiskScoredAt,
+		&asset.CreatedAt,
+		&asset.LastScannedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// queryAssets runs a query selecting assetColumns
+func (s *sqlAssets) queryAssets(query string, args ...any) ([]*db.Asset, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assets: %v", err)
+	}
+	defer rows.Close()
+
+	var assets []*db.Asset
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, rows.Err()
+}
+
+func (s *sqlAssets) Create(asset *db.Asset) (*db.Asset, error) {
+	query := `
+		INSERT INTO assets (user_id, organization_id, name, target, asset_type, scan_engine, scan_profile_id, group_id, criticality, internet_facing, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		RETURNING ` + assetColumns
+
+	created, err := scanAsset(s.db.QueryRow(query, asset.UserID, asset.OrganizationID, asset.Name, asset.Target, asset.AssetType, asset.ScanEngine,
+		asset.ScanProfileID, asset.GroupID, asset.Criticality, asset.InternetFacing))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create asset: %v", err)
+	}
+
+	return created, nil
+}
+
+func (s *sqlAssets) Get(id int) (*db.Asset, error) {
+	asset, err := scanAsset(s.db.QueryRow(`SELECT `+assetColumns+` FROM assets WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAssetNotFound
+		}
+		return nil, fmt.Errorf("failed to get asset: %v", err)
+	}
+
+	return asset, nil
+}
+
+func (s *sqlAssets) GetMany(ids []int) (map[int]*db.Asset, error) {
+	c := &conditions{}
+	clause, args := inClause("id", ids)
+	c.add(clause, args...)
+
+	assets, err := s.queryAssets(`SELECT `+assetColumns+` FROM assets`+c.where(), c.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[int]*db.Asset, len(assets))
+	for _, asset := range assets {
+		found[asset.ID] = asset
+	}
+	return found, nil
+}
+
+// assetSortColumns maps sort fields to the assets column they sort by
+var assetSortColumns = map[AssetSort]string{
+	AssetSortName:          "a.name",
+	AssetSortTarget:        "a.target",
+	AssetSortAssetType:     "a.asset_type",
+	AssetSortCreatedAt:     "a.created_at",
+	AssetSortLastScannedAt: "a.last_scanned_at",
+	AssetSortRiskScore:     "a.risk_score",
+}
+
+// assetConditions returns the conditions selecting the organization's assets that match filter
+func assetConditions(organizationID int, filter AssetFilter) *conditions {
+	c := &conditions{}
+	c.add("a.organization_id = ?", organizationID)
+	if filter.AssetType != "" {
+		c.add("a.asset_type = ?", filter.AssetType)
+	}
+	if filter.Target != "" {
+		c.add(`LOWER(a.target) LIKE ? ESCAPE '\'`, containsPattern(filter.Target))
+	}
+	if filter.CreatedAfter != nil {
+		c.add("a.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		c.add("a.created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.ScanStatus != "" {
+		c.add(`(
+			SELECT s.status FROM scans s WHERE s.asset_id = a.id ORDER BY s.queued_at DESC, s.id DESC LIMIT 1
+		) = ?`, filter.ScanStatus)
+	}
+	if filter.OpenPort != nil || filter.Service != "" {
+		clause, args := openResultClause(filter.OpenPort, filter.Service)
+		c.add(`EXISTS (
+			SELECT 1 FROM scan_results r
+			WHERE r.scan_id = (SELECT MAX(s.id) FROM scans s WHERE s.asset_id = a.id AND s.status = ?)
+			AND `+clause+`
+		)`, append([]any{ScanStatusCompleted}, args...)...)
+	}
+	return c
+}
+
+func (s *sqlAssets) Count(organizationID int, filter AssetFilter) (int, error) {
+	c := assetConditions(organizationID, filter)
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM assets a`+c.where(), c.args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count assets: %v", err)
+	}
+
+	return count, nil
+}
+
+func (s *sqlAssets) Find(organizationID int, filter AssetFilter, order AssetOrder, page Page) ([]*db.Asset, error) {
+	c := assetConditions(organizationID, filter)
+	column, ok := assetSortColumns[order.Field]
+	if !ok {
+		column = assetSortColumns[AssetSortCreatedAt]
+	}
+
+	query := `SELECT ` + assetColumns + ` FROM assets a` + c.where() +
+		orderBy(column, "a.id", order.Desc) + c.limit(page)
+	return s.queryAssets(query, c.args...)
+}
+
+func (s *sqlAssets) ListByGroup(organizationID, groupID int) ([]*db.Asset, error) {
+	return s.queryAssets(`SELECT `+assetColumns+` FROM assets WHERE group_id = $1 AND organization_id = $2 ORDER BY name ASC, id ASC`, groupID, organizationID)
+}
+
+func (s *sqlAssets) ListByOrganization(organizationID int) ([]*db.Asset, error) {
+	return s.queryAssets(`SELECT `+assetColumns+` FROM assets WHERE organization_id = $1 ORDER BY name ASC, id ASC`, organizationID)
+}
+
+func (s *sqlAssets) Update(asset *db.Asset) error {
+	result, err := s.db.Exec(`
+		UPDATE assets
+		SET name = $1, target = $2, asset_type = $3, scan_engine = $4, scan_profile_id = $5, group_id = $6,
+			criticality = $7, internet_facing = $8
+		WHERE id = $9
+	`, asset.Name, asset.Target, asset.AssetType, asset.ScanEngine, asset.ScanProfileID, asset.GroupID,
+		asset.Criticality, asset.InternetFacing, asset.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update asset: %v", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrAssetNotFound
+	}
+	return nil
+}
+
+func (s *sqlAssets) Delete(organizationID, id int) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM assets WHERE id = $1 AND organization_id = $2`, id, organizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete asset: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s *sqlAssets) SetLastScanned(id int, at time.Time) error {
+	if _, err := s.db.Exec(`UPDATE assets SET last_scanned_at = $1 WHERE id = $2`, at, id); err != nil {
+		return fmt.Errorf("failed to update asset: %v", err)
+	}
+	return nil
+}
+
+// sqlAssetGroups stores asset groups in the asset_groups table
+type sqlAssetGroups struct {
+	db *db.DB
+}
+
+const assetGroupColumns = `id, user_id, organization_id, name, created_at`
+
+// scanAssetGroup scans an asset_groups row selected with assetGroupColumns
+func scanAssetGroup(row interface{ Scan(...any) error }) (*db.AssetGroup, error) {
+	var group db.AssetGroup
+	if err := row.Scan(&group.ID, &group.UserID, &group.OrganizationID, &group.Name, &group.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (s *sqlAssetGroups) Create(userID, organizationID int, name string) (*db.AssetGroup, error) {
+	query := `
+		INSERT INTO asset_groups (user_id, organization_id, name, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING ` + assetGroupColumns
+
+	group, err := scanAssetGroup(s.db.QueryRow(query, userID, organizationID, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create asset group: %v", err)
+	}
+
+	return group, nil
+}
+
+func (s *sqlAssetGroups) Get(id int) (*db.AssetGroup, error) {
+	group, err := scanAssetGroup(s.db.QueryRow(`SELECT `+assetGroupColumns+` FROM asset_groups WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAssetGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to get asset group: %v", err)
+	}
+
+	return group, nil
+}
+
+func (s *sqlAssetGroups) ListByOrganization(organizationID int) ([]*db.AssetGroup, error) {
+	rows, err := s.db.Query(`SELECT `+assetGroupColumns+` FROM asset_groups WHERE organization_id = $1 ORDER BY name ASC, id ASC`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset groups: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []*db.AssetGroup
+	for rows.Next() {
+		group, err := scanAssetGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+func (s *sqlAssetGroups) Delete(organizationID, id int) (bool, error) {
+	// Member assets leave the group through ON DELETE SET NULL
+	result, err := s.db.Exec(`DELETE FROM asset_groups WHERE id = $1 AND organization_id = $2`, id, organizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete asset group: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// sqlScans stores scans in the scans table
+type sqlScans struct {
+	db *db.DB
+}
+
+const scanColumns = `s.id, s.asset_id, s.requested_by, s.status, s.engine, s.profile_id, s.attempts,
+	s.queued_at, s.started_at, s.completed_at, s.duration_ms, s.error_code, s.error_message, s.progress,
+	s.estimated_completion, s.claimed_by, s.lease_expires_at`
+
+// returnedScanColumns are scanColumns unqualified, since SQLite RETURNING
+// clauses can't refer to a table alias
+const returnedScanColumns = `id, asset_id, requested_by, status, engine, profile_id, attempts, queued_at,
+	started_at, completed_at, duration_ms, error_code, error_message, progress,
+	estimated_completion, claimed_by, lease_expires_at`
+
+// scanScan scans a scans row selected with scanColumns or returnedScanColumns
+func scanScan(row interface{ Scan(...any) error }) (*Scan, error) {
+	var scan Scan
+	err := row.Scan(
+		&scan.ID,
+		&scan.AssetID,
+		&scan.RequestedBy,
+		&scan.Status,
+		&scan.Engine,
+		&scan.ProfileID,
+		&scan.Attempts,
+		&scan.QueuedAt,
+		&scan.StartedAt,
+		&scan.CompletedAt,
+		&scan.DurationMS,
+		&scan.ErrorCode,
+		&scan.ErrorMessage,
+		&scan.Progress,
+		&scan.EstimatedCompletion,
+		&scan.ClaimedBy,
+		&scan.LeaseExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// queryScans runs a query selecting scanColumns
+func (s *sqlScans) queryScans(query string, args ...any) ([]*Scan, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scans: %v", err)
+	}
+	defer rows.Close()
+
+	var scans []*Scan
+	for rows.Next() {
+		scan, err := scanScan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		scans = append(scans, scan)
+	}
+
+	return scans, rows.Err()
+}
+
+func (s *sqlScans) Create(assetID, requestedBy int, engine string, profileID *int) (*Scan, error) {
+	return createScan(s.db, assetID, requestedBy, engine, profileID)
+}
+
+// CreateScanTx queues a new scan like ScanStore.Create as part of tx, so no
+// worker can claim it until tx commits
+func CreateScanTx(tx *sql.Tx, assetID, requestedBy int, engine string, profileID *int) (*Scan, error) {
+	return createScan(tx, assetID, requestedBy, engine, profileID)
+}
+
+// createScan inserts a pending scan through q, a database or a transaction
+func createScan(q rowQuerier, assetID, requestedBy int, engine string, profileID *int) (*Scan, error) {
+	query := `
+		INSERT INTO scans (asset_id, requested_by, status, engine, profile_id, queued_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + returnedScanColumns
+
+	scan, err := scanScan(q.QueryRow(query, assetID, requestedBy, ScanStatusPending, engine, profileID, time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan: %v", err)
+	}
+
+	return scan, nil
+}
+
+func (s *sqlScans) Get(id int) (*Scan, error) {
+	scan, err := scanScan(s.db.QueryRow(`SELECT `+scanColumns+` FROM scans s WHERE s.id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrScanNotFound
+		}
+		return nil, fmt.Errorf("failed to get scan: %v", err)
+	}
+
+	return scan, nil
+}
+
+func (s *sqlScans) ListByAsset(assetID int) ([]*Scan, error) {
+	return s.queryScans(`SELECT `+scanColumns+` FROM scans s WHERE s.asset_id = $1 ORDER BY s.queued_at DESC, s.id DESC`, assetID)
+}
+
+func (s *sqlScans) CountByAssets(assetIDs []int) (map[int]int, error) {
+	c := &conditions{}
+	clause, args := inClause("asset_id", assetIDs)
+	c.add(clause, args...)
+
+	rows, err := s.db.Query(`SELECT asset_id, COUNT(*) FROM scans`+c.where()+` GROUP BY asset_id`, c.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count scans: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int, len(assetIDs))
+	for rows.Next() {
+		var assetID, count int
+		if err := rows.Scan(&assetID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		counts[assetID] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *sqlScans) FindByAssets(assetIDs []int, page Page) (map[int][]*Scan, error) {
+	c := &conditions{}
+	clause, args := inClause("asset_id", assetIDs)
+	c.add(clause, args...)
+	assets := c.where()
+	c.args = append(c.args, page.Offset, page.Offset+page.Limit)
+
+	// Each asset's scans are numbered newest first, so one query pages them all
+	query := `
+		SELECT ` + scanColumns + ` FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY asset_id ORDER BY queued_at DESC, id DESC) AS position
+			FROM scans` + assets + `
+		) s` + fmt.Sprintf(" WHERE s.position > $%d AND s.position <= $%d", len(c.args)-1, len(c.args)) + `
+		ORDER BY s.asset_id, s.position`
+	scans, err := s.queryScans(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byAsset := make(map[int][]*Scan, len(assetIDs))
+	for _, scan := range scans {
+		byAsset[scan.AssetID] = append(byAsset[scan.AssetID], scan)
+	}
+	return byAsset, nil
+}
+
+// scanSortColumns maps sort fields to the scans column they sort by
+var scanSortColumns = map[ScanSort]string{
+	ScanSortQueuedAt:    "s.queued_at",
+	ScanSortStartedAt:   "s.started_at",
+	ScanSortCompletedAt: "s.completed_at",
+	ScanSortDuration:    "s.duration_ms",
+	ScanSortStatus:      "s.status",
+}
+
+// scanConditions returns the conditions selecting the scans of the
+// organization's assets that match filter, for queries joining scans s to assets a
+func scanConditions(organizationID int, filter ScanFilter) *conditions {
+	c := &conditions{}
+	c.add("a.organization_id = ?", organizationID)
+	if filter.AssetID != nil {
+		c.add("s.asset_id = ?", *filter.AssetID)
+	}
+	if filter.Status != "" {
+		c.add("s.status = ?", filter.Status)
+	}
+	if filter.QueuedAfter != nil {
+		c.add("s.queued_at >= ?", *filter.QueuedAfter)
+	}
+	if filter.QueuedBefore != nil {
+		c.add("s.queued_at < ?", *filter.QueuedBefore)
+	}
+	if filter.AssetType != "" {
+		c.add("a.asset_type = ?", filter.AssetType)
+	}
+	if filter.Target != "" {
+		c.add(`LOWER(a.target) LIKE ? ESCAPE '\'`, containsPattern(filter.Target))
+	}
+	if filter.OpenPort != nil || filter.Service != "" {
+		clause, args := openResultClause(filter.OpenPort, filter.Service)
+		c.add(`EXISTS (SELECT 1 FROM scan_results r WHERE r.scan_id = s.id AND `+clause+`)`, args...)
+	}
+	return c
+}
+
+func (s *sqlScans) Count(organizationID int, filter ScanFilter) (int, error) {
+	c := scanConditions(organizationID, filter)
+
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM scans s JOIN assets a ON a.id = s.asset_id`+c.where(), c.args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count scans: %v", err)
+	}
+
+	return count, nil
+}
+
+func (s *sqlScans) Find(organizationID int, filter ScanFilter, order ScanOrder, page Page) ([]*Scan, error) {
+	c := scanConditions(organizationID, filter)
+	column, ok := scanSortColumns[order.Field]
+	if !ok {
+		column = scanSortColumns[ScanSortQueuedAt]
+	}
+
+	query := `SELECT ` + scanColumns + ` FROM scans s JOIN assets a ON a.id = s.asset_id` + c.where() +
+		orderBy(column, "s.id", order.Desc) + c.limit(page)
+	return s.queryScans(query, c.args...)
+}
+
+func (s *sqlScans) OrganizationID(scanID int) (int, error) {
+	var organizationID int
+	err := s.db.QueryRow(`
+		SELECT a.organization_id
+		FROM scans s
+		JOIN assets a ON a.id = s.asset_id
+		WHERE s.id = $1
+	`, scanID).Scan(&organizationID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrScanNotFound
+		}
+		return 0, fmt.Errorf("failed to get scan: %v", err)
+	}
+
+	return organizationID, nil
+}
+
+func (s *sqlScans) HasActive(assetID int) (bool, error) {
+	return hasActiveScan(s.db, assetID)
+}
+
+// HasActiveScanTx is ScanStore.HasActive inside tx, seeing the scans it queued
+func HasActiveScanTx(tx *sql.Tx, assetID int) (bool, error) {
+	return hasActiveScan(tx, assetID)
+}
+
+// hasActiveScan checks for a queued or running scan of the asset through q
+func hasActiveScan(q rowQuerier, assetID int) (bool, error) {
+	var active bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM scans WHERE asset_id = $1 AND status IN ($2, $3))
+	`, assetID, ScanStatusPending, ScanStatusRunning).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("failed to check active scans: %v", err)
+	}
+
+	return active, nil
+}
+
+func (s *sqlScans) PreviousCompleted(scanID int) (int, error) {
+	var previousID int
+	err := s.db.QueryRow(`
+		SELECT id FROM scans
+		WHERE asset_id = (SELECT asset_id FROM scans WHERE id = $1)
+		  AND status = $2 AND id < $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, scanID, ScanStatusCompleted).Scan(&previousID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find previous scan: %v", err)
+	}
+
+	return previousID, nil
+}
+
+func (s *sqlScans) ClaimNext(owner string, perUserLimit int, lease time.Duration) (*Scan, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Scans whose requester has been deleted are not capped
+	var scanID int
+	var userID *int
+	err = tx.QueryRow(`
+		SELECT s.id, s.requested_by
+		FROM scans s
+		WHERE s.status = $1
+		  AND (
+			s.requested_by IS NULL OR (
+				SELECT COUNT(*) FROM scans r
+				WHERE r.status = $2 AND r.requested_by = s.requested_by
+			) < $3
+		  )
+		ORDER BY s.queued_at ASC, s.id ASC
+		FOR UPDATE OF s SKIP LOCKED
+		LIMIT 1
+	`, ScanStatusPending, ScanStatusRunning, perUserLimit).Scan(&scanID, &userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select pending scan: %v", err)
+	}
+
+	// Serialise claims per requester and re-check the limit, since another
+	// worker may have claimed one of their scans after the count above. SQLite
+	// transactions already hold the database's write lock.
+	if userID != nil {
+		if s.db.Dialect == db.Postgres {
+			if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, *userID); err != nil {
+				return nil, fmt.Errorf("failed to lock user queue: %v", err)
+			}
+		}
+		var running int
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM scans s
+			WHERE s.status = $1 AND s.requested_by = $2
+		`, ScanStatusRunning, *userID).Scan(&running)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count running scans: %v", err)
+		}
+		if running >= perUserLimit {
+			return nil, nil
+		}
+	}
+
+	now := time.Now()
+	scan, err := scanScan(tx.QueryRow(`
+		UPDATE scans
+		SET status = $1, attempts = attempts + 1, started_at = $2, completed_at = NULL, duration_ms = NULL,
+			progress = NULL, estimated_completion = NULL, claimed_by = $3, lease_expires_at = $4
+		WHERE id = $5
+		RETURNING `+returnedScanColumns, ScanStatusRunning, now, owner, now.Add(lease), scanID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scan: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return scan, nil
+}
+
+func (s *sqlScans) CancelPending(scanID int) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE scans SET status = $1, completed_at = $2
+		WHERE id = $3 AND status = $4
+	`, ScanStatusCancelled, time.Now(), scanID, ScanStatusPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel scan: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+func (s *sqlScans) SetProgress(scanID int, progress float64, estimatedCompletion *time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE scans SET progress = $1, estimated_completion = $2
+		WHERE id = $3 AND status = $4
+	`, progress, estimatedCompletion, scanID, ScanStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update scan progress: %v", err)
+	}
+
+	return nil
+}
+
+func (s *sqlScans) Requeue(scanID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE scans
+		SET status = $1, started_at = NULL, completed_at = NULL, duration_ms = NULL,
+			progress = NULL, estimated_completion = NULL, claimed_by = NULL, lease_expires_at = NULL
+		WHERE id = $2 AND status = $3
+	`, ScanStatusPending, scanID, ScanStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to requeue scan: %v", err)
+	}
+	if requeued, _ := result.RowsAffected(); requeued == 0 {
+		return nil
+	}
+
+	// Drop the results the interrupted run stored so they aren't repeated
+	if _, err := tx.Exec(`DELETE FROM scan_results WHERE scan_id = $1`, scanID); err != nil {
+		return fmt.Errorf("failed to delete partial scan results: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (s *sqlScans) Finish(scanID int, status ScanStatus, scanErr *ScanError) (bool, error) {
+	var errorCode, errorMessage *string
+	if scanErr != nil {
+		errorCode, errorMessage = &scanErr.Code, &scanErr.Message
+	}
+
+	// Completed scans are all the way done; others keep the progress they reached
+	result, err := s.db.Exec(`
+		UPDATE scans
+		SET status = $1, completed_at = $2,
+			duration_ms = `+s.db.MillisBetween("started_at", "$2")+`,
+			error_code = $3, error_message = $4,
+			progress = CASE WHEN $6 THEN 100 ELSE progress END,
+			estimated_completion = NULL, lease_expires_at = NULL
+		WHERE id = $5 AND status = $7
+	`, status, time.Now(), errorCode, errorMessage, scanID, status == ScanStatusCompleted, ScanStatusRunning)
+	if err != nil {
+		return false, fmt.Errorf("failed to update scan status: %v", err)
+	}
+
+	finished, _ := result.RowsAffected()
+	return finished > 0, nil
+}
+
+func (s *sqlScans) RenewLeases(owner string, lease time.Duration) ([]int, error) {
+	rows, err := s.db.Query(`
+		UPDATE scans SET lease_expires_at = $1
+		WHERE status = $2 AND claimed_by = $3
+		RETURNING id
+	`, time.Now().Add(lease), ScanStatusRunning, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew scan leases: %v", err)
+	}
+	defer rows.Close()
+
+	var scanIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		scanIDs = append(scanIDs, id)
+	}
+
+	return scanIDs, rows.Err()
+}
+
+func (s *sqlScans) RecoverOrphaned(maxAttempts int, scanErr ScanError) (requeued, failed int64, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the expired scans so a server renewing its leases at the same time
+	// finds they are no longer running, and another recovering server skips them
+	now := time.Now()
+	rows, err := tx.Query(`
+		SELECT s.id, s.attempts FROM scans s
+		WHERE s.status = $1 AND (s.lease_expires_at IS NULL OR s.lease_expires_at < $2)
+		FOR UPDATE OF s SKIP LOCKED
+	`, ScanStatusRunning, now)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find orphaned scans: %v", err)
+	}
+	attempts := make(map[int]int)
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan row: %v", err)
+		}
+		attempts[id] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to find orphaned scans: %v", err)
+	}
+
+	for id, count := range attempts {
+		if count >= maxAttempts {
+			_, err := tx.Exec(`
+				UPDATE scans
+				SET status = $1, completed_at = $2,
+					duration_ms = `+s.db.MillisBetween("started_at", "$2")+`,
+					error_code = $3, error_message = $4, lease_expires_at = NULL
+				WHERE id = $5
+			`, ScanStatusFailed, now, scanErr.Code, scanErr.Message, id)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to fail orphaned scan: %v", err)
+			}
+			failed++
+			continue
+		}
+
+		if _, err := tx.Exec(`DELETE FROM scan_results WHERE scan_id = $1`, id); err != nil {
+			return 0, 0, fmt.Errorf("failed to delete partial scan results: %v", err)
+		}
+		_, err := tx.Exec(`
+			UPDATE scans
+			SET status = $1, started_at = NULL, progress = NULL, estimated_completion = NULL,
+				claimed_by = NULL, lease_expires_at = NULL
+			WHERE id = $2
+		`, ScanStatusPending, id)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to requeue orphaned scan: %v", err)
+		}
+		requeued++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return requeued, failed, nil
+}
+
+// sqlResults stores scan results in the scan_results table
+type sqlResults struct {
+	db *db.DB
+}
+
+func (s *sqlResults) Insert(scanID int, results []ScanResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO scan_results (scan_id, host, hostname, port, protocol, state, service, version, banner, cpes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, result := range results {
+		protocol := result.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		_, err = stmt.Exec(
+			scanID,
+			result.Host,
+			result.Hostname,
+			result.Port,
+			protocol,
+			result.State,
+			result.Service,
+			result.Version,
+			result.Banner,
+			strings.Join(result.CPEs, " "),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert scan result: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (s *sqlResults) ListByScan(scanID int) ([]ScanResult, error) {
+	results, err := s.ListByScans([]int{scanID})
+	if err != nil {
+		return nil, err
+	}
+	return results[scanID], nil
+}
+
+func (s *sqlResults) ListByScans(scanIDs []int) (map[int][]ScanResult, error) {
+	c := &conditions{}
+	clause, args := inClause("scan_id", scanIDs)
+	c.add(clause, args...)
+
+	rows, err := s.db.Query(`
+		SELECT scan_id, id, COALESCE(host, ''), COALESCE(hostname, ''), port, protocol, state, COALESCE(service, ''),
+			COALESCE(version, ''), COALESCE(banner, ''), COALESCE(cpes, ''), risk_score, risk_severity
+		FROM scan_results`+c.where()+`
+		ORDER BY scan_id, host ASC, port ASC, protocol ASC
+	`, c.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan results: %v", err)
+	}
+	defer rows.Close()
+
+	results := make(map[int][]ScanResult, len(scanIDs))
+	for rows.Next() {
+		var scanID int
+		var result ScanResult
+		var cpes string
+		err := rows.Scan(
+			&scanID,
+			&result.ID,
+			&result.Host,
+			&result.Hostname,
+			&result.Port,
+			&result.Protocol,
+			&result.State,
+			&result.Service,
+			&result.Version,
+			&result.Banner,
+			&cpes,
+			&result.RiskScore,
+			&result.RiskSeverity,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		result.CPEs = strings.Fields(cpes)
+		results[scanID] = append(results[scanID], result)
+	}
+
+	return results, rows.Err()
+}
+
+// conditions collects the WHERE clauses of a filtered query and their arguments
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+// add appends a clause, numbering each ? placeholder after the arguments added so far
+func (c *conditions) add(clause string, args ...any) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		clause = strings.Replace(clause, "?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+// where returns the WHERE clause joining every condition
+func (c *conditions) where() string {
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// limit returns the LIMIT and OFFSET clause selecting page, adding its arguments
+func (c *conditions) limit(page Page) string {
+	c.args = append(c.args, page.Limit, page.Offset)
+	return fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(c.args)-1, len(c.args))
+}
+
+// inClause returns the condition matching rows whose column is one of ids
+func inClause(column string, ids []int) (string, []any) {
+	if len(ids) == 0 {
+		return "1 = 0", nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return column + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args
+}
+
+// orderBy sorts by column with NULLs last, then by the ID column in the same direction
+func orderBy(column, idColumn string, desc bool) string {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY (%s IS NULL), %s %s, %s %s", column, column, direction, idColumn, direction)
+}
+
+// openResultClause returns the condition matching an open scan_results r row
+// on the port and service, ignoring case, that are set
+func openResultClause(port *int, service string) (string, []any) {
+	clause := "r.state = 'open'"
+	var args []any
+	if port != nil {
+		clause += " AND r.port = ?"
+		args = append(args, *port)
+	}
+	if service != "" {
+		clause += " AND LOWER(r.service) = ?"
+		args = append(args, strings.ToLower(service))
+	}
+	return clause, args
+}
+
+// containsPattern returns a LIKE pattern matching lowercase values containing
+// substr, escaping LIKE's wildcards
+func containsPattern(substr string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(substr))
+	return "%" + escaped + "%"
+}
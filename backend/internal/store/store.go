@@ -0,0 +1,320 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+)
+
+// Errors returned when a record does not exist
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrMemberNotFound       = errors.New("user is not a member of the organization")
+	ErrAssetNotFound        = errors.New("asset not found")
+	ErrAssetGroupNotFound   = errors.New("asset group not found")
+	ErrScanNotFound         = errors.New("scan not found")
+)
+
+// ErrMemberExists is returned when adding a user to an organization they already belong to
+var ErrMemberExists = errors.New("user is already a member of the organization")
+
+// ScanStatus represents the current status of a scan
+type ScanStatus string
+
+const (
+	ScanStatusPending   ScanStatus = "pending"
+	ScanStatusRunning   ScanStatus = "running"
+	ScanStatusCompleted ScanStatus = "completed"
+	ScanStatusFailed    ScanStatus = "failed"
+	ScanStatusCancelled ScanStatus = "cancelled"
+)
+
+// Scan represents a scan record. QueuedAt is when the scan was requested,
+// StartedAt when a worker began running it and CompletedAt when it reached a
+// final status; DurationMS is the time between the last two. Progress and
+// EstimatedCompletion are reported by engines that can tell while a scan runs.
+type Scan struct {
+	ID      int `json:"id"`
+	AssetID int `json:"assetId"`
+	// RequestedBy is the user who queued the scan, nil once they are deleted
+	RequestedBy  *int       `json:"requestedBy,omitempty"`
+	Status       ScanStatus `json:"status"`
+	Engine       string     `json:"engine"`
+	ProfileID    *int       `json:"profileId,omitempty"`
+	Attempts     int        `json:"attempts"`
+	QueuedAt     time.Time  `json:"queuedAt"`
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
+	CompletedAt  *time.Time `json:"completedAt,omitempty"`
+	DurationMS   *int64     `json:"durationMs,omitempty"`
+	ErrorCode    *string    `json:"errorCode,omitempty"`
+	ErrorMessage *string    `json:"errorMessage,omitempty"`
+	// Progress is the percentage of the scan done, 0-100
+	Progress            *float64   `json:"progress,omitempty"`
+	EstimatedCompletion *time.Time `json:"estimatedCompletion,omitempty"`
+	// ClaimedBy is the queue owner of the server that last claimed the scan,
+	// which holds it while running until LeaseExpiresAt
+	ClaimedBy      *string    `json:"claimedBy,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty"`
+}
+
+// ScanError is the reason a scan failed
+type ScanError struct {
+	Code    string
+	Message string
+}
+
+// ScanResult represents a single port found by a scan
+type ScanResult struct {
+	ID       int    `json:"id,omitempty"`
+	Host     string `json:"host"`
+	Hostname string `json:"hostname"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	State    string `json:"state"`
+	Service  string `json:"service"`
+	Version  string `json:"version"`
+	Banner   string `json:"banner"`
+	// CPEs are the CPE 2.2 names of the detected product, used for vulnerability matching
+	CPEs []string `json:"cpes,omitempty"`
+	// RiskScore and RiskSeverity are set once the result's scan has been scored
+	RiskScore    *float64 `json:"riskScore,omitempty"`
+	RiskSeverity *string  `json:"riskSeverity,omitempty"`
+}
+
+// AssetFilter narrows a list of assets; zero fields match every asset
+type AssetFilter struct {
+	AssetType string
+	// Target matches assets whose target contains it, ignoring case
+	Target        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// ScanStatus matches the status of the asset's most recent scan
+	ScanStatus ScanStatus
+	// OpenPort and Service match an open port in the asset's most recent completed scan
+	OpenPort *int
+	Service  string
+}
+
+// ScanFilter narrows a list of scans; zero fields match every scan
+type ScanFilter struct {
+	AssetID      *int
+	Status       ScanStatus
+	QueuedAfter  *time.Time
+	QueuedBefore *time.Time
+	// AssetType and Target match the scanned asset like AssetFilter
+	AssetType string
+	Target    string
+	// OpenPort and Service match an open port found by the scan
+	OpenPort *int
+	Service  string
+}
+
+// AssetSort is a field assets can be sorted by
+type AssetSort string
+
+const (
+	AssetSortName          AssetSort = "NAME"
+	AssetSortTarget        AssetSort = "TARGET"
+	AssetSortAssetType     AssetSort = "ASSET_TYPE"
+	AssetSortCreatedAt     AssetSort = "CREATED_AT"
+	AssetSortLastScannedAt AssetSort = "LAST_SCANNED_AT"
+	AssetSortRiskScore     AssetSort = "RISK_SCORE"
+)
+
+// Valid reports whether assets can be sorted by the field
+func (f AssetSort) Valid() bool {
+	switch f {
+	case AssetSortName, AssetSortTarget, AssetSortAssetType, AssetSortCreatedAt, AssetSortLastScannedAt, AssetSortRiskScore:
+		return true
+	}
+	return false
+}
+
+// ScanSort is a field scans can be sorted by
+type ScanSort string
+
+const (
+	ScanSortQueuedAt    ScanSort = "QUEUED_AT"
+	ScanSortStartedAt   ScanSort = "STARTED_AT"
+	ScanSortCompletedAt ScanSort = "COMPLETED_AT"
+	ScanSortDuration    ScanSort = "DURATION"
+	ScanSortStatus      ScanSort = "STATUS"
+)
+
+// Valid reports whether scans can be sorted by the field
+func (f ScanSort) Valid() bool {
+	switch f {
+	case ScanSortQueuedAt, ScanSortStartedAt, ScanSortCompletedAt, ScanSortDuration, ScanSortStatus:
+		return true
+	}
+	return false
+}
+
+// AssetOrder sorts assets by a field, then by ID in the same direction.
+// Assets without a value for the field sort last.
+type AssetOrder struct {
+	Field AssetSort
+	Desc  bool
+}
+
+// ScanOrder sorts scans by a field, then by ID in the same direction.
+// Scans without a value for the field sort last.
+type ScanOrder struct {
+	Field ScanSort
+	Desc  bool
+}
+
+// Page is the window of a sorted list to return
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// Membership is an organization a user belongs to and their role in it
+type Membership struct {
+	Organization *db.Organization
+	Role         string
+}
+
+// UserStore stores user accounts
+type UserStore interface {
+	// Create stores a new user and returns it with its ID
+	Create(email, passwordHash string) (*db.User, error)
+	// Get returns ErrUserNotFound if the user does not exist
+	Get(id int) (*db.User, error)
+	// GetByEmail returns ErrUserNotFound if no user has the email
+	GetByEmail(email string) (*db.User, error)
+	// SetCurrentOrganization records the organization the user last switched to,
+	// returning ErrUserNotFound if the user does not exist
+	SetCurrentOrganization(id, organizationID int) error
+}
+
+// OrganizationStore stores organizations and the users that belong to them
+type OrganizationStore interface {
+	// Create stores a new organization with ownerID as its admin
+	Create(name string, ownerID int) (*db.Organization, error)
+	// Get returns ErrOrganizationNotFound if the organization does not exist
+	Get(id int) (*db.Organization, error)
+	// ListByUser returns the organizations the user belongs to, by name
+	ListByUser(userID int) ([]*Membership, error)
+	// Member returns ErrMemberNotFound if the user does not belong to the organization
+	Member(organizationID, userID int) (*db.OrganizationMember, error)
+	// ListMembers returns an organization's members, by email
+	ListMembers(organizationID int) ([]*db.OrganizationMember, error)
+	// AddMember adds a user to an organization, returning ErrMemberExists if
+	// they already belong to it
+	AddMember(organizationID, userID int, role string) (*db.OrganizationMember, error)
+	// SetMemberRole changes a member's role, returning ErrMemberNotFound if
+	// the user does not belong to the organization
+	SetMemberRole(organizationID, userID int, role string) (*db.OrganizationMember, error)
+	// RemoveMember removes a user from an organization, reporting whether they belonged to it
+	RemoveMember(organizationID, userID int) (bool, error)
+}
+
+// AssetStore stores the assets organizations scan
+type AssetStore interface {
+	// Create stores a new asset and returns it with its ID
+	Create(asset *db.Asset) (*db.Asset, error)
+	// Get returns ErrAssetNotFound if the asset does not exist
+	Get(id int) (*db.Asset, error)
+	// GetMany returns the assets with the IDs, leaving out those that do not exist
+	GetMany(ids []int) (map[int]*db.Asset, error)
+	// Count returns how many of the organization's assets match filter
+	Count(organizationID int, filter AssetFilter) (int, error)
+	// Find returns a page of the organization's assets that match filter, sorted by order
+	Find(organizationID int, filter AssetFilter, order AssetOrder, page Page) ([]*db.Asset, error)
+	// ListByGroup returns the organization's assets in a group, by name
+	ListByGroup(organizationID, groupID int) ([]*db.Asset, error)
+	// ListByOrganization returns the organization's assets, by name
+	ListByOrganization(organizationID int) ([]*db.Asset, error)
+	// Update stores an asset's name, target, type, engine, profile, group and
+	// risk context, returning ErrAssetNotFound if it does not exist
+	Update(asset *db.Asset) error
+	// Delete removes one of the organization's assets with its scans, reporting whether it existed
+	Delete(organizationID, id int) (bool, error)
+	// SetLastScanned records when the asset was last scanned
+	SetLastScanned(id int, at time.Time) error
+}
+
+// AssetGroupStore stores the groups organizations sort their assets into
+type AssetGroupStore interface {
+	// Create stores a new group and returns it with its ID
+	Create(userID, organizationID int, name string) (*db.AssetGroup, error)
+	// Get returns ErrAssetGroupNotFound if the group does not exist
+	Get(id int) (*db.AssetGroup, error)
+	// ListByOrganization returns the organization's groups, by name
+	ListByOrganization(organizationID int) ([]*db.AssetGroup, error)
+	// Delete removes one of the organization's groups, reporting whether it
+	// existed. Its assets are kept and leave the group.
+	Delete(organizationID, id int) (bool, error)
+}
+
+// ScanStore stores scans and their lifecycle
+type ScanStore interface {
+	// Create queues a new scan of an asset on behalf of the requesting user
+	Create(assetID, requestedBy int, engine string, profileID *int) (*Scan, error)
+	// Get returns ErrScanNotFound if the scan does not exist
+	Get(id int) (*Scan, error)
+	// ListByAsset returns an asset's scans, newest first
+	ListByAsset(assetID int) ([]*Scan, error)
+	// CountByAssets returns how many scans each asset has, leaving out assets with none
+	CountByAssets(assetIDs []int) (map[int]int, error)
+	// FindByAssets returns the same page of each asset's scans, newest first
+	FindByAssets(assetIDs []int, page Page) (map[int][]*Scan, error)
+	// Count returns how many scans of the organization's assets match filter
+	Count(organizationID int, filter ScanFilter) (int, error)
+	// Find returns a page of the scans of the organization's assets that match filter, sorted by order
+	Find(organizationID int, filter ScanFilter, order ScanOrder, page Page) ([]*Scan, error)
+	// OrganizationID returns the ID of the organization the scanned asset belongs to
+	OrganizationID(scanID int) (int, error)
+	// HasActive reports whether the asset has a scan that is queued or running
+	HasActive(assetID int) (bool, error)
+	// PreviousCompleted returns the ID of the asset's last completed scan
+	// before scanID, or 0 if there is none
+	PreviousCompleted(scanID int) (int, error)
+	// ClaimNext atomically moves the oldest pending scan whose requester has
+	// fewer than perUserLimit scans running to running, leased to owner for
+	// lease, or returns nil
+	ClaimNext(owner string, perUserLimit int, lease time.Duration) (*Scan, error)
+	// RenewLeases extends the leases of the running scans owner holds by lease
+	// and returns their IDs
+	RenewLeases(owner string, lease time.Duration) ([]int, error)
+	// CancelPending cancels a scan that is still queued, reporting whether it was
+	CancelPending(scanID int) (bool, error)
+	// SetProgress records how far a running scan has got and when it is
+	// expected to finish, if known
+	SetProgress(scanID int, progress float64, estimatedCompletion *time.Time) error
+	// Requeue puts a running scan back on the queue to be run again from the
+	// start, discarding the results it stored so far
+	Requeue(scanID int) error
+	// Finish records that a running scan reached a final status and, for failed
+	// scans, why. It reports false if the scan was no longer running, such as
+	// when it was cancelled while its engine was finishing.
+	Finish(scanID int, status ScanStatus, scanErr *ScanError) (bool, error)
+	// RecoverOrphaned re-queues running scans whose lease expired because the
+	// server holding them stopped, like Requeue, failing with scanErr those that
+	// have already used up maxAttempts. Scans still leased are left alone.
+	RecoverOrphaned(maxAttempts int, scanErr ScanError) (requeued, failed int64, err error)
+}
+
+// ResultStore stores the ports found by scans
+type ResultStore interface {
+	// Insert stores a scan's results
+	Insert(scanID int, results []ScanResult) error
+	// ListByScan returns a scan's results ordered by host, port and protocol
+	ListByScan(scanID int) ([]ScanResult, error)
+	// ListByScans returns each scan's results ordered by host, port and protocol
+	ListByScans(scanIDs []int) (map[int][]ScanResult, error)
+}
+
+// Store groups the stores the API, scan manager and exporters read and write through
+type Store struct {
+	Users         UserStore
+	Organizations OrganizationStore
+	Assets        AssetStore
+	AssetGroups   AssetGroupStore
+	Scans         ScanStore
+	Results       ResultStore
+}
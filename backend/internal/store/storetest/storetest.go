@@ -0,0 +1,132 @@
+package storetest
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cyber-risk-monitor/internal/auth"
+	"cyber-risk-monitor/internal/db"
+	"cyber-risk-monitor/internal/store"
+)
+
+// NewSQLite creates a migrated SQLite database that is removed when the test ends
+func NewSQLite(t testing.TB) *db.DB {
+	t.Helper()
+
+	database, err := db.NewConnection("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.RunMigrations(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+// Tenants are two organizations with one scanned asset each: Alpha, owned by
+// Alice with Carol as an auditor and Victor as a viewer, and Beta, owned by Bob
+type Tenants struct {
+	Alice, Bob, Carol, Victor *db.User
+	Alpha, Beta               *db.Organization
+	AlphaAsset, BetaAsset     *db.Asset
+}
+
+// NewTenants stores the tenants
+func NewTenants(t testing.TB, stores *store.Store) *Tenants {
+	t.Helper()
+
+	tt := &Tenants{
+		Alice:  CreateUser(t, stores, "alice@example.com"),
+		Bob:    CreateUser(t, stores, "bob@example.com"),
+		Carol:  CreateUser(t, stores, "carol@example.com"),
+		Victor: CreateUser(t, stores, "victor@example.com"),
+	}
+	tt.Alpha = CreateOrganization(t, stores, "Alpha", tt.Alice.ID)
+	tt.Beta = CreateOrganization(t, stores, "Beta", tt.Bob.ID)
+	AddMember(t, stores, tt.Alpha.ID, tt.Carol.ID, auth.RoleAuditor)
+	AddMember(t, stores, tt.Alpha.ID, tt.Victor.ID, auth.RoleViewer)
+
+	tt.AlphaAsset = CreateScannedAsset(t, stores, tt.Alice.ID, tt.Alpha.ID, "alpha-web", "10.0.0.1")
+	tt.BetaAsset = CreateScannedAsset(t, stores, tt.Bob.ID, tt.Beta.ID, "beta-db", "10.9.9.9")
+	return tt
+}
+
+// CreateUser stores a user with the email
+func CreateUser(t testing.TB, stores *store.Store, email string) *db.User {
+	t.Helper()
+
+	user, err := stores.Users.Create(email, "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// CreateOrganization stores an organization with ownerID as its admin
+func CreateOrganization(t testing.TB, stores *store.Store, name string, ownerID int) *db.Organization {
+	t.Helper()
+
+	org, err := stores.Organizations.Create(name, ownerID)
+	if err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	return org
+}
+
+// AddMember adds a user to an organization with the role
+func AddMember(t testing.TB, stores *store.Store, organizationID, userID int, role string) {
+	t.Helper()
+
+	if _, err := stores.Organizations.AddMember(organizationID, userID, role); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+}
+
+// CreateScannedAsset stores an asset with one completed scan that found port
+// 443 open, with the asset's Banner
+func CreateScannedAsset(t testing.TB, stores *store.Store, userID, organizationID int, name, target string) *db.Asset {
+	t.Helper()
+
+	asset, err := stores.Assets.Create(&db.Asset{
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Name:           name,
+		Target:         target,
+		AssetType:      "host",
+		ScanEngine:     "tcp",
+		Criticality:    "medium",
+	})
+	if err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+
+	scan, err := stores.Scans.Create(asset.ID, userID, "tcp", nil)
+	if err != nil {
+		t.Fatalf("create scan: %v", err)
+	}
+	claimed, err := stores.Scans.ClaimNext("storetest", math.MaxInt32, time.Hour)
+	if err != nil || claimed == nil || claimed.ID != scan.ID {
+		t.Fatalf("claim scan %d: got %v, %v", scan.ID, claimed, err)
+	}
+	result := store.ScanResult{Host: target, Port: 443, Protocol: "tcp", State: "open", Service: "https", Banner: Banner(asset)}
+	if err := stores.Results.Insert(scan.ID, []store.ScanResult{result}); err != nil {
+		t.Fatalf("insert results: %v", err)
+	}
+	if finished, err := stores.Scans.Finish(scan.ID, store.ScanStatusCompleted, nil); err != nil || !finished {
+		t.Fatalf("finish scan: %v, %v", finished, err)
+	}
+	return asset
+}
+
+// Banner is the banner found on the port of an asset CreateScannedAsset stored
+func Banner(asset *db.Asset) string {
+	return asset.Name + "-banner"
+}
+
+// Data is what only an export of an asset CreateScannedAsset stored contains
+func Data(asset *db.Asset) []string {
+	return []string{asset.Name, asset.Target, Banner(asset)}
+}
@@ -0,0 +1,221 @@
+package vuln
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// CPE holds the fields of a Common Platform Enumeration name used for matching
+type CPE struct {
+	Part    string
+	Vendor  string
+	Product string
+	Version string
+	Update  string
+}
+
+// ParseCPE parses a CPE 2.2 URI (cpe:/a:openbsd:openssh:8.2p1, as emitted by
+// nmap) or a CPE 2.3 formatted string (cpe:2.3:a:openbsd:openssh:8.2:p1:*:...,
+// as used by NVD). Missing fields are returned as "*".
+func ParseCPE(name string) (CPE, error) {
+	var fields []string
+
+	switch {
+	case strings.HasPrefix(name, "cpe:2.3:"):
+		fields = splitCPE23(strings.TrimPrefix(name, "cpe:2.3:"))
+	case strings.HasPrefix(name, "cpe:/"):
+		for _, field := range strings.Split(strings.TrimPrefix(name, "cpe:/"), ":") {
+			if decoded, err := url.PathUnescape(field); err == nil {
+				field = decoded
+			}
+			fields = append(fields, field)
+		}
+	default:
+		return CPE{}, fmt.Errorf("unsupported CPE name %q", name)
+	}
+
+	if len(fields) < 3 || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+		return CPE{}, fmt.Errorf("CPE name %q has no vendor or product", name)
+	}
+
+	field := func(i int) string {
+		if i >= len(fields) || fields[i] == "" {
+			return "*"
+		}
+		return strings.ToLower(fields[i])
+	}
+
+	return CPE{
+		Part:    field(0),
+		Vendor:  field(1),
+		Product: field(2),
+		Version: field(3),
+		Update:  field(4),
+	}, nil
+}
+
+// splitCPE23 splits a CPE 2.3 formatted string on unescaped colons and removes
+// the backslash escapes
+func splitCPE23(value string) []string {
+	var fields []string
+	var current strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value):
+			i++
+			current.WriteByte(value[i])
+		case c == ':':
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	return append(fields, current.String())
+}
+
+// FullVersion joins the version and update fields the way products such as
+// OpenSSH report them ("8.2" and "p1" become "8.2p1")
+func (c CPE) FullVersion() string {
+	if !isWildcard(c.Update) {
+		return c.Version + c.Update
+	}
+	return c.Version
+}
+
+// String formats the CPE as a CPE 2.2 URI
+func (c CPE) String() string {
+	name := "cpe:/" + c.Part + ":" + c.Vendor + ":" + c.Product
+	if !isWildcard(c.Version) {
+		name += ":" + c.Version
+		if !isWildcard(c.Update) {
+			name += ":" + c.Update
+		}
+	}
+	return name
+}
+
+// isWildcard reports whether a CPE field matches any value ("*") or no value ("-")
+func isWildcard(value string) bool {
+	return value == "" || value == "*" || value == "-"
+}
+
+// CompareVersions compares two version strings segment by segment, treating runs
+// of digits numerically ("8.10" > "8.9") and runs of letters lexically ("8.2p1" >
+// "8.2"). Pre-release markers such as "rc" and "beta" sort before the release
+// they lead up to ("1.0rc1" < "1.0") and in order of maturity ("1.0beta2" <
+// "1.0rc1"). It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	as, bs := versionSegments(a), versionSegments(b)
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareSegments(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+
+	// The longer version is newer unless what follows is a pre-release
+	switch {
+	case len(as) < len(bs):
+		if isPreRelease(bs[len(as)]) {
+			return 1
+		}
+		return -1
+	case len(as) > len(bs):
+		if isPreRelease(as[len(bs)]) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// preReleases ranks the letter segments that mark a version before its release
+var preReleases = map[string]int{
+	"dev":   1,
+	"alpha": 2,
+	"beta":  3,
+	"pre":   4,
+	"rc":    5,
+}
+
+// isPreRelease reports whether a version segment marks a pre-release
+func isPreRelease(segment string) bool {
+	return preReleases[segment] > 0
+}
+
+// versionSegments splits a version into alternating runs of digits and letters,
+// dropping separators
+func versionSegments(version string) []string {
+	var segments []string
+	var current []rune
+	var digits bool
+
+	flush := func() {
+		if len(current) > 0 {
+			segments = append(segments, string(current))
+			current = current[:0]
+		}
+	}
+
+	for _, r := range strings.ToLower(version) {
+		switch {
+		case unicode.IsDigit(r):
+			if !digits {
+				flush()
+			}
+			digits = true
+			current = append(current, r)
+		case unicode.IsLetter(r):
+			if digits {
+				flush()
+			}
+			digits = false
+			current = append(current, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return segments
+}
+
+// compareSegments compares two version segments; numeric segments sort after
+// alphabetic ones so "1.0" is newer than "1.rc", and pre-release markers sort
+// before other letters so "1.0p1" is newer than "1.0rc1"
+func compareSegments(a, b string) int {
+	aNum, bNum := unicode.IsDigit(rune(a[0])), unicode.IsDigit(rune(b[0]))
+
+	switch {
+	case aNum && bNum:
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	case aNum:
+		return 1
+	case bNum:
+		return -1
+	}
+
+	aPre, bPre := preReleases[a], preReleases[b]
+	switch {
+	case aPre > 0 && bPre > 0:
+		return cmp.Compare(aPre, bPre)
+	case aPre > 0:
+		return -1
+	case bPre > 0:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,122 @@
+package vuln
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.4.49", "2.4.49", 0},
+		{"1.01", "1.1", 0},
+		{"1.0-RC1", "1.0rc1", 0},
+		{"8.10", "8.9", 1},
+		{"2.4.50", "2.4.49", 1},
+		{"1.0.1", "1.0", 1},
+		{"8.2p1", "8.2", 1},
+		{"8.2p2", "8.2p1", 1},
+		{"1.0.2b", "1.0.2a", 1},
+		// Pre-releases come before the release
+		{"1.0", "1.0rc1", 1},
+		{"1.0", "1.0-beta", 1},
+		{"2.0", "2.0.dev3", 1},
+		{"1.0rc2", "1.0rc1", 1},
+		{"1.0rc1", "1.0beta2", 1},
+		{"1.0beta1", "1.0alpha3", 1},
+		{"1.0alpha1", "1.0dev1", 1},
+		{"1.0p1", "1.0rc1", 1},
+		{"1.0.1", "1.0rc1", 1},
+		{"1.0rc1", "0.9", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" vs "+test.b, func(t *testing.T) {
+			if got := CompareVersions(test.a, test.b); got != test.want {
+				t.Fatalf("CompareVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+			}
+			if got := CompareVersions(test.b, test.a); got != -test.want {
+				t.Fatalf("CompareVersions(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+			}
+		})
+	}
+}
+
+func TestParseCPE(t *testing.T) {
+	tests := []struct {
+		name string
+		want CPE
+	}{
+		{"cpe:/a:openbsd:openssh:8.2p1", CPE{Part: "a", Vendor: "openbsd", Product: "openssh", Version: "8.2p1", Update: "*"}},
+		{"cpe:/a:Apache:HTTP_Server", CPE{Part: "a", Vendor: "apache", Product: "http_server", Version: "*", Update: "*"}},
+		{"cpe:/a:foo%3abar:baz:1.0", CPE{Part: "a", Vendor: "foo:bar", Product: "baz", Version: "1.0", Update: "*"}},
+		{"cpe:2.3:a:openbsd:openssh:8.2:p1:*:*:*:*:*:*", CPE{Part: "a", Vendor: "openbsd", Product: "openssh", Version: "8.2", Update: "p1"}},
+		// Escaped colons stay in their field
+		{`cpe:2.3:a:foo\:bar:baz\:qux:1.0:*:*:*:*:*:*:*`, CPE{Part: "a", Vendor: "foo:bar", Product: "baz:qux", Version: "1.0", Update: "*"}},
+		{`cpe:2.3:a:vendor:product:1.0\:1:-:*:*:*:*:*:*`, CPE{Part: "a", Vendor: "vendor", Product: "product", Version: "1.0:1", Update: "-"}},
+		{`cpe:2.3:a:vendor:product\\:2.0`, CPE{Part: "a", Vendor: "vendor", Product: `product\`, Version: "2.0", Update: "*"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseCPE(test.name)
+			if err != nil {
+				t.Fatalf("ParseCPE(%q): %v", test.name, err)
+			}
+			if got != test.want {
+				t.Fatalf("ParseCPE(%q) = %+v, want %+v", test.name, got, test.want)
+			}
+		})
+	}
+
+	for _, name := range []string{"", "openssh", "cpe:/a:openbsd", "cpe:2.3:a::openssh:8.2", `cpe:2.3:a:openbsd\:openssh`} {
+		if _, err := ParseCPE(name); err == nil {
+			t.Errorf("ParseCPE(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestCPEMatchMatches(t *testing.T) {
+	openssh := CPE{Part: "a", Vendor: "openbsd", Product: "openssh", Version: "*", Update: "*"}
+	product := func(version, update string) CPE {
+		p := openssh
+		p.Version, p.Update = version, update
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		match   CPEMatch
+		product CPE
+		want    bool
+	}{
+		{"start including below", CPEMatch{CPE: openssh, VersionStartIncluding: "8.0"}, product("7.9", "*"), false},
+		{"start including at", CPEMatch{CPE: openssh, VersionStartIncluding: "8.0"}, product("8.0", "*"), true},
+		{"start including above", CPEMatch{CPE: openssh, VersionStartIncluding: "8.0"}, product("8.1", "*"), true},
+		{"start excluding below", CPEMatch{CPE: openssh, VersionStartExcluding: "8.0"}, product("7.9", "*"), false},
+		{"start excluding at", CPEMatch{CPE: openssh, VersionStartExcluding: "8.0"}, product("8.0", "*"), false},
+		{"start excluding above", CPEMatch{CPE: openssh, VersionStartExcluding: "8.0"}, product("8.0p1", "*"), true},
+		{"end including below", CPEMatch{CPE: openssh, VersionEndIncluding: "8.2"}, product("8.1", "*"), true},
+		{"end including at", CPEMatch{CPE: openssh, VersionEndIncluding: "8.2"}, product("8.2", "*"), true},
+		{"end including above", CPEMatch{CPE: openssh, VersionEndIncluding: "8.2"}, product("8.2", "p1"), false},
+		{"end excluding below", CPEMatch{CPE: openssh, VersionEndExcluding: "8.2"}, product("8.2rc1", "*"), true},
+		{"end excluding at", CPEMatch{CPE: openssh, VersionEndExcluding: "8.2"}, product("8.2", "*"), false},
+		{"end excluding above", CPEMatch{CPE: openssh, VersionEndExcluding: "8.2"}, product("8.3", "*"), false},
+		{"inside a range", CPEMatch{CPE: openssh, VersionStartIncluding: "7.0", VersionEndExcluding: "8.0"}, product("7.4", "*"), true},
+		{"outside a range", CPEMatch{CPE: openssh, VersionStartIncluding: "7.0", VersionEndExcluding: "8.0"}, product("8.0", "*"), false},
+		{"exact version", CPEMatch{CPE: product("8.2", "p1")}, product("8.2p1", "*"), true},
+		{"other update", CPEMatch{CPE: product("8.2", "p1")}, product("8.2", "p2"), false},
+		{"any update", CPEMatch{CPE: product("8.2", "*")}, product("8.2p1", "*"), true},
+		{"other version", CPEMatch{CPE: product("8.2", "*")}, product("8.3", "*"), false},
+		{"every version", CPEMatch{CPE: openssh}, product("1.0", "*"), true},
+		{"product without a version", CPEMatch{CPE: openssh}, openssh, false},
+		{"other product", CPEMatch{CPE: CPE{Part: "a", Vendor: "openbsd", Product: "openbgpd", Version: "*", Update: "*"}}, product("8.2", "*"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.match.Matches(test.product); got != test.want {
+				t.Fatalf("%+v.Matches(%+v) = %v, want %v", test.match, test.product, got, test.want)
+			}
+		})
+	}
+}
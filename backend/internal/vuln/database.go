@@ -0,0 +1,356 @@
+package vuln
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"cyber-risk-monitor/internal/db"
+)
+
+// Vulnerability is a known CVE matched to a discovered product
+type Vulnerability struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description"`
+	CVSSScore   *float64   `json:"cvssScore,omitempty"`
+	CVSSVersion string     `json:"cvssVersion,omitempty"`
+	CVSSVector  string     `json:"cvssVector,omitempty"`
+	Severity    string     `json:"severity"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	// CPE is the discovered product the vulnerability was matched against
+	CPE string `json:"cpe"`
+}
+
+// ImportStats summarises an NVD feed import
+type ImportStats struct {
+	CVEs    int `json:"cves"`
+	Matches int `json:"matches"`
+}
+
+// Database stores NVD data locally and matches scan results against it
+type Database struct {
+	db *db.DB
+}
+
+// NewDatabase creates a new Database backed by the cves and cve_cpe_matches tables
+func NewDatabase(database *db.DB) *Database {
+	return &Database{db: database}
+}
+
+// Import loads an NVD JSON feed, replacing any CVEs it already contains
+func (d *Database) Import(r io.Reader) (*ImportStats, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	upsertCVE, err := tx.Prepare(`
+		INSERT INTO cves (id, description, cvss_score, cvss_version, cvss_vector, severity, published_at, last_modified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET
+			description = EXCLUDED.description,
+			cvss_score = EXCLUDED.cvss_score,
+			cvss_version = EXCLUDED.cvss_version,
+			cvss_vector = EXCLUDED.cvss_vector,
+			severity = EXCLUDED.severity,
+			published_at = EXCLUDED.published_at,
+			last_modified_at = EXCLUDED.last_modified_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer upsertCVE.Close()
+
+	deleteMatches, err := tx.Prepare(`DELETE FROM cve_cpe_matches WHERE cve_id = $1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer deleteMatches.Close()
+
+	insertMatch, err := tx.Prepare(`
+		INSERT INTO cve_cpe_matches (cve_id, part, vendor, product, version, version_update,
+			version_start_including, version_start_excluding, version_end_including, version_end_excluding)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer insertMatch.Close()
+
+	var stats ImportStats
+	err = ParseFeed(r, func(cve *CVE) error {
+		if cve.ID == "" {
+			return nil
+		}
+
+		severity := cve.Severity
+		if severity == "" && cve.CVSSScore != nil {
+			severity = SeverityForScore(*cve.CVSSScore)
+		}
+
+		_, err := upsertCVE.Exec(cve.ID, cve.Description, cve.CVSSScore, cve.CVSSVersion, cve.CVSSVector,
+			severity, cve.PublishedAt, cve.LastModifiedAt)
+		if err != nil {
+			return fmt.Errorf("failed to store %s: %v", cve.ID, err)
+		}
+		if _, err := deleteMatches.Exec(cve.ID); err != nil {
+			return fmt.Errorf("failed to replace matches of %s: %v", cve.ID, err)
+		}
+
+		for _, match := range cve.Matches {
+			_, err := insertMatch.Exec(cve.ID, match.CPE.Part, match.CPE.Vendor, match.CPE.Product, match.CPE.Version,
+				match.CPE.Update, match.VersionStartIncluding, match.VersionStartExcluding,
+				match.VersionEndIncluding, match.VersionEndExcluding)
+			if err != nil {
+				return fmt.Errorf("failed to store match of %s: %v", cve.ID, err)
+			}
+		}
+
+		stats.CVEs++
+		stats.Matches += len(cve.Matches)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return &stats, nil
+}
+
+const vulnerabilityColumns = `c.id, COALESCE(c.description, ''), c.cvss_score, COALESCE(c.cvss_version, ''),
+	COALESCE(c.cvss_vector, ''), COALESCE(c.severity, ''), c.published_at`
+
+// scanVulnerability scans a cves row selected with vulnerabilityColumns followed by the matched CPE
+func scanVulnerability(row interface{ Scan(...any) error }) (*Vulnerability, error) {
+	var v Vulnerability
+	err := row.Scan(&v.ID, &v.Description, &v.CVSSScore, &v.CVSSVersion, &v.CVSSVector, &v.Severity, &v.PublishedAt, &v.CPE)
+	if err != nil {
+		return nil, err
+	}
+	if v.Severity == "" {
+		v.Severity = "unknown"
+	}
+	return &v, nil
+}
+
+// Match finds the known vulnerabilities of a product identified by a CPE name
+func (d *Database) Match(name string) ([]*Vulnerability, error) {
+	product, err := ParseCPE(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT m.version, m.version_update, m.version_start_including, m.version_start_excluding,
+		       m.version_end_including, m.version_end_excluding, `+vulnerabilityColumns+`
+		FROM cve_cpe_matches m
+		JOIN cves c ON c.id = m.cve_id
+		WHERE m.part = $1 AND m.vendor = $2 AND m.product = $3
+	`, product.Part, product.Vendor, product.Product)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vulnerabilities: %v", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var vulnerabilities []*Vulnerability
+	for rows.Next() {
+		match := CPEMatch{CPE: product}
+		var v Vulnerability
+		err := rows.Scan(&match.CPE.Version, &match.CPE.Update, &match.VersionStartIncluding, &match.VersionStartExcluding,
+			&match.VersionEndIncluding, &match.VersionEndExcluding,
+			&v.ID, &v.Description, &v.CVSSScore, &v.CVSSVersion, &v.CVSSVector, &v.Severity, &v.PublishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		if seen[v.ID] || !match.Matches(product) {
+			continue
+		}
+		seen[v.ID] = true
+		v.CPE = name
+		if v.Severity == "" {
+			v.Severity = "unknown"
+		}
+		vulnerabilities = append(vulnerabilities, &v)
+	}
+
+	return vulnerabilities, rows.Err()
+}
+
+// MatchScan matches the products detected by a scan against the local CVE
+// tables and stores the matches, returning how many were found
+func (d *Database) MatchScan(scanID int) (int, error) {
+	rows, err := d.db.Query(`SELECT id, cpes FROM scan_results WHERE scan_id = $1 AND COALESCE(cpes, '') <> ''`, scanID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get scan results: %v", err)
+	}
+
+	productsByResult := make(map[int][]string)
+	for rows.Next() {
+		var resultID int
+		var cpes string
+		if err := rows.Scan(&resultID, &cpes); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row: %v", err)
+		}
+		productsByResult[resultID] = strings.Fields(cpes)
+	}
+	rows.Close()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM scan_result_vulnerabilities
+		WHERE scan_result_id IN (SELECT id FROM scan_results WHERE scan_id = $1)
+	`, scanID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear previous matches: %v", err)
+	}
+
+	matched := 0
+	for resultID, products := range productsByResult {
+		for _, product := range products {
+			// Unparseable CPEs from the engine are skipped rather than failing the scan
+			if _, err := ParseCPE(product); err != nil {
+				continue
+			}
+
+			vulnerabilities, err := d.Match(product)
+			if err != nil {
+				return 0, err
+			}
+
+			for _, v := range vulnerabilities {
+				result, err := tx.Exec(`
+					INSERT INTO scan_result_vulnerabilities (scan_result_id, cve_id, cpe)
+					VALUES ($1, $2, $3)
+					ON CONFLICT DO NOTHING
+				`, resultID, v.ID, product)
+				if err != nil {
+					return 0, fmt.Errorf("failed to store match: %v", err)
+				}
+				if n, _ := result.RowsAffected(); n > 0 {
+					matched++
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return matched, nil
+}
+
+// RematchAll re-runs matching for every completed scan with detected products,
+// typically after importing a newer feed
+func (d *Database) RematchAll() (int, error) {
+	rows, err := d.db.Query(`
+		SELECT DISTINCT s.id FROM scans s
+		JOIN scan_results sr ON sr.scan_id = s.id
+		WHERE s.status = 'completed' AND COALESCE(sr.cpes, '') <> ''
+		ORDER BY s.id
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get scans: %v", err)
+	}
+
+	var scanIDs []int
+	for rows.Next() {
+		var scanID int
+		if err := rows.Scan(&scanID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row: %v", err)
+		}
+		scanIDs = append(scanIDs, scanID)
+	}
+	rows.Close()
+
+	total := 0
+	for _, scanID := range scanIDs {
+		matched, err := d.MatchScan(scanID)
+		if err != nil {
+			return total, fmt.Errorf("failed to match scan %d: %v", scanID, err)
+		}
+		total += matched
+	}
+
+	return total, nil
+}
+
+// GetResultVulnerabilities retrieves the vulnerabilities matched to a scan result
+func (d *Database) GetResultVulnerabilities(resultID int) ([]*Vulnerability, error) {
+	query := `
+		SELECT ` + vulnerabilityColumns + `, v.cpe
+		FROM scan_result_vulnerabilities v
+		JOIN cves c ON c.id = v.cve_id
+		WHERE v.scan_result_id = $1
+	`
+	return d.queryVulnerabilities(query, resultID)
+}
+
+// GetAssetVulnerabilities retrieves the distinct vulnerabilities matched by an
+// asset's most recent completed scan
+func (d *Database) GetAssetVulnerabilities(assetID int) ([]*Vulnerability, error) {
+	query := `
+		SELECT ` + vulnerabilityColumns + `, MIN(v.cpe)
+		FROM scan_result_vulnerabilities v
+		JOIN cves c ON c.id = v.cve_id
+		JOIN scan_results sr ON sr.id = v.scan_result_id
+		WHERE sr.scan_id = (
+			SELECT id FROM scans WHERE asset_id = $1 AND status = 'completed' ORDER BY id DESC LIMIT 1
+		)
+		GROUP BY c.id
+	`
+	return d.queryVulnerabilities(query, assetID)
+}
+
+// queryVulnerabilities runs a vulnerability query and orders the rows by
+// descending CVSS score, unscored CVEs last
+func (d *Database) queryVulnerabilities(query string, args ...any) ([]*Vulnerability, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vulnerabilities: %v", err)
+	}
+	defer rows.Close()
+
+	var vulnerabilities []*Vulnerability
+	for rows.Next() {
+		v, err := scanVulnerability(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		vulnerabilities = append(vulnerabilities, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get vulnerabilities: %v", err)
+	}
+
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		a, b := vulnerabilities[i].CVSSScore, vulnerabilities[j].CVSSScore
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case *a != *b:
+			return *a > *b
+		}
+		return vulnerabilities[i].ID < vulnerabilities[j].ID
+	})
+
+	return vulnerabilities, nil
+}
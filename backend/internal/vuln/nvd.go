@@ -0,0 +1,395 @@
+package vuln
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CVE is a vulnerability record read from an NVD feed
+type CVE struct {
+	ID             string
+	Description    string
+	CVSSScore      *float64
+	CVSSVersion    string
+	CVSSVector     string
+	Severity       string
+	PublishedAt    *time.Time
+	LastModifiedAt *time.Time
+	Matches        []CPEMatch
+}
+
+// CPEMatch is a vulnerable product from a CVE's configurations, optionally
+// limited to a range of versions
+type CPEMatch struct {
+	CPE                   CPE
+	VersionStartIncluding string
+	VersionStartExcluding string
+	VersionEndIncluding   string
+	VersionEndExcluding   string
+}
+
+// Matches reports whether a discovered product falls within this match. The
+// product must have a concrete version; wildcard versions never match.
+func (m CPEMatch) Matches(product CPE) bool {
+	if m.CPE.Part != product.Part || m.CPE.Vendor != product.Vendor || m.CPE.Product != product.Product {
+		return false
+	}
+
+	version := product.FullVersion()
+	if isWildcard(version) {
+		return false
+	}
+
+	// A concrete version in the criteria must match exactly
+	if !isWildcard(m.CPE.Version) {
+		if !isWildcard(m.CPE.Update) {
+			return CompareVersions(m.CPE.FullVersion(), version) == 0
+		}
+		// Any update of the version matches, including products that fold the
+		// update into the version string ("8.2p1" for 8.2 update p1)
+		return CompareVersions(m.CPE.Version, version) == 0 || hasUpdateSuffix(version, m.CPE.Version)
+	}
+
+	if m.VersionStartIncluding != "" && CompareVersions(version, m.VersionStartIncluding) < 0 {
+		return false
+	}
+	if m.VersionStartExcluding != "" && CompareVersions(version, m.VersionStartExcluding) <= 0 {
+		return false
+	}
+	if m.VersionEndIncluding != "" && CompareVersions(version, m.VersionEndIncluding) > 0 {
+		return false
+	}
+	if m.VersionEndExcluding != "" && CompareVersions(version, m.VersionEndExcluding) >= 0 {
+		return false
+	}
+
+	// Criteria without a version or range apply to every version of the product
+	return true
+}
+
+// hasUpdateSuffix reports whether version is base followed by an update such as "p1" or "rc2"
+func hasUpdateSuffix(version, base string) bool {
+	if !strings.HasPrefix(strings.ToLower(version), strings.ToLower(base)) || len(version) == len(base) {
+		return false
+	}
+	next := version[len(base)]
+	return (next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z')
+}
+
+// SeverityForScore maps a CVSS v3 base score to its qualitative severity
+func SeverityForScore(score float64) string {
+	switch {
+	case score >= 9.0:
+		return "critical"
+	case score >= 7.0:
+		return "high"
+	case score >= 4.0:
+		return "medium"
+	case score > 0:
+		return "low"
+	}
+	return "none"
+}
+
+// ParseFeed streams the CVEs of an NVD JSON feed to fn. Both the current 2.0
+// feeds (nvdcve-2.0-*.json) and the legacy 1.1 feeds (nvdcve-1.1-*.json) are
+// supported, plain or gzip-compressed.
+//
+// Configurations are flattened: every vulnerable CPE criterion is recorded as a
+// match even when NVD only lists it as vulnerable in combination with another
+// platform, so matching errs on the side of reporting.
+func ParseFeed(r io.Reader, fn func(*CVE) error) error {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip feed: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read feed: %v", err)
+		}
+
+		switch token {
+		case "vulnerabilities":
+			err = decodeArray(dec, func() error {
+				var item nvd2Item
+				if err := dec.Decode(&item); err != nil {
+					return err
+				}
+				return fn(item.CVE.toCVE())
+			})
+		case "CVE_Items":
+			err = decodeArray(dec, func() error {
+				var item nvd1Item
+				if err := dec.Decode(&item); err != nil {
+					return err
+				}
+				return fn(item.toCVE())
+			})
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read feed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// expectDelim reads the next token and checks it is the given delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read feed: %v", err)
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected token %v in feed, expected %v", token, delim)
+	}
+	return nil
+}
+
+// decodeArray calls decode once for each element of the JSON array at the decoder's position
+func decodeArray(dec *json.Decoder, decode func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := decode(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// parseFeedTime parses the timestamp formats used by the NVD feeds
+func parseFeedTime(value string) *time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000", "2006-01-02T15:04:05", "2006-01-02T15:04Z"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// nvdDescription is a translated CVE description
+type nvdDescription struct {
+	Lang  string `json:"lang"`
+	Value string `json:"value"`
+}
+
+// englishDescription picks the English description from a list of translations
+func englishDescription(descriptions []nvdDescription) string {
+	for _, description := range descriptions {
+		if description.Lang == "en" {
+			return description.Value
+		}
+	}
+	if len(descriptions) > 0 {
+		return descriptions[0].Value
+	}
+	return ""
+}
+
+// nvdMatch is a CPE criterion in either feed format
+type nvdMatch struct {
+	Vulnerable            bool   `json:"vulnerable"`
+	Criteria              string `json:"criteria"`
+	CPE23URI              string `json:"cpe23Uri"`
+	VersionStartIncluding string `json:"versionStartIncluding"`
+	VersionStartExcluding string `json:"versionStartExcluding"`
+	VersionEndIncluding   string `json:"versionEndIncluding"`
+	VersionEndExcluding   string `json:"versionEndExcluding"`
+}
+
+// toCPEMatch converts a vulnerable criterion, returning false for anything unusable
+func (m nvdMatch) toCPEMatch() (CPEMatch, bool) {
+	name := m.Criteria
+	if name == "" {
+		name = m.CPE23URI
+	}
+	if !m.Vulnerable || name == "" {
+		return CPEMatch{}, false
+	}
+
+	cpe, err := ParseCPE(name)
+	if err != nil {
+		return CPEMatch{}, false
+	}
+
+	return CPEMatch{
+		CPE:                   cpe,
+		VersionStartIncluding: m.VersionStartIncluding,
+		VersionStartExcluding: m.VersionStartExcluding,
+		VersionEndIncluding:   m.VersionEndIncluding,
+		VersionEndExcluding:   m.VersionEndExcluding,
+	}, true
+}
+
+// nvdNode is a configuration node in either feed format
+type nvdNode struct {
+	CPEMatch  []nvdMatch `json:"cpeMatch"`
+	CPEMatch1 []nvdMatch `json:"cpe_match"`
+	Children  []nvdNode  `json:"children"`
+}
+
+// collectMatches flattens the vulnerable criteria of a configuration tree
+func collectMatches(nodes []nvdNode) []CPEMatch {
+	var matches []CPEMatch
+	for _, node := range nodes {
+		for _, m := range append(node.CPEMatch, node.CPEMatch1...) {
+			if match, ok := m.toCPEMatch(); ok {
+				matches = append(matches, match)
+			}
+		}
+		matches = append(matches, collectMatches(node.Children)...)
+	}
+	return matches
+}
+
+// nvdCVSSData holds the score fields shared by every CVSS version
+type nvdCVSSData struct {
+	Version      string  `json:"version"`
+	BaseScore    float64 `json:"baseScore"`
+	BaseSeverity string  `json:"baseSeverity"`
+	VectorString string  `json:"vectorString"`
+}
+
+// nvd2Item is an element of the 2.0 feed's vulnerabilities array
+type nvd2Item struct {
+	CVE nvd2CVE `json:"cve"`
+}
+
+type nvd2CVE struct {
+	ID           string           `json:"id"`
+	Published    string           `json:"published"`
+	LastModified string           `json:"lastModified"`
+	Descriptions []nvdDescription `json:"descriptions"`
+	Metrics      struct {
+		V31 []nvd2Metric `json:"cvssMetricV31"`
+		V30 []nvd2Metric `json:"cvssMetricV30"`
+		V2  []nvd2Metric `json:"cvssMetricV2"`
+	} `json:"metrics"`
+	Configurations []struct {
+		Nodes []nvdNode `json:"nodes"`
+	} `json:"configurations"`
+}
+
+type nvd2Metric struct {
+	Type         string      `json:"type"`
+	CVSSData     nvdCVSSData `json:"cvssData"`
+	BaseSeverity string      `json:"baseSeverity"`
+}
+
+// toCVE converts a 2.0 feed entry, preferring the primary CVSS v3.1 score
+func (c nvd2CVE) toCVE() *CVE {
+	cve := &CVE{
+		ID:             c.ID,
+		Description:    englishDescription(c.Descriptions),
+		PublishedAt:    parseFeedTime(c.Published),
+		LastModifiedAt: parseFeedTime(c.LastModified),
+	}
+
+	for _, metrics := range [][]nvd2Metric{c.Metrics.V31, c.Metrics.V30, c.Metrics.V2} {
+		if len(metrics) == 0 {
+			continue
+		}
+		metric := metrics[0]
+		for _, m := range metrics {
+			if m.Type == "Primary" {
+				metric = m
+				break
+			}
+		}
+
+		score := metric.CVSSData.BaseScore
+		severity := metric.CVSSData.BaseSeverity
+		if severity == "" {
+			severity = metric.BaseSeverity
+		}
+		cve.CVSSScore = &score
+		cve.CVSSVersion = metric.CVSSData.Version
+		cve.CVSSVector = metric.CVSSData.VectorString
+		cve.Severity = strings.ToLower(severity)
+		break
+	}
+
+	for _, config := range c.Configurations {
+		cve.Matches = append(cve.Matches, collectMatches(config.Nodes)...)
+	}
+
+	return cve
+}
+
+// nvd1Item is an element of the legacy 1.1 feed's CVE_Items array
+type nvd1Item struct {
+	CVE struct {
+		Meta struct {
+			ID string `json:"ID"`
+		} `json:"CVE_data_meta"`
+		Description struct {
+			Data []nvdDescription `json:"description_data"`
+		} `json:"description"`
+	} `json:"cve"`
+	Configurations struct {
+		Nodes []nvdNode `json:"nodes"`
+	} `json:"configurations"`
+	Impact struct {
+		V3 *struct {
+			CVSS nvdCVSSData `json:"cvssV3"`
+		} `json:"baseMetricV3"`
+		V2 *struct {
+			CVSS     nvdCVSSData `json:"cvssV2"`
+			Severity string      `json:"severity"`
+		} `json:"baseMetricV2"`
+	} `json:"impact"`
+	PublishedDate    string `json:"publishedDate"`
+	LastModifiedDate string `json:"lastModifiedDate"`
+}
+
+// toCVE converts a 1.1 feed entry, preferring the CVSS v3 score
+func (item nvd1Item) toCVE() *CVE {
+	cve := &CVE{
+		ID:             item.CVE.Meta.ID,
+		Description:    englishDescription(item.CVE.Description.Data),
+		PublishedAt:    parseFeedTime(item.PublishedDate),
+		LastModifiedAt: parseFeedTime(item.LastModifiedDate),
+		Matches:        collectMatches(item.Configurations.Nodes),
+	}
+
+	switch {
+	case item.Impact.V3 != nil:
+		score := item.Impact.V3.CVSS.BaseScore
+		cve.CVSSScore = &score
+		cve.CVSSVersion = item.Impact.V3.CVSS.Version
+		cve.CVSSVector = item.Impact.V3.CVSS.VectorString
+		cve.Severity = strings.ToLower(item.Impact.V3.CVSS.BaseSeverity)
+	case item.Impact.V2 != nil:
+		score := item.Impact.V2.CVSS.BaseScore
+		cve.CVSSScore = &score
+		cve.CVSSVersion = item.Impact.V2.CVSS.Version
+		cve.CVSSVector = item.Impact.V2.CVSS.VectorString
+		cve.Severity = strings.ToLower(item.Impact.V2.Severity)
+	}
+
+	return cve
+}
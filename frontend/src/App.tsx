@@ -1,6 +1,6 @@
 import { BrowserRouter as Router, Routes, Route, Navigate } from 'react-router-dom';
 import { Toaster } from 'sonner';
-import { AuthProvider } from './context/AuthContext';
+import { AuthProvider, useAuth } from './context/AuthContext';
 import { ErrorBoundary } from './components/ErrorBoundary';
 import { NetworkStatus } from './components/NetworkStatus';
 import { ProtectedRoute } from './components/ProtectedRoute';
@@ -8,6 +8,12 @@ import { LoginPage } from './pages/LoginPage';
 import { RegisterPage } from './pages/RegisterPage';
 import { DashboardPage } from './pages/DashboardPage';
 
+// Remounts the dashboard when the user switches organization so its data is reloaded
+function OrganizationDashboard() {
+  const { organization } = useAuth();
+  return <DashboardPage key={organization?.id} />;
+}
+
 function App() {
   return (
     <ErrorBoundary>
@@ -22,7 +28,7 @@ function App() {
                 path="/dashboard"
                 element={
                   <ProtectedRoute>
-                    <DashboardPage />
+                    <OrganizationDashboard />
                   </ProtectedRoute>
                 }
               />
@@ -11,6 +11,10 @@ interface AssetListProps {
   onDeleteAsset: (id: string) => Promise<void>;
   onStartScan: (assetId: string) => Promise<void>;
   isLoading?: boolean;
+  // Whether the user's role allows adding, scanning and deleting assets
+  canManage?: boolean;
+  // Whether the user's role allows exporting scan results
+  canExport?: boolean;
 }
 
 export const AssetList: React.FC<AssetListProps> = ({
@@ -19,6 +23,8 @@ export const AssetList: React.FC<AssetListProps> = ({
   onDeleteAsset,
   onStartScan,
   isLoading = false,
+  canManage = true,
+  canExport = true,
 }) => {
   const [deletingId, setDeletingId] = useState<string>('');
   const [scanningId, setScanningId] = useState<string>('');
@@ -50,12 +56,18 @@ export const AssetList: React.FC<AssetListProps> = ({
       return { status: 'never', icon: Clock, color: 'text-gray-500' };
     }
 
-    const lastScan = asset.scans[asset.scans.length - 1];
+    // Scans are listed newest first
+    const lastScan = asset.scans[0];
     switch (lastScan.status) {
       case 'completed':
         return { status: 'completed', icon: CheckCircle, color: 'text-green-600' };
       case 'running':
-        return { status: 'running', icon: Play, color: 'text-blue-600' };
+        return {
+          status: lastScan.progress != null ? `running ${Math.floor(lastScan.progress)}%` : 'running',
+          icon: Play,
+          color: 'text-blue-600',
+          eta: lastScan.estimatedCompletion,
+        };
       case 'failed':
         return { status: 'failed', icon: AlertCircle, color: 'text-red-600' };
       default:
@@ -90,19 +102,23 @@ export const AssetList: React.FC<AssetListProps> = ({
       <CardHeader>
         <div className="flex items-center justify-between">
           <CardTitle>Assets</CardTitle>
-          <Button onClick={onAddAsset} className="flex items-center space-x-2">
-            <Plus className="h-4 w-4" />
-            <span>Add Asset</span>
-          </Button>
+          {canManage && (
+            <Button onClick={onAddAsset} className="flex items-center space-x-2">
+              <Plus className="h-4 w-4" />
+              <span>Add Asset</span>
+            </Button>
+          )}
         </div>
       </CardHeader>
       <CardContent>
         {assets.length === 0 ? (
           <div className="text-center py-8">
             <p className="text-gray-500 mb-4">No assets found</p>
-            <Button onClick={onAddAsset} variant="outline">
-              Add your first asset
-            </Button>
+            {canManage && (
+              <Button onClick={onAddAsset} variant="outline">
+                Add your first asset
+              </Button>
+            )}
           </div>
         ) : (
           <div className="overflow-x-auto">
@@ -139,35 +155,46 @@ export const AssetList: React.FC<AssetListProps> = ({
                       <td className="py-3 px-4">
                         <div className={`flex items-center space-x-1 ${scanStatus.color}`}>
                           <StatusIcon className="h-4 w-4" />
-                          <span className="text-sm capitalize">{scanStatus.status}</span>
+                          <span
+                            className="text-sm capitalize"
+                            title={'eta' in scanStatus && scanStatus.eta ? `Expected to finish ${formatDate(scanStatus.eta)}` : undefined}
+                          >
+                            {scanStatus.status}
+                          </span>
                         </div>
                       </td>
                       <td className="py-3 px-4">
                         <div className="flex items-center justify-end space-x-2">
-                          <ExportButton 
-                            assetId={asset.id} 
-                            assetName={asset.name}
-                          />
-                          <Button
-                            size="sm"
-                            onClick={() => handleStartScan(asset.id)}
-                            isLoading={scanningId === asset.id}
-                            disabled={scanningId === asset.id || deletingId === asset.id}
-                            className="flex items-center space-x-1"
-                          >
-                            <Play className="h-3 w-3" />
-                            <span>Scan</span>
-                          </Button>
-                          <Button
-                            size="sm"
-                            variant="destructive"
-                            onClick={() => handleDelete(asset.id)}
-                            isLoading={deletingId === asset.id}
-                            disabled={deletingId === asset.id || scanningId === asset.id}
-                            className="flex items-center space-x-1"
-                          >
-                            <Trash2 className="h-3 w-3" />
-                          </Button>
+                          {canExport && (
+                            <ExportButton
+                              assetId={asset.id}
+                              assetName={asset.name}
+                            />
+                          )}
+                          {canManage && (
+                            <>
+                              <Button
+                                size="sm"
+                                onClick={() => handleStartScan(asset.id)}
+                                isLoading={scanningId === asset.id}
+                                disabled={scanningId === asset.id || deletingId === asset.id}
+                                className="flex items-center space-x-1"
+                              >
+                                <Play className="h-3 w-3" />
+                                <span>Scan</span>
+                              </Button>
+                              <Button
+                                size="sm"
+                                variant="destructive"
+                                onClick={() => handleDelete(asset.id)}
+                                isLoading={deletingId === asset.id}
+                                disabled={deletingId === asset.id || scanningId === asset.id}
+                                className="flex items-center space-x-1"
+                              >
+                                <Trash2 className="h-3 w-3" />
+                              </Button>
+                            </>
+                          )}
                         </div>
                       </td>
                     </tr>
@@ -75,7 +75,7 @@ export const ScanResults: React.FC<ScanResultsProps> = ({
 
   const openPorts = results.filter(r => r.state === 'open');
   const closedPorts = results.filter(r => r.state === 'closed');
-  const filteredPorts = results.filter(r => r.state === 'filtered');
+  const filteredPorts = results.filter(r => r.state === 'filtered' || r.state === 'open|filtered');
 
   return (
     <Card>
@@ -1,10 +1,30 @@
-import React from 'react';
+import React, { useEffect, useState } from 'react';
 import { Shield, LogOut, User } from 'lucide-react';
+import { toast } from 'sonner';
 import { useAuth } from '../../context/AuthContext';
+import { graphqlRequest } from '../../services/api';
+import { ORGANIZATIONS_QUERY } from '../../services/graphql';
+import { Organization } from '../../types';
 import { Button } from '../ui/Button';
 
 export const Header: React.FC = () => {
-  const { user, logout } = useAuth();
+  const { user, organization, logout, switchOrganization } = useAuth();
+  const [organizations, setOrganizations] = useState<Organization[]>([]);
+
+  useEffect(() => {
+    if (!user) return;
+    graphqlRequest<{ organizations: Organization[] }>(ORGANIZATIONS_QUERY)
+      .then((response) => setOrganizations(response.organizations))
+      .catch(() => setOrganizations([]));
+  }, [user?.id, organization?.id]);
+
+  const handleSwitch = async (organizationId: string) => {
+    try {
+      await switchOrganization(organizationId);
+    } catch (error: any) {
+      toast.error(error.message);
+    }
+  };
 
   return (
     <header className="bg-white shadow-sm border-b">
@@ -18,6 +38,23 @@ export const Header: React.FC = () => {
           </div>
           
           <div className="flex items-center space-x-4">
+            {organizations.length > 1 ? (
+              <select
+                value={organization?.id ?? ''}
+                onChange={(e) => handleSwitch(e.target.value)}
+                className="flex h-9 rounded-md border border-input bg-background px-3 py-1 text-sm ring-offset-background focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring focus-visible:ring-offset-2"
+              >
+                {organizations.map((org) => (
+                  <option key={org.id} value={org.id}>
+                    {org.name}
+                  </option>
+                ))}
+              </select>
+            ) : (
+              organization && (
+                <span className="text-sm text-gray-600">{organization.name}</span>
+              )
+            )}
             <div className="flex items-center space-x-2 text-sm text-gray-600">
               <User className="h-4 w-4" />
               <span>{user?.email}</span>
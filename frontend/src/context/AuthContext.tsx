@@ -1,17 +1,20 @@
 import React, { createContext, useContext, useEffect, useState, ReactNode } from 'react';
-import { User, AuthPayload, LoginInput, RegisterInput } from '../types';
+import { User, Organization, AuthPayload, LoginInput, RegisterInput } from '../types';
 import { graphqlRequest } from '../services/api';
-import { LOGIN_MUTATION, REGISTER_MUTATION, ME_QUERY } from '../services/graphql';
+import { LOGIN_MUTATION, REGISTER_MUTATION, ME_QUERY, SWITCH_ORGANIZATION_MUTATION } from '../services/graphql';
 import { tokenManager } from '../services/api';
 
 interface AuthContextType {
   user: User | null;
+  // The organization the user is working in
+  organization: Organization | null;
   isAuthenticated: boolean;
   isLoading: boolean;
   login: (credentials: LoginInput) => Promise<void>;
   register: (userData: RegisterInput) => Promise<void>;
   logout: () => void;
   refreshUser: () => Promise<void>;
+  switchOrganization: (organizationId: string) => Promise<void>;
 }
 
 const AuthContext = createContext<AuthContextType | undefined>(undefined);
@@ -22,6 +25,7 @@ interface AuthProviderProps {
 
 export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
   const [user, setUser] = useState<User | null>(null);
+  const [organization, setOrganization] = useState<Organization | null>(null);
   const [isLoading, setIsLoading] = useState(true);
 
   const isAuthenticated = !!user && tokenManager.isTokenValid();
@@ -55,10 +59,11 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
         { input: credentials }
       );
 
-      const { token, user: userData } = response.login;
+      const { token, user: userData, organization: org } = response.login;
       
       tokenManager.setToken(token);
       setUser(userData);
+      setOrganization(org);
     } catch (error) {
       throw error;
     } finally {
@@ -75,10 +80,11 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
         { input: userData }
       );
 
-      const { token, user: newUser } = response.register;
+      const { token, user: newUser, organization: org } = response.register;
       
       tokenManager.setToken(token);
       setUser(newUser);
+      setOrganization(org);
     } catch (error) {
       throw error;
     } finally {
@@ -89,25 +95,43 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
   const logout = (): void => {
     tokenManager.removeToken();
     setUser(null);
+    setOrganization(null);
   };
 
   const refreshUser = async (): Promise<void> => {
     try {
-      const response = await graphqlRequest<{ me: User }>(ME_QUERY);
+      const response = await graphqlRequest<{ me: User; organization: Organization | null }>(ME_QUERY);
       setUser(response.me);
+      setOrganization(response.organization);
     } catch (error) {
       throw error;
     }
   };
 
+  // Switching issues a new token scoped to the organization
+  const switchOrganization = async (organizationId: string): Promise<void> => {
+    const response = await graphqlRequest<{ switchOrganization: AuthPayload }>(
+      SWITCH_ORGANIZATION_MUTATION,
+      { organizationId }
+    );
+
+    const { token, user: userData, organization: org } = response.switchOrganization;
+
+    tokenManager.setToken(token);
+    setUser(userData);
+    setOrganization(org);
+  };
+
   const value: AuthContextType = {
     user,
+    organization,
     isAuthenticated,
     isLoading,
     login,
     register,
     logout,
     refreshUser,
+    switchOrganization,
   };
 
   return (
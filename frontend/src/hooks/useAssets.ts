@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
-import { Asset, CreateAssetInput } from '../types';
-import { graphqlRequest } from '../services/api';
+import { Asset, AssetNode, Connection, CreateAssetInput } from '../types';
+import { graphqlRequest, connectionNodes, toAsset } from '../services/api';
 import { ASSETS_QUERY, CREATE_ASSET_MUTATION, DELETE_ASSET_MUTATION } from '../services/graphql';
 
 export const useAssets = () => {
@@ -12,8 +12,8 @@ export const useAssets = () => {
     try {
       setIsLoading(true);
       setError('');
-      const response = await graphqlRequest<{ assets: Asset[] }>(ASSETS_QUERY);
-      setAssets(response.assets);
+      const response = await graphqlRequest<{ assets: Connection<AssetNode> }>(ASSETS_QUERY);
+      setAssets(connectionNodes(response.assets).map(toAsset));
     } catch (err: any) {
       setError(err.message || 'Failed to fetch assets');
     } finally {
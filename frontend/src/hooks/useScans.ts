@@ -1,7 +1,16 @@
 import { useState, useEffect } from 'react';
-import { Scan } from '../types';
-import { graphqlRequest } from '../services/api';
-import { START_SCAN_MUTATION, SCAN_QUERY, SCANS_QUERY } from '../services/graphql';
+import { Connection, Scan } from '../types';
+import { graphqlRequest, graphqlSubscribe, connectionNodes } from '../services/api';
+import {
+  START_SCAN_MUTATION,
+  SCAN_QUERY,
+  SCANS_QUERY,
+  SCAN_UPDATED_SUBSCRIPTION,
+  ASSET_SCANS_UPDATED_SUBSCRIPTION,
+} from '../services/graphql';
+
+const isFinished = (scan: Scan) =>
+  scan.status === 'completed' || scan.status === 'failed' || scan.status === 'cancelled';
 
 export const useScans = (assetId?: string) => {
   const [scans, setScans] = useState<Scan[]>([]);
@@ -12,11 +21,11 @@ export const useScans = (assetId?: string) => {
     try {
       setIsLoading(true);
       setError('');
-      const response = await graphqlRequest<{ scans: Scan[] }>(
+      const response = await graphqlRequest<{ scans: Connection<Scan> }>(
         SCANS_QUERY,
         assetId ? { assetId } : {}
       );
-      setScans(response.scans);
+      setScans(connectionNodes(response.scans));
     } catch (err: any) {
       setError(err.message || 'Failed to fetch scans');
     } finally {
@@ -24,6 +33,16 @@ export const useScans = (assetId?: string) => {
     }
   };
 
+  // Add a new scan to the top of the list, or update it if it is already listed
+  const upsertScan = (updated: Scan) => {
+    setScans(prev => {
+      if (!prev.some(scan => scan.id === updated.id)) {
+        return [updated, ...prev];
+      }
+      return prev.map(scan => (scan.id === updated.id ? { ...scan, ...updated } : scan));
+    });
+  };
+
   const startScan = async (targetAssetId: string): Promise<Scan> => {
     try {
       const response = await graphqlRequest<{ startScan: Scan }>(
@@ -31,7 +50,7 @@ export const useScans = (assetId?: string) => {
         { assetId: targetAssetId }
       );
       const newScan = response.startScan;
-      setScans(prev => [newScan, ...prev]);
+      upsertScan(newScan);
       return newScan;
     } catch (err: any) {
       throw new Error(err.message || 'Failed to start scan');
@@ -50,29 +69,33 @@ export const useScans = (assetId?: string) => {
     }
   };
 
-  const pollScanStatus = (scanId: string, onUpdate: (scan: Scan) => void) => {
-    const interval = setInterval(async () => {
-      try {
-        const scan = await getScanById(scanId);
-        onUpdate(scan);
-        
-        // Stop polling if scan is completed or failed
-        if (scan.status === 'completed' || scan.status === 'failed') {
-          clearInterval(interval);
-          // Refresh the scans list
-          fetchScans();
+  // Follow one scan as it runs, stopping once it finishes
+  const watchScan = (scanId: string, onUpdate: (scan: Scan) => void) => {
+    const unsubscribe = graphqlSubscribe<{ scanUpdated: Scan }>(
+      SCAN_UPDATED_SUBSCRIPTION,
+      { scanId },
+      ({ scanUpdated }) => {
+        onUpdate(scanUpdated);
+        if (isFinished(scanUpdated)) {
+          unsubscribe();
         }
-      } catch (error) {
-        console.error('Error polling scan status:', error);
-        clearInterval(interval);
-      }
-    }, 2000); // Poll every 2 seconds
+      },
+      (error) => console.error('Error watching scan:', error)
+    );
 
-    return () => clearInterval(interval);
+    return unsubscribe;
   };
 
   useEffect(() => {
     fetchScans();
+
+    // Keep the list current as scans are queued, run and finish
+    return graphqlSubscribe<{ assetScansUpdated: Scan }>(
+      ASSET_SCANS_UPDATED_SUBSCRIPTION,
+      assetId ? { assetId } : {},
+      ({ assetScansUpdated }) => upsertScan(assetScansUpdated),
+      (error) => console.error('Error subscribing to scan updates:', error)
+    );
   }, [assetId]);
 
   return {
@@ -82,6 +105,6 @@ export const useScans = (assetId?: string) => {
     fetchScans,
     startScan,
     getScanById,
-    pollScanStatus,
+    watchScan,
   };
 };
@@ -9,6 +9,7 @@ import { ScanResults } from '../components/ScanResults';
 import { ExportButton } from '../components/ExportButton';
 import { useAssets } from '../hooks/useAssets';
 import { useScans } from '../hooks/useScans';
+import { useAuth } from '../context/AuthContext';
 import { toast } from 'sonner';
 import { AssetFormData } from '../types';
 
@@ -19,6 +20,11 @@ export const DashboardPage: React.FC = () => {
   
   const { assets, isLoading: assetsLoading, createAsset, deleteAsset } = useAssets();
   const { startScan, scans } = useScans();
+  const { user } = useAuth();
+
+  // Mirrors the @hasRole checks on the API's mutations
+  const canManage = user?.role === 'admin' || user?.role === 'analyst';
+  const canExport = canManage || user?.role === 'auditor';
 
   const handleCreateAsset = async (data: AssetFormData) => {
     try {
@@ -133,6 +139,8 @@ export const DashboardPage: React.FC = () => {
               onDeleteAsset={handleDeleteAsset}
               onStartScan={handleStartScan}
               isLoading={assetsLoading}
+              canManage={canManage}
+              canExport={canExport}
             />
 
             {/* Recent Scans */}
@@ -164,7 +172,7 @@ export const DashboardPage: React.FC = () => {
                             {scan.status.charAt(0).toUpperCase() + scan.status.slice(1)}
                           </p>
                           <p className="text-xs text-gray-500">
-                            {new Date(scan.startedAt).toLocaleDateString()}
+                            {new Date(scan.startedAt ?? scan.queuedAt).toLocaleDateString()}
                           </p>
                         </div>
                       </div>
@@ -1,4 +1,5 @@
 import axios, { AxiosInstance, AxiosRequestConfig, AxiosResponse } from 'axios';
+import { Asset, AssetNode, Connection } from '../types';
 
 // API Configuration
 const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
@@ -97,6 +98,69 @@ export const graphqlRequest = async <T>(
   }
 };
 
+// GraphQL subscription helper speaking the graphql-transport-ws protocol.
+// Browsers can't send headers on websockets, so the token goes in the init payload.
+// Returns a function that ends the subscription.
+export const graphqlSubscribe = <T>(
+  query: string,
+  variables: Record<string, any>,
+  onData: (data: T) => void,
+  onError?: (error: Error) => void
+): (() => void) => {
+  const url = API_BASE_URL.replace(/^http/, 'ws') + '/query';
+  const socket = new WebSocket(url, 'graphql-transport-ws');
+  const id = '1';
+
+  socket.onopen = () => {
+    const token = tokenManager.getToken();
+    socket.send(JSON.stringify({
+      type: 'connection_init',
+      payload: token ? { Authorization: `Bearer ${token}` } : {},
+    }));
+  };
+
+  socket.onmessage = (event) => {
+    const message = JSON.parse(event.data);
+    switch (message.type) {
+      case 'connection_ack':
+        socket.send(JSON.stringify({ id, type: 'subscribe', payload: { query, variables } }));
+        break;
+      case 'ping':
+        socket.send(JSON.stringify({ type: 'pong' }));
+        break;
+      case 'next':
+        if (message.payload.errors) {
+          onError?.(new Error(message.payload.errors[0].message));
+        } else {
+          onData(message.payload.data);
+        }
+        break;
+      case 'error':
+        onError?.(new Error(message.payload?.[0]?.message || 'Subscription failed'));
+        break;
+    }
+  };
+
+  socket.onerror = () => onError?.(new Error('Subscription connection failed'));
+
+  return () => {
+    if (socket.readyState === WebSocket.OPEN) {
+      socket.send(JSON.stringify({ id, type: 'complete' }));
+    }
+    socket.close();
+  };
+};
+
+// Unwrap the nodes of a paginated connection
+export const connectionNodes = <T>(connection?: Connection<T>): T[] =>
+  connection ? connection.edges.map(edge => edge.node) : [];
+
+// Convert an asset from the API, flattening its scans connection
+export const toAsset = (asset: AssetNode): Asset => ({
+  ...asset,
+  scans: connectionNodes(asset.scans),
+});
+
 // Health check endpoint
 export const healthCheck = async (): Promise<{ status: string; service: string }> => {
   const response = await api.get('/health');
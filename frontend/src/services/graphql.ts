@@ -11,6 +11,12 @@ export const REGISTER_MUTATION = `
         role
         createdAt
       }
+      organization {
+        id
+        name
+        role
+        createdAt
+      }
     }
   }
 `;
@@ -25,6 +31,12 @@ export const LOGIN_MUTATION = `
         role
         createdAt
       }
+      organization {
+        id
+        name
+        role
+        createdAt
+      }
     }
   }
 `;
@@ -38,24 +50,72 @@ export const ME_QUERY = `
       role
       createdAt
     }
+    organization {
+      id
+      name
+      role
+      createdAt
+    }
   }
 `;
 
-// Asset Queries
-export const ASSETS_QUERY = `
-  query Assets {
-    assets {
+// Organization Queries and Mutations
+export const ORGANIZATIONS_QUERY = `
+  query Organizations {
+    organizations {
       id
       name
-      target
-      assetType
+      role
       createdAt
-      lastScannedAt
-      scans {
+    }
+  }
+`;
+
+export const SWITCH_ORGANIZATION_MUTATION = `
+  mutation SwitchOrganization($organizationId: ID!) {
+    switchOrganization(organizationId: $organizationId) {
+      token
+      user {
         id
-        status
-        startedAt
-        completedAt
+        email
+        role
+        createdAt
+      }
+      organization {
+        id
+        name
+        role
+        createdAt
+      }
+    }
+  }
+`;
+
+// Asset Queries
+export const ASSETS_QUERY = `
+  query Assets {
+    assets(first: 200) {
+      totalCount
+      edges {
+        node {
+          id
+          name
+          target
+          assetType
+          createdAt
+          lastScannedAt
+          scans(first: 1) {
+            edges {
+              node {
+                id
+                status
+                queuedAt
+                startedAt
+                completedAt
+              }
+            }
+          }
+        }
       }
     }
   }
@@ -70,20 +130,30 @@ export const ASSET_QUERY = `
       assetType
       createdAt
       lastScannedAt
-      scans {
-        id
-        status
-        startedAt
-        completedAt
-        errorMessage
-        results {
-          id
-          port
-          protocol
-          state
-          service
-          version
-          banner
+      scans(first: 50) {
+        totalCount
+        edges {
+          node {
+            id
+            status
+            queuedAt
+            startedAt
+            completedAt
+            durationMs
+            errorCode
+            errorMessage
+            progress
+            estimatedCompletion
+            results {
+              id
+              port
+              protocol
+              state
+              service
+              version
+              banner
+            }
+          }
         }
       }
     }
@@ -113,16 +183,58 @@ export const DELETE_ASSET_MUTATION = `
 // Scan Queries
 export const SCANS_QUERY = `
   query Scans($assetId: ID) {
-    scans(assetId: $assetId) {
+    scans(assetId: $assetId, first: 100) {
+      totalCount
+      edges {
+        node {
+          id
+          status
+          queuedAt
+          startedAt
+          completedAt
+          durationMs
+          errorCode
+          errorMessage
+          progress
+          estimatedCompletion
+          asset {
+            id
+            name
+            target
+          }
+          results {
+            id
+            port
+            protocol
+            state
+            service
+            version
+            banner
+          }
+        }
+      }
+    }
+  }
+`;
+
+export const SCAN_QUERY = `
+  query Scan($id: ID!) {
+    scan(id: $id) {
       id
       status
+      queuedAt
       startedAt
       completedAt
+      durationMs
+      errorCode
       errorMessage
+      progress
+      estimatedCompletion
       asset {
         id
         name
         target
+        assetType
       }
       results {
         id
@@ -137,19 +249,51 @@ export const SCANS_QUERY = `
   }
 `;
 
-export const SCAN_QUERY = `
-  query Scan($id: ID!) {
-    scan(id: $id) {
+// Scan Mutations
+// Scan Subscriptions
+export const SCAN_UPDATED_SUBSCRIPTION = `
+  subscription ScanUpdated($scanId: ID!) {
+    scanUpdated(scanId: $scanId) {
       id
       status
+      queuedAt
       startedAt
       completedAt
+      durationMs
+      errorCode
       errorMessage
+      progress
+      estimatedCompletion
+      results {
+        id
+        port
+        protocol
+        state
+        service
+        version
+        banner
+      }
+    }
+  }
+`;
+
+export const ASSET_SCANS_UPDATED_SUBSCRIPTION = `
+  subscription AssetScansUpdated($assetId: ID) {
+    assetScansUpdated(assetId: $assetId) {
+      id
+      status
+      queuedAt
+      startedAt
+      completedAt
+      durationMs
+      errorCode
+      errorMessage
+      progress
+      estimatedCompletion
       asset {
         id
         name
         target
-        assetType
       }
       results {
         id
@@ -164,12 +308,12 @@ export const SCAN_QUERY = `
   }
 `;
 
-// Scan Mutations
 export const START_SCAN_MUTATION = `
   mutation StartScan($assetId: ID!) {
     startScan(assetId: $assetId) {
       id
       status
+      queuedAt
       startedAt
       asset {
         id
@@ -2,6 +2,14 @@
 export interface User {
   id: string;
   email: string;
+  // Role in the current organization
+  role?: string | null;
+  createdAt: string;
+}
+
+export interface Organization {
+  id: string;
+  name: string;
   role: string;
   createdAt: string;
 }
@@ -9,6 +17,7 @@ export interface User {
 export interface AuthPayload {
   token: string;
   user: User;
+  organization: Organization;
 }
 
 export interface LoginInput {
@@ -43,14 +52,21 @@ export interface Scan {
   id: string;
   asset: Asset;
   status: string;
-  startedAt: string;
+  queuedAt: string;
+  startedAt?: string;
   completedAt?: string;
+  durationMs?: number;
+  errorCode?: string;
   errorMessage?: string;
+  progress?: number;
+  estimatedCompletion?: string;
   results: ScanResult[];
 }
 
 export interface ScanResult {
   id: string;
+  host?: string;
+  hostname?: string;
   port: number;
   protocol: string;
   state: string;
@@ -59,6 +75,29 @@ export interface ScanResult {
   banner?: string;
 }
 
+export interface ScanHost {
+  address: string;
+  hostname?: string;
+  results: ScanResult[];
+}
+
+// Pagination Types
+export interface PageInfo {
+  hasNextPage: boolean;
+  hasPreviousPage: boolean;
+  startCursor?: string;
+  endCursor?: string;
+}
+
+export interface Connection<T> {
+  edges: Array<{ cursor?: string; node: T }>;
+  pageInfo?: PageInfo;
+  totalCount?: number;
+}
+
+// Assets as returned by the API, with their scans as a connection
+export type AssetNode = Omit<Asset, 'scans'> & { scans?: Connection<Scan> };
+
 // API Response Types
 export interface ApiResponse<T> {
   data: T;
@@ -103,12 +142,14 @@ export enum ScanStatus {
   PENDING = 'pending',
   RUNNING = 'running',
   COMPLETED = 'completed',
-  FAILED = 'failed'
+  FAILED = 'failed',
+  CANCELLED = 'cancelled'
 }
 
 // Port State Colors
 export const PORT_STATE_COLORS = {
   open: 'text-green-600 bg-green-50',
+  'open|filtered': 'text-orange-600 bg-orange-50',
   closed: 'text-gray-600 bg-gray-50',
   filtered: 'text-yellow-600 bg-yellow-50',
   unfiltered: 'text-blue-600 bg-blue-50'